@@ -13,14 +13,15 @@ import (
 	"github.com/in4it/ecs-deploy/provider/ecs"
 	"github.com/in4it/ecs-deploy/service"
 	"github.com/in4it/ecs-deploy/session"
-	"github.com/in4it/ecs-deploy/util"
 	"github.com/juju/loggo"
 	sns "github.com/robbiet480/go.sns"
 	swaggerfiles "github.com/swaggo/files"     // swagger embed files
 	ginSwagger "github.com/swaggo/gin-swagger" // gin-swagger middleware
 
+	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -50,7 +51,7 @@ type login struct {
 }
 
 func (a *API) Launch() error {
-	if util.GetEnv("SAML_ENABLED", "") == "yes" {
+	if serverConfig.SAML.Enabled == "yes" {
 		err := a.initSAML()
 		if err != nil {
 			return err
@@ -74,7 +75,7 @@ func (a *API) Launch() error {
 func (a *API) initSAML() error {
 	// initialize samlHelper
 	var err error
-	a.samlHelper, err = newSAML(util.GetEnv("SAML_METADATA_URL", ""), []byte(util.GetEnv("SAML_CERTIFICATE", "")), []byte(util.GetEnv("SAML_PRIVATE_KEY", "")))
+	a.samlHelper, err = newSAML(serverConfig.SAML.MetadataURL, []byte(serverConfig.SAML.Certificate), []byte(serverConfig.SAML.PrivateKey))
 	if err != nil {
 		return err
 	}
@@ -90,14 +91,17 @@ func (a *API) createRoutes() {
 	r.Use(location.Default())
 
 	// cookie sessions
-	r.Use(session.SessionHandler("ecs-deploy", util.GetEnv("JWT_SECRET", "unsecure secret key 8a045eb")))
+	r.Use(session.SessionHandler("ecs-deploy", serverConfig.Auth.JWTSecret))
 
 	// prefix
-	prefix := util.GetEnv("URL_PREFIX", "")
-	apiPrefix := prefix + util.GetEnv("URL_PREFIX_API", "/api/v1")
+	prefix := serverConfig.Server.UrlPrefix
+	apiPrefix := prefix + serverConfig.Server.UrlPrefixApi
 
 	// ip whitelisting
-	r.Use(ipfilter.IPWhiteList(util.GetEnv("ECS_WHITELIST", "0.0.0.0/0")))
+	r.Use(ipfilter.IPWhiteList(serverConfig.Server.Whitelist))
+
+	// request id (returned in the X-Request-ID header)
+	r.Use(requestIDMiddleware())
 
 	// webapp
 	r.Use(ngserve.ServeWithDefault(prefix+"/webapp", ngserve.LocalFile("./webapp/dist", false), "./webapp/dist/index.html"))
@@ -113,7 +117,7 @@ func (a *API) createRoutes() {
 		r.GET(prefix+"/health", a.healthHandler)
 
 		// saml init
-		if util.GetEnv("SAML_ENABLED", "") == "yes" {
+		if serverConfig.SAML.Enabled == "yes" {
 			r.POST(prefix+"/saml/acs", a.samlHelper.samlInitHandler)
 			r.GET(prefix+"/saml/acs", a.samlHelper.samlInitHandler)
 		}
@@ -125,6 +129,9 @@ func (a *API) createRoutes() {
 		// webhook
 		r.POST(prefix+"/webhook", a.webhookHandler)
 
+		// deploy file schema (without auth for editor integration)
+		r.GET(apiPrefix+"/deploy/schema", a.deploySchemaHandler)
+
 		// login handlers
 		r.POST(prefix+"/login", a.authMiddleware.LoginHandler)
 
@@ -136,6 +143,7 @@ func (a *API) createRoutes() {
 
 		// ECR
 		auth.POST("/ecr/create/:repository", a.ecrCreateHandler)
+		auth.POST("/ecr/update/:repository", a.ecrUpdateHandler)
 
 		// Deploy
 		auth.POST("/deploy/:service", a.deployServiceHandler)
@@ -146,14 +154,34 @@ func (a *API) createRoutes() {
 
 		// Export
 		auth.GET("/export/terraform", a.exportTerraformHandler)
+		auth.GET("/export/terraform/:service", a.exportTerraformServiceHandler)
 		auth.GET("/export/terraform/:service/targetgrouparn", a.exportTerraformTargetGroupArnHandler)
 		auth.GET("/export/terraform/:service/listenerrulearn", a.exportTerraformListenerRuleArnsHandler)
 		auth.GET("/export/terraform/:service/listenerrulearn/:rule", a.exportTerraformListenerRuleArnHandler)
+		auth.GET("/export/cloudformation", a.exportCloudFormationHandler)
+		auth.GET("/export/cloudformation/:service", a.exportCloudFormationServiceHandler)
+		auth.GET("/export/cloudformation/cluster/:cluster", a.exportCloudFormationClusterHandler)
+
+		// image scan policy (per cluster)
+		auth.GET("/imagescan/policy/:cluster/get", a.getImageScanPolicyHandler)
+		auth.POST("/imagescan/policy/:cluster/put", a.putImageScanPolicyHandler)
+
+		// capacity and utilisation of a cluster
+		auth.GET("/cluster/capacity/:cluster", a.getClusterCapacityHandler)
+
+		// rolling AMI upgrade of the cluster instances
+		auth.POST("/cluster/upgrade/:cluster/start", a.startClusterUpgradeHandler)
+		auth.GET("/cluster/upgrade/:cluster/get", a.getClusterUpgradeHandler)
+		auth.POST("/cluster/upgrade/:cluster/cancel", a.cancelClusterUpgradeHandler)
+
+		// Import existing services
+		auth.POST("/import/:cluster/:service", a.importServiceHandler)
 
 		// deploy list
 		auth.GET("/deploy/list", a.listDeploysHandler)
 		auth.GET("/deploy/list/:service", a.listDeploysForServiceHandler)
 		auth.GET("/deploy/status/:service/:time", a.getDeploymentStatusHandler)
+		auth.GET("/deploy/status/:service/:time/stream", a.streamDeploymentStatusHandler)
 		auth.GET("/deploy/get/:service/:time", a.getDeploymentHandler)
 		// service list
 		auth.GET("/service/list", a.listServicesHandler)
@@ -179,12 +207,22 @@ func (a *API) createRoutes() {
 
 		// cloudwatch logs
 		auth.GET("/service/log/:service/get/:taskarn/:container/:start/:end", a.getServiceLogsHandler)
+		auth.GET("/service/log/:service/follow", a.followServiceLogsHandler)
+
+		// drift between the last deployment and the live state
+		auth.GET("/service/drift", a.getDriftHandler)
+		auth.GET("/service/drift/:service", a.getServiceDriftHandler)
 
 		// service autoscaling
 		auth.POST("/service/autoscaling/:service/put", a.putServiceAutoscalingHandler)
 		auth.GET("/service/autoscaling/:service/get", a.getServiceAutoscalingHandler)
 		auth.POST("/service/autoscaling/:service/delete/:policyname", a.deleteServiceAutoscalingPolicyHandler)
 		auth.POST("/service/autoscaling/:service/delete", a.deleteServiceAutoscalingHandler)
+
+		// cluster autoscaling
+		auth.POST("/autoscaling/cluster/:cluster/simulate", a.simulateClusterAutoscalingHandler)
+		auth.GET("/autoscaling/decisions", a.listScalingDecisionsHandler)
+		auth.GET("/autoscaling/cluster/:cluster/decisions", a.listScalingDecisionsHandler)
 	}
 
 	// run API
@@ -204,7 +242,7 @@ func (a *API) createAuthMiddleware() {
 	)
 	a.authMiddleware, err = jwt.New(&jwt.GinJWTMiddleware{
 		Realm:            "ecs-deploy",
-		Key:              []byte(util.GetEnv("JWT_SECRET", "unsecure secret key 8a045eb")),
+		Key:              []byte(serverConfig.Auth.JWTSecret),
 		SigningAlgorithm: "HS256",
 		Timeout:          time.Hour,
 		MaxRefresh:       time.Hour,
@@ -231,7 +269,8 @@ func (a *API) createAuthMiddleware() {
 			userID := loginVals.Username
 			password := loginVals.Password
 
-			if (userID == "deploy" && password == util.GetEnv("DEPLOY_PASSWORD", "deploy")) || (userID == "developer" && password == util.GetEnv("DEVELOPER_PASSWORD", "developer")) {
+			// an empty password never matches, the deploy password has no default
+			if password != "" && ((userID == "deploy" && password == serverConfig.Auth.DeployPassword) || (userID == "developer" && password == serverConfig.Auth.DeveloperPassword)) {
 				return &User{UserID: userID}, nil
 			}
 
@@ -280,8 +319,18 @@ func (a *API) createAuthMiddleware() {
 // @param   repository     path    string     true        "repository"
 // @router /api/v1/ecr/create/{repository} [post]
 func (a *API) ecrCreateHandler(c *gin.Context) {
+	var json service.RepositorySettings
 	controller := Controller{}
-	res, err := controller.createRepository(c.Param("repository"))
+	// the settings are optional
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(200, gin.H{
+				"error": "Invalid input",
+			})
+			return
+		}
+	}
+	res, err := controller.createRepository(c.Param("repository"), json)
 	if err == nil {
 		c.JSON(200, gin.H{
 			"message": res,
@@ -293,6 +342,34 @@ func (a *API) ecrCreateHandler(c *gin.Context) {
 	}
 }
 
+// @summary Update ECR repository
+// @description Updates the lifecycle rules, tag immutability, scan on push, pull accounts and replication of an existing ECR repository
+// @id ecr-update-repository
+// @accept  json
+// @produce  json
+// @param   repository     path    string     true        "repository"
+// @router /api/v1/ecr/update/{repository} [post]
+func (a *API) ecrUpdateHandler(c *gin.Context) {
+	var json service.RepositorySettings
+	controller := Controller{}
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(200, gin.H{
+			"error": "Invalid input",
+		})
+		return
+	}
+	res, err := controller.updateRepository(c.Param("repository"), json)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"message": res,
+	})
+}
+
 // @summary Healthcheck
 // @description Healthcheck for loadbalancer
 // @id healthcheck
@@ -311,11 +388,16 @@ func (a *API) healthHandler(c *gin.Context) {
 // @accept  json
 // @produce  json
 // @param   service         path    string     true        "service name"
+// @param   imageScanOverride query  string     false       "reason to deploy images that are blocked by the image scan policy"
 // @router /api/v1/deploy/{service} [post]
 func (a *API) deployServiceHandler(c *gin.Context) {
 	var json service.Deploy
-	controller := Controller{}
+	controller := Controller{requestID: c.GetString("requestID"), imageScanOverride: a.getImageScanOverride(c)}
 	service.SetDeployDefaults(&json)
+	if errs := a.validateDeployBody(c, "Deploy"); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errs.Error(), "validationErrors": errs})
+		return
+	}
 	if err := c.ShouldBindJSON(&json); err == nil {
 		if err = a.deployServiceValidator(c.Param("service"), json); err == nil {
 			res, err := controller.Deploy(c.Param("service"), json)
@@ -326,6 +408,8 @@ func (a *API) deployServiceHandler(c *gin.Context) {
 			} else {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			}
+		} else if errs, ok := err.(service.ValidationErrors); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "validationErrors": errs})
 		} else {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		}
@@ -339,6 +423,7 @@ func (a *API) deployServiceHandler(c *gin.Context) {
 // @id ecs-deploy-service
 // @accept  json
 // @produce  json
+// @param   imageScanOverride query  string     false       "reason to deploy images that are blocked by the image scan policy"
 // @router /api/v1/deploy [post]
 func (a *API) deployServicesHandler(c *gin.Context) {
 	var json service.DeployServices
@@ -348,7 +433,11 @@ func (a *API) deployServicesHandler(c *gin.Context) {
 	var res *service.DeployResult
 	var failures int
 	errors = make(map[string]string)
-	controller := Controller{}
+	controller := Controller{requestID: c.GetString("requestID"), imageScanOverride: a.getImageScanOverride(c)}
+	if errs := a.validateDeployBody(c, "DeployServices"); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errs.Error(), "validationErrors": errs})
+		return
+	}
 	if err = c.ShouldBindJSON(&json); err == nil {
 		for i, v := range json.Services {
 			if err = a.deployServiceValidator(v.ServiceName, json.Services[i]); err == nil {
@@ -372,6 +461,232 @@ func (a *API) deployServicesHandler(c *gin.Context) {
 	}
 }
 
+// getImageScanOverride returns the image scan override with the reason and the user, when requested
+func (a *API) getImageScanOverride(c *gin.Context) *service.ImageScanOverride {
+	reason := c.Query("imageScanOverride")
+	if reason == "" {
+		return nil
+	}
+	claims := jwt.ExtractClaims(c)
+	user, _ := claims["id"].(string)
+	return &service.ImageScanOverride{User: user, Reason: reason}
+}
+
+// validateDeployBody validates the request body strictly against the deploy schema and restores the body for binding
+func (a *API) validateDeployBody(c *gin.Context, definition string) service.ValidationErrors {
+	body, err := c.GetRawData()
+	if err != nil {
+		return service.ValidationErrors{{Message: err.Error()}}
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return service.ValidationErrors{{Message: "invalid json: " + err.Error()}}
+	}
+	return service.ValidateDeployJSON(v, definition)
+}
+
+// @summary Get the deploy file schema
+// @description Returns the JSON Schema of the deploy files, for validation and editor integration
+// @id deploy-schema
+// @produce  json
+// @router /api/v1/deploy/schema [get]
+func (a *API) deploySchemaHandler(c *gin.Context) {
+	c.JSON(200, service.GetDeploySchema())
+}
+
+// @summary Get the image scan policy of a cluster
+// @description Get the image scan policy that blocks deploys of images with vulnerabilities
+// @id imagescan-policy-get
+// @produce  json
+// @param   cluster         path    string     true        "cluster name"
+// @router /api/v1/imagescan/policy/{cluster}/get [get]
+func (a *API) getImageScanPolicyHandler(c *gin.Context) {
+	controller := Controller{}
+	policy, err := controller.getImageScanPolicy(c.Param("cluster"))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"policy": policy,
+	})
+}
+
+// @summary Put the image scan policy of a cluster
+// @description Put the image scan policy that blocks deploys of images with findings at or above the severity threshold, except the CVEs in the allowlist
+// @id imagescan-policy-put
+// @accept  json
+// @produce  json
+// @param   cluster         path    string     true        "cluster name"
+// @router /api/v1/imagescan/policy/{cluster}/put [post]
+func (a *API) putImageScanPolicyHandler(c *gin.Context) {
+	var json service.ImageScanPolicy
+	claims := jwt.ExtractClaims(c)
+	controller := Controller{}
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(200, gin.H{
+			"error": "Invalid input",
+		})
+		return
+	}
+	user, _ := claims["id"].(string)
+	policy, err := controller.putImageScanPolicy(c.Param("cluster"), user, json)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"policy": policy,
+	})
+}
+
+// @summary Get the capacity report of a cluster
+// @description Registered and free resources per availability zone, the reservations of the services, fragmentation and how many more copies of the largest task fit
+// @id cluster-capacity
+// @produce  json
+// @param   cluster         path    string     true        "cluster name"
+// @router /api/v1/cluster/capacity/{cluster} [get]
+func (a *API) getClusterCapacityHandler(c *gin.Context) {
+	controller := Controller{}
+	report, err := controller.getClusterCapacityReport(c.Param("cluster"))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"capacity": report,
+	})
+}
+
+// @summary Start a rolling AMI upgrade of a cluster
+// @description Replaces the container instances one by one with instances running the latest ECS optimized AMI
+// @id cluster-upgrade-start
+// @produce  json
+// @param   cluster         path    string     true        "cluster name"
+// @router /api/v1/cluster/upgrade/{cluster}/start [post]
+func (a *API) startClusterUpgradeHandler(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	controller := Controller{}
+	user, _ := claims["id"].(string)
+	upgrade, err := controller.startClusterUpgrade(c.Param("cluster"), user)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"upgrade": upgrade,
+	})
+}
+
+// @summary Get the AMI upgrade of a cluster
+// @description Get the status of the last rolling AMI upgrade of a cluster and its instances
+// @id cluster-upgrade-get
+// @produce  json
+// @param   cluster         path    string     true        "cluster name"
+// @router /api/v1/cluster/upgrade/{cluster}/get [get]
+func (a *API) getClusterUpgradeHandler(c *gin.Context) {
+	controller := Controller{}
+	upgrade, err := controller.getClusterUpgrade(c.Param("cluster"))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"upgrade": upgrade,
+	})
+}
+
+// @summary Cancel the AMI upgrade of a cluster
+// @description Stops the running upgrade after the current step
+// @id cluster-upgrade-cancel
+// @produce  json
+// @param   cluster         path    string     true        "cluster name"
+// @router /api/v1/cluster/upgrade/{cluster}/cancel [post]
+func (a *API) cancelClusterUpgradeHandler(c *gin.Context) {
+	controller := Controller{}
+	upgrade, err := controller.cancelClusterUpgrade(c.Param("cluster"))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"upgrade": upgrade,
+	})
+}
+
+// @summary Simulate the cluster autoscaling decision
+// @description Explains the scale up and scale down decision of the cluster strategy. Without a body the live state of the cluster is used, otherwise the posted cluster snapshot
+// @id autoscaling-cluster-simulate
+// @accept  json
+// @produce  json
+// @param   cluster         path    string     true        "cluster name"
+// @param   strategy        query   string     false       "strategy to simulate instead of the configured one (e.g. binpacking, headroom:30)"
+// @router /api/v1/autoscaling/cluster/{cluster}/simulate [post]
+func (a *API) simulateClusterAutoscalingHandler(c *gin.Context) {
+	var snapshot *ClusterSnapshot
+	asc := AutoscalingController{}
+	if c.Request.ContentLength > 0 {
+		snapshot = &ClusterSnapshot{}
+		if err := c.ShouldBindJSON(snapshot); err != nil {
+			c.JSON(200, gin.H{
+				"error": "Invalid input",
+			})
+			return
+		}
+	}
+	simulation, err := asc.simulateScaleStrategy(c.Param("cluster"), c.Query("strategy"), snapshot)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"simulation": simulation,
+	})
+}
+
+// @summary List the scaling decisions of the cluster autoscaler
+// @description Lists the scaling decisions with the trigger, the free resources per availability zone, the strategy, the reasons and the outcome, newest first. Without cluster the decisions of all clusters in the service registry are returned
+// @id autoscaling-decisions
+// @produce  json
+// @param   cluster         path    string     false       "cluster name"
+// @param   since           query   string     false       "duration to look back (default: 24h)"
+// @router /api/v1/autoscaling/cluster/{cluster}/decisions [get]
+func (a *API) listScalingDecisionsHandler(c *gin.Context) {
+	controller := Controller{}
+	since, err := time.ParseDuration(c.DefaultQuery("since", "24h"))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": "invalid since duration: " + err.Error(),
+		})
+		return
+	}
+	decisions, err := controller.getScalingDecisions(c.Param("cluster"), time.Now().Add(-since))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"decisions": decisions,
+	})
+}
+
 // @summary Redeploy existing service to ECS
 // @description Redeploy existing service to ECS
 // @id ecs-redeploy-service
@@ -381,7 +696,7 @@ func (a *API) deployServicesHandler(c *gin.Context) {
 // @param   time            path    time       true        "timestamp"
 // @router /api/v1/deploy/{service} [post]
 func (a *API) redeployServiceHandler(c *gin.Context) {
-	controller := Controller{}
+	controller := Controller{requestID: c.GetString("requestID")}
 	res, err := controller.redeploy(c.Param("service"), c.Param("time"))
 	if err == nil {
 		c.JSON(200, gin.H{
@@ -398,24 +713,14 @@ func (a *API) deployServiceValidator(serviceName string, d service.Deploy) error
 	if len(serviceName) < 3 {
 		return errors.New("service name needs to be at least 3 characters")
 	}
-	if strings.ToLower(d.ServiceProtocol) != "none" && d.ServicePort == 0 {
-		return errors.New("ServicePort needs to be set if ServiceProtocol is not set to none.")
-	}
-	t := false
-	for _, container := range d.Containers {
-		if container.ContainerName == serviceName {
-			t = true
-		}
-	}
-	if !t {
-		return errors.New("At least one container needs to have the same name as the service (" + serviceName + ")")
+	if errs := service.ValidateDeployService(serviceName, d); len(errs) > 0 {
+		return errs
 	}
-
 	return nil
 }
 
 // @summary Export current services to terraform
-// @description Export service data stored in dynamodb into terraform tf files
+// @description Export the services into terraform tf files (base64 encoded), including import blocks for the existing resources
 // @id export-terraform
 // @produce  json
 // @router /api/v1/export/terraform [get]
@@ -439,6 +744,25 @@ func (a *API) exportTerraformHandler(c *gin.Context) {
 	}
 }
 
+// @summary Export a service to terraform
+// @description Export a service into a terraform tf file (base64 encoded), including import blocks for the existing resources
+// @id export-terraform-service
+// @produce  json
+// @router /api/v1/export/terraform/{service} [get]
+func (a *API) exportTerraformServiceHandler(c *gin.Context) {
+	e := Export{}
+	exp, err := e.terraformService(c.Param("service"))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"export": exp,
+	})
+}
+
 // @summary Export targetgroup arn
 // @description Export target group arn stored in dynamodb into terraform tf files
 // @id export-terraform-targetgroup-arn
@@ -496,6 +820,148 @@ func (a *API) exportTerraformListenerRuleArnsHandler(c *gin.Context) {
 		})
 	}
 }
+
+// cloudFormationExport returns the template in the requested format (cloudformation or cdk)
+func cloudFormationExport(name string, exp *CloudFormationExport, format string) interface{} {
+	if format == "cdk" {
+		return renderCDK(name, exp.Template)
+	}
+	return exp.Template
+}
+
+// @summary Export current services to CloudFormation
+// @description Export the services into a CloudFormation template per service, or a CDK construct tree with format=cdk. The cluster parameter limits the export to one cluster
+// @id export-cloudformation
+// @produce  json
+// @param   cluster         query   string     false       "cluster name"
+// @param   format          query   string     false       "cloudformation (default) or cdk"
+// @router /api/v1/export/cloudformation [get]
+func (a *API) exportCloudFormationHandler(c *gin.Context) {
+	e := Export{}
+	exp, err := e.cloudFormation(c.Query("cluster"))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	apps := make(map[string]interface{})
+	resourcesToImport := make(map[string][]CloudFormationResourceToImport)
+	for serviceName, serviceExport := range exp {
+		apps[serviceName] = cloudFormationExport(serviceName, serviceExport, c.Query("format"))
+		resourcesToImport[serviceName] = serviceExport.ResourcesToImport
+	}
+	c.JSON(200, gin.H{
+		"export":            gin.H{"apps": apps},
+		"resourcesToImport": resourcesToImport,
+	})
+}
+
+// @summary Export a service to CloudFormation
+// @description Export a service into a CloudFormation template, or a CDK construct tree with format=cdk. The resources to import can be used to create an import change set
+// @id export-cloudformation-service
+// @produce  json
+// @param   service         path    string     true        "service name"
+// @param   format          query   string     false       "cloudformation (default) or cdk"
+// @router /api/v1/export/cloudformation/{service} [get]
+func (a *API) exportCloudFormationServiceHandler(c *gin.Context) {
+	e := Export{}
+	exp, err := e.cloudFormationService(c.Param("service"))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"export":            cloudFormationExport(c.Param("service"), exp, c.Query("format")),
+		"resourcesToImport": exp.ResourcesToImport,
+	})
+}
+
+// @summary Export a cluster to CloudFormation
+// @description Export all services of a cluster into one CloudFormation template, or a CDK construct tree with format=cdk
+// @id export-cloudformation-cluster
+// @produce  json
+// @param   cluster         path    string     true        "cluster name"
+// @param   format          query   string     false       "cloudformation (default) or cdk"
+// @router /api/v1/export/cloudformation/cluster/{cluster} [get]
+func (a *API) exportCloudFormationClusterHandler(c *gin.Context) {
+	e := Export{}
+	exp, err := e.cloudFormationCluster(c.Param("cluster"))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"export":            cloudFormationExport(c.Param("cluster"), exp, c.Query("format")),
+		"resourcesToImport": exp.ResourcesToImport,
+	})
+}
+
+// @summary Import an existing service
+// @description Adopt a service that wasn't created by ecs-deploy: the deploy data is synthesised from the live service, the service is registered and an initial deployment is written. With dryRun=true only the deploy data is returned
+// @id import-service
+// @produce  json
+// @param   cluster         path    string     true        "cluster name"
+// @param   service         path    string     true        "service name"
+// @param   dryRun          query   bool       false       "only return the deploy data"
+// @router /api/v1/import/{cluster}/{service} [post]
+func (a *API) importServiceHandler(c *gin.Context) {
+	i := Import{}
+	result, err := i.importService(c.Param("cluster"), c.Param("service"), c.Query("dryRun") == "true")
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"import": result,
+	})
+}
+
+// @summary Drift of all services
+// @description Compare the last deployment of every service with the live state (task definition, desired count, health check, target group attributes and listener rules)
+// @id get-drift
+// @produce  json
+// @router /api/v1/service/drift [get]
+func (a *API) getDriftHandler(c *gin.Context) {
+	controller := Controller{}
+	drift, err := controller.getDriftForAllServices()
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"drift": drift,
+	})
+}
+
+// @summary Drift of a service
+// @description Compare the last deployment of a service with the live state
+// @id get-service-drift
+// @produce  json
+// @param   service         path    string     true        "service name"
+// @router /api/v1/service/drift/{service} [get]
+func (a *API) getServiceDriftHandler(c *gin.Context) {
+	controller := Controller{}
+	drift, err := controller.getServiceDrift(c.Param("service"))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"drift": drift,
+	})
+}
+
 func (a *API) listDeploysHandler(c *gin.Context) {
 	controller := Controller{}
 	deploys, err := controller.getDeploys()
@@ -588,6 +1054,43 @@ func (a *API) getDeploymentStatusHandler(c *gin.Context) {
 		})
 	}
 }
+
+// @summary Stream deployment progress
+// @description Server-sent events with the phase changes, ECS service events, task changes and final status of a deployment
+// @id ecs-deploy-status-stream
+// @produce  text/event-stream
+// @param   service         path    string     true        "service name"
+// @param   time            path    string     true        "deployment time"
+// @router /api/v1/deploy/status/{service}/{time}/stream [get]
+func (a *API) streamDeploymentStatusHandler(c *gin.Context) {
+	controller := Controller{}
+	events := make(chan service.DeployProgressEvent)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- controller.watchDeployment(c.Request.Context(), c.Param("service"), c.Param("time"), events)
+		close(events)
+	}()
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				if err := <-errs; err != nil {
+					c.SSEvent("error", gin.H{"error": err.Error()})
+				}
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-keepalive.C:
+			c.SSEvent("ping", gin.H{"time": time.Now()})
+			return true
+		}
+	})
+}
 func (a *API) getDeploymentHandler(c *gin.Context) {
 	controller := Controller{}
 	deployment, err := controller.getDeployment(c.Param("service"), c.Param("time"))
@@ -603,7 +1106,7 @@ func (a *API) getDeploymentHandler(c *gin.Context) {
 }
 
 func (a *API) redirectFrontendHandler(c *gin.Context) {
-	c.Redirect(http.StatusMovedPermanently, util.GetEnv("URL_PREFIX", "")+"/webapp/")
+	c.Redirect(http.StatusMovedPermanently, serverConfig.Server.UrlPrefix+"/webapp/")
 }
 
 func (a *API) listServiceParametersHandler(c *gin.Context) {
@@ -733,6 +1236,12 @@ func (a *API) webhookHandler(c *gin.Context) {
 							apiLogger.Debugf("Lifecycle Message: %v", snsPayload.Message)
 							err = a.asController.processLifecycleMessage(lifecycleMessage)
 						}
+					} else if genericMessage.DetailType == "EC2 Spot Instance Interruption Warning" || genericMessage.DetailType == "EC2 Instance Rebalance Recommendation" {
+						var spotMessage ecs.SNSPayloadSpotInterruption
+						if err = json.Unmarshal([]byte(snsPayload.Message), &spotMessage); err == nil {
+							apiLogger.Debugf("Spot Message: %v", snsPayload.Message)
+							err = a.asController.processSpotInterruptionMessage(spotMessage)
+						}
 					}
 				}
 			} else {
@@ -827,6 +1336,51 @@ func (a *API) getServiceLogsHandler(c *gin.Context) {
 		})
 	}
 }
+
+// @summary Follow service logs
+// @description Server-sent events with the log events of all running tasks of a service
+// @id ecs-service-logs-follow
+// @produce  text/event-stream
+// @param   service         path    string     true        "service name"
+// @param   filter          query   string     false       "CloudWatch logs filter pattern"
+// @param   since           query   string     false       "show logs since duration (e.g. 10m), default 5m"
+// @param   follow          query   bool       false       "keep following the logs, default true"
+// @router /api/v1/service/log/{service}/follow [get]
+func (a *API) followServiceLogsHandler(c *gin.Context) {
+	controller := Controller{}
+	since, err := time.ParseDuration(c.DefaultQuery("since", "5m"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Can't parse since: " + err.Error()})
+		return
+	}
+	follow := c.DefaultQuery("follow", "true") != "false"
+	events := make(chan service.ServiceLogEvent)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- controller.followServiceLogs(c.Request.Context(), c.Param("service"), c.Query("filter"), time.Now().Add(-since), follow, events)
+		close(events)
+	}()
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				if err := <-errs; err != nil {
+					c.SSEvent("error", gin.H{"error": err.Error()})
+				}
+				return false
+			}
+			c.SSEvent("log", event)
+			return true
+		case <-keepalive.C:
+			c.SSEvent("ping", gin.H{"time": time.Now()})
+			return true
+		}
+	})
+}
 func (a *API) putServiceAutoscalingHandler(c *gin.Context) {
 	var json service.Autoscaling
 	controller := Controller{}
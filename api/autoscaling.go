@@ -11,7 +11,6 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
-	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -21,10 +20,48 @@ import (
 type AutoscalingController struct {
 	muUp   sync.Mutex
 	muDown sync.Mutex
+	// configuration with the autoscaling settings, the server configuration when not set
+	config *Config
+}
+
+// getConfig returns the autoscaling settings of the controller
+func (c *AutoscalingController) getConfig() AutoscalingConfig {
+	if c.config != nil {
+		return c.config.Autoscaling
+	}
+	return serverConfig.Autoscaling
 }
 
 var asAutoscalingControllerLogger = loggo.GetLogger("as-controller")
 
+// clusters with managed scaling (capacity providers) are scaled by ECS, cached per cluster
+var managedScalingCache = struct {
+	sync.Mutex
+	clusters map[string]managedScalingCacheItem
+}{clusters: make(map[string]managedScalingCacheItem)}
+
+type managedScalingCacheItem struct {
+	enabled bool
+	expires time.Time
+}
+
+// isManagedScalingEnabled returns true when the cluster autoscaler should leave the cluster to the capacity provider
+func (c *AutoscalingController) isManagedScalingEnabled(clusterName string) bool {
+	managedScalingCache.Lock()
+	defer managedScalingCache.Unlock()
+	if item, ok := managedScalingCache.clusters[clusterName]; ok && item.expires.After(time.Now()) {
+		return item.enabled
+	}
+	e := ecs.ECS{}
+	enabled, err := e.IsManagedScalingEnabled(clusterName)
+	if err != nil {
+		asAutoscalingControllerLogger.Errorf("Could not determine whether cluster %v has managed scaling: %v", clusterName, err)
+		return false
+	}
+	managedScalingCache.clusters[clusterName] = managedScalingCacheItem{enabled: enabled, expires: time.Now().Add(5 * time.Minute)}
+	return enabled
+}
+
 func (c *AutoscalingController) getClusterInfoWithCache(clusterName string, s service.ServiceIf, e ecs.ECSIf) (*service.DynamoCluster, error) {
 	return c.getClusterInfo(clusterName, true, s, e)
 }
@@ -52,7 +89,7 @@ func (c *AutoscalingController) getClusterInfo(clusterName string, withCache boo
 		}
 
 		// calculate free resources
-		firs, _, err := e.GetInstanceResources(clusterName)
+		firs, rirs, err := e.GetInstanceResources(clusterName)
 		if err != nil {
 			return nil, err
 		}
@@ -64,6 +101,13 @@ func (c *AutoscalingController) getClusterInfo(clusterName string, withCache boo
 			dcci.FreeMemory = f.FreeMemory
 			dcci.FreeCpu = f.FreeCpu
 			dcci.Status = f.Status
+			// instances can have different instance types
+			for _, r := range rirs {
+				if r.InstanceId == f.InstanceId {
+					dcci.RegisteredMemory = r.RegisteredMemory
+					dcci.RegisteredCpu = r.RegisteredCpu
+				}
+			}
 			dc.ContainerInstances = append(dc.ContainerInstances, dcci)
 		}
 	}
@@ -101,7 +145,7 @@ func (c *AutoscalingController) getResourcesNeeded(clusterName string, cc Contro
 }
 func (c *AutoscalingController) getAutoscalingStrategy() (bool, bool) {
 	// Check whether Strategy is enabled
-	asStrategies := strings.Split(util.GetEnv("AUTOSCALING_STRATEGIES", "LargestContainerUp,LargestContainerDown"), ",")
+	asStrategies := strings.Split(c.getConfig().Strategies, ",")
 	asStrategyLargestContainerUp := false
 	asStrategyLargestContainerDown := false
 	for _, v := range asStrategies {
@@ -128,6 +172,14 @@ func (c *AutoscalingController) processEcsMessage(message ecs.SNSPayloadEcs) err
 		return errors.New("Could not determine cluster name from message (arn: " + message.Detail.ClusterArn + ")")
 	}
 	clusterName := sp[1]
+	if c.isManagedScalingEnabled(clusterName) {
+		asAutoscalingControllerLogger.Debugf("Cluster %v uses managed scaling, skipping ecs notification", clusterName)
+		return nil
+	}
+	if cc.isClusterUpgradeRunning(clusterName) {
+		asAutoscalingControllerLogger.Debugf("Instances of cluster %v are being upgraded, skipping ecs notification", clusterName)
+		return nil
+	}
 	// determine max reservation
 	memoryNeeded, cpuNeeded, err := c.getResourcesNeeded(clusterName, cc)
 	if err != nil {
@@ -157,6 +209,8 @@ func (c *AutoscalingController) processEcsMessage(message ecs.SNSPayloadEcs) err
 			}
 			dc.ContainerInstances[k].FreeMemory = f.FreeMemory
 			dc.ContainerInstances[k].FreeCpu = f.FreeCpu
+			dc.ContainerInstances[k].RegisteredMemory = registeredInstanceMemory
+			dc.ContainerInstances[k].RegisteredCpu = registeredInstanceCpu
 			// get az
 			for _, v := range message.Detail.Attributes {
 				if v.Name == "ecs.availability-zone" {
@@ -176,6 +230,8 @@ func (c *AutoscalingController) processEcsMessage(message ecs.SNSPayloadEcs) err
 		}
 		dcci.FreeMemory = f.FreeMemory
 		dcci.FreeCpu = f.FreeCpu
+		dcci.RegisteredMemory = registeredInstanceMemory
+		dcci.RegisteredCpu = registeredInstanceCpu
 		dcci.Status = f.Status
 		// get az
 		for _, v := range message.Detail.Attributes {
@@ -194,52 +250,59 @@ func (c *AutoscalingController) processEcsMessage(message ecs.SNSPayloadEcs) err
 	if err != nil {
 		return err
 	}
-	// Check whether Strategy is enabled
-	asStrategyLargestContainerUp, asStrategyLargestContainerDown := c.getAutoscalingStrategy()
+	// get the scale strategy of the cluster
+	strategy, err := c.getScaleStrategy(clusterName)
+	if err != nil {
+		return err
+	}
+	snapshot := c.getClusterSnapshot(strategy, clusterName, dc.ContainerInstances, registeredInstanceCpu, registeredInstanceMemory, cpuNeeded, memoryNeeded, cc)
 	// make scaling (up) decision
 	var resourcesFitGlobal bool
 	var scalingOp = "no"
 	var pendingScalingOp string
-	if asStrategyLargestContainerUp {
-		if desiredCapacity < maxSize {
-			resourcesFitGlobal = c.scaleUpDecision(clusterName, dc.ContainerInstances, cpuNeeded, memoryNeeded)
-			if !resourcesFitGlobal {
-				cooldownMin, err := strconv.ParseInt(util.GetEnv("AUTOSCALING_UP_COOLDOWN", "5"), 10, 64)
-				if err != nil {
-					cooldownMin = 5
-				}
-				startTime := time.Now().Add(-1 * time.Duration(cooldownMin) * time.Minute)
-				lastScalingOp, _, err := s.GetScalingActivity(clusterName, startTime)
-				if err != nil {
-					return err
-				}
-				if lastScalingOp == "no" {
-					if util.GetEnv("AUTOSCALING_UP_STRATEGY", "immediately") == "gracefully" {
-						pendingScalingOp = "up"
-					} else {
-						asAutoscalingControllerLogger.Infof("Initiating scaling activity")
-						scalingOp = "up"
-						err = autoscaling.ScaleClusterNodes(autoScalingGroupName, 1)
-						if err != nil {
-							return err
-						}
+	trigger := "ecs container instance state change (" + message.Detail.Ec2InstanceId + ")"
+	if desiredCapacity < maxSize {
+		decision := strategy.ScaleUp(snapshot)
+		c.logScaleDecision(strategy, "up", decision)
+		resourcesFitGlobal = !decision.Scale
+		if decision.Scale {
+			scalingDecision := c.newScalingDecision(trigger, "up", strategy, decision, snapshot)
+			scalingDecision.MinSize, scalingDecision.DesiredCapacity, scalingDecision.MaxSize = minSize, desiredCapacity, maxSize
+			cooldownMin := c.getConfig().UpCooldown
+			startTime := time.Now().Add(-1 * time.Duration(cooldownMin) * time.Minute)
+			lastScalingOp, _, err := s.GetScalingActivity(clusterName, startTime)
+			if err != nil {
+				return err
+			}
+			if lastScalingOp == "no" {
+				if c.getConfig().UpStrategy == "gracefully" {
+					pendingScalingOp = "up"
+					c.recordScalingDecision(s, scalingDecision, "pending")
+				} else {
+					asAutoscalingControllerLogger.Infof("Initiating scaling activity")
+					scalingOp = "up"
+					err = autoscaling.ScaleClusterNodes(autoScalingGroupName, 1)
+					if err != nil {
+						c.recordScalingDecision(s, scalingDecision, "error: "+err.Error())
+						return err
 					}
+					c.recordScalingDecision(s, scalingDecision, "scaled")
 				}
+			} else {
+				scalingDecision.CooldownMinutes = cooldownMin
+				c.recordScalingDecision(s, scalingDecision, "cooldown (last scaling operation: "+lastScalingOp+")")
 			}
 		}
-	} else {
-		// if strategy is "latgestContainerUp" is disabled, resources always fit, and scaling down always needs to be checked
-		resourcesFitGlobal = true
 	}
 	// make scaling (down) decision
-	if asStrategyLargestContainerDown && desiredCapacity > minSize && (resourcesFitGlobal || desiredCapacity == maxSize) {
-		hasFreeResourcesGlobal := c.scaleDownDecision(clusterName, dc.ContainerInstances, registeredInstanceCpu, registeredInstanceMemory, cpuNeeded, memoryNeeded)
-		if hasFreeResourcesGlobal {
+	if desiredCapacity > minSize && (resourcesFitGlobal || desiredCapacity == maxSize) {
+		decision := strategy.ScaleDown(snapshot)
+		c.logScaleDecision(strategy, "down", decision)
+		if decision.Scale {
+			scalingDecision := c.newScalingDecision(trigger, "down", strategy, decision, snapshot)
+			scalingDecision.MinSize, scalingDecision.DesiredCapacity, scalingDecision.MaxSize = minSize, desiredCapacity, maxSize
 			// check cooldown period
-			cooldownMin, err := strconv.ParseInt(util.GetEnv("AUTOSCALING_DOWN_COOLDOWN", "5"), 10, 64)
-			if err != nil {
-				cooldownMin = 5
-			}
+			cooldownMin := c.getConfig().DownCooldown
 			startTime := time.Now().Add(-1 * time.Duration(cooldownMin) * time.Minute)
 			lastScalingOp, tmpPendingScalingOp, err := s.GetScalingActivity(clusterName, startTime)
 			if err != nil {
@@ -253,6 +316,14 @@ func (c *AutoscalingController) processEcsMessage(message ecs.SNSPayloadEcs) err
 			// only scale down if the cooldown period is not active and if there are no deploys currently running
 			if lastScalingOp == "no" && tmpPendingScalingOp == "" && !deployRunning {
 				pendingScalingOp = "down"
+				c.recordScalingDecision(s, scalingDecision, "pending")
+			} else if lastScalingOp != "no" {
+				scalingDecision.CooldownMinutes = cooldownMin
+				c.recordScalingDecision(s, scalingDecision, "cooldown (last scaling operation: "+lastScalingOp+")")
+			} else if tmpPendingScalingOp != "" {
+				c.recordScalingDecision(s, scalingDecision, "skipped (pending scaling operation: "+tmpPendingScalingOp+")")
+			} else {
+				c.recordScalingDecision(s, scalingDecision, "skipped (deploy running)")
 			}
 		}
 	}
@@ -271,30 +342,13 @@ func (c *AutoscalingController) processEcsMessage(message ecs.SNSPayloadEcs) err
 	return nil
 }
 func (c *AutoscalingController) getAutoscalingPeriodInterval(scalingOp string) (int64, int64) {
-	var period, interval int64
-	var err error
+	config := c.getConfig()
 	if scalingOp == "down" {
-		period, err = strconv.ParseInt(util.GetEnv("AUTOSCALING_DOWN_PERIOD", "5"), 10, 64)
-		if err != nil {
-			period = 5
-		}
-		interval, err = strconv.ParseInt(util.GetEnv("AUTOSCALING_DOWN_INTERVAL", "60"), 10, 64)
-		if err != nil {
-			interval = 60
-		}
+		return config.DownPeriod, config.DownInterval
 	} else if scalingOp == "up" {
-		period, err = strconv.ParseInt(util.GetEnv("AUTOSCALING_UP_PERIOD", "2"), 10, 64)
-		if err != nil {
-			period = 5
-		}
-		interval, err = strconv.ParseInt(util.GetEnv("AUTOSCALING_UP_INTERVAL", "60"), 10, 64)
-		if err != nil {
-			interval = 60
-		}
-	} else {
-		return 5, 60
+		return config.UpPeriod, config.UpInterval
 	}
-	return period, interval
+	return 5, 60
 }
 
 func (c *AutoscalingController) launchProcessPendingScalingOpWithLocking(clusterName, scalingOp string, registeredInstanceCpu, registeredInstanceMemory int64, s service.ServiceIf, cc ControllerIf, autoscaling ecs.AutoScalingIf) error {
@@ -337,8 +391,16 @@ func (c *AutoscalingController) launchProcessPendingScalingOp(clusterName, scali
 	}
 
 	period, interval := c.getAutoscalingPeriodInterval(scalingOp)
+	trigger := "pending scaling operation (" + strconv.FormatInt(period, 10) + " checks every " + strconv.FormatInt(interval, 10) + "s)"
+
+	strategy, err := c.getScaleStrategy(clusterName)
+	if err != nil {
+		return err
+	}
 
 	var abort, deployRunning, hasFreeResourcesGlobal, resourcesFit bool
+	var scalingDecision service.ScalingDecision
+	var abortReasons []string
 	var i int64
 	for i = 0; i < period && !abort; i++ {
 		time.Sleep(time.Duration(interval) * time.Second)
@@ -354,11 +416,16 @@ func (c *AutoscalingController) launchProcessPendingScalingOp(clusterName, scali
 		if dcNew.ScalingOperation.PendingAction != scalingOp {
 			asAutoscalingControllerLogger.Infof("Abort scaling operation: scaling %s not found anymore in dynamodb (scalingOp in db: %s)", scalingOp, dcNew.ScalingOperation.PendingAction)
 			abort = true
+			abortReasons = append(abortReasons, "scaling operation not pending anymore")
 		}
+		snapshot := c.getClusterSnapshot(strategy, clusterName, dcNew.ContainerInstances, registeredInstanceCpu, registeredInstanceMemory, cpuNeeded, memoryNeeded, cc)
 		// pending scaling down logic
 		if scalingOp == "down" {
 			// make scaling decision
-			hasFreeResourcesGlobal = c.scaleDownDecision(clusterName, dcNew.ContainerInstances, registeredInstanceCpu, registeredInstanceMemory, cpuNeeded, memoryNeeded)
+			decision := strategy.ScaleDown(snapshot)
+			c.logScaleDecision(strategy, scalingOp, decision)
+			scalingDecision = c.newScalingDecision(trigger, scalingOp, strategy, decision, snapshot)
+			hasFreeResourcesGlobal = decision.Scale
 			if hasFreeResourcesGlobal {
 				// abort if deploy is running
 				deployRunning, err = s.IsDeployRunning()
@@ -367,19 +434,26 @@ func (c *AutoscalingController) launchProcessPendingScalingOp(clusterName, scali
 				}
 				if deployRunning {
 					abort = true
+					abortReasons = append(abortReasons, "deploy running")
 				}
 				// abort if not all services are scheduled
 				if !c.areAllTasksRunningInCluster(clusterName, cc) {
 					abort = true
+					abortReasons = append(abortReasons, "not all tasks are running")
 				}
 			} else {
 				abort = true
+				abortReasons = append(abortReasons, "strategy decided not to scale down")
 			}
 		} else {
 			// pending scaling up logic
-			resourcesFit = c.scaleUpDecision(clusterName, dcNew.ContainerInstances, cpuNeeded, memoryNeeded)
+			decision := strategy.ScaleUp(snapshot)
+			c.logScaleDecision(strategy, scalingOp, decision)
+			scalingDecision = c.newScalingDecision(trigger, scalingOp, strategy, decision, snapshot)
+			resourcesFit = !decision.Scale
 			if resourcesFit {
 				abort = true
+				abortReasons = append(abortReasons, "strategy decided not to scale up")
 			}
 		}
 	}
@@ -392,13 +466,16 @@ func (c *AutoscalingController) launchProcessPendingScalingOp(clusterName, scali
 		}
 		err = autoscaling.ScaleClusterNodes(autoScalingGroupName, sizeChange)
 		if err != nil {
+			c.recordScalingDecision(s, scalingDecision, "error: "+err.Error())
 			return err
 		}
+		c.recordScalingDecision(s, scalingDecision, "scaled")
 		_, err = s.PutClusterInfo(*dcNew, clusterName, scalingOp, "")
 		if err != nil {
 			return err
 		}
 	} else {
+		c.recordScalingDecision(s, scalingDecision, "aborted ("+strings.Join(abortReasons, ", ")+")")
 		asAutoscalingControllerLogger.Infof("Scaling operation: scaling %s aborted. deploy running: %v, free resources (scaling down): %v, resources fit (scaling up): %v, pendingAction: %s", scalingOp, deployRunning, hasFreeResourcesGlobal, resourcesFit, dcNew.ScalingOperation.PendingAction)
 	}
 	return nil
@@ -419,127 +496,334 @@ func (c *AutoscalingController) areAllTasksRunningInCluster(clusterName string,
 	return true
 }
 
-func (c *AutoscalingController) scaleUpDecision(clusterName string, containerInstances []service.DynamoClusterContainerInstance, cpuNeeded, memoryNeeded int64) bool {
-	resourcesFit := make(map[string]bool)
-	resourcesFitGlobal := true
-	for _, dcci := range containerInstances {
-		if clusterName == dcci.ClusterName {
-			if dcci.Status != "DRAINING" && dcci.FreeCpu > cpuNeeded && dcci.FreeMemory > memoryNeeded {
-				resourcesFit[dcci.AvailabilityZone] = true
-				asAutoscalingControllerLogger.Debugf("Cluster %v needs at least %v cpu and %v memory. Found instance %v (%v) with %v cpu and %v memory",
-					clusterName,
-					cpuNeeded,
-					memoryNeeded,
-					dcci.ContainerInstanceId,
-					dcci.AvailabilityZone,
-					dcci.FreeCpu,
-					dcci.FreeMemory,
-				)
-			} else {
-				// set resourcesFit[az] in case it's not set to true
-				if _, ok := resourcesFit[dcci.AvailabilityZone]; !ok {
-					resourcesFit[dcci.AvailabilityZone] = false
-				}
-			}
+// clusterTasks are the running tasks of the services in a cluster, with the data to place them on the instances
+type clusterTasks struct {
+	services []service.RunningService
+	// ec2 instance id per container instance arn
+	instanceIds map[string]string
+	// static host ports per task definition arn
+	hostPorts map[string][]int64
+}
+
+// getClusterSnapshot returns the snapshot of the cluster, with the pending and running tasks when the strategy needs them
+func (c *AutoscalingController) getClusterSnapshot(strategy ScaleStrategy, clusterName string, containerInstances []service.DynamoClusterContainerInstance, registeredInstanceCpu, registeredInstanceMemory, cpuNeeded, memoryNeeded int64, cc ControllerIf) ClusterSnapshot {
+	snapshot := newClusterSnapshot(clusterName, containerInstances, registeredInstanceCpu, registeredInstanceMemory, cpuNeeded, memoryNeeded)
+	if t, ok := strategy.(taskAwareScaleStrategy); !ok || !t.needsTasks() {
+		return snapshot
+	}
+	dss, err := cc.getServices()
+	if err != nil {
+		asAutoscalingControllerLogger.Errorf("Could not get services of cluster %v: %v", clusterName, err)
+		return snapshot
+	}
+	tasks, err := cc.describeClusterTasks(clusterName)
+	if err != nil {
+		asAutoscalingControllerLogger.Errorf("Could not get tasks of cluster %v: %v", clusterName, err)
+		return snapshot
+	}
+	snapshot.PendingTasks = getPendingTasks(clusterName, dss, tasks)
+	snapshot.RunningTasks = getRunningTasks(clusterName, dss, tasks)
+	return snapshot
+}
+
+// getServiceReservation returns the cpu and memory reservation of a service
+func getServiceReservation(clusterName, serviceName string, dss []*service.DynamoServicesElement) (int64, int64, bool) {
+	for _, ds := range dss {
+		if ds.C == clusterName && ds.S == serviceName {
+			return ds.CpuReservation, ds.MemoryReservation, true
 		}
 	}
-	for k, v := range resourcesFit {
-		if !v {
-			resourcesFitGlobal = false
-			asAutoscalingControllerLogger.Infof("No instance found in %v with %v cpu and %v memory free", k, cpuNeeded, memoryNeeded)
-		}
-	}
-	return resourcesFitGlobal
-}
-func (c *AutoscalingController) scaleDownDecision(clusterName string, containerInstances []service.DynamoClusterContainerInstance, instanceCpu, instanceMemory, cpuNeeded, memoryNeeded int64) bool {
-	var clusterMemoryNeeded = instanceMemory + memoryNeeded            // capacity of full container node + biggest task
-	clusterMemoryNeeded += int64(math.Ceil(float64(memoryNeeded) / 2)) // + buffer
-	var clusterCpuNeeded = instanceCpu + cpuNeeded
-	clusterCpuNeeded += int64(math.Ceil(float64(cpuNeeded) / 2)) // + buffer
-	totalFreeCpu := make(map[string]int64)
-	totalFreeMemory := make(map[string]int64)
-	hasFreeResources := make(map[string]bool)
-	hasFreeResourcesGlobal := true
-	for _, dcci := range containerInstances {
-		if clusterName == dcci.ClusterName {
-			if dcci.Status != "DRAINING" {
-				totalFreeCpu[dcci.AvailabilityZone] += dcci.FreeCpu
-				totalFreeMemory[dcci.AvailabilityZone] += dcci.FreeMemory
+	return 0, 0, false
+}
+
+// getPendingTasks returns the tasks that are not running yet (desired count - running count), with the reservations of the service
+// and the host ports of the primary deployment
+func getPendingTasks(clusterName string, dss []*service.DynamoServicesElement, tasks clusterTasks) []ClusterSnapshotTask {
+	var pendingTasks []ClusterSnapshotTask
+	for _, rs := range tasks.services {
+		cpu, memory, ok := getServiceReservation(clusterName, rs.ServiceName, dss)
+		if !ok {
+			continue
+		}
+		var ports []int64
+		for _, deployment := range rs.Deployments {
+			if deployment.Status == "PRIMARY" {
+				ports = tasks.hostPorts[deployment.TaskDefinition]
 			}
 		}
+		for i := rs.RunningCount; i < rs.DesiredCount; i++ {
+			pendingTasks = append(pendingTasks, ClusterSnapshotTask{ServiceName: rs.ServiceName, Cpu: cpu, Memory: memory, Ports: ports})
+		}
 	}
-	asStrategyLargestContainerUp, _ := c.getAutoscalingStrategy()
-	if !asStrategyLargestContainerUp { // if we're not using the LargestContainerUp strategy, scale down only when there's a full instance size of extra resources
-		clusterMemoryNeeded = instanceMemory
-		clusterCpuNeeded = instanceCpu
-	}
-	for k, _ := range totalFreeCpu {
-		asAutoscalingControllerLogger.Debugf("%v: Have %d cpu available, need %d", k, totalFreeCpu[k], clusterCpuNeeded)
-		asAutoscalingControllerLogger.Debugf("%v: Have %d memory available, need %d", k, totalFreeMemory[k], clusterMemoryNeeded)
-		if totalFreeCpu[k] >= clusterCpuNeeded && totalFreeMemory[k] >= clusterMemoryNeeded {
-			hasFreeResources[k] = true
-		} else {
-			// set hasFreeResources[k] in case the map key hasn't been set to true
-			if _, ok := hasFreeResources[k]; !ok {
-				hasFreeResources[k] = false
+	return pendingTasks
+}
+
+// getRunningTasks returns the running tasks with the instance they run on, the reservations of the service and their host ports
+func getRunningTasks(clusterName string, dss []*service.DynamoServicesElement, tasks clusterTasks) []ClusterSnapshotTask {
+	var runningTasks []ClusterSnapshotTask
+	for _, rs := range tasks.services {
+		cpu, memory, ok := getServiceReservation(clusterName, rs.ServiceName, dss)
+		if !ok {
+			continue
+		}
+		for _, task := range rs.Tasks {
+			instanceId, ok := tasks.instanceIds[task.ContainerInstanceArn]
+			if !ok {
+				continue
 			}
+			runningTasks = append(runningTasks, ClusterSnapshotTask{
+				ServiceName:         rs.ServiceName,
+				ContainerInstanceId: instanceId,
+				Cpu:                 cpu,
+				Memory:              memory,
+				Ports:               tasks.hostPorts[task.TaskDefinitionArn],
+			})
 		}
 	}
-	if asStrategyLargestContainerUp {
-		// when using LargestContainerUp, only downscale when all AZs have too much capacity, otherwise a scaleUp will immediately be triggered
-		for k, v := range hasFreeResources {
-			asAutoscalingControllerLogger.Debugf("%v has free resources: %v", k, v)
-			if !v {
-				hasFreeResourcesGlobal = false
-			}
+	return runningTasks
+}
+
+// ScaleSimulation explains the decisions of a strategy for a cluster snapshot
+type ScaleSimulation struct {
+	Strategy  string          `json:"strategy"`
+	Snapshot  ClusterSnapshot `json:"snapshot"`
+	ScaleUp   ScaleDecision   `json:"scaleUp"`
+	ScaleDown ScaleDecision   `json:"scaleDown"`
+}
+
+// simulateScaleStrategy runs the strategy on the snapshot, or on the live state of the cluster when no snapshot is given
+func (c *AutoscalingController) simulateScaleStrategy(clusterName, strategyName string, snapshot *ClusterSnapshot) (ScaleSimulation, error) {
+	var simulation ScaleSimulation
+	var strategy ScaleStrategy
+	var err error
+	if strategyName == "" {
+		strategy, err = c.getScaleStrategy(clusterName)
+	} else {
+		strategy, err = c.newScaleStrategy(strategyName)
+	}
+	if err != nil {
+		return simulation, err
+	}
+	if snapshot == nil {
+		liveSnapshot, err := c.getLiveClusterSnapshot(strategy, clusterName)
+		if err != nil {
+			return simulation, err
 		}
+		snapshot = &liveSnapshot
 	} else {
-		// when not using the strategy LargestContainerUp, set hasFreeResourcesGlobal to true if any of the nodes has too many resources
-		foundTrue := false
-		for k, v := range hasFreeResources {
-			asAutoscalingControllerLogger.Debugf("%v has free resources: %v", k, v)
-			if v {
-				foundTrue = true
+		snapshot.ClusterName = clusterName
+		if snapshot.Time.IsZero() {
+			snapshot.Time = time.Now()
+		}
+	}
+	simulation.Strategy = strategy.Name()
+	simulation.Snapshot = *snapshot
+	simulation.ScaleUp = strategy.ScaleUp(*snapshot)
+	simulation.ScaleDown = strategy.ScaleDown(*snapshot)
+	return simulation, nil
+}
+
+func (c *AutoscalingController) getLiveClusterSnapshot(strategy ScaleStrategy, clusterName string) (ClusterSnapshot, error) {
+	s := service.NewService()
+	e := &ecs.ECS{}
+	cc := &Controller{}
+	memoryNeeded, cpuNeeded, err := c.getResourcesNeeded(clusterName, cc)
+	if err != nil {
+		return ClusterSnapshot{}, err
+	}
+	dc, err := c.getClusterInfoWithCache(clusterName, s, e)
+	if err != nil {
+		return ClusterSnapshot{}, err
+	}
+	_, rirs, err := e.GetInstanceResources(clusterName)
+	if err != nil {
+		return ClusterSnapshot{}, err
+	}
+	if len(rirs) == 0 {
+		return ClusterSnapshot{}, errors.New("Couldn't retrieve any EC2 Container instances")
+	}
+	registeredInstanceCpu, registeredInstanceMemory := getLargestRegisteredResources(rirs)
+	return c.getClusterSnapshot(strategy, clusterName, dc.ContainerInstances, registeredInstanceCpu, registeredInstanceMemory, cpuNeeded, memoryNeeded, cc), nil
+}
+
+// getLargestRegisteredResources returns the registered cpu and memory of the largest instance
+func getLargestRegisteredResources(rirs []ecs.RegisteredInstanceResource) (int64, int64) {
+	var cpu, memory int64
+	for _, v := range rirs {
+		if v.RegisteredCpu > cpu {
+			cpu = v.RegisteredCpu
+		}
+		if v.RegisteredMemory > memory {
+			memory = v.RegisteredMemory
+		}
+	}
+	return cpu, memory
+}
+
+func (c *AutoscalingController) logScaleDecision(strategy ScaleStrategy, scalingOp string, decision ScaleDecision) {
+	asAutoscalingControllerLogger.Debugf("Strategy %v: scale %v: %v", strategy.Name(), scalingOp, decision.Scale)
+	for _, reason := range decision.Reasons {
+		asAutoscalingControllerLogger.Debugf("Strategy %v: %v", strategy.Name(), reason)
+	}
+}
+
+// setContainerInstanceDraining writes a new record to switch the container instance to draining
+func (c *AutoscalingController) setContainerInstanceDraining(clusterName, instanceId string, s service.ServiceIf) error {
+	dc, err := s.GetClusterInfo()
+	if err != nil {
+		return err
+	}
+	var writeRecord bool
+	if dc != nil {
+		for i, dcci := range dc.ContainerInstances {
+			if clusterName == dcci.ClusterName && instanceId == dcci.ContainerInstanceId {
+				dc.ContainerInstances[i].Status = "DRAINING"
+				writeRecord = true
 			}
 		}
-		if !foundTrue {
-			hasFreeResourcesGlobal = false
+	}
+	if writeRecord {
+		s.PutClusterInfo(*dc, clusterName, "no", "")
+	}
+	return nil
+}
+
+// spot instances with an interruption notice or rebalance recommendation that have been handled
+var spotInterruptions = struct {
+	sync.Mutex
+	instances map[string]time.Time
+}{instances: make(map[string]time.Time)}
+
+// isNewSpotInterruption returns false when the instance has already been handled (a rebalance recommendation is often followed by an interruption notice)
+func (c *AutoscalingController) isNewSpotInterruption(instanceId string, now time.Time) bool {
+	spotInterruptions.Lock()
+	defer spotInterruptions.Unlock()
+	for k, v := range spotInterruptions.instances {
+		if v.Before(now.Add(-1 * time.Hour)) {
+			delete(spotInterruptions.instances, k)
 		}
 	}
+	if _, ok := spotInterruptions.instances[instanceId]; ok {
+		return false
+	}
+	spotInterruptions.instances[instanceId] = now
+	return true
+}
 
-	return hasFreeResourcesGlobal
+// forgetSpotInterruption removes the instance from the handled spot interruptions
+func (c *AutoscalingController) forgetSpotInterruption(instanceId string) {
+	spotInterruptions.Lock()
+	defer spotInterruptions.Unlock()
+	delete(spotInterruptions.instances, instanceId)
 }
-func (c *AutoscalingController) processLifecycleMessage(message ecs.SNSPayloadLifecycle) error {
+
+// drainSpotInstance drains the container instance of a spot instance and returns the cluster name
+func (c *AutoscalingController) drainSpotInstance(instanceId, detailType string) (string, error) {
 	e := ecs.ECS{}
-	clusterName, err := e.GetClusterNameByInstanceId(message.Detail.EC2InstanceId)
+	clusterName, err := e.GetClusterNameByInstanceId(instanceId)
 	if err != nil {
+		return "", err
+	}
+	containerInstanceArn, err := e.GetContainerInstanceArnByInstanceId(clusterName, instanceId)
+	if err != nil {
+		return "", err
+	}
+	asAutoscalingControllerLogger.Infof("%v for %v (cluster: %v): draining instance", detailType, instanceId, clusterName)
+	err = e.DrainNode(clusterName, containerInstanceArn)
+	if err != nil {
+		return "", err
+	}
+	err = c.setContainerInstanceDraining(clusterName, instanceId, service.NewService())
+	if err != nil {
+		return "", err
+	}
+	return clusterName, nil
+}
+
+// processSpotInterruptionMessage drains a spot instance that is about to be interrupted and scales up to have replacement capacity within the 2 minute window
+func (c *AutoscalingController) processSpotInterruptionMessage(message ecs.SNSPayloadSpotInterruption) error {
+	instanceId := message.Detail.InstanceId
+	if instanceId == "" {
+		return errors.New("Could not determine instance id from " + message.DetailType + " message")
+	}
+	if !c.isNewSpotInterruption(instanceId, time.Now()) {
+		asAutoscalingControllerLogger.Debugf("Spot interruption of %v already handled, ignoring %v", instanceId, message.DetailType)
+		return nil
+	}
+	clusterName, err := c.drainSpotInstance(instanceId, message.DetailType)
+	if err != nil {
+		// the next notice for the instance retries the drain
+		c.forgetSpotInterruption(instanceId)
 		return err
 	}
-	containerInstanceArn, err := e.GetContainerInstanceArnByInstanceId(clusterName, message.Detail.EC2InstanceId)
+	// clusters with managed scaling get replacement capacity from the capacity provider
+	if c.isManagedScalingEnabled(clusterName) {
+		return nil
+	}
+	autoscaling := ecs.AutoScaling{}
+	autoScalingGroupName, err := autoscaling.GetAutoScalingGroupByTag(clusterName)
 	if err != nil {
 		return err
 	}
-	err = e.DrainNode(clusterName, containerInstanceArn)
+	minSize, desiredCapacity, maxSize, err := autoscaling.GetClusterNodeDesiredCount(autoScalingGroupName)
 	if err != nil {
 		return err
 	}
 	s := service.NewService()
+	scalingDecision := service.ScalingDecision{
+		ClusterName:     clusterName,
+		Time:            time.Now(),
+		Trigger:         message.DetailType + " (" + instanceId + ")",
+		ScalingOp:       "up",
+		Scale:           true,
+		Reasons:         []string{"replacement capacity for spot instance " + instanceId},
+		MinSize:         minSize,
+		DesiredCapacity: desiredCapacity,
+		MaxSize:         maxSize,
+	}
+	if desiredCapacity >= maxSize {
+		asAutoscalingControllerLogger.Infof("Scaling operation: not scaling up for spot interruption of %v, autoscaling group %v is at maximum capacity", instanceId, autoScalingGroupName)
+		c.recordScalingDecision(s, scalingDecision, "skipped (at maximum capacity)")
+		return nil
+	}
+	asAutoscalingControllerLogger.Infof("Scaling operation: scaling up now to replace spot instance %v", instanceId)
+	err = autoscaling.ScaleClusterNodes(autoScalingGroupName, 1)
+	if err != nil {
+		c.recordScalingDecision(s, scalingDecision, "error: "+err.Error())
+		return err
+	}
+	c.recordScalingDecision(s, scalingDecision, "scaled")
+	// register the scaling activity, so the cooldown period applies
 	dc, err := s.GetClusterInfo()
 	if err != nil {
 		return err
 	}
-	// write new record to switch container instance to draining
-	var writeRecord bool
 	if dc != nil {
-		for i, dcci := range dc.ContainerInstances {
-			if clusterName == dcci.ClusterName && message.Detail.EC2InstanceId == dcci.ContainerInstanceId {
-				dc.ContainerInstances[i].Status = "DRAINING"
-				writeRecord = true
-			}
+		_, err = s.PutClusterInfo(*dc, clusterName, "up", "")
+		if err != nil {
+			return err
 		}
 	}
-	if writeRecord {
-		s.PutClusterInfo(*dc, clusterName, "no", "")
+	return nil
+}
+
+func (c *AutoscalingController) processLifecycleMessage(message ecs.SNSPayloadLifecycle) error {
+	e := ecs.ECS{}
+	clusterName, err := e.GetClusterNameByInstanceId(message.Detail.EC2InstanceId)
+	if err != nil {
+		return err
+	}
+	containerInstanceArn, err := e.GetContainerInstanceArnByInstanceId(clusterName, message.Detail.EC2InstanceId)
+	if err != nil {
+		return err
+	}
+	err = e.DrainNode(clusterName, containerInstanceArn)
+	if err != nil {
+		return err
+	}
+	err = c.setContainerInstanceDraining(clusterName, message.Detail.EC2InstanceId, service.NewService())
+	if err != nil {
+		return err
 	}
 	// monitor drained node
 	go e.LaunchWaitForDrainedNode(clusterName, containerInstanceArn, message.Detail.EC2InstanceId, message.Detail.AutoScalingGroupName, message.Detail.LifecycleHookName, message.Detail.LifecycleActionToken)
@@ -584,6 +868,9 @@ func (c *AutoscalingController) startAutoscalingPollingStrategy() {
 				services[ds.C] = append(services[ds.C], &ds.S)
 			}
 			for clusterName, serviceList := range services {
+				if c.isManagedScalingEnabled(clusterName) {
+					continue
+				}
 				rss, err := e.DescribeServicesWithOptions(clusterName, serviceList, showEvents, showTasks, showStoppedTasks, map[string]string{"sleep": "1"})
 				if err != nil {
 					asAutoscalingControllerLogger.Errorf("Error occured during describe services: %v", err)
@@ -639,15 +926,28 @@ func (c *AutoscalingController) scaleWhenUnschedulableMessage(clusterName, messa
 	if strings.Contains(message, "was unable to place a task because no container instance met all of its requirements") && strings.Contains(message, "has insufficient") {
 		autoscaling := ecs.AutoScaling{}
 		asAutoscalingControllerLogger.Infof("Scaling operation: scaling up now")
+		scalingDecision := service.ScalingDecision{
+			ClusterName: clusterName,
+			Time:        time.Now(),
+			Trigger:     "polling: unschedulable service",
+			ScalingOp:   "up",
+			Strategy:    "polling",
+			Scale:       true,
+			Reasons:     []string{message},
+		}
+		outcome := "scaled"
 		autoScalingGroupName, err := autoscaling.GetAutoScalingGroupByTag(clusterName)
 		if err != nil {
 			asAutoscalingControllerLogger.Errorf("Error: %v", err)
+			outcome = "error: " + err.Error()
 		} else {
 			err = autoscaling.ScaleClusterNodes(autoScalingGroupName, 1)
 			if err != nil {
 				asAutoscalingControllerLogger.Errorf("Error: %v", err)
+				outcome = "error: " + err.Error()
 			}
 		}
+		c.recordScalingDecision(service.NewService(), scalingDecision, outcome)
 		return true
 	}
 	return false
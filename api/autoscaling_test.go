@@ -1,7 +1,7 @@
 package api
 
 import (
-	"os"
+	"encoding/json"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -23,6 +23,7 @@ type MockService struct {
 	IsDeployRunningOutput bool
 	PutClusterInfoOutput  *service.DynamoCluster
 	PutClusterInfoCounter uint64
+	ScalingDecisions      []service.ScalingDecision
 	service.ServiceIf
 }
 
@@ -57,6 +58,11 @@ func (m *MockService) IsDeployRunning() (bool, error) {
 	return m.IsDeployRunningOutput, nil
 }
 
+func (m *MockService) PutScalingDecision(decision service.ScalingDecision) error {
+	m.ScalingDecisions = append(m.ScalingDecisions, decision)
+	return nil
+}
+
 func TestAreAllTasksRunningInCluster(t *testing.T) {
 	mc1 := &MockController{
 		runningServices: []service.RunningService{
@@ -102,8 +108,9 @@ func TestAreAllTasksRunningInCluster(t *testing.T) {
 
 func TestLaunchProcessPendingScalingOpWithLocking(t *testing.T) {
 	// configuration
-	os.Setenv("AUTOSCALING_DOWN_PERIOD", "2")
-	os.Setenv("AUTOSCALING_DOWN_INTERVAL", "1")
+	config := NewConfig()
+	config.Autoscaling.DownPeriod = 2
+	config.Autoscaling.DownInterval = 1
 	asAutoscalingControllerLogger.SetLogLevel(loggo.DEBUG)
 	// mock
 	am := &MockAutoScaling{
@@ -167,7 +174,7 @@ func TestLaunchProcessPendingScalingOpWithLocking(t *testing.T) {
 		},
 	}
 	// test
-	as := AutoscalingController{}
+	as := AutoscalingController{config: config}
 	clusterName := "testCluster"
 	pendingScalingOp := "down"
 	registeredInstanceCpu := int64(1024)
@@ -205,6 +212,16 @@ func TestLaunchProcessPendingScalingOpWithLocking(t *testing.T) {
 	if s.GetClusterInfoCounter != 3 {
 		t.Errorf("GetClusterInfoCounter is %d (expected 3)", s.GetClusterInfoCounter)
 	}
+	// the first operation scales down, the second one is aborted
+	if len(s.ScalingDecisions) != 2 {
+		t.Fatalf("Expected 2 scaling decisions, got %d", len(s.ScalingDecisions))
+	}
+	if s.ScalingDecisions[0].Outcome != "scaled" || s.ScalingDecisions[0].ScalingOp != "down" || s.ScalingDecisions[0].Strategy != "largestcontainer" {
+		t.Errorf("Unexpected scaling decision: %+v", s.ScalingDecisions[0])
+	}
+	if s.ScalingDecisions[1].Outcome != "aborted (scaling operation not pending anymore)" {
+		t.Errorf("Unexpected outcome of the second scaling decision: %v", s.ScalingDecisions[1].Outcome)
+	}
 }
 
 func TestGetClusterInfoWithExpiredCache(t *testing.T) {
@@ -276,3 +293,49 @@ func TestGetClusterInfoWithExpiredCache(t *testing.T) {
 		t.Errorf("wrong container instance returned")
 	}
 }
+
+func TestIsManagedScalingEnabledWithCache(t *testing.T) {
+	asc := AutoscalingController{}
+	managedScalingCache.Lock()
+	managedScalingCache.clusters["managed-cluster"] = managedScalingCacheItem{enabled: true, expires: time.Now().Add(1 * time.Minute)}
+	managedScalingCache.Unlock()
+	if !asc.isManagedScalingEnabled("managed-cluster") {
+		t.Errorf("Expected managed scaling to be enabled for managed-cluster (cached)")
+	}
+}
+
+func TestIsNewSpotInterruption(t *testing.T) {
+	asc := AutoscalingController{}
+	var message ecs.SNSPayloadSpotInterruption
+	err := json.Unmarshal([]byte(`{"version":"0","id":"1","detail-type":"EC2 Spot Instance Interruption Warning","source":"aws.ec2","detail":{"instance-id":"i-1234567890abcdef0","instance-action":"terminate"}}`), &message)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if message.Detail.InstanceId != "i-1234567890abcdef0" || message.Detail.InstanceAction != "terminate" {
+		t.Errorf("Unexpected detail: %+v", message.Detail)
+	}
+	now := time.Now()
+	if !asc.isNewSpotInterruption(message.Detail.InstanceId, now) {
+		t.Errorf("Expected new spot interruption")
+	}
+	// interruption notice after a rebalance recommendation
+	if asc.isNewSpotInterruption(message.Detail.InstanceId, now.Add(1*time.Minute)) {
+		t.Errorf("Expected spot interruption to be handled already")
+	}
+	if !asc.isNewSpotInterruption(message.Detail.InstanceId, now.Add(2*time.Hour)) {
+		t.Errorf("Expected handled spot interruption to expire")
+	}
+}
+
+func TestForgetSpotInterruption(t *testing.T) {
+	asc := AutoscalingController{}
+	now := time.Now()
+	if !asc.isNewSpotInterruption("i-forget", now) {
+		t.Errorf("Expected new spot interruption")
+	}
+	// the drain failed, the next notice is handled again
+	asc.forgetSpotInterruption("i-forget")
+	if !asc.isNewSpotInterruption("i-forget", now.Add(1*time.Minute)) {
+		t.Errorf("Expected spot interruption to be handled again after a failed drain")
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/in4it/ecs-deploy/provider/ecs"
+	"github.com/in4it/ecs-deploy/service"
+)
+
+// getClusterCapacityReport returns the capacity and utilisation of a cluster, with the reservations of the services in the registry
+func (c *Controller) getClusterCapacityReport(clusterName string) (service.ClusterCapacityReport, error) {
+	e := ecs.ECS{}
+	firs, rirs, err := e.GetInstanceResources(clusterName)
+	if err != nil {
+		return service.ClusterCapacityReport{}, err
+	}
+	dss, err := c.getServices()
+	if err != nil {
+		return service.ClusterCapacityReport{}, err
+	}
+	var services []service.DynamoServicesElement
+	var serviceNames []*string
+	for _, ds := range dss {
+		if ds.C == clusterName {
+			services = append(services, *ds)
+			serviceNames = append(serviceNames, &ds.S)
+		}
+	}
+	runningCounts := make(map[string]int64)
+	if len(serviceNames) > 0 {
+		rss, err := e.DescribeServices(clusterName, serviceNames, false, false, false)
+		if err != nil {
+			return service.ClusterCapacityReport{}, err
+		}
+		for _, rs := range rss {
+			runningCounts[rs.ServiceName] = rs.RunningCount
+		}
+	}
+	return buildClusterCapacityReport(clusterName, firs, rirs, services, runningCounts, time.Now()), nil
+}
+
+// buildClusterCapacityReport calculates the report, only active instances are taken into account for the resources
+func buildClusterCapacityReport(clusterName string, firs []ecs.FreeInstanceResource, rirs []ecs.RegisteredInstanceResource, services []service.DynamoServicesElement, runningCounts map[string]int64, now time.Time) service.ClusterCapacityReport {
+	report := service.ClusterCapacityReport{
+		ClusterName:       clusterName,
+		AvailabilityZones: []service.ClusterCapacityZone{},
+		Services:          []service.ClusterCapacityService{},
+		GeneratedAt:       now,
+	}
+	registered := make(map[string]ecs.RegisteredInstanceResource)
+	for _, rir := range rirs {
+		registered[rir.InstanceId] = rir
+	}
+	// largest task, the same way the cluster autoscaler determines it
+	for _, s := range services {
+		if s.CpuReservation > report.LargestTask.Cpu {
+			report.LargestTask.Cpu = s.CpuReservation
+		}
+		if s.MemoryReservation > report.LargestTask.Memory {
+			report.LargestTask.Memory = s.MemoryReservation
+		}
+	}
+	zones := make(map[string]*service.ClusterCapacityZone)
+	var maxFreeCpu, maxFreeMemory int64
+	for _, fir := range firs {
+		if fir.Status != "ACTIVE" {
+			report.DrainingInstances++
+			continue
+		}
+		rir := registered[fir.InstanceId]
+		report.Instances++
+		report.RegisteredCpu += rir.RegisteredCpu
+		report.RegisteredMemory += rir.RegisteredMemory
+		report.FreeCpu += fir.FreeCpu
+		report.FreeMemory += fir.FreeMemory
+		if _, ok := zones[fir.AvailabilityZone]; !ok {
+			zones[fir.AvailabilityZone] = &service.ClusterCapacityZone{AvailabilityZone: fir.AvailabilityZone}
+		}
+		zone := zones[fir.AvailabilityZone]
+		zone.Instances++
+		zone.RegisteredCpu += rir.RegisteredCpu
+		zone.RegisteredMemory += rir.RegisteredMemory
+		zone.FreeCpu += fir.FreeCpu
+		zone.FreeMemory += fir.FreeMemory
+		if fir.FreeCpu > maxFreeCpu {
+			maxFreeCpu = fir.FreeCpu
+		}
+		if fir.FreeMemory > maxFreeMemory {
+			maxFreeMemory = fir.FreeMemory
+		}
+		report.LargestTask.AdditionalCopies += getTaskCopies(fir.FreeCpu, fir.FreeMemory, report.LargestTask.Cpu, report.LargestTask.Memory)
+	}
+	report.CpuUtilisation = getPercentage(report.RegisteredCpu-report.FreeCpu, report.RegisteredCpu)
+	report.MemoryUtilisation = getPercentage(report.RegisteredMemory-report.FreeMemory, report.RegisteredMemory)
+	report.Fragmentation.Cpu = getPercentage(report.FreeCpu-maxFreeCpu, report.FreeCpu)
+	report.Fragmentation.Memory = getPercentage(report.FreeMemory-maxFreeMemory, report.FreeMemory)
+	for _, zone := range zones {
+		report.AvailabilityZones = append(report.AvailabilityZones, *zone)
+	}
+	sort.Slice(report.AvailabilityZones, func(i, j int) bool {
+		return report.AvailabilityZones[i].AvailabilityZone < report.AvailabilityZones[j].AvailabilityZone
+	})
+	for _, s := range services {
+		cs := service.ClusterCapacityService{
+			ServiceName:       s.S,
+			RunningCount:      runningCounts[s.S],
+			CpuReservation:    s.CpuReservation,
+			MemoryReservation: s.MemoryReservation,
+			ReservedCpu:       s.CpuReservation * runningCounts[s.S],
+			ReservedMemory:    s.MemoryReservation * runningCounts[s.S],
+		}
+		cs.CpuShare = getPercentage(cs.ReservedCpu, report.RegisteredCpu)
+		cs.MemoryShare = getPercentage(cs.ReservedMemory, report.RegisteredMemory)
+		report.Services = append(report.Services, cs)
+	}
+	// biggest consumers first
+	sort.SliceStable(report.Services, func(i, j int) bool {
+		return report.Services[i].ReservedMemory > report.Services[j].ReservedMemory
+	})
+	return report
+}
+
+// getTaskCopies returns how many tasks fit in the free resources of an instance
+func getTaskCopies(freeCpu, freeMemory, cpu, memory int64) int64 {
+	if cpu == 0 && memory == 0 {
+		return 0
+	}
+	copies := int64(math.MaxInt64)
+	if cpu > 0 {
+		copies = freeCpu / cpu
+	}
+	if memory > 0 && freeMemory/memory < copies {
+		copies = freeMemory / memory
+	}
+	return copies
+}
+
+// getPercentage returns the percentage rounded to 1 decimal
+func getPercentage(value, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(value)/float64(total)*1000) / 10
+}
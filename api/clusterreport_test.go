@@ -0,0 +1,61 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/in4it/ecs-deploy/provider/ecs"
+	"github.com/in4it/ecs-deploy/service"
+)
+
+func TestBuildClusterCapacityReport(t *testing.T) {
+	firs := []ecs.FreeInstanceResource{
+		{InstanceId: "i-1", AvailabilityZone: "eu-west-1a", Status: "ACTIVE", FreeCpu: 512, FreeMemory: 1024},
+		{InstanceId: "i-2", AvailabilityZone: "eu-west-1b", Status: "ACTIVE", FreeCpu: 1536, FreeMemory: 3072},
+		{InstanceId: "i-3", AvailabilityZone: "eu-west-1b", Status: "DRAINING", FreeCpu: 2048, FreeMemory: 4096},
+	}
+	rirs := []ecs.RegisteredInstanceResource{
+		{InstanceId: "i-1", RegisteredCpu: 2048, RegisteredMemory: 4096},
+		{InstanceId: "i-2", RegisteredCpu: 2048, RegisteredMemory: 4096},
+		{InstanceId: "i-3", RegisteredCpu: 2048, RegisteredMemory: 4096},
+	}
+	services := []service.DynamoServicesElement{
+		{C: "testCluster", S: "web", CpuReservation: 256, MemoryReservation: 512},
+		{C: "testCluster", S: "worker", CpuReservation: 512, MemoryReservation: 1024},
+	}
+	runningCounts := map[string]int64{"web": 2, "worker": 3}
+	report := buildClusterCapacityReport("testCluster", firs, rirs, services, runningCounts, time.Now())
+	if report.Instances != 2 || report.DrainingInstances != 1 {
+		t.Errorf("Unexpected instances: %d active, %d draining", report.Instances, report.DrainingInstances)
+	}
+	if report.RegisteredCpu != 4096 || report.FreeCpu != 2048 || report.CpuUtilisation != 50 {
+		t.Errorf("Unexpected cpu: %d registered, %d free, %v%%", report.RegisteredCpu, report.FreeCpu, report.CpuUtilisation)
+	}
+	if len(report.AvailabilityZones) != 2 || report.AvailabilityZones[1].Instances != 1 || report.AvailabilityZones[1].FreeMemory != 3072 {
+		t.Errorf("Unexpected availability zones: %+v", report.AvailabilityZones)
+	}
+	// worker reserves 1536 cpu of 4096
+	if report.Services[0].ServiceName != "worker" || report.Services[0].ReservedCpu != 1536 || report.Services[0].CpuShare != 37.5 {
+		t.Errorf("Unexpected services: %+v", report.Services)
+	}
+	// 512 of 2048 free cpu isn't on i-2
+	if report.Fragmentation.Cpu != 25 || report.Fragmentation.Memory != 25 {
+		t.Errorf("Unexpected fragmentation: %+v", report.Fragmentation)
+	}
+	// 1 copy on i-1, 3 copies on i-2
+	if report.LargestTask.Cpu != 512 || report.LargestTask.Memory != 1024 || report.LargestTask.AdditionalCopies != 4 {
+		t.Errorf("Unexpected largest task: %+v", report.LargestTask)
+	}
+}
+
+func TestGetTaskCopies(t *testing.T) {
+	if copies := getTaskCopies(1024, 1024, 256, 512); copies != 2 {
+		t.Errorf("Expected memory to limit the copies to 2, got %d", copies)
+	}
+	if copies := getTaskCopies(1024, 4096, 0, 512); copies != 8 {
+		t.Errorf("Expected 8 copies without cpu reservation, got %d", copies)
+	}
+	if copies := getTaskCopies(1024, 4096, 0, 0); copies != 0 {
+		t.Errorf("Expected 0 copies without reservations, got %d", copies)
+	}
+}
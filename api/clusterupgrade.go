@@ -0,0 +1,401 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/in4it/ecs-deploy/provider/ecs"
+	"github.com/in4it/ecs-deploy/service"
+	"github.com/juju/loggo"
+)
+
+// logging
+var clusterUpgradeLogger = loggo.GetLogger("cluster-upgrade")
+
+var errClusterUpgradeCancelled = errors.New("cluster upgrade has been cancelled")
+
+// interval to check whether the replacement instance registered with the cluster
+var clusterUpgradePollInterval = 15 * time.Second
+
+// startClusterUpgrade starts a rolling upgrade of the container instances of a cluster to the latest ECS AMI
+func (c *Controller) startClusterUpgrade(clusterName, user string) (*service.ClusterUpgrade, error) {
+	s := service.NewService()
+	e := ecs.ECS{}
+	ec2 := ecs.EC2{}
+	autoscaling := ecs.AutoScaling{}
+	current, err := s.GetClusterUpgrade(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && current.Status == "running" {
+		return nil, fmt.Errorf("an upgrade of cluster %v is already running", clusterName)
+	}
+	amiId, err := e.GetECSAMI()
+	if err != nil {
+		return nil, err
+	}
+	cis, err := c.getClusterContainerInstances(clusterName, &e)
+	if err != nil {
+		return nil, err
+	}
+	var instanceIds []string
+	for _, ci := range cis {
+		instanceIds = append(instanceIds, ci.Ec2InstanceId)
+	}
+	imageIds := make(map[string]string)
+	if len(instanceIds) > 0 {
+		imageIds, err = ec2.GetInstanceImageIds(instanceIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+	upgrade := service.ClusterUpgrade{
+		ClusterName: clusterName,
+		AmiId:       amiId,
+		Status:      "running",
+		Instances:   getClusterUpgradeInstances(cis, imageIds, amiId),
+		// replacement instances are counted on top of the instances already running the AMI
+		InitialUpgradedInstances: countClusterInstancesWithAmi(cis, imageIds, amiId),
+		StartedBy:                user,
+		StartedAt:                time.Now(),
+	}
+	if len(upgrade.Instances) == 0 {
+		clusterUpgradeLogger.Infof("All container instances of %v are running %v", clusterName, amiId)
+		upgrade.Status = "completed"
+		return &upgrade, s.PutClusterUpgrade(upgrade)
+	}
+	// new instances are launched with the new AMI
+	autoScalingGroupName, err := autoscaling.GetAutoScalingGroupByTag(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	launchTemplateName, launchTemplateVersion, err := autoscaling.GetAutoScalingGroupLaunchTemplate(autoScalingGroupName)
+	if err != nil {
+		return nil, err
+	}
+	if launchTemplateVersion != "$Latest" && launchTemplateVersion != "$Default" {
+		return nil, fmt.Errorf("autoscaling group %v uses version %v of launch template %v (only $Latest or $Default can be upgraded)", autoScalingGroupName, launchTemplateVersion, launchTemplateName)
+	}
+	version, err := ec2.CreateLaunchTemplateVersion(launchTemplateName, amiId)
+	if err != nil {
+		return nil, err
+	}
+	if launchTemplateVersion == "$Default" {
+		if err = ec2.SetDefaultLaunchTemplateVersion(launchTemplateName, version); err != nil {
+			return nil, err
+		}
+	}
+	if err = s.PutClusterUpgrade(upgrade); err != nil {
+		return nil, err
+	}
+	clusterUpgradeLogger.Infof("Upgrading %d container instances of %v to %v (launch template %v version %d)", len(upgrade.Instances), clusterName, amiId, launchTemplateName, version)
+	go c.runClusterUpgrade(upgrade, s, &e, &ec2, &autoscaling)
+	return &upgrade, nil
+}
+
+// getClusterUpgradeInstances returns the active container instances that are not running the AMI, oldest first
+func getClusterUpgradeInstances(cis []ecs.ContainerInstance, imageIds map[string]string, amiId string) []service.ClusterUpgradeInstance {
+	var instances []service.ClusterUpgradeInstance
+	sort.SliceStable(cis, func(i, j int) bool {
+		return cis[i].RegisteredAt.Before(cis[j].RegisteredAt)
+	})
+	for _, ci := range cis {
+		if ci.Status != "ACTIVE" || imageIds[ci.Ec2InstanceId] == amiId {
+			continue
+		}
+		instances = append(instances, service.ClusterUpgradeInstance{
+			InstanceId: ci.Ec2InstanceId,
+			AmiId:      imageIds[ci.Ec2InstanceId],
+			Status:     "pending",
+		})
+	}
+	return instances
+}
+
+// countClusterInstancesWithAmi returns the number of active container instances running the AMI
+func countClusterInstancesWithAmi(cis []ecs.ContainerInstance, imageIds map[string]string, amiId string) int {
+	count := 0
+	for _, ci := range cis {
+		if ci.Status == "ACTIVE" && imageIds[ci.Ec2InstanceId] == amiId {
+			count++
+		}
+	}
+	return count
+}
+
+// getClusterContainerInstances returns all container instances of a cluster
+func (c *Controller) getClusterContainerInstances(clusterName string, e ecs.ECSIf) ([]ecs.ContainerInstance, error) {
+	ciArns, err := e.ListContainerInstances(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciArns) == 0 {
+		return []ecs.ContainerInstance{}, nil
+	}
+	return e.DescribeContainerInstances(clusterName, ciArns)
+}
+
+// runClusterUpgrade replaces the instances one by one, the state is saved after every step to be able to resume
+func (c *Controller) runClusterUpgrade(upgrade service.ClusterUpgrade, s service.ServiceIf, e ecs.ECSIf, ec2 ecs.EC2If, autoscaling ecs.AutoScalingIf) {
+	for i := range upgrade.Instances {
+		if upgrade.Instances[i].Status == "terminated" {
+			continue
+		}
+		err := c.upgradeClusterInstance(&upgrade, i, s, e, ec2, autoscaling)
+		if err == errClusterUpgradeCancelled {
+			clusterUpgradeLogger.Infof("Upgrade of cluster %v has been cancelled", upgrade.ClusterName)
+			return
+		}
+		if err != nil {
+			clusterUpgradeLogger.Errorf("Upgrade of cluster %v failed: %v", upgrade.ClusterName, err)
+			upgrade.Status = "failed"
+			upgrade.Error = err.Error()
+			c.putClusterUpgrade(s, upgrade)
+			return
+		}
+	}
+	upgrade.Status = "completed"
+	if err := c.putClusterUpgrade(s, upgrade); err != nil && err != errClusterUpgradeCancelled {
+		clusterUpgradeLogger.Errorf("Could not save upgrade of cluster %v: %v", upgrade.ClusterName, err)
+		return
+	}
+	clusterUpgradeLogger.Infof("Upgrade of cluster %v to %v completed", upgrade.ClusterName, upgrade.AmiId)
+}
+
+// upgradeClusterInstance replaces a single instance: scale up, drain, wait for the services and terminate
+func (c *Controller) upgradeClusterInstance(upgrade *service.ClusterUpgrade, i int, s service.ServiceIf, e ecs.ECSIf, ec2 ecs.EC2If, autoscaling ecs.AutoScalingIf) error {
+	asc := AutoscalingController{}
+	instance := &upgrade.Instances[i]
+	autoScalingGroupName, err := autoscaling.GetAutoScalingGroupByTag(upgrade.ClusterName)
+	if err != nil {
+		return err
+	}
+	if instance.Status == "pending" {
+		_, desired, max, err := autoscaling.GetClusterNodeDesiredCount(autoScalingGroupName)
+		if err != nil {
+			return err
+		}
+		// without room in the autoscaling group the instance is replaced after termination
+		// the scale up is saved first, so that it doesn't happen twice when resuming
+		if desired < max {
+			instance.ScaledUp = true
+			instance.DesiredCount = desired
+		}
+		instance.Status = "scaling"
+		if err := c.putClusterUpgrade(s, *upgrade); err != nil {
+			return err
+		}
+	}
+	if instance.Status == "scaling" {
+		if instance.ScaledUp {
+			_, desired, _, err := autoscaling.GetClusterNodeDesiredCount(autoScalingGroupName)
+			if err != nil {
+				return err
+			}
+			if desired <= instance.DesiredCount {
+				if err := autoscaling.ScaleClusterNodes(autoScalingGroupName, 1); err != nil {
+					return err
+				}
+			}
+			clusterUpgradeLogger.Infof("Waiting for the replacement of %v in cluster %v", instance.InstanceId, upgrade.ClusterName)
+			if err := c.waitForReplacementInstance(upgrade, e, ec2); err != nil {
+				return err
+			}
+		}
+		instance.Status = "draining"
+		if err := c.putClusterUpgrade(s, *upgrade); err != nil {
+			return err
+		}
+	}
+	if instance.Status == "draining" {
+		containerInstanceArn, err := e.GetContainerInstanceArnByInstanceId(upgrade.ClusterName, instance.InstanceId)
+		if err != nil {
+			return err
+		}
+		clusterUpgradeLogger.Infof("Draining %v in cluster %v", instance.InstanceId, upgrade.ClusterName)
+		if err := e.DrainNode(upgrade.ClusterName, containerInstanceArn); err != nil {
+			return err
+		}
+		if err := asc.setContainerInstanceDraining(upgrade.ClusterName, instance.InstanceId, s); err != nil {
+			return err
+		}
+		drained, err := e.WaitForDrainedNode(upgrade.ClusterName, containerInstanceArn)
+		if err != nil {
+			return err
+		}
+		if !drained {
+			return fmt.Errorf("could not drain %v: timeout of 20m reached", instance.InstanceId)
+		}
+		if err := c.waitForStableServices(upgrade.ClusterName, e); err != nil {
+			return err
+		}
+		instance.Status = "terminating"
+		if err := c.putClusterUpgrade(s, *upgrade); err != nil {
+			return err
+		}
+	}
+	if instance.Status == "terminating" {
+		clusterUpgradeLogger.Infof("Terminating %v in cluster %v", instance.InstanceId, upgrade.ClusterName)
+		err := autoscaling.TerminateInstanceInAutoScalingGroup(instance.InstanceId, instance.ScaledUp)
+		// the instance is already gone when resuming after the termination
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		instance.Status = "terminated"
+		if !instance.ScaledUp {
+			instance.Status = "replacing"
+		}
+		if err := c.putClusterUpgrade(s, *upgrade); err != nil {
+			return err
+		}
+	}
+	if instance.Status == "replacing" {
+		clusterUpgradeLogger.Infof("Waiting for the replacement of %v in cluster %v", instance.InstanceId, upgrade.ClusterName)
+		if err := c.waitForReplacementInstance(upgrade, e, ec2); err != nil {
+			return err
+		}
+		instance.Status = "terminated"
+		if err := c.putClusterUpgrade(s, *upgrade); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForReplacementInstance waits until the replacement of the instance that is being upgraded registered
+func (c *Controller) waitForReplacementInstance(upgrade *service.ClusterUpgrade, e ecs.ECSIf, ec2 ecs.EC2If) error {
+	// every replaced instance has a replacement, plus the replacement of this instance
+	count := upgrade.InitialUpgradedInstances + countUpgradedInstances(upgrade.Instances) + 1
+	return c.waitForUpgradedInstances(upgrade.ClusterName, upgrade.AmiId, count, e, ec2)
+}
+
+// countUpgradedInstances returns the number of instances that have been replaced
+func countUpgradedInstances(instances []service.ClusterUpgradeInstance) int {
+	count := 0
+	for _, instance := range instances {
+		if instance.Status == "terminated" {
+			count++
+		}
+	}
+	return count
+}
+
+// waitForUpgradedInstances waits (max 10 minutes) until the cluster has enough active container instances running the AMI
+func (c *Controller) waitForUpgradedInstances(clusterName, amiId string, count int, e ecs.ECSIf, ec2 ecs.EC2If) error {
+	for i := 0; i < 40; i++ {
+		cis, err := c.getClusterContainerInstances(clusterName, e)
+		if err != nil {
+			return err
+		}
+		var instanceIds []string
+		for _, ci := range cis {
+			if ci.Status == "ACTIVE" {
+				instanceIds = append(instanceIds, ci.Ec2InstanceId)
+			}
+		}
+		if len(instanceIds) > 0 {
+			imageIds, err := ec2.GetInstanceImageIds(instanceIds)
+			if err != nil {
+				return err
+			}
+			upgraded := 0
+			for _, imageId := range imageIds {
+				if imageId == amiId {
+					upgraded++
+				}
+			}
+			if upgraded >= count {
+				return nil
+			}
+		}
+		time.Sleep(clusterUpgradePollInterval)
+	}
+	return fmt.Errorf("new container instance with %v didn't register with cluster %v: timeout of 10m reached", amiId, clusterName)
+}
+
+// waitForStableServices waits until all services in the cluster are stable after draining an instance
+func (c *Controller) waitForStableServices(clusterName string, e ecs.ECSIf) error {
+	services, err := e.ListServices(clusterName)
+	if err != nil {
+		return err
+	}
+	for _, serviceName := range services {
+		if err := e.WaitUntilServicesStable(clusterName, *serviceName, 15); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putClusterUpgrade saves the upgrade, unless it has been cancelled in the meantime
+func (c *Controller) putClusterUpgrade(s service.ServiceIf, upgrade service.ClusterUpgrade) error {
+	current, err := s.GetClusterUpgrade(upgrade.ClusterName)
+	if err != nil {
+		return err
+	}
+	if current != nil && current.Status == "cancelled" {
+		return errClusterUpgradeCancelled
+	}
+	return s.PutClusterUpgrade(upgrade)
+}
+
+// getClusterUpgrade returns the last upgrade of a cluster
+func (c *Controller) getClusterUpgrade(clusterName string) (*service.ClusterUpgrade, error) {
+	s := service.NewService()
+	upgrade, err := s.GetClusterUpgrade(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if upgrade == nil {
+		return nil, fmt.Errorf("no upgrade found for cluster %v", clusterName)
+	}
+	return upgrade, nil
+}
+
+// cancelClusterUpgrade stops a running upgrade after the current step, instances that are already drained are not reactivated
+// and an instance launched for a replacement is kept, the cluster autoscaler removes it when the capacity isn't needed
+func (c *Controller) cancelClusterUpgrade(clusterName string) (*service.ClusterUpgrade, error) {
+	s := service.NewService()
+	upgrade, err := c.getClusterUpgrade(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if upgrade.Status != "running" {
+		return nil, fmt.Errorf("upgrade of cluster %v is not running (status: %v)", clusterName, upgrade.Status)
+	}
+	upgrade.Status = "cancelled"
+	if err := s.PutClusterUpgrade(*upgrade); err != nil {
+		return nil, err
+	}
+	return upgrade, nil
+}
+
+// isClusterUpgradeRunning returns true when the instances of the cluster are being replaced
+func (c *Controller) isClusterUpgradeRunning(clusterName string) bool {
+	s := service.NewService()
+	upgrade, err := s.GetClusterUpgrade(clusterName)
+	if err != nil {
+		clusterUpgradeLogger.Errorf("Could not get upgrade of cluster %v: %v", clusterName, err)
+		return false
+	}
+	return upgrade != nil && upgrade.Status == "running"
+}
+
+// resumeClusterUpgrades continues the upgrades that were running when ecs-deploy stopped
+func (c *Controller) resumeClusterUpgrades() error {
+	s := service.NewService()
+	upgrades, err := s.GetClusterUpgrades()
+	if err != nil {
+		return err
+	}
+	for _, upgrade := range upgrades {
+		if upgrade.Status == "running" {
+			clusterUpgradeLogger.Infof("Resuming upgrade of cluster %v", upgrade.ClusterName)
+			go c.runClusterUpgrade(upgrade, s, &ecs.ECS{}, &ecs.EC2{}, &ecs.AutoScaling{})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/in4it/ecs-deploy/provider/ecs"
+	"github.com/in4it/ecs-deploy/service"
+)
+
+func TestGetClusterUpgradeInstances(t *testing.T) {
+	now := time.Now()
+	cis := []ecs.ContainerInstance{
+		{Ec2InstanceId: "i-new", Status: "ACTIVE", RegisteredAt: now.Add(-1 * time.Hour)},
+		{Ec2InstanceId: "i-2", Status: "ACTIVE", RegisteredAt: now.Add(-2 * time.Hour)},
+		{Ec2InstanceId: "i-1", Status: "ACTIVE", RegisteredAt: now.Add(-3 * time.Hour)},
+		{Ec2InstanceId: "i-draining", Status: "DRAINING", RegisteredAt: now.Add(-4 * time.Hour)},
+	}
+	imageIds := map[string]string{
+		"i-new":      "ami-new",
+		"i-1":        "ami-old",
+		"i-2":        "ami-old",
+		"i-draining": "ami-old",
+	}
+	instances := getClusterUpgradeInstances(cis, imageIds, "ami-new")
+	if len(instances) != 2 {
+		t.Fatalf("Expected 2 instances to upgrade, got: %+v", instances)
+	}
+	// oldest instance first
+	if instances[0].InstanceId != "i-1" || instances[1].InstanceId != "i-2" {
+		t.Errorf("Unexpected order: %+v", instances)
+	}
+	if instances[0].AmiId != "ami-old" || instances[0].Status != "pending" {
+		t.Errorf("Unexpected instance: %+v", instances[0])
+	}
+	if len(getClusterUpgradeInstances(cis, imageIds, "ami-old")) != 1 {
+		t.Errorf("Expected only i-new to be upgraded")
+	}
+}
+
+func TestCountUpgradedInstances(t *testing.T) {
+	instances := []service.ClusterUpgradeInstance{
+		{InstanceId: "i-1", Status: "terminated"},
+		{InstanceId: "i-2", Status: "draining"},
+		{InstanceId: "i-3", Status: "pending"},
+	}
+	if count := countUpgradedInstances(instances); count != 1 {
+		t.Errorf("Expected 1 upgraded instance, got %d", count)
+	}
+}
+
+type MockClusterUpgradeService struct {
+	MockService
+	Upgrade *service.ClusterUpgrade
+}
+
+func (m *MockClusterUpgradeService) GetClusterUpgrade(clusterName string) (*service.ClusterUpgrade, error) {
+	return m.Upgrade, nil
+}
+
+func (m *MockClusterUpgradeService) PutClusterUpgrade(upgrade service.ClusterUpgrade) error {
+	m.Upgrade = &upgrade
+	return nil
+}
+
+// MockClusterUpgradeECS registers the replacement instance a few polls after the autoscaling group scaled up
+type MockClusterUpgradeECS struct {
+	ecs.ECSIf
+	Instances            []string
+	Replacement          string
+	ScaledUp             bool
+	PollsUntilRegistered int
+	Drained              []string
+	// the replacement instance was registered when the instance was drained
+	DrainedWithReplacement bool
+}
+
+func (m *MockClusterUpgradeECS) ListContainerInstances(clusterName string) ([]string, error) {
+	if m.ScaledUp {
+		if m.PollsUntilRegistered == 0 {
+			m.Instances = append(m.Instances, m.Replacement)
+			m.ScaledUp = false
+		}
+		m.PollsUntilRegistered--
+	}
+	return m.Instances, nil
+}
+
+func (m *MockClusterUpgradeECS) DescribeContainerInstances(clusterName string, containerInstances []string) ([]ecs.ContainerInstance, error) {
+	var cis []ecs.ContainerInstance
+	for _, instanceId := range containerInstances {
+		cis = append(cis, ecs.ContainerInstance{ContainerInstanceArn: "arn-" + instanceId, Ec2InstanceId: instanceId, Status: "ACTIVE"})
+	}
+	return cis, nil
+}
+
+func (m *MockClusterUpgradeECS) GetContainerInstanceArnByInstanceId(clusterName, instanceId string) (string, error) {
+	return "arn-" + instanceId, nil
+}
+
+func (m *MockClusterUpgradeECS) DrainNode(clusterName, instance string) error {
+	m.Drained = append(m.Drained, instance)
+	for _, instanceId := range m.Instances {
+		if instanceId == m.Replacement {
+			m.DrainedWithReplacement = true
+		}
+	}
+	return nil
+}
+
+func (m *MockClusterUpgradeECS) WaitForDrainedNode(clusterName, containerInstanceArn string) (bool, error) {
+	return true, nil
+}
+
+func (m *MockClusterUpgradeECS) ListServices(clusterName string) ([]*string, error) {
+	return []*string{}, nil
+}
+
+type MockClusterUpgradeEC2 struct {
+	ImageIds map[string]string
+}
+
+func (m *MockClusterUpgradeEC2) GetInstanceImageIds(instanceIds []string) (map[string]string, error) {
+	imageIds := make(map[string]string)
+	for _, instanceId := range instanceIds {
+		imageIds[instanceId] = m.ImageIds[instanceId]
+	}
+	return imageIds, nil
+}
+
+// MockClusterUpgradeAutoScaling launches a replacement instance when scaling up or when terminating without decrement
+type MockClusterUpgradeAutoScaling struct {
+	MockAutoScaling
+	ECS        *MockClusterUpgradeECS
+	Desired    int64
+	Max        int64
+	ScaleUps   int
+	Terminated map[string]bool
+}
+
+func (m *MockClusterUpgradeAutoScaling) GetClusterNodeDesiredCount(autoScalingGroupName string) (int64, int64, int64, error) {
+	return 1, m.Desired, m.Max, nil
+}
+
+func (m *MockClusterUpgradeAutoScaling) ScaleClusterNodes(autoScalingGroupName string, change int64) error {
+	m.Desired += change
+	m.ScaleUps++
+	m.ECS.ScaledUp = true
+	return nil
+}
+
+func (m *MockClusterUpgradeAutoScaling) TerminateInstanceInAutoScalingGroup(instanceId string, decrementDesiredCapacity bool) error {
+	m.Terminated[instanceId] = decrementDesiredCapacity
+	var instances []string
+	for _, id := range m.ECS.Instances {
+		if id != instanceId {
+			instances = append(instances, id)
+		}
+	}
+	m.ECS.Instances = instances
+	if !decrementDesiredCapacity {
+		m.ECS.ScaledUp = true
+	}
+	return nil
+}
+
+func TestRunClusterUpgrade(t *testing.T) {
+	clusterUpgradePollInterval = time.Millisecond
+	defer func() { clusterUpgradePollInterval = 15 * time.Second }()
+
+	// i-current is already running the new AMI, the old instance can only be drained when i-replacement registered
+	e := &MockClusterUpgradeECS{
+		Instances:            []string{"i-old", "i-current"},
+		Replacement:          "i-replacement",
+		PollsUntilRegistered: 2,
+	}
+	ec2 := &MockClusterUpgradeEC2{ImageIds: map[string]string{"i-old": "ami-old", "i-current": "ami-new", "i-replacement": "ami-new"}}
+	am := &MockClusterUpgradeAutoScaling{ECS: e, Desired: 2, Max: 4, Terminated: make(map[string]bool)}
+	upgrade := service.ClusterUpgrade{
+		ClusterName:              "testCluster",
+		AmiId:                    "ami-new",
+		Status:                   "running",
+		Instances:                []service.ClusterUpgradeInstance{{InstanceId: "i-old", AmiId: "ami-old", Status: "pending"}},
+		InitialUpgradedInstances: 1,
+	}
+	s := &MockClusterUpgradeService{Upgrade: &upgrade}
+	c := Controller{}
+	c.runClusterUpgrade(upgrade, s, e, ec2, am)
+
+	if s.Upgrade.Status != "completed" || s.Upgrade.Instances[0].Status != "terminated" {
+		t.Fatalf("Unexpected upgrade: %+v", s.Upgrade)
+	}
+	if len(e.Drained) != 1 || e.Drained[0] != "arn-i-old" {
+		t.Errorf("Expected i-old to be drained, got: %v", e.Drained)
+	}
+	if !e.DrainedWithReplacement {
+		t.Errorf("i-old was drained before the replacement instance registered")
+	}
+	if am.ScaleUps != 1 {
+		t.Errorf("Expected 1 scale up, got %d", am.ScaleUps)
+	}
+	// the autoscaling group was scaled up, the desired capacity is decremented again
+	if decrement, ok := am.Terminated["i-old"]; !ok || !decrement {
+		t.Errorf("Expected i-old to be terminated with decrement, got: %v", am.Terminated)
+	}
+}
+
+func TestRunClusterUpgradeWithoutRoom(t *testing.T) {
+	clusterUpgradePollInterval = time.Millisecond
+	defer func() { clusterUpgradePollInterval = 15 * time.Second }()
+
+	// the autoscaling group is at its maximum, the replacement is launched after the termination
+	e := &MockClusterUpgradeECS{
+		Instances:            []string{"i-old", "i-current"},
+		Replacement:          "i-replacement",
+		PollsUntilRegistered: 2,
+	}
+	ec2 := &MockClusterUpgradeEC2{ImageIds: map[string]string{"i-old": "ami-old", "i-current": "ami-new", "i-replacement": "ami-new"}}
+	am := &MockClusterUpgradeAutoScaling{ECS: e, Desired: 2, Max: 2, Terminated: make(map[string]bool)}
+	upgrade := service.ClusterUpgrade{
+		ClusterName:              "testCluster",
+		AmiId:                    "ami-new",
+		Status:                   "running",
+		Instances:                []service.ClusterUpgradeInstance{{InstanceId: "i-old", AmiId: "ami-old", Status: "pending"}},
+		InitialUpgradedInstances: 1,
+	}
+	s := &MockClusterUpgradeService{Upgrade: &upgrade}
+	c := Controller{}
+	c.runClusterUpgrade(upgrade, s, e, ec2, am)
+
+	if s.Upgrade.Status != "completed" || s.Upgrade.Instances[0].Status != "terminated" {
+		t.Fatalf("Unexpected upgrade: %+v", s.Upgrade)
+	}
+	if am.ScaleUps != 0 {
+		t.Errorf("Expected no scale up, got %d", am.ScaleUps)
+	}
+	if decrement, ok := am.Terminated["i-old"]; !ok || decrement {
+		t.Errorf("Expected i-old to be terminated without decrement, got: %v", am.Terminated)
+	}
+	if e.ScaledUp {
+		t.Errorf("Upgrade completed before the replacement instance registered")
+	}
+}
+
+func TestRunClusterUpgradeResumeAfterScaleUp(t *testing.T) {
+	clusterUpgradePollInterval = time.Millisecond
+	defer func() { clusterUpgradePollInterval = 15 * time.Second }()
+
+	// ecs-deploy stopped after scaling up from 2 to 3 instances, the replacement is already running
+	e := &MockClusterUpgradeECS{
+		Instances:   []string{"i-old", "i-current", "i-replacement"},
+		Replacement: "i-replacement",
+	}
+	ec2 := &MockClusterUpgradeEC2{ImageIds: map[string]string{"i-old": "ami-old", "i-current": "ami-new", "i-replacement": "ami-new"}}
+	am := &MockClusterUpgradeAutoScaling{ECS: e, Desired: 3, Max: 4, Terminated: make(map[string]bool)}
+	upgrade := service.ClusterUpgrade{
+		ClusterName:              "testCluster",
+		AmiId:                    "ami-new",
+		Status:                   "running",
+		Instances:                []service.ClusterUpgradeInstance{{InstanceId: "i-old", AmiId: "ami-old", Status: "scaling", ScaledUp: true, DesiredCount: 2}},
+		InitialUpgradedInstances: 1,
+	}
+	s := &MockClusterUpgradeService{Upgrade: &upgrade}
+	c := Controller{}
+	c.runClusterUpgrade(upgrade, s, e, ec2, am)
+
+	if s.Upgrade.Status != "completed" || s.Upgrade.Instances[0].Status != "terminated" {
+		t.Fatalf("Unexpected upgrade: %+v", s.Upgrade)
+	}
+	if am.ScaleUps != 0 {
+		t.Errorf("Expected no second scale up, got %d", am.ScaleUps)
+	}
+}
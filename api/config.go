@@ -0,0 +1,462 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/in4it/ecs-deploy/provider/ecs"
+	"github.com/juju/loggo"
+)
+
+// logging
+var configLogger = loggo.GetLogger("config")
+
+// Config is the configuration of the ecs-deploy server. Every setting has an environment variable (env tag),
+// the precedence is: defaults < configuration file < environment variables < parameter store
+type Config struct {
+	AWS            AWSConfig            `json:"aws"`
+	Server         ServerConfig         `json:"server"`
+	Auth           AuthConfig           `json:"auth"`
+	SAML           SAMLConfig           `json:"saml"`
+	Paramstore     ParamstoreConfig     `json:"paramstore"`
+	Deploy         DeployConfig         `json:"deploy"`
+	Autoscaling    AutoscalingConfig    `json:"autoscaling"`
+	Slack          SlackConfig          `json:"slack"`
+	DriftDetection DriftDetectionConfig `json:"driftDetection"`
+	Tracing        TracingConfig        `json:"tracing"`
+	// source of every setting (default, file, env or paramstore), by environment variable
+	sources map[string]string
+}
+type AWSConfig struct {
+	Region                  string `json:"region" env:"AWS_REGION" required:"true"`
+	AccountEnv              string `json:"accountEnv" env:"AWS_ACCOUNT_ENV"`
+	EcsServiceRole          string `json:"ecsServiceRole" env:"AWS_ECS_SERVICE_ROLE" default:"ecs-service-role"`
+	ResourceCreationEnabled string `json:"resourceCreationEnabled" env:"AWS_RESOURCE_CREATION_ENABLED" default:"yes" enum:"yes,no"`
+	DynamoDBTable           string `json:"dynamodbTable" env:"DYNAMODB_TABLE" default:"Services"`
+}
+type ServerConfig struct {
+	UrlPrefix    string `json:"urlPrefix" env:"URL_PREFIX"`
+	UrlPrefixApi string `json:"urlPrefixApi" env:"URL_PREFIX_API" default:"/api/v1"`
+	Whitelist    string `json:"whitelist" env:"ECS_WHITELIST" default:"0.0.0.0/0"`
+	Debug        string `json:"debug" env:"DEBUG"`
+	LogFormat    string `json:"logFormat" env:"LOG_FORMAT" default:"text" enum:"text,json"`
+}
+type AuthConfig struct {
+	JWTSecret         string `json:"jwtSecret" env:"JWT_SECRET" required:"true" secret:"true"`
+	DeployPassword    string `json:"deployPassword" env:"DEPLOY_PASSWORD" required:"true" secret:"true"`
+	DeveloperPassword string `json:"developerPassword" env:"DEVELOPER_PASSWORD" default:"developer" secret:"true"`
+}
+type SAMLConfig struct {
+	Enabled     string `json:"enabled" env:"SAML_ENABLED" enum:"yes,no"`
+	AcsURL      string `json:"acsUrl" env:"SAML_ACS_URL"`
+	MetadataURL string `json:"metadataUrl" env:"SAML_METADATA_URL"`
+	Certificate string `json:"certificate" env:"SAML_CERTIFICATE"`
+	PrivateKey  string `json:"privateKey" env:"SAML_PRIVATE_KEY" secret:"true"`
+}
+type ParamstoreConfig struct {
+	Enabled    string `json:"enabled" env:"PARAMSTORE_ENABLED" default:"no" enum:"yes,no"`
+	Prefix     string `json:"prefix" env:"PARAMSTORE_PREFIX"`
+	EnvPath    string `json:"envPath" env:"AWS_ENV_PATH"`
+	KmsArn     string `json:"kmsArn" env:"PARAMSTORE_KMS_ARN"`
+	AssumeRole string `json:"assumeRole" env:"PARAMSTORE_ASSUME_ROLE"`
+	Inject     string `json:"inject" env:"PARAMSTORE_INJECT" default:"no" enum:"yes,no"`
+}
+type DeployConfig struct {
+	MaxWaitSeconds                   int64  `json:"maxWaitSeconds" env:"DEPLOY_MAX_WAIT_SECONDS" default:"900" min:"1"`
+	StreamInterval                   int64  `json:"streamInterval" env:"DEPLOY_STREAM_INTERVAL" default:"5" min:"1"`
+	LogsFollowInterval               int64  `json:"logsFollowInterval" env:"LOGS_FOLLOW_INTERVAL" default:"3" min:"1"`
+	ImageDigestResolution            string `json:"imageDigestResolution" env:"IMAGE_DIGEST_RESOLUTION" default:"no" enum:"yes,no"`
+	EcrScanOnPush                    string `json:"ecrScanOnPush" env:"ECR_SCAN_ON_PUSH" default:"false" enum:"true,false"`
+	DefaultContainerCpuLimit         string `json:"defaultContainerCpuLimit" env:"DEFAULT_CONTAINER_CPU_LIMIT"`
+	TaskRolePermissionBoundaryArn    string `json:"taskRolePermissionBoundaryArn" env:"ECS_TASK_ROLE_PERMISSION_BOUNDARY_ARN"`
+	CloudwatchLogsEnabled            string `json:"cloudwatchLogsEnabled" env:"CLOUDWATCH_LOGS_ENABLED" default:"no" enum:"yes,no"`
+	CloudwatchLogsPrefix             string `json:"cloudwatchLogsPrefix" env:"CLOUDWATCH_LOGS_PREFIX"`
+	ServiceDiscoveryTTL              int64  `json:"serviceDiscoveryTTL" env:"SERVICE_DISCOVERY_TTL" default:"60" min:"0"`
+	ServiceDiscoveryFailureThreshold int64  `json:"serviceDiscoveryFailureThreshold" env:"SERVICE_DISCOVERY_FAILURETHRESHOLD" default:"1" min:"1"`
+}
+type AutoscalingConfig struct {
+	Strategies            string `json:"strategies" env:"AUTOSCALING_STRATEGIES" default:"LargestContainerUp,LargestContainerDown"`
+	ClusterStrategies     string `json:"clusterStrategies" env:"AUTOSCALING_CLUSTER_STRATEGIES"`
+	UpStrategy            string `json:"upStrategy" env:"AUTOSCALING_UP_STRATEGY" default:"immediately" enum:"immediately,gracefully"`
+	UpCooldown            int64  `json:"upCooldown" env:"AUTOSCALING_UP_COOLDOWN" default:"5" min:"0"`
+	UpInterval            int64  `json:"upInterval" env:"AUTOSCALING_UP_INTERVAL" default:"60" min:"1"`
+	UpPeriod              int64  `json:"upPeriod" env:"AUTOSCALING_UP_PERIOD" default:"2" min:"1"`
+	DownCooldown          int64  `json:"downCooldown" env:"AUTOSCALING_DOWN_COOLDOWN" default:"5" min:"0"`
+	DownInterval          int64  `json:"downInterval" env:"AUTOSCALING_DOWN_INTERVAL" default:"60" min:"1"`
+	DownPeriod            int64  `json:"downPeriod" env:"AUTOSCALING_DOWN_PERIOD" default:"5" min:"1"`
+	HeadroomPercent       int64  `json:"headroomPercent" env:"AUTOSCALING_HEADROOM_PERCENT" default:"20" min:"0" max:"99"`
+	ScheduleWindows       string `json:"scheduleWindows" env:"AUTOSCALING_SCHEDULE_WINDOWS"`
+	ScheduleTimezone      string `json:"scheduleTimezone" env:"AUTOSCALING_SCHEDULE_TIMEZONE" default:"UTC"`
+	ScheduleBaseStrategy  string `json:"scheduleBaseStrategy" env:"AUTOSCALING_SCHEDULE_BASE_STRATEGY" default:"largestcontainer"`
+	DecisionRetentionDays int64  `json:"decisionRetentionDays" env:"AUTOSCALING_DECISION_RETENTION_DAYS" default:"7" min:"1"`
+}
+type SlackConfig struct {
+	Webhooks string `json:"webhooks" env:"SLACK_WEBHOOKS" secret:"true"`
+	Username string `json:"username" env:"SLACK_USERNAME" default:"ecs-deploy"`
+}
+type DriftDetectionConfig struct {
+	Interval string `json:"interval" env:"DRIFT_DETECTION_INTERVAL" duration:"true"`
+	Notify   string `json:"notify" env:"DRIFT_DETECTION_NOTIFY" default:"no" enum:"yes,no"`
+}
+type TracingConfig struct {
+	Endpoint    string `json:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string `json:"serviceName" env:"OTEL_SERVICE_NAME" default:"ecs-deploy"`
+}
+
+// serverConfig is the configuration the server runs with, set at startup with SetServerConfig
+var serverConfig = NewConfig()
+
+// SetServerConfig sets the configuration the server runs with, including the settings of the provider
+func SetServerConfig(c *Config) {
+	serverConfig = c
+	ecs.SetConfig(c.providerConfig())
+}
+
+// providerConfig returns the settings the provider uses
+func (c *Config) providerConfig() ecs.Config {
+	// the cpu limit is validated
+	defaultContainerCpuLimit, _ := strconv.ParseInt(c.Deploy.DefaultContainerCpuLimit, 10, 64)
+	return ecs.Config{
+		Region:                           c.AWS.Region,
+		AccountEnv:                       c.AWS.AccountEnv,
+		EcsServiceRole:                   c.AWS.EcsServiceRole,
+		DeployMaxWaitSeconds:             c.Deploy.MaxWaitSeconds,
+		DefaultContainerCpuLimit:         defaultContainerCpuLimit,
+		EcrScanOnPush:                    c.Deploy.EcrScanOnPush == "true",
+		CloudwatchLogsEnabled:            c.Deploy.CloudwatchLogsEnabled == "yes",
+		CloudwatchLogsPrefix:             c.Deploy.CloudwatchLogsPrefix,
+		ServiceDiscoveryTTL:              c.Deploy.ServiceDiscoveryTTL,
+		ServiceDiscoveryFailureThreshold: c.Deploy.ServiceDiscoveryFailureThreshold,
+		ParamstoreEnabled:                c.Paramstore.Enabled == "yes",
+		ParamstorePrefix:                 c.Paramstore.Prefix,
+		ParamstoreEnvPath:                c.Paramstore.EnvPath,
+		ParamstoreKmsArn:                 c.Paramstore.KmsArn,
+		ParamstoreInject:                 c.Paramstore.Inject == "yes",
+	}
+}
+
+// configField is a setting of the configuration, found with reflection
+type configField struct {
+	section string
+	name    string
+	field   reflect.StructField
+	value   reflect.Value
+}
+
+func (f configField) env() string {
+	return f.field.Tag.Get("env")
+}
+
+// fields returns all settings of the configuration, in order of declaration
+func (c *Config) fields() []configField {
+	var fields []configField
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		sectionField := v.Type().Field(i)
+		if sectionField.PkgPath != "" {
+			continue
+		}
+		section := v.Field(i)
+		for j := 0; j < section.NumField(); j++ {
+			fields = append(fields, configField{
+				section: strings.Split(sectionField.Tag.Get("json"), ",")[0],
+				name:    strings.Split(section.Type().Field(j).Tag.Get("json"), ",")[0],
+				field:   section.Type().Field(j),
+				value:   section.Field(j),
+			})
+		}
+	}
+	return fields
+}
+
+// set sets the value of a setting from a string and records the source
+func (c *Config) set(f configField, value, source string) error {
+	switch f.value.Kind() {
+	case reflect.Int64:
+		i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%v.%v (%v): %q is not a number", f.section, f.name, f.env(), value)
+		}
+		f.value.SetInt(i)
+	default:
+		f.value.SetString(value)
+	}
+	c.sources[f.env()] = source
+	return nil
+}
+
+// get returns the value of a setting as string
+func (f configField) get() string {
+	if f.value.Kind() == reflect.Int64 {
+		return strconv.FormatInt(f.value.Int(), 10)
+	}
+	return f.value.String()
+}
+
+// NewConfig returns the configuration with the default values
+func NewConfig() *Config {
+	c := &Config{sources: make(map[string]string)}
+	for _, f := range c.fields() {
+		c.set(f, f.field.Tag.Get("default"), "default")
+	}
+	return c
+}
+
+// LoadConfig loads the configuration file (optional), the environment variables and the parameter store
+func LoadConfig(filename string) (*Config, error) {
+	c := NewConfig()
+	if filename != "" {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read configuration file: %v", err)
+		}
+		if err = c.loadFile(content); err != nil {
+			return nil, fmt.Errorf("invalid configuration file %v: %v", filename, err)
+		}
+		configLogger.Debugf("Loaded configuration file %v", filename)
+	}
+	if err := c.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.loadParamstore(); err != nil {
+		return nil, err
+	}
+	c.export()
+	return c, nil
+}
+
+// loadFile loads the settings from a yaml (or json) file
+func (c *Config) loadFile(content []byte) error {
+	var sections map[string]interface{}
+	if err := yaml.Unmarshal(content, &sections); err != nil {
+		return err
+	}
+	fields := make(map[string]configField)
+	for _, f := range c.fields() {
+		fields[f.section+"."+f.name] = f
+	}
+	var errs []string
+	for section, value := range sections {
+		settings, ok := value.(map[string]interface{})
+		if !ok {
+			errs = append(errs, section+" is not a section with settings (e.g. aws.region)")
+			continue
+		}
+		for name, value := range settings {
+			f, ok := fields[section+"."+name]
+			if !ok {
+				errs = append(errs, "unknown setting "+section+"."+name)
+				continue
+			}
+			if err := c.set(f, configFileValue(f, value), "file"); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// configFileValue converts a value of the configuration file to the format of the environment variable
+func configFileValue(f configField, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		// yes and no are booleans in yaml
+		if f.field.Tag.Get("enum") == "yes,no" {
+			if v {
+				return "yes"
+			}
+			return "no"
+		}
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		// lists (e.g. slack webhooks) are comma separated
+		var values []string
+		for _, item := range v {
+			values = append(values, configFileValue(f, item))
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// loadEnv loads the settings from the environment variables
+func (c *Config) loadEnv() error {
+	for _, f := range c.fields() {
+		if value, ok := os.LookupEnv(f.env()); ok {
+			if err := c.set(f, value, "env"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadParamstore loads the keys from the parameter store (when enabled), the keys that are not settings
+// of the configuration are set as environment variables
+func (c *Config) loadParamstore() error {
+	if c.Paramstore.Enabled != "yes" {
+		return nil
+	}
+	// the parameter store settings can come from the configuration file
+	ecs.SetConfig(c.providerConfig())
+	paramstore := ecs.Paramstore{}
+	if err := paramstore.GetParameters(paramstore.GetPrefix(), true); err != nil {
+		return fmt.Errorf("couldn't retrieve variables from parameter store: %v", err)
+	}
+	fields := make(map[string]configField)
+	for _, f := range c.fields() {
+		fields[f.env()] = f
+	}
+	for name, parameter := range paramstore.Parameters {
+		f, ok := fields[name]
+		if !ok {
+			os.Setenv(name, parameter.Value)
+			continue
+		}
+		if err := c.set(f, parameter.Value, "paramstore"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// export sets the environment variables of the settings from the configuration file or the parameter store,
+// for the AWS SDK and the packages that read them from the environment. Defaults and secrets are not exported
+func (c *Config) export() {
+	for _, f := range c.fields() {
+		if source := c.sources[f.env()]; source == "file" || source == "paramstore" {
+			if f.field.Tag.Get("secret") != "true" {
+				os.Setenv(f.env(), f.get())
+			}
+		}
+	}
+}
+
+// Validate checks all settings, the error contains every invalid setting
+func (c *Config) Validate() error {
+	var errs []string
+	for _, f := range c.fields() {
+		key := f.section + "." + f.name + " (" + f.env() + ")"
+		value := f.get()
+		if f.field.Tag.Get("required") == "true" && (value == "" || c.sources[f.env()] == "default") {
+			errs = append(errs, key+" is required")
+			continue
+		}
+		if enum := f.field.Tag.Get("enum"); enum != "" && value != "" && !inConfigEnum(value, enum) {
+			errs = append(errs, fmt.Sprintf("%v must be one of %v, got %q", key, strings.Replace(enum, ",", ", ", -1), value))
+		}
+		if min := f.field.Tag.Get("min"); min != "" {
+			if m, _ := strconv.ParseInt(min, 10, 64); f.value.Int() < m {
+				errs = append(errs, fmt.Sprintf("%v must be at least %v, got %v", key, min, value))
+			}
+		}
+		if max := f.field.Tag.Get("max"); max != "" {
+			if m, _ := strconv.ParseInt(max, 10, 64); f.value.Int() > m {
+				errs = append(errs, fmt.Sprintf("%v must be at most %v, got %v", key, max, value))
+			}
+		}
+		if f.field.Tag.Get("duration") == "true" && value != "" {
+			if _, err := time.ParseDuration(value); err != nil {
+				errs = append(errs, fmt.Sprintf("%v is not a duration (e.g. 30m): %q", key, value))
+			}
+		}
+	}
+	for _, subnet := range strings.Split(c.Server.Whitelist, ",") {
+		if _, _, err := net.ParseCIDR(strings.TrimSpace(subnet)); err != nil {
+			errs = append(errs, fmt.Sprintf("server.whitelist (ECS_WHITELIST) contains an invalid subnet: %q", subnet))
+		}
+	}
+	if c.Deploy.DefaultContainerCpuLimit != "" {
+		if _, err := strconv.ParseInt(c.Deploy.DefaultContainerCpuLimit, 10, 64); err != nil {
+			errs = append(errs, fmt.Sprintf("deploy.defaultContainerCpuLimit (DEFAULT_CONTAINER_CPU_LIMIT) is not a number: %q", c.Deploy.DefaultContainerCpuLimit))
+		}
+	}
+	if c.SAML.Enabled == "yes" && (c.SAML.AcsURL == "" || c.SAML.MetadataURL == "" || c.SAML.Certificate == "" || c.SAML.PrivateKey == "") {
+		errs = append(errs, "saml.acsUrl, saml.metadataUrl, saml.certificate and saml.privateKey are required when saml is enabled")
+	}
+	if c.Paramstore.Enabled == "yes" && c.Paramstore.Prefix == "" && c.Paramstore.EnvPath == "" {
+		errs = append(errs, "paramstore.prefix (PARAMSTORE_PREFIX) or paramstore.envPath (AWS_ENV_PATH) is required when the parameter store is enabled")
+	}
+	if _, err := parseScheduleWindows(c.Autoscaling.ScheduleWindows); c.Autoscaling.ScheduleWindows != "" && err != nil {
+		errs = append(errs, fmt.Sprintf("autoscaling.scheduleWindows (AUTOSCALING_SCHEDULE_WINDOWS): %v", err))
+	}
+	if _, err := time.LoadLocation(c.Autoscaling.ScheduleTimezone); err != nil {
+		errs = append(errs, fmt.Sprintf("autoscaling.scheduleTimezone (AUTOSCALING_SCHEDULE_TIMEZONE): %v", err))
+	}
+	errs = append(errs, c.validateClusterStrategies()...)
+	if len(errs) > 0 {
+		return errors.New("invalid configuration:\n - " + strings.Join(errs, "\n - "))
+	}
+	return nil
+}
+
+// validateClusterStrategies checks the scale strategy of every cluster in AUTOSCALING_CLUSTER_STRATEGIES
+func (c *Config) validateClusterStrategies() []string {
+	var errs []string
+	if c.Autoscaling.ClusterStrategies == "" {
+		return errs
+	}
+	asc := AutoscalingController{config: c}
+	for _, v := range strings.Split(c.Autoscaling.ClusterStrategies, ",") {
+		kv := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			errs = append(errs, fmt.Sprintf("autoscaling.clusterStrategies (AUTOSCALING_CLUSTER_STRATEGIES): %q is not cluster=strategy", v))
+			continue
+		}
+		if _, err := asc.newScaleStrategy(kv[1]); err != nil {
+			errs = append(errs, fmt.Sprintf("autoscaling.clusterStrategies (AUTOSCALING_CLUSTER_STRATEGIES): %v: %v", kv[0], err))
+		}
+	}
+	return errs
+}
+
+func inConfigEnum(value, enum string) bool {
+	for _, v := range strings.Split(enum, ",") {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the effective configuration as yaml, with the environment variable and source of every setting.
+// Secrets are redacted
+func (c *Config) Print(w io.Writer) {
+	section := ""
+	for _, f := range c.fields() {
+		if f.section != section {
+			section = f.section
+			fmt.Fprintf(w, "%v:\n", section)
+		}
+		var value string
+		if f.value.Kind() == reflect.Int64 {
+			value = f.get()
+		} else if f.field.Tag.Get("secret") == "true" && f.get() != "" {
+			value = `"********"`
+		} else {
+			out, _ := json.Marshal(f.get())
+			value = string(out)
+		}
+		fmt.Fprintf(w, "  %v: %v # %v (%v)\n", f.name, value, f.env(), c.sources[f.env()])
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	content := []byte(`
+aws:
+  region: eu-west-1
+auth:
+  jwtSecret: secret
+  deployPassword: password
+paramstore:
+  inject: yes
+autoscaling:
+  upPeriod: 3
+  downCooldown: 10
+slack:
+  webhooks:
+    - https://hooks.slack.com/1
+    - https://hooks.slack.com/2
+`)
+	filename := t.TempDir() + "/config.yaml"
+	if err := os.WriteFile(filename, content, 0600); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	// environment variables take precedence over the configuration file
+	t.Setenv("AUTOSCALING_UP_PERIOD", "4")
+	t.Setenv("PARAMSTORE_ENABLED", "no")
+	for _, env := range []string{"PARAMSTORE_INJECT", "AUTOSCALING_DOWN_COOLDOWN", "AUTOSCALING_DOWN_PERIOD", "SLACK_WEBHOOKS", "AWS_REGION", "JWT_SECRET", "DEPLOY_PASSWORD", "DEPLOY_MAX_WAIT_SECONDS"} {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+	config, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if config.Paramstore.Inject != "yes" {
+		t.Errorf("Expected yes, got %v", config.Paramstore.Inject)
+	}
+	if config.Autoscaling.UpPeriod != 4 || config.sources["AUTOSCALING_UP_PERIOD"] != "env" {
+		t.Errorf("Expected upPeriod 4 from env, got %d from %v", config.Autoscaling.UpPeriod, config.sources["AUTOSCALING_UP_PERIOD"])
+	}
+	if config.Autoscaling.DownCooldown != 10 || config.Autoscaling.DownPeriod != 5 {
+		t.Errorf("Unexpected down cooldown %d or period %d", config.Autoscaling.DownCooldown, config.Autoscaling.DownPeriod)
+	}
+	if config.Slack.Webhooks != "https://hooks.slack.com/1,https://hooks.slack.com/2" {
+		t.Errorf("Unexpected webhooks: %v", config.Slack.Webhooks)
+	}
+	if pc := config.providerConfig(); pc.Region != "eu-west-1" || !pc.ParamstoreInject || pc.DeployMaxWaitSeconds != 900 {
+		t.Errorf("Unexpected provider config: %+v", pc)
+	}
+	// the settings of the file are exported, except the secrets
+	if os.Getenv("AWS_REGION") != "eu-west-1" || os.Getenv("PARAMSTORE_INJECT") != "yes" {
+		t.Errorf("Settings of the configuration file are not exported")
+	}
+	for _, env := range []string{"JWT_SECRET", "DEPLOY_PASSWORD", "SLACK_WEBHOOKS"} {
+		if _, ok := os.LookupEnv(env); ok {
+			t.Errorf("Secret %v is exported", env)
+		}
+	}
+	// the defaults are not exported
+	if _, ok := os.LookupEnv("DEPLOY_MAX_WAIT_SECONDS"); ok {
+		t.Errorf("Default of DEPLOY_MAX_WAIT_SECONDS is exported")
+	}
+	if err = config.Validate(); err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	var out bytes.Buffer
+	config.Print(&out)
+	if strings.Contains(out.String(), "password") || !strings.Contains(out.String(), `deployPassword: "********" # DEPLOY_PASSWORD (file)`) {
+		t.Errorf("Secrets are not redacted: %v", out.String())
+	}
+	if !strings.Contains(out.String(), "upPeriod: 4 # AUTOSCALING_UP_PERIOD (env)") {
+		t.Errorf("Unexpected output: %v", out.String())
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	config := NewConfig()
+	err := config.loadFile([]byte("aws:\n  regoin: x\ndeploy:\n  maxWaitSeconds: abc\nregion: x\n"))
+	if err == nil {
+		t.Fatalf("Expected error")
+	}
+	for _, expected := range []string{"unknown setting aws.regoin", "DEPLOY_MAX_WAIT_SECONDS", "region is not a section"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %v in error: %v", expected, err)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	config := NewConfig()
+	if err := config.loadFile([]byte("aws:\n  region: eu-west-1\nauth:\n  jwtSecret: a\n  deployPassword: b\n")); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	// debug logging is only enabled with true, other values are allowed
+	config.Server.Debug = "1"
+	if err := config.Validate(); err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	config.Server.LogFormat = "xml"
+	config.Autoscaling.HeadroomPercent = 100
+	config.Server.Whitelist = "10.0.0.0/8, 1.2.3.4"
+	config.DriftDetection.Interval = "30"
+	config.SAML.Enabled = "yes"
+	config.Autoscaling.ClusterStrategies = "cluster1=binpacking,cluster2=unknown"
+	err := config.Validate()
+	if err == nil {
+		t.Fatalf("Expected error")
+	}
+	for _, expected := range []string{"LOG_FORMAT", "AUTOSCALING_HEADROOM_PERCENT", "1.2.3.4", "DRIFT_DETECTION_INTERVAL", "saml.acsUrl", "cluster2: unknown scale strategy"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %v in error: %v", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "cluster1") {
+		t.Errorf("Unexpected error for cluster1: %v", err)
+	}
+	// required settings
+	if err := NewConfig().Validate(); err == nil || !strings.Contains(err.Error(), "JWT_SECRET") {
+		t.Errorf("Expected JWT_SECRET to be required: %v", err)
+	}
+}
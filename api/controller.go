@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"embed"
 
 	"github.com/google/go-cmp/cmp"
@@ -26,86 +27,88 @@ var defaultTemplates embed.FS
 
 // Controller struct
 type Controller struct {
+	requestID string
+	// deploy even when the image scan policy blocks the images
+	imageScanOverride *service.ImageScanOverride
 }
 
 // controller interface (for tests)
 type ControllerIf interface {
 	describeServices() ([]service.RunningService, error)
 	getServices() ([]*service.DynamoServicesElement, error)
+	describeClusterTasks(clusterName string) (clusterTasks, error)
 }
 
 // logging
 var controllerLogger = loggo.GetLogger("controller")
 
-func (c *Controller) createRepository(repository string) (*string, error) {
+func (c *Controller) createRepository(repository string, settings service.RepositorySettings) (*string, error) {
 	// create service in ECR if not exists
 	ecr := ecs.ECR{RepositoryName: repository}
-	err := ecr.CreateRepository()
+	err := ecr.CreateRepository(settings)
 	if err != nil {
 		controllerLogger.Errorf("Could not create repository %v: %v", repository, err)
-		return nil, errors.New("CouldNotCreateRepository")
+		return nil, fmt.Errorf("CouldNotCreateRepository: %v", err)
 	}
 	msg := fmt.Sprintf("Service: %v - ECR: %v", repository, ecr.RepositoryURI)
 	return &msg, nil
 }
 
-func (c *Controller) Deploy(serviceName string, d service.Deploy) (*service.DeployResult, error) {
+func (c *Controller) updateRepository(repository string, settings service.RepositorySettings) (*string, error) {
+	ecr := ecs.ECR{RepositoryName: repository}
+	err := ecr.UpdateRepository(settings)
+	if err != nil {
+		controllerLogger.Errorf("Could not update repository %v: %v", repository, err)
+		return nil, err
+	}
+	msg := fmt.Sprintf("Service: %v - ECR: %v (updated)", repository, ecr.RepositoryURI)
+	return &msg, nil
+}
+
+func (c *Controller) Deploy(serviceName string, d service.Deploy) (ret *service.DeployResult, err error) {
+	ctx, span := startSpan(context.Background(), "Deploy", serviceName, d.Cluster)
+	defer func() { endSpan(span, err) }()
+
+	// correlation id
+	requestID := c.requestID
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	logger := util.WithFields(controllerLogger, util.Fields{"service": serviceName, "cluster": d.Cluster, "requestId": requestID})
+	ctx = contextWithLogger(ctx, logger)
+
 	// get last deployment
 	s := service.NewService()
 	s.ServiceName = serviceName
 	s.ClusterName = d.Cluster
+	s.RequestID = requestID
 	ddLast, err := s.GetLastDeploy()
 	if err != nil {
 		if !strings.HasPrefix(err.Error(), "NoItemsFound") {
-			controllerLogger.Errorf("Error while getting last deployment for %v: %v", serviceName, err)
+			logger.Errorf("Error while getting last deployment for %v: %v", serviceName, err)
 			return nil, err
 		}
 	}
 	// validate
 	for _, container := range d.Containers {
 		if container.Memory == 0 && container.MemoryReservation == 0 {
-			controllerLogger.Errorf("Could not deploy %v: Memory / MemoryReservation not set", serviceName)
+			logger.Errorf("Could not deploy %v: Memory / MemoryReservation not set", serviceName)
 			return nil, errors.New("At least one of 'memory' or 'memoryReservation' must be specified within the container specification.")
 		}
 	}
 
 	// create role if role doesn't exists
-	iam := ecs.IAM{}
-	iamRoleArn, err := iam.RoleExists("ecs-" + serviceName)
-	if err == nil && iamRoleArn == nil {
-		if util.GetEnv("AWS_RESOURCE_CREATION_ENABLED", "yes") == "yes" {
-			// role does not exist, create it
-			controllerLogger.Debugf("Role does not exist, creating: ecs-%v", serviceName)
-			iamRoleArn, err = iam.CreateRoleWithPermissionBoundary("ecs-"+serviceName, iam.GetEcsTaskIAMTrust(), util.GetEnv("ECS_TASK_ROLE_PERMISSION_BOUNDARY_ARN", ""))
-			if err != nil {
-				return nil, err
-			}
-			// optionally add a policy
-			ps := ecs.Paramstore{}
-			if ps.IsEnabled() {
-				namespace := d.EnvNamespace
-				if namespace == "" {
-					namespace = serviceName
-				}
-				controllerLogger.Debugf("Paramstore enabled, putting role: paramstore-%v", namespace)
-				err = iam.PutRolePolicy("ecs-"+serviceName, "paramstore-"+namespace, ps.GetParamstoreIAMPolicy(namespace))
-				if err != nil {
-					return nil, err
-				}
-			}
-		} else {
-			return nil, errors.New("IAM Task Role not found and resource creation is disabled")
-		}
-	} else if err != nil {
+	iamRoleArn, err := c.getOrCreateTaskRole(ctx, serviceName, d)
+	if err != nil {
 		return nil, err
 	}
 
 	// retrieving secrets
 	secrets := make(map[string]string)
-	if util.GetEnv("PARAMSTORE_INJECT", "no") == "yes" {
+	if serverConfig.Paramstore.Inject == "yes" {
 		ps := ecs.Paramstore{}
 		if ps.IsEnabled() {
-			err := ps.GetParameters("/"+util.GetEnv("PARAMSTORE_PREFIX", "")+"-"+util.GetEnv("AWS_ACCOUNT_ENV", "")+"/"+serviceName+"/", false)
+			err := ps.GetParameters("/"+serverConfig.Paramstore.Prefix+"-"+serverConfig.AWS.AccountEnv+"/"+serviceName+"/", false)
 			if err != nil {
 				return nil, err
 			}
@@ -116,28 +119,56 @@ func (c *Controller) Deploy(serviceName string, d service.Deploy) (*service.Depl
 		}
 	}
 
+	// resolve image tags to digests, so a redeploy uses exactly the same images
+	if serverConfig.Deploy.ImageDigestResolution == "yes" {
+		_, digestSpan := startSpan(ctx, "ECR ResolveImageDigests", serviceName, d.Cluster)
+		d.Containers, err = c.resolveImageDigests(ctx, d.Containers)
+		endSpan(digestSpan, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// block images with findings above the threshold of the image scan policy of the cluster
+	_, scanSpan := startSpan(ctx, "ECR CheckImageScan", serviceName, d.Cluster)
+	blocked, err := c.checkImageScan(ctx, d.Cluster, d.Containers)
+	endSpan(scanSpan, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocked) > 0 {
+		if c.imageScanOverride == nil || c.imageScanOverride.Reason == "" {
+			logger.Errorf("Deploy of %v blocked by image scan policy: %v", serviceName, formatImageScanFindings(blocked))
+			return nil, fmt.Errorf("deploy blocked by image scan policy of cluster %v: %v", d.Cluster, formatImageScanFindings(blocked))
+		}
+		logger.Warningf("Image scan policy overridden by %v (reason: %v): %v", c.imageScanOverride.User, c.imageScanOverride.Reason, formatImageScanFindings(blocked))
+		s.ImageScanOverride = &service.ImageScanOverride{User: c.imageScanOverride.User, Reason: c.imageScanOverride.Reason, Findings: blocked}
+	}
+
 	// create task definition
 	e := ecs.ECS{ServiceName: serviceName, IamRoleArn: *iamRoleArn, ClusterName: d.Cluster}
+	_, taskDefSpan := startSpan(ctx, "CreateTaskDefinition", serviceName, d.Cluster)
 	taskDefArn, err := e.CreateTaskDefinition(d, secrets)
+	endSpan(taskDefSpan, err)
 	if err != nil {
-		controllerLogger.Errorf("Could not create task def %v", serviceName)
+		logger.Errorf("Could not create task def %v", serviceName)
 		return nil, err
 	}
-	controllerLogger.Debugf("Created task definition: %v", *taskDefArn)
+	logger.Debugf("Created task definition: %v", *taskDefArn)
 
 	// update service with new task (update desired instance in case of difference)
-	controllerLogger.Debugf("Updating service: %v with taskdefarn: %v", serviceName, *taskDefArn)
+	logger.Debugf("Updating service: %v with taskdefarn: %v", serviceName, *taskDefArn)
 	serviceExists, err := e.ServiceExists(serviceName)
 	if err == nil && !serviceExists {
-		controllerLogger.Debugf("service (%v) not found, creating...", serviceName)
-		if util.GetEnv("AWS_RESOURCE_CREATION_ENABLED", "yes") == "yes" {
-			s.Listeners, err = c.createService(serviceName, d, taskDefArn)
+		logger.Debugf("service (%v) not found, creating...", serviceName)
+		if serverConfig.AWS.ResourceCreationEnabled == "yes" {
+			s.Listeners, err = c.createService(ctx, serviceName, d, taskDefArn)
 			if err != nil {
-				controllerLogger.Errorf("Could not create service %v: %s", serviceName, err)
+				logger.Errorf("Could not create service %v: %s", serviceName, err)
 				return nil, err
 			}
 			// create service in dynamodb
-			err = c.checkAndCreateServiceInDynamo(s, d)
+			err = c.checkAndCreateServiceInDynamo(ctx, s, d)
 			if err != nil {
 				return nil, err
 			}
@@ -148,51 +179,166 @@ func (c *Controller) Deploy(serviceName string, d service.Deploy) (*service.Depl
 		return nil, errors.New("Error during checking whether service exists")
 	} else {
 		// create service in dynamodb
-		err = c.checkAndCreateServiceInDynamo(s, d)
+		err = c.checkAndCreateServiceInDynamo(ctx, s, d)
 		if err != nil {
 			return nil, err
 		}
-		err = c.updateDeployment(d, ddLast, serviceName, taskDefArn, iamRoleArn)
+		err = c.updateDeployment(ctx, d, ddLast, serviceName, taskDefArn, iamRoleArn)
 		if err != nil {
-			controllerLogger.Errorf("updateDeployment failed: %s", err)
+			logger.Errorf("updateDeployment failed: %s", err)
 		}
 	}
 
+	// reconcile the autoscaling with the deploy file
+	// the deployment continues when it fails, the error is returned in the deploy result and the partial state is saved
+	var autoscalingErr error
+	if d.Autoscaling != nil {
+		var current service.DynamoDeploymentAutoscaling
+		if ddLast != nil {
+			current = ddLast.Scaling.Autoscaling
+		}
+		_, autoscalingSpan := startSpan(ctx, "ApplicationAutoScaling Reconcile", serviceName, d.Cluster)
+		autoscaling, err := c.reconcileServiceAutoscaling(ctx, serviceName, d, current)
+		endSpan(autoscalingSpan, err)
+		if err != nil {
+			logger.Errorf("Could not reconcile autoscaling of %v: %v", serviceName, err)
+			autoscalingErr = err
+		}
+		s.Autoscaling = &autoscaling
+	}
+
 	// Mark previous deployment as aborted if still running
 	if ddLast != nil && ddLast.Status == "running" {
+		_, abortSpan := startSpan(ctx, "DynamoDB SetDeploymentStatus", serviceName, d.Cluster)
 		err = s.SetDeploymentStatus(ddLast, "aborted")
+		endSpan(abortSpan, err)
 		if err != nil {
-			controllerLogger.Errorf("Could not set status of %v to aborted: %v", serviceName, err)
+			logger.Errorf("Could not set status of %v to aborted: %v", serviceName, err)
 			return nil, err
 		}
 	}
 
 	// write changes in db
+	_, dynamoSpan := startSpan(ctx, "DynamoDB NewDeployment", serviceName, d.Cluster)
 	dd, err := s.NewDeployment(taskDefArn, &d)
+	endSpan(dynamoSpan, err)
 	if err != nil {
-		controllerLogger.Errorf("Could not create/update service (%v) in db: %v", serviceName, err)
+		logger.Errorf("Could not create/update service (%v) in db: %v", serviceName, err)
 		return nil, err
 	}
+	logger = logger.With("deploymentTime", dd.Time.Format(time.RFC3339Nano))
+	logger.Infof("Deployment of %v started, waiting for service to become stable", serviceName)
 
 	// run goroutine to update status of service
 	var notification integrations.Notification
-	if util.GetEnv("SLACK_WEBHOOKS", "") != "" {
-		notification = integrations.NewSlack()
+	if serverConfig.Slack.Webhooks != "" {
+		notification = integrations.NewSlack(serverConfig.Slack.Webhooks, serverConfig.Slack.Username, serverConfig.AWS.AccountEnv)
 	} else {
 		notification = integrations.NewDummy()
 	}
-	go e.LaunchWaitUntilServicesStable(dd, ddLast, notification)
+	// the stabilization span outlives the deploy span, it ends when the background wait is finished
+	_, waitSpan := startSpan(ctx, "WaitUntilServicesStable", serviceName, d.Cluster)
+	go func() {
+		err := e.LaunchWaitUntilServicesStable(dd, ddLast, notification)
+		endSpan(waitSpan, err)
+	}()
 
-	ret := &service.DeployResult{
+	ret = &service.DeployResult{
 		ServiceName:       serviceName,
 		ClusterName:       d.Cluster,
 		TaskDefinitionArn: *taskDefArn,
 		DeploymentTime:    dd.Time,
+		RequestID:         requestID,
+		ImageDigests:      dd.ImageDigests,
+	}
+	if autoscalingErr != nil {
+		ret.DeployError = "Could not reconcile autoscaling: " + autoscalingErr.Error()
 	}
 	return ret, nil
 }
 
-func (c *Controller) updateDeployment(d service.Deploy, ddLast *service.DynamoDeployment, serviceName string, taskDefArn *string, iamRoleArn *string) error {
+// resolveImageDigests returns a copy of the containers with the digest set for images in ECR that are referenced by tag
+func (c *Controller) resolveImageDigests(ctx context.Context, containers []*service.DeployContainer) ([]*service.DeployContainer, error) {
+	logger := loggerFromContext(ctx)
+	ecr := ecs.ECR{}
+	tags := make(map[string]map[string]string)
+	var resolved []*service.DeployContainer
+	for _, container := range containers {
+		// images outside ecr (containerURI) and pinned digests are used as-is
+		if container.ContainerURI != "" || container.ContainerDigest != "" {
+			resolved = append(resolved, container)
+			continue
+		}
+		repository := container.ContainerImage
+		if repository == "" {
+			repository = container.ContainerName
+		}
+		tag := container.ContainerTag
+		if tag == "" {
+			tag = "latest"
+		}
+		if _, ok := tags[repository]; !ok {
+			t, err := ecr.ListImagesWithTag(repository)
+			if err != nil {
+				return nil, fmt.Errorf("could not resolve image digest for %v:%v: %v", repository, tag, err)
+			}
+			tags[repository] = t
+		}
+		digest, ok := tags[repository][tag]
+		if !ok {
+			return nil, fmt.Errorf("image %v:%v not found in ECR", repository, tag)
+		}
+		logger.Debugf("Resolved image %v:%v to %v", repository, tag, digest)
+		containerCopy := *container
+		containerCopy.ContainerDigest = digest
+		resolved = append(resolved, &containerCopy)
+	}
+	return resolved, nil
+}
+
+// getOrCreateTaskRole returns the task role of the service, creating it when it doesn't exist yet
+func (c *Controller) getOrCreateTaskRole(ctx context.Context, serviceName string, d service.Deploy) (iamRoleArn *string, err error) {
+	_, span := startSpan(ctx, "IAM GetOrCreateTaskRole", serviceName, d.Cluster)
+	defer func() { endSpan(span, err) }()
+	logger := loggerFromContext(ctx)
+
+	iam := ecs.IAM{}
+	iamRoleArn, err = iam.RoleExists("ecs-" + serviceName)
+	if err == nil && iamRoleArn == nil {
+		if serverConfig.AWS.ResourceCreationEnabled == "yes" {
+			// role does not exist, create it
+			logger.Debugf("Role does not exist, creating: ecs-%v", serviceName)
+			iamRoleArn, err = iam.CreateRoleWithPermissionBoundary("ecs-"+serviceName, iam.GetEcsTaskIAMTrust(), serverConfig.Deploy.TaskRolePermissionBoundaryArn)
+			if err != nil {
+				return nil, err
+			}
+			// optionally add a policy
+			ps := ecs.Paramstore{}
+			if ps.IsEnabled() {
+				namespace := d.EnvNamespace
+				if namespace == "" {
+					namespace = serviceName
+				}
+				logger.Debugf("Paramstore enabled, putting role: paramstore-%v", namespace)
+				err = iam.PutRolePolicy("ecs-"+serviceName, "paramstore-"+namespace, ps.GetParamstoreIAMPolicy(namespace))
+				if err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			return nil, errors.New("IAM Task Role not found and resource creation is disabled")
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return iamRoleArn, nil
+}
+
+func (c *Controller) updateDeployment(ctx context.Context, d service.Deploy, ddLast *service.DynamoDeployment, serviceName string, taskDefArn *string, iamRoleArn *string) (err error) {
+	ctx, span := startSpan(ctx, "UpdateDeployment", serviceName, d.Cluster)
+	defer func() { endSpan(span, err) }()
+	logger := loggerFromContext(ctx)
+
 	s := service.NewService()
 	s.ServiceName = serviceName
 	s.ClusterName = d.Cluster
@@ -214,7 +360,7 @@ func (c *Controller) updateDeployment(d service.Deploy, ddLast *service.DynamoDe
 			}
 			// update healthchecks if changed
 			if !cmp.Equal(ddLast.DeployData.HealthCheck, d.HealthCheck) {
-				controllerLogger.Debugf("Updating ecs healthcheck: %v", serviceName)
+				logger.Debugf("Updating ecs healthcheck: %v", serviceName)
 				alb.UpdateHealthCheck(*targetGroupArn, d.HealthCheck)
 			}
 			// update target group attributes if changed
@@ -230,7 +376,7 @@ func (c *Controller) updateDeployment(d service.Deploy, ddLast *service.DynamoDe
 				noLBChange = true
 			}
 			if strings.ToLower(d.LoadBalancer) != strings.ToLower(ddLast.DeployData.LoadBalancer) && !noLBChange && strings.ToLower(d.ServiceProtocol) != "none" {
-				controllerLogger.Infof("LoadBalancer change detected for service %s", serviceName)
+				logger.Infof("LoadBalancer change detected for service %s", serviceName)
 				// delete old loadbalancer rules
 				var oldAlb *ecs.ALB
 				if ddLast.DeployData.LoadBalancer == "" {
@@ -238,18 +384,20 @@ func (c *Controller) updateDeployment(d service.Deploy, ddLast *service.DynamoDe
 				} else {
 					oldAlb, err = ecs.NewALB(ddLast.DeployData.LoadBalancer)
 				}
+				_, ruleSpan := startSpan(ctx, "ALB DeleteRulesForTarget", serviceName, d.Cluster)
 				err = c.deleteRulesForTarget(serviceName, d, targetGroupArn, oldAlb)
+				endSpan(ruleSpan, err)
 				if err != nil {
 
 				}
 				// delete target group
-				controllerLogger.Debugf("Deleting target group for service: %v", serviceName)
+				logger.Debugf("Deleting target group for service: %v", serviceName)
 				err = oldAlb.DeleteTargetGroup(*targetGroupArn)
 				if err != nil {
 					return err
 				}
 				// create new target group
-				controllerLogger.Debugf("Creating target group for service: %v", serviceName)
+				logger.Debugf("Creating target group for service: %v", serviceName)
 				newTargetGroupArn, err := alb.CreateTargetGroup(serviceName, d)
 				if err != nil {
 					return err
@@ -262,24 +410,30 @@ func (c *Controller) updateDeployment(d service.Deploy, ddLast *service.DynamoDe
 					}
 				}
 				// create new rules
+				_, ruleSpan = startSpan(ctx, "ALB CreateRulesForTarget", serviceName, d.Cluster)
 				listeners, err := c.createRulesForTarget(serviceName, d, newTargetGroupArn, alb)
+				endSpan(ruleSpan, err)
 				s.Listeners = listeners
 				if err != nil {
 					return err
 				}
 				// recreating ecs service
-				controllerLogger.Infof("Recreating ecs service: %v", serviceName)
+				logger.Infof("Recreating ecs service: %v", serviceName)
+				_, recreateSpan := startSpan(ctx, "ECS RecreateService", serviceName, d.Cluster)
 				err = e.DeleteService(d.Cluster, serviceName)
 				if err != nil {
+					endSpan(recreateSpan, err)
 					return err
 				}
 				err = e.WaitUntilServicesInactive(d.Cluster, serviceName)
 				if err != nil {
+					endSpan(recreateSpan, err)
 					return err
 				}
 				// create ecs service
 				e.TargetGroupArn = newTargetGroupArn
 				err = e.CreateService(d)
+				endSpan(recreateSpan, err)
 				if err != nil {
 					return err
 				}
@@ -290,14 +444,18 @@ func (c *Controller) updateDeployment(d service.Deploy, ddLast *service.DynamoDe
 			} else {
 				// check for rules changes
 				if c.rulesChanged(d, ddLast) {
-					controllerLogger.Infof("Recreating alb rules for: " + serviceName)
+					logger.Infof("Recreating alb rules for: " + serviceName)
 					// recreate rules
+					_, ruleSpan := startSpan(ctx, "ALB DeleteRulesForTarget", serviceName, d.Cluster)
 					err = c.deleteRulesForTarget(serviceName, d, targetGroupArn, alb)
+					endSpan(ruleSpan, err)
 					if err != nil {
-						controllerLogger.Infof("Couldn't delete existing rules for target: " + serviceName)
+						logger.Infof("Couldn't delete existing rules for target: " + serviceName)
 					}
 					// create new rules
+					_, ruleSpan = startSpan(ctx, "ALB CreateRulesForTarget", serviceName, d.Cluster)
 					_, err := c.createRulesForTarget(serviceName, d, targetGroupArn, alb)
+					endSpan(ruleSpan, err)
 					if err != nil {
 						return err
 					}
@@ -315,7 +473,7 @@ func (c *Controller) updateDeployment(d service.Deploy, ddLast *service.DynamoDe
 				lastNamespace = serviceName
 			}
 			if thisNamespace != lastNamespace {
-				controllerLogger.Debugf("Paramstore enabled, putting role: paramstore-%v", serviceName)
+				logger.Debugf("Paramstore enabled, putting role: paramstore-%v", serviceName)
 				err = iam.DeleteRolePolicy("ecs-"+serviceName, "paramstore-"+lastNamespace)
 				if err != nil {
 					return err
@@ -335,10 +493,12 @@ func (c *Controller) updateDeployment(d service.Deploy, ddLast *service.DynamoDe
 	// update service
 	if updateECSService {
 		var err error
+		_, updateSpan := startSpan(ctx, "ECS UpdateService", serviceName, d.Cluster)
 		_, err = e.UpdateService(serviceName, taskDefArn, d)
-		controllerLogger.Debugf("Updating ecs service: %v", serviceName)
+		endSpan(updateSpan, err)
+		logger.Debugf("Updating ecs service: %v", serviceName)
 		if err != nil {
-			controllerLogger.Errorf("Could not update service %v: %v", serviceName, err)
+			logger.Errorf("Could not update service %v: %v", serviceName, err)
 			return err
 		}
 	}
@@ -376,6 +536,7 @@ func (c *Controller) redeploy(serviceName, time string) (*service.DeployResult,
 
 	controllerLogger.Debugf("Redeploying %v_%v", serviceName, time)
 
+	// the deploy data contains the image digests resolved during the original deployment, so exactly the same images are deployed
 	ret, err := c.Deploy(serviceName, *dd.DeployData)
 
 	if err != nil {
@@ -386,12 +547,14 @@ func (c *Controller) redeploy(serviceName, time string) (*service.DeployResult,
 }
 
 // service not found, create ALB target group + rule
-func (c *Controller) createService(serviceName string, d service.Deploy, taskDefArn *string) ([]string, error) {
+func (c *Controller) createService(ctx context.Context, serviceName string, d service.Deploy, taskDefArn *string) (listeners []string, err error) {
+	ctx, span := startSpan(ctx, "CreateService", serviceName, d.Cluster)
+	defer func() { endSpan(span, err) }()
+	logger := loggerFromContext(ctx)
+
 	iam := ecs.IAM{}
 	var targetGroupArn *string
-	var listeners []string
 	var alb *ecs.ALB
-	var err error
 	if d.LoadBalancer != "" {
 		alb, err = ecs.NewALB(d.LoadBalancer)
 	} else {
@@ -404,7 +567,7 @@ func (c *Controller) createService(serviceName string, d service.Deploy, taskDef
 	// create target group
 	if strings.ToLower(d.ServiceProtocol) != "none" {
 		var err error
-		controllerLogger.Debugf("Creating target group for service: %v", serviceName)
+		logger.Debugf("Creating target group for service: %v", serviceName)
 		targetGroupArn, err = alb.CreateTargetGroup(serviceName, d)
 		if err != nil {
 			return nil, err
@@ -418,23 +581,25 @@ func (c *Controller) createService(serviceName string, d service.Deploy, taskDef
 		}
 
 		// deploy rules for target group
+		_, ruleSpan := startSpan(ctx, "ALB CreateRulesForTarget", serviceName, d.Cluster)
 		listeners, err = c.createRulesForTarget(serviceName, d, targetGroupArn, alb)
+		endSpan(ruleSpan, err)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// check whether ecs-service-role exists
-	controllerLogger.Debugf("Checking whether role exists: %v", util.GetEnv("AWS_ECS_SERVICE_ROLE", "ecs-service-role"))
-	iamServiceRoleArn, err := iam.RoleExists(util.GetEnv("AWS_ECS_SERVICE_ROLE", "ecs-service-role"))
+	logger.Debugf("Checking whether role exists: %v", serverConfig.AWS.EcsServiceRole)
+	iamServiceRoleArn, err := iam.RoleExists(serverConfig.AWS.EcsServiceRole)
 	if err == nil && iamServiceRoleArn == nil {
-		controllerLogger.Debugf("Creating ecs service role")
-		_, err = iam.CreateRole(util.GetEnv("AWS_ECS_SERVICE_ROLE", "ecs-service-role"), iam.GetEcsServiceIAMTrust())
+		logger.Debugf("Creating ecs service role")
+		_, err = iam.CreateRole(serverConfig.AWS.EcsServiceRole, iam.GetEcsServiceIAMTrust())
 		if err != nil {
 			return nil, err
 		}
-		controllerLogger.Debugf("Attaching ecs service role")
-		err = iam.AttachRolePolicy(util.GetEnv("AWS_ECS_SERVICE_ROLE", "ecs-service-role"), iam.GetEcsServicePolicy())
+		logger.Debugf("Attaching ecs service role")
+		err = iam.AttachRolePolicy(serverConfig.AWS.EcsServiceRole, iam.GetEcsServicePolicy())
 		if err != nil {
 			return nil, err
 		}
@@ -443,20 +608,26 @@ func (c *Controller) createService(serviceName string, d service.Deploy, taskDef
 	}
 
 	// create ecs service
-	controllerLogger.Debugf("Creating ecs service: %v", serviceName)
+	logger.Debugf("Creating ecs service: %v", serviceName)
 	e := ecs.ECS{ServiceName: serviceName, TaskDefArn: taskDefArn, TargetGroupArn: targetGroupArn}
+	_, ecsSpan := startSpan(ctx, "ECS CreateService", serviceName, d.Cluster)
 	err = e.CreateService(d)
+	endSpan(ecsSpan, err)
 	if err != nil {
 		return nil, err
 	}
 	return listeners, nil
 }
-func (c *Controller) checkAndCreateServiceInDynamo(s *service.Service, d service.Deploy) error {
+func (c *Controller) checkAndCreateServiceInDynamo(ctx context.Context, s *service.Service, d service.Deploy) (err error) {
+	_, span := startSpan(ctx, "DynamoDB CreateService", s.ServiceName, s.ClusterName)
+	defer func() { endSpan(span, err) }()
+	logger := loggerFromContext(ctx)
+
 	serviceExistsInDynamo, err := s.ServiceExistsInDynamo()
 	if err == nil && !serviceExistsInDynamo {
 		err = c.createServiceInDynamo(s, d)
 		if err != nil {
-			controllerLogger.Errorf("Could not create service %v in dynamodb", s.ServiceName)
+			logger.Errorf("Could not create service %v in dynamodb", s.ServiceName)
 			return err
 		}
 	}
@@ -612,6 +783,55 @@ func (c *Controller) getServices() ([]*service.DynamoServicesElement, error) {
 	return ds.Services, err
 }
 
+// describeClusterTasks returns the running tasks of the services in a cluster, with the instances they run on and their static host ports
+func (c *Controller) describeClusterTasks(clusterName string) (clusterTasks, error) {
+	tasks := clusterTasks{instanceIds: make(map[string]string), hostPorts: make(map[string][]int64)}
+	e := ecs.ECS{}
+	dss, err := c.getServices()
+	if err != nil {
+		return tasks, err
+	}
+	var serviceNames []*string
+	for _, ds := range dss {
+		if ds.C == clusterName {
+			serviceNames = append(serviceNames, &ds.S)
+		}
+	}
+	if len(serviceNames) == 0 {
+		return tasks, nil
+	}
+	tasks.services, err = e.DescribeServices(clusterName, serviceNames, false, true, false)
+	if err != nil {
+		return tasks, err
+	}
+	cis, err := c.getClusterContainerInstances(clusterName, &e)
+	if err != nil {
+		return tasks, err
+	}
+	for _, ci := range cis {
+		tasks.instanceIds[ci.ContainerInstanceArn] = ci.Ec2InstanceId
+	}
+	for _, rs := range tasks.services {
+		var taskDefinitions []string
+		for _, deployment := range rs.Deployments {
+			taskDefinitions = append(taskDefinitions, deployment.TaskDefinition)
+		}
+		for _, task := range rs.Tasks {
+			taskDefinitions = append(taskDefinitions, task.TaskDefinitionArn)
+		}
+		for _, taskDefinition := range taskDefinitions {
+			if _, ok := tasks.hostPorts[taskDefinition]; ok {
+				continue
+			}
+			tasks.hostPorts[taskDefinition], err = e.GetTaskDefinitionHostPorts(taskDefinition)
+			if err != nil {
+				return tasks, err
+			}
+		}
+	}
+	return tasks, nil
+}
+
 func (c *Controller) describeServices() ([]service.RunningService, error) {
 	var rss []service.RunningService
 	showEvents := false
@@ -725,14 +945,14 @@ func (c *Controller) getDeployment(serviceName, time string) (*service.Deploy, e
 func (c *Controller) getServiceParameters(serviceName, userId, creds string) (map[string]ecs.Parameter, string, error) {
 	var err error
 	p := ecs.Paramstore{}
-	role := util.GetEnv("PARAMSTORE_ASSUME_ROLE", "")
+	role := serverConfig.Paramstore.AssumeRole
 	if role != "" {
 		creds, err = p.AssumeRole(role, userId, creds)
 		if err != nil {
 			return p.Parameters, creds, err
 		}
 	}
-	err = p.GetParameters("/"+util.GetEnv("PARAMSTORE_PREFIX", "")+"-"+util.GetEnv("AWS_ACCOUNT_ENV", "")+"/"+serviceName+"/", false)
+	err = p.GetParameters("/"+serverConfig.Paramstore.Prefix+"-"+serverConfig.AWS.AccountEnv+"/"+serviceName+"/", false)
 	if err != nil {
 		return p.Parameters, creds, err
 	}
@@ -742,7 +962,7 @@ func (c *Controller) putServiceParameter(serviceName, userId, creds string, para
 	var err error
 	p := ecs.Paramstore{}
 	res := make(map[string]int64)
-	role := util.GetEnv("PARAMSTORE_ASSUME_ROLE", "")
+	role := serverConfig.Paramstore.AssumeRole
 	if role != "" {
 		creds, err = p.AssumeRole(role, userId, creds)
 		if err != nil {
@@ -758,7 +978,7 @@ func (c *Controller) putServiceParameter(serviceName, userId, creds string, para
 func (c *Controller) deleteServiceParameter(serviceName, userId, creds, parameter string) (string, error) {
 	var err error
 	p := ecs.Paramstore{}
-	role := util.GetEnv("PARAMSTORE_ASSUME_ROLE", "")
+	role := serverConfig.Paramstore.AssumeRole
 	if role != "" {
 		creds, err = p.AssumeRole(role, userId, creds)
 		if err != nil {
@@ -795,7 +1015,25 @@ func (c *Controller) deleteService(serviceName string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.deleteServiceDiscovery(serviceName)
+}
+
+// deleteServiceDiscovery deletes the service discovery service of the last deployment of the service
+func (c *Controller) deleteServiceDiscovery(serviceName string) error {
+	s := service.NewService()
+	s.ServiceName = serviceName
+	dd, err := s.GetLastDeploy()
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "NoItemsFound") {
+			return nil
+		}
+		return err
+	}
+	if dd.DeployData == nil || dd.DeployData.ServiceRegistry == "" {
+		return nil
+	}
+	sd := ecs.ServiceDiscovery{}
+	return sd.DeleteService(serviceName, dd.DeployData.ServiceRegistry)
 }
 func (c *Controller) scaleService(serviceName string, desiredCount int64) error {
 	s := service.NewService()
@@ -884,7 +1122,7 @@ func (c *Controller) listTasks(serviceName string) ([]service.RunningTask, error
 }
 func (c *Controller) getServiceLogs(serviceName, taskArn, containerName string, start, end time.Time) (ecs.CloudWatchLog, error) {
 	cw := ecs.CloudWatch{}
-	return cw.GetLogEventsByTime(util.GetEnv("CLOUDWATCH_LOGS_PREFIX", "")+"-"+util.GetEnv("AWS_ACCOUNT_ENV", ""), containerName+"/"+containerName+"/"+taskArn, start, end, "")
+	return cw.GetLogEventsByTime(serverConfig.Deploy.CloudwatchLogsPrefix+"-"+serverConfig.AWS.AccountEnv, containerName+"/"+containerName+"/"+taskArn, start, end, "")
 }
 
 func (c *Controller) Resume() error {
@@ -927,8 +1165,8 @@ func (c *Controller) Resume() error {
 				ddLast = dds[i-1]
 			}
 			var notification integrations.Notification
-			if util.GetEnv("SLACK_WEBHOOKS", "") != "" {
-				notification = integrations.NewSlack()
+			if serverConfig.Slack.Webhooks != "" {
+				notification = integrations.NewSlack(serverConfig.Slack.Webhooks, serverConfig.Slack.Username, serverConfig.AWS.AccountEnv)
 			} else {
 				notification = integrations.NewDummy()
 			}
@@ -1001,13 +1239,21 @@ func (c *Controller) Resume() error {
 			if len(cis) == 0 {
 				return errors.New("Couldn't retrieve any EC2 Container instances")
 			}
-			f, err := e.ConvertResourceToRir(cis[0].RegisteredResources)
-			if err != nil {
-				return err
+			// instances can have different instance types, use the largest instance
+			var rirs []ecs.RegisteredInstanceResource
+			for _, ci := range cis {
+				rir, err := e.ConvertResourceToRir(ci.RegisteredResources)
+				if err != nil {
+					return err
+				}
+				rirs = append(rirs, rir)
 			}
 			asc := AutoscalingController{}
-			registeredInstanceCpu := f.RegisteredCpu
-			registeredInstanceMemory := f.RegisteredMemory
+			if asc.isManagedScalingEnabled(clusterName) {
+				controllerLogger.Infof("Cluster %v uses managed scaling - skipping pending scaling operations", clusterName)
+				continue
+			}
+			registeredInstanceCpu, registeredInstanceMemory := getLargestRegisteredResources(rirs)
 			for _, scalingOp := range []string{"up", "down"} {
 				period, interval := asc.getAutoscalingPeriodInterval(scalingOp)
 				startTime := time.Now().Add(-1 * time.Duration(period) * time.Duration(interval) * time.Second)
@@ -1025,7 +1271,7 @@ func (c *Controller) Resume() error {
 		}
 	}
 	// Start autoscaling polling if enabled
-	autoscalingStrategies := strings.Split(util.GetEnv("AUTOSCALING_STRATEGIES", ""), ",")
+	autoscalingStrategies := strings.Split(serverConfig.Autoscaling.Strategies, ",")
 	for _, v := range autoscalingStrategies {
 		if strings.ToLower(v) == "polling" {
 			asc := AutoscalingController{}
@@ -1033,6 +1279,19 @@ func (c *Controller) Resume() error {
 			go asc.startAutoscalingPollingStrategy()
 		}
 	}
+	// Resume cluster upgrades
+	if err := c.resumeClusterUpgrades(); err != nil {
+		controllerLogger.Errorf("Could not resume cluster upgrades: %v", err)
+	}
+	// Start drift detection if enabled
+	if serverConfig.DriftDetection.Interval != "" {
+		interval, err := time.ParseDuration(serverConfig.DriftDetection.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid DRIFT_DETECTION_INTERVAL: %v", err)
+		}
+		controllerLogger.Debugf("Starting drift detection in goroutine")
+		go c.startDriftDetection(interval)
+	}
 	controllerLogger.Debugf("Finished controller resume. Checked %d services", len(dds))
 	return err
 }
@@ -1176,14 +1435,28 @@ func (c *Controller) Bootstrap(b *Flags) error {
 		}
 	}
 
-	// create launch configuration
-	err = autoscaling.CreateLaunchConfiguration(b.ClusterName, b.KeyName, b.InstanceType, instanceProfile, strings.Split(b.EcsSecurityGroups, ","))
+	// create launch template
+	mixedInstances, err := getMixedInstancesConfig(b)
+	if err != nil {
+		return err
+	}
+	err = ec2.CreateLaunchTemplate(b.ClusterName, b.KeyName, mixedInstances.InstanceTypes[0], instanceProfile, strings.Split(b.EcsSecurityGroups, ","))
+	if err != nil {
+		return err
+	}
+
+	// create autoscaling group
+	intEcsDesiredSize, _ := strconv.ParseInt(b.EcsDesiredSize, 10, 64)
+	intEcsMaxSize, _ := strconv.ParseInt(b.EcsMaxSize, 10, 64)
+	intEcsMinSize, _ := strconv.ParseInt(b.EcsMinSize, 10, 64)
+	// managed termination protection of the capacity provider requires scale-in protection on new instances
+	err = autoscaling.CreateAutoScalingGroup(b.ClusterName, intEcsDesiredSize, intEcsMaxSize, intEcsMinSize, strings.Split(b.EcsSubnets, ","), mixedInstances, b.CapacityProvider)
 	if err != nil {
 		for i := 0; i < 5 && err != nil; i++ {
 			if strings.HasPrefix(err.Error(), "RetryableError:") {
 				fmt.Printf("Error: %v - waiting 10s and retrying...\n", err.Error())
 				time.Sleep(10 * time.Second)
-				err = autoscaling.CreateLaunchConfiguration(b.ClusterName, b.KeyName, b.InstanceType, instanceProfile, strings.Split(b.EcsSecurityGroups, ","))
+				err = autoscaling.CreateAutoScalingGroup(b.ClusterName, intEcsDesiredSize, intEcsMaxSize, intEcsMinSize, strings.Split(b.EcsSubnets, ","), mixedInstances, b.CapacityProvider)
 			}
 		}
 		if err != nil {
@@ -1191,15 +1464,6 @@ func (c *Controller) Bootstrap(b *Flags) error {
 		}
 	}
 
-	// create autoscaling group
-	intEcsDesiredSize, _ := strconv.ParseInt(b.EcsDesiredSize, 10, 64)
-	intEcsMaxSize, _ := strconv.ParseInt(b.EcsMaxSize, 10, 64)
-	intEcsMinSize, _ := strconv.ParseInt(b.EcsMinSize, 10, 64)
-	autoscaling.CreateAutoScalingGroup(b.ClusterName, intEcsDesiredSize, intEcsMaxSize, intEcsMinSize, strings.Split(b.EcsSubnets, ","))
-	if err != nil {
-		return err
-	}
-
 	// create log group
 	if b.CloudwatchLogsEnabled {
 		err = cloudwatch.CreateLogGroup(b.ClusterName, b.CloudwatchLogsPrefix+"-"+b.Environment)
@@ -1213,6 +1477,12 @@ func (c *Controller) Bootstrap(b *Flags) error {
 		return err
 	}
 	fmt.Printf("Created ECS Cluster with ARN: %v\n", *clusterArn)
+	if b.CapacityProvider {
+		err = c.createCapacityProvider(b)
+		if err != nil {
+			return err
+		}
+	}
 	if len(b.LoadBalancers) == 0 {
 		b.LoadBalancers = []service.LoadBalancer{
 			{
@@ -1262,12 +1532,18 @@ func (c *Controller) Bootstrap(b *Flags) error {
 			parameters = append(parameters, service.DeployServiceParameter{Name: "CLOUDWATCH_LOGS_PREFIX", Value: b.CloudwatchLogsPrefix})
 		}
 		paramstore.Bootstrap("ecs-deploy", b.ParamstorePrefix, b.Environment, parameters)
-		// retrieve keys from parameter store and set as environment variable
-		os.Setenv("PARAMSTORE_ENABLED", "yes")
-		err = paramstore.RetrieveKeys()
-		if err != nil {
+		// load the configuration from the parameter store, like the server does at startup
+		config := NewConfig()
+		if err = config.loadEnv(); err != nil {
+			return err
+		}
+		config.Paramstore.Enabled = "yes"
+		config.Paramstore.Prefix = b.ParamstorePrefix
+		config.AWS.AccountEnv = b.Environment
+		if err = config.loadParamstore(); err != nil {
 			return err
 		}
+		SetServerConfig(config)
 	}
 
 	// wait for autoscaling group to be in service
@@ -1331,6 +1607,87 @@ func (c *Controller) Bootstrap(b *Flags) error {
 	return nil
 }
 
+// getMixedInstancesConfig returns the instance types (the instance type first) and the on-demand/spot distribution of the bootstrap flags
+func getMixedInstancesConfig(b *Flags) (ecs.MixedInstancesConfig, error) {
+	var mixedInstances ecs.MixedInstancesConfig
+	for _, v := range append([]string{b.InstanceType}, strings.Split(b.InstanceTypes, ",")...) {
+		v = strings.TrimSpace(v)
+		if found, _ := util.InArray(mixedInstances.InstanceTypes, v); v != "" && !found {
+			mixedInstances.InstanceTypes = append(mixedInstances.InstanceTypes, v)
+		}
+	}
+	if len(mixedInstances.InstanceTypes) == 0 {
+		return mixedInstances, fmt.Errorf("no instance type specified")
+	}
+	var err error
+	mixedInstances.OnDemandBaseCapacity, err = strconv.ParseInt(b.OnDemandBaseCapacity, 10, 64)
+	if err != nil || mixedInstances.OnDemandBaseCapacity < 0 {
+		return mixedInstances, fmt.Errorf("invalid on-demand base capacity: %v", b.OnDemandBaseCapacity)
+	}
+	mixedInstances.OnDemandPercentageAboveBaseCapacity, err = strconv.ParseInt(b.OnDemandPercentage, 10, 64)
+	if err != nil || mixedInstances.OnDemandPercentageAboveBaseCapacity < 0 || mixedInstances.OnDemandPercentageAboveBaseCapacity > 100 {
+		return mixedInstances, fmt.Errorf("invalid on-demand percentage: %v (must be between 0 and 100)", b.OnDemandPercentage)
+	}
+	if found, _ := util.InArray([]string{"lowest-price", "capacity-optimized", "capacity-optimized-prioritized", "price-capacity-optimized"}, b.SpotAllocationStrategy); !found {
+		return mixedInstances, fmt.Errorf("invalid spot allocation strategy: %v", b.SpotAllocationStrategy)
+	}
+	mixedInstances.SpotAllocationStrategy = b.SpotAllocationStrategy
+	return mixedInstances, nil
+}
+
+// createCapacityProvider links the autoscaling group of the cluster to a capacity provider with managed scaling
+func (c *Controller) createCapacityProvider(b *Flags) error {
+	e := ecs.ECS{}
+	autoscaling := ecs.AutoScaling{}
+	targetCapacity, err := strconv.ParseInt(b.CapacityProviderTargetCapacity, 10, 64)
+	if err != nil || targetCapacity < 1 || targetCapacity > 100 {
+		return fmt.Errorf("invalid capacity provider target capacity: %v (must be between 1 and 100)", b.CapacityProviderTargetCapacity)
+	}
+	autoScalingGroupArn, err := autoscaling.GetAutoScalingGroupArn(b.ClusterName)
+	if err != nil {
+		return err
+	}
+	capacityProviderName := ecs.GetCapacityProviderName(b.ClusterName)
+	err = e.CreateCapacityProvider(capacityProviderName, autoScalingGroupArn, targetCapacity)
+	if err != nil {
+		return err
+	}
+	err = e.PutClusterCapacityProviders(b.ClusterName, []string{capacityProviderName})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created capacity provider %v with managed scaling (target capacity: %d%%)\n", capacityProviderName, targetCapacity)
+	return nil
+}
+
+// deleteCapacityProviders removes the capacity providers from the cluster and deletes them
+func (c *Controller) deleteCapacityProviders(clusterName string) error {
+	e := ecs.ECS{}
+	capacityProviders, err := e.GetClusterCapacityProviders(clusterName)
+	if err != nil {
+		return err
+	}
+	if len(capacityProviders) == 0 {
+		return nil
+	}
+	err = e.PutClusterCapacityProviders(clusterName, []string{})
+	if err != nil {
+		return err
+	}
+	for _, v := range capacityProviders {
+		// fargate capacity providers are managed by AWS
+		if strings.HasPrefix(v, "FARGATE") {
+			continue
+		}
+		err = e.DeleteCapacityProvider(v)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted capacity provider %v\n", v)
+	}
+	return nil
+}
+
 func (c *Controller) DeleteCluster(b *Flags) error {
 	iam := ecs.IAM{}
 	e := ecs.ECS{}
@@ -1338,6 +1695,7 @@ func (c *Controller) DeleteCluster(b *Flags) error {
 	clusterName := b.ClusterName
 	roleName := "ecs-" + clusterName
 	cloudwatch := ecs.CloudWatch{}
+	ec2 := ecs.EC2{}
 	err := autoscaling.DeleteAutoScalingGroup(clusterName, true)
 	if err != nil && !strings.Contains(err.Error(), "AutoScalingGroup name not found") {
 		return err
@@ -1408,17 +1766,29 @@ func (c *Controller) DeleteCluster(b *Flags) error {
 			if err != nil {
 				return err
 			}
+			err = c.deleteServiceDiscovery(v.ServiceName)
+			if err != nil {
+				fmt.Printf("Could not delete service discovery service of %v: %v\n", v.ServiceName, err)
+			}
 		}
 		err = alb.DeleteLoadBalancer()
 		if err != nil {
 			return err
 		}
 	}
+	err = c.deleteCapacityProviders(clusterName)
+	if err != nil {
+		return err
+	}
 	fmt.Println("Wait for autoscaling group deletion")
 	err = autoscaling.WaitForAutoScalingGroupNotExists(clusterName)
 	if err != nil {
 		return err
 	}
+	err = ec2.DeleteLaunchTemplate(clusterName)
+	if err != nil && !strings.Contains(err.Error(), "InvalidLaunchTemplateName.NotFoundException") {
+		return err
+	}
 	var drained bool
 	fmt.Println("Waiting for EC2 instances to drain from ECS cluster")
 	for i := 0; i < 5 && !drained; i++ {
@@ -1442,7 +1812,6 @@ func (c *Controller) DeleteCluster(b *Flags) error {
 	}
 
 	// delete security groups
-	ec2 := ecs.EC2{}
 
 	clusterSecGroupId, err := ec2.GetSecurityGroupID("ecs-deploy-cluster-sg")
 	if err == nil && clusterSecGroupId != "" {
@@ -1467,15 +1836,10 @@ func (c *Controller) DeleteCluster(b *Flags) error {
 
 func (c *Controller) putServiceAutoscaling(serviceName string, autoscaling service.Autoscaling) (string, error) {
 	var result string
-	var writeChanges bool
 	// validation
 	if autoscaling.MinimumCount == 0 && autoscaling.MaximumCount == 0 {
 		return result, errors.New("minimumCount / maximumCount missing")
 	}
-	// autoscaling
-	as := ecs.AutoScaling{}
-	cloudwatch := ecs.CloudWatch{}
-	iam := ecs.IAM{}
 	s := service.NewService()
 	s.ServiceName = serviceName
 	clusterName, err := s.GetClusterName()
@@ -1487,103 +1851,267 @@ func (c *Controller) putServiceAutoscaling(serviceName string, autoscaling servi
 		return result, err
 	}
 	resourceId := "service/" + clusterName + "/" + serviceName
+	policyNames, err := c.applyServiceAutoscaling(serviceName, clusterName, *dd.DeployData, dd.Scaling.Autoscaling, autoscaling, false)
+	if err != nil {
+		return result, err
+	}
+	writeChanges := dd.Scaling.Autoscaling.ResourceId == "" || len(policyNames) != len(dd.Scaling.Autoscaling.PolicyNames)
+	// change desired count if necessary
+	if dd.Scaling.DesiredCount != autoscaling.DesiredCount {
+		e := ecs.ECS{}
+		e.ManualScaleService(clusterName, serviceName, autoscaling.DesiredCount)
+		writeChanges = true
+	}
+
+	if writeChanges {
+		err = s.SetAutoscalingProperties(autoscaling.DesiredCount, resourceId, policyNames)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return "OK", nil
+}
+
+// applyServiceAutoscaling registers the scalable target and puts the policies and scheduled actions.
+// It returns the policy names of the service: the current names with the new policies added.
+// Existing step scaling policies are only updated when updateExisting is set, target tracking policies and scheduled actions are always updated
+func (c *Controller) applyServiceAutoscaling(serviceName, clusterName string, d service.Deploy, current service.DynamoDeploymentAutoscaling, autoscaling service.Autoscaling, updateExisting bool) ([]string, error) {
+	as := ecs.AutoScaling{}
+	cloudwatch := ecs.CloudWatch{}
+	iam := ecs.IAM{}
+	policyNames := append([]string{}, current.PolicyNames...)
+	resourceId := "service/" + clusterName + "/" + serviceName
 	// check whether iam role exists
 	var autoscalingRoleArn *string
 	autoscalingRoleName := "ecs-app-autoscaling-role"
-	autoscalingRoleArn, err = iam.RoleExists(autoscalingRoleName)
+	autoscalingRoleArn, err := iam.RoleExists(autoscalingRoleName)
 	if err != nil {
-		return result, err
+		return policyNames, err
 	}
 	if err == nil && autoscalingRoleArn == nil {
 		autoscalingRoleArn, err = iam.CreateRole(autoscalingRoleName, iam.GetEcsAppAutoscalingIAMTrust())
 		if err != nil {
-			return result, err
+			return policyNames, err
 		}
 		err = iam.AttachRolePolicy(autoscalingRoleName, "arn:aws:iam::aws:policy/service-role/AmazonEC2ContainerServiceAutoscaleRole")
 		if err != nil {
-			return result, err
+			return policyNames, err
 		}
 	}
 	// register scalable target
-	if dd.Scaling.Autoscaling.ResourceId == "" {
+	if current.ResourceId == "" {
 		err = as.RegisterScalableTarget(autoscaling.MinimumCount, autoscaling.MaximumCount, resourceId, *autoscalingRoleArn)
 		if err != nil {
-			return result, err
+			return policyNames, err
 		}
 	} else {
 		// describe -> check difference, apply difference
 		a, err := as.DescribeScalableTargets([]string{resourceId})
 		if err != nil {
-			return result, err
+			return policyNames, err
 		}
 		if len(a) == 0 {
-			return result, errors.New("Couldn't describe scalable target")
+			return policyNames, errors.New("Couldn't describe scalable target")
 		}
 		if a[0].MinimumCount != autoscaling.MinimumCount || a[0].MaximumCount != autoscaling.MaximumCount {
 			err = as.RegisterScalableTarget(autoscaling.MinimumCount, autoscaling.MaximumCount, resourceId, *autoscalingRoleArn)
 			if err != nil {
-				return result, err
+				return policyNames, err
 			}
 		}
 	}
-	// change desired count if necessary
-	if dd.Scaling.DesiredCount != autoscaling.DesiredCount {
-		e := ecs.ECS{}
-		e.ManualScaleService(clusterName, serviceName, autoscaling.DesiredCount)
-		writeChanges = true
-	}
 	// Add Autoscaling policy
-	if len(autoscaling.Policies) > 0 {
-		for _, p := range autoscaling.Policies {
-			// autoscaling up or down?
-			var autoscalingType string
-			if p.ScalingAdjustment > 0 {
-				autoscalingType = "up"
-			} else {
-				autoscalingType = "down"
+	for _, p := range autoscaling.Policies {
+		policyName := getAutoscalingPolicyName(serviceName, p)
+		// metric name
+		var metricName, metricNamespace string
+		if p.Metric == "cpu" {
+			metricName = "CPUUtilization"
+			metricNamespace = "AWS/ECS"
+		} else {
+			metricName = "MemoryUtilization"
+			metricNamespace = "AWS/ECS"
+		}
+		exists, _ := util.InArray(policyNames, policyName)
+		if !exists || updateExisting {
+			// put scaling policy
+			scalingPolicyArn, err := as.PutScalingPolicy(policyName, resourceId, 300, p.ScalingAdjustment)
+			if err != nil {
+				return policyNames, err
 			}
-			// metric name
-			var metricName, metricNamespace string
-			if p.Metric == "cpu" {
-				metricName = "CPUUtilization"
-				metricNamespace = "AWS/ECS"
-			} else {
-				metricName = "MemoryUtilization"
-				metricNamespace = "AWS/ECS"
+			// put metric alarm
+			err = cloudwatch.PutMetricAlarm(serviceName, clusterName, policyName, []string{scalingPolicyArn}, policyName, p.DatapointsToAlarm, metricName, metricNamespace, p.Period, p.Threshold, strings.Title(p.ComparisonOperator), strings.Title(p.ThresholdStatistic), p.EvaluationPeriods)
+			if err != nil {
+				return policyNames, err
 			}
-			// autoscaling policies
-			autoscalingPolicyArns := make(map[string]struct{})
-			for _, v := range dd.Scaling.Autoscaling.PolicyNames {
-				autoscalingPolicyArns[v] = struct{}{}
+		}
+		if !exists {
+			policyNames = append(policyNames, policyName)
+		}
+	}
+	// Add target tracking policies (put updates existing policies)
+	var resourceLabel string
+	for _, p := range autoscaling.TargetTrackingPolicies {
+		if p.TargetValue <= 0 {
+			return policyNames, fmt.Errorf("targetValue missing for target tracking policy with metric %v", p.Metric)
+		}
+		if p.Metric == "albRequestCountPerTarget" && resourceLabel == "" {
+			resourceLabel, err = c.getResourceLabel(serviceName, d)
+			if err != nil {
+				return policyNames, err
 			}
-			// set policy name
-			policyName := serviceName + "-" + p.Metric + "-" + autoscalingType
-			if _, exists := autoscalingPolicyArns[policyName]; !exists {
-				// put scaling policy
-				scalingPolicyArn, err := as.PutScalingPolicy(policyName, resourceId, 300, p.ScalingAdjustment)
-				if err != nil {
-					return result, err
-				}
-				// put metric alarm
-				err = cloudwatch.PutMetricAlarm(serviceName, clusterName, policyName, []string{scalingPolicyArn}, policyName, p.DatapointsToAlarm, metricName, metricNamespace, p.Period, p.Threshold, strings.Title(p.ComparisonOperator), strings.Title(p.ThresholdStatistic), p.EvaluationPeriods)
-				if err != nil {
-					return result, err
-				}
-				// write changes to the database
-				writeChanges = true
-				dd.Scaling.Autoscaling.PolicyNames = append(dd.Scaling.Autoscaling.PolicyNames, policyName)
+		}
+		policyName := getTargetTrackingPolicyName(serviceName, p)
+		_, err = as.PutTargetTrackingScalingPolicy(policyName, resourceId, resourceLabel, p)
+		if err != nil {
+			return policyNames, err
+		}
+		if exists, _ := util.InArray(policyNames, policyName); !exists {
+			policyNames = append(policyNames, policyName)
+		}
+	}
+	// Add scheduled actions (put updates existing actions)
+	for _, action := range autoscaling.ScheduledActions {
+		err = as.PutScheduledAction(resourceId, action)
+		if err != nil {
+			return policyNames, err
+		}
+	}
+	return policyNames, nil
+}
+
+// getAutoscalingPolicyName returns the name of a step scaling policy: <service>-<metric>-<up|down>
+func getAutoscalingPolicyName(serviceName string, p service.AutoscalingPolicy) string {
+	// autoscaling up or down?
+	if p.ScalingAdjustment > 0 {
+		return serviceName + "-" + p.Metric + "-up"
+	}
+	return serviceName + "-" + p.Metric + "-down"
+}
+
+// getTargetTrackingPolicyName returns the name of a target tracking policy, <service>-<metric>-target when the name is not set
+func getTargetTrackingPolicyName(serviceName string, p service.AutoscalingTargetTrackingPolicy) string {
+	if p.PolicyName != "" {
+		return p.PolicyName
+	}
+	return serviceName + "-" + p.Metric + "-target"
+}
+
+// reconcileServiceAutoscaling applies the autoscaling block of the deploy file and deletes the policies and scheduled actions that are not declared.
+// The autoscaling of services without autoscaling block is left untouched, an autoscaling block without maximumCount removes the autoscaling
+func (c *Controller) reconcileServiceAutoscaling(ctx context.Context, serviceName string, d service.Deploy, current service.DynamoDeploymentAutoscaling) (service.DynamoDeploymentAutoscaling, error) {
+	logger := loggerFromContext(ctx)
+	as := ecs.AutoScaling{}
+	cloudwatch := ecs.CloudWatch{}
+	if d.Autoscaling == nil {
+		return current, nil
+	}
+	if d.Autoscaling.MaximumCount == 0 {
+		if current.ResourceId == "" {
+			return current, nil
+		}
+		logger.Infof("Removing autoscaling of %v", serviceName)
+		err := c.removeServiceAutoscaling(current)
+		if err != nil {
+			return current, err
+		}
+		return service.DynamoDeploymentAutoscaling{}, nil
+	}
+	resourceId := "service/" + d.Cluster + "/" + serviceName
+	policyNames, err := c.applyServiceAutoscaling(serviceName, d.Cluster, d, current, *d.Autoscaling, true)
+	if err != nil {
+		// keep the policies that were created before the error
+		return service.DynamoDeploymentAutoscaling{ResourceId: resourceId, PolicyNames: policyNames}, err
+	}
+	return c.deleteUndeclaredAutoscaling(ctx, serviceName, d, resourceId, policyNames, &as, &cloudwatch)
+}
+
+// deleteUndeclaredAutoscaling deletes the policies and scheduled actions that are not in the deploy file and returns the remaining policies.
+// Policies and alarms that are already deleted are skipped, so a deploy after a partial failure removes the remaining policies
+func (c *Controller) deleteUndeclaredAutoscaling(ctx context.Context, serviceName string, d service.Deploy, resourceId string, policyNames []string, as ecs.ApplicationAutoScalingIf, cloudwatch ecs.CloudWatchIf) (service.DynamoDeploymentAutoscaling, error) {
+	logger := loggerFromContext(ctx)
+	declared := make(map[string]bool)
+	for _, p := range d.Autoscaling.Policies {
+		declared[getAutoscalingPolicyName(serviceName, p)] = true
+	}
+	for _, p := range d.Autoscaling.TargetTrackingPolicies {
+		declared[getTargetTrackingPolicyName(serviceName, p)] = true
+	}
+	var newPolicyNames []string
+	for i, policyName := range policyNames {
+		if declared[policyName] {
+			newPolicyNames = append(newPolicyNames, policyName)
+			continue
+		}
+		logger.Infof("Deleting autoscaling policy %v: not in deploy file", policyName)
+		err := deleteAutoscalingPolicy(as, cloudwatch, policyName, resourceId)
+		if err != nil {
+			// keep the policies that are not deleted yet
+			return service.DynamoDeploymentAutoscaling{ResourceId: resourceId, PolicyNames: append(newPolicyNames, policyNames[i:]...)}, err
+		}
+	}
+	// delete the scheduled actions that are not in the deploy file
+	actions, err := as.DescribeScheduledActions(resourceId)
+	if err != nil {
+		return service.DynamoDeploymentAutoscaling{ResourceId: resourceId, PolicyNames: newPolicyNames}, err
+	}
+	for _, action := range actions {
+		found := false
+		for _, v := range d.Autoscaling.ScheduledActions {
+			if v.ActionName == action.ActionName {
+				found = true
+			}
+		}
+		if !found {
+			logger.Infof("Deleting scheduled action %v: not in deploy file", action.ActionName)
+			err = as.DeleteScheduledAction(action.ActionName, resourceId)
+			if err != nil {
+				return service.DynamoDeploymentAutoscaling{ResourceId: resourceId, PolicyNames: newPolicyNames}, err
 			}
 		}
 	}
+	return service.DynamoDeploymentAutoscaling{ResourceId: resourceId, PolicyNames: newPolicyNames}, nil
+}
 
-	if writeChanges {
-		err = s.SetAutoscalingProperties(autoscaling.DesiredCount, resourceId, dd.Scaling.Autoscaling.PolicyNames)
+// deleteAutoscalingPolicy deletes a scaling policy and the alarm of a step scaling policy (target tracking policies have no alarm with the policy name)
+func deleteAutoscalingPolicy(as ecs.ApplicationAutoScalingIf, cloudwatch ecs.CloudWatchIf, policyName, resourceId string) error {
+	err := as.DeleteScalingPolicy(policyName, resourceId)
+	if err != nil {
+		return err
+	}
+	return cloudwatch.DeleteAlarms([]string{policyName})
+}
+
+// removeServiceAutoscaling deletes the policies, alarms and scalable target (which also deletes the scheduled actions)
+func (c *Controller) removeServiceAutoscaling(current service.DynamoDeploymentAutoscaling) error {
+	autoscaling := ecs.AutoScaling{}
+	cloudwatch := ecs.CloudWatch{}
+	for _, policyName := range current.PolicyNames {
+		err := deleteAutoscalingPolicy(&autoscaling, &cloudwatch, policyName, current.ResourceId)
 		if err != nil {
-			return result, err
+			return err
 		}
 	}
 
-	return "OK", nil
+	return autoscaling.DeregisterScalableTarget(current.ResourceId)
+}
+
+// getResourceLabel returns the resource label of the target group of the service, used by the ALBRequestCountPerTarget metric
+func (c *Controller) getResourceLabel(serviceName string, d service.Deploy) (string, error) {
+	loadBalancer := d.Cluster
+	if d.LoadBalancer != "" {
+		loadBalancer = d.LoadBalancer
+	}
+	alb, err := ecs.NewALB(loadBalancer)
+	if err != nil {
+		return "", err
+	}
+	targetGroupArn, err := alb.GetTargetGroupArn(serviceName)
+	if err != nil {
+		return "", err
+	}
+	return alb.GetResourceLabel(*targetGroupArn)
 }
 func (c *Controller) getServiceAutoscaling(serviceName string) (service.Autoscaling, error) {
 	var a service.Autoscaling
@@ -1622,7 +2150,14 @@ func (c *Controller) getServiceAutoscaling(serviceName string) (service.Autoscal
 	a.DesiredCount = runningService.DesiredCount
 
 	// get policy
-	apsPolicy, err := autoscaling.DescribeScalingPolicies(dd.Scaling.Autoscaling.PolicyNames, dd.Scaling.Autoscaling.ResourceId)
+	apsPolicy, targetTrackingPolicies, err := autoscaling.DescribeScalingPolicies(dd.Scaling.Autoscaling.PolicyNames, dd.Scaling.Autoscaling.ResourceId)
+	if err != nil {
+		return a, err
+	}
+	a.TargetTrackingPolicies = targetTrackingPolicies
+
+	// get scheduled actions
+	a.ScheduledActions, err = autoscaling.DescribeScheduledActions(dd.Scaling.Autoscaling.ResourceId)
 	if err != nil {
 		return a, err
 	}
@@ -1670,15 +2205,20 @@ func (c *Controller) deleteServiceAutoscalingPolicy(serviceName, policyName stri
 		}
 	}
 	if !found {
+		// scheduled actions are not stored, they can be deleted by name as well
+		actions, err := autoscaling.DescribeScheduledActions(dd.Scaling.Autoscaling.ResourceId)
+		if err != nil {
+			return err
+		}
+		for _, action := range actions {
+			if action.ActionName == policyName {
+				return autoscaling.DeleteScheduledAction(policyName, dd.Scaling.Autoscaling.ResourceId)
+			}
+		}
 		return fmt.Errorf("Autoscaling policy %v not found", policyName)
 	}
 
-	err = autoscaling.DeleteScalingPolicy(policyName, dd.Scaling.Autoscaling.ResourceId)
-	if err != nil {
-		return err
-	}
-
-	err = cloudwatch.DeleteAlarms([]string{policyName})
+	err = deleteAutoscalingPolicy(&autoscaling, &cloudwatch, policyName, dd.Scaling.Autoscaling.ResourceId)
 	if err != nil {
 		return err
 	}
@@ -1695,8 +2235,6 @@ func (c *Controller) deleteServiceAutoscalingPolicy(serviceName, policyName stri
 func (c *Controller) deleteServiceAutoscaling(serviceName string) error {
 	s := service.NewService()
 	s.ServiceName = serviceName
-	autoscaling := ecs.AutoScaling{}
-	cloudwatch := ecs.CloudWatch{}
 
 	// get last deploy
 	dd, err := s.GetLastDeploy()
@@ -1708,19 +2246,7 @@ func (c *Controller) deleteServiceAutoscaling(serviceName string) error {
 		return errors.New("Autoscaling not active for service")
 	}
 
-	for _, policyName := range dd.Scaling.Autoscaling.PolicyNames {
-		err = autoscaling.DeleteScalingPolicy(policyName, dd.Scaling.Autoscaling.ResourceId)
-		if err != nil {
-			return err
-		}
-
-		err = cloudwatch.DeleteAlarms([]string{policyName})
-		if err != nil {
-			return err
-		}
-	}
-
-	err = autoscaling.DeregisterScalableTarget(dd.Scaling.Autoscaling.ResourceId)
+	err = c.removeServiceAutoscaling(dd.Scaling.Autoscaling)
 	if err != nil {
 		return err
 	}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/in4it/ecs-deploy/provider/ecs"
 	"github.com/in4it/ecs-deploy/service"
 )
 
@@ -10,6 +14,7 @@ type MockController struct {
 	ControllerIf
 	runningServices   []service.RunningService
 	getServicesOutput []*service.DynamoServicesElement
+	clusterTasks      clusterTasks
 }
 
 func (m *MockController) getServices() ([]*service.DynamoServicesElement, error) {
@@ -18,6 +23,9 @@ func (m *MockController) getServices() ([]*service.DynamoServicesElement, error)
 func (m *MockController) describeServices() ([]service.RunningService, error) {
 	return m.runningServices, nil
 }
+func (m *MockController) describeClusterTasks(clusterName string) (clusterTasks, error) {
+	return m.clusterTasks, nil
+}
 
 func TestDefaultTemplate(t *testing.T) {
 	_, err := defaultTemplates.ReadFile("default-templates/ecs-deploy-task.json")
@@ -25,3 +33,137 @@ func TestDefaultTemplate(t *testing.T) {
 		t.Errorf("could not read default template ecs-deploy-task.json: %s", err)
 	}
 }
+
+func TestGetMixedInstancesConfig(t *testing.T) {
+	b := &Flags{
+		InstanceType:           "t3.medium",
+		InstanceTypes:          "t3a.medium, t3.medium,m5.large",
+		OnDemandBaseCapacity:   "1",
+		OnDemandPercentage:     "25",
+		SpotAllocationStrategy: "capacity-optimized",
+	}
+	mixedInstances, err := getMixedInstancesConfig(b)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if strings.Join(mixedInstances.InstanceTypes, ",") != "t3.medium,t3a.medium,m5.large" {
+		t.Errorf("Unexpected instance types: %v", mixedInstances.InstanceTypes)
+	}
+	if mixedInstances.OnDemandBaseCapacity != 1 || mixedInstances.OnDemandPercentageAboveBaseCapacity != 25 || !mixedInstances.HasSpot() {
+		t.Errorf("Unexpected instances distribution: %+v", mixedInstances)
+	}
+	b.OnDemandPercentage = "101"
+	if _, err := getMixedInstancesConfig(b); err == nil {
+		t.Errorf("Expected error for on-demand percentage above 100")
+	}
+	b.OnDemandPercentage = "100"
+	b.SpotAllocationStrategy = "cheapest"
+	if _, err := getMixedInstancesConfig(b); err == nil {
+		t.Errorf("Expected error for invalid spot allocation strategy")
+	}
+}
+
+type MockApplicationAutoScaling struct {
+	ecs.ApplicationAutoScalingIf
+	policies map[string]bool
+	// errors returned once by DeleteScalingPolicy, by policy name
+	deleteErrors map[string]error
+}
+
+func (m *MockApplicationAutoScaling) DeleteScalingPolicy(policyName, resourceId string) error {
+	if err, ok := m.deleteErrors[policyName]; ok {
+		delete(m.deleteErrors, policyName)
+		return err
+	}
+	// deleting a policy that doesn't exist isn't an error
+	delete(m.policies, policyName)
+	return nil
+}
+func (m *MockApplicationAutoScaling) DescribeScheduledActions(resourceId string) ([]service.AutoscalingScheduledAction, error) {
+	return nil, nil
+}
+
+type MockCloudWatch struct {
+	ecs.CloudWatchIf
+	alarms map[string]bool
+}
+
+func (m *MockCloudWatch) DeleteAlarms(alarmNames []string) error {
+	// alarms that don't exist (of target tracking policies) are ignored
+	for _, alarmName := range alarmNames {
+		delete(m.alarms, alarmName)
+	}
+	return nil
+}
+
+func TestDeleteUndeclaredAutoscaling(t *testing.T) {
+	c := Controller{}
+	resourceId := "service/mycluster/myservice"
+	// the step scaling policy is replaced by a target tracking policy
+	d := service.Deploy{
+		Autoscaling: &service.Autoscaling{
+			MaximumCount:           4,
+			TargetTrackingPolicies: []service.AutoscalingTargetTrackingPolicy{{Metric: "cpu", TargetValue: 60}},
+		},
+	}
+	as := &MockApplicationAutoScaling{policies: map[string]bool{"myservice-cpu-up": true, "myservice-cpu-target": true}}
+	cloudwatch := &MockCloudWatch{alarms: map[string]bool{"myservice-cpu-up": true}}
+	autoscaling, err := c.deleteUndeclaredAutoscaling(context.Background(), "myservice", d, resourceId, []string{"myservice-cpu-up", "myservice-cpu-target"}, as, cloudwatch)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if strings.Join(autoscaling.PolicyNames, ",") != "myservice-cpu-target" || autoscaling.ResourceId != resourceId {
+		t.Errorf("Unexpected policies: %+v", autoscaling)
+	}
+	if len(as.policies) != 1 || len(cloudwatch.alarms) != 0 {
+		t.Errorf("Unexpected policies %v or alarms %v", as.policies, cloudwatch.alarms)
+	}
+	// switching back removes the target tracking policy, which has no alarm
+	d.Autoscaling = &service.Autoscaling{MaximumCount: 4, Policies: []service.AutoscalingPolicy{{Metric: "cpu", ScalingAdjustment: 1}}}
+	autoscaling, err = c.deleteUndeclaredAutoscaling(context.Background(), "myservice", d, resourceId, []string{"myservice-cpu-target", "myservice-cpu-up"}, as, cloudwatch)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if strings.Join(autoscaling.PolicyNames, ",") != "myservice-cpu-up" {
+		t.Errorf("Unexpected policies: %v", autoscaling.PolicyNames)
+	}
+}
+
+func TestDeleteUndeclaredAutoscalingAfterPartialFailure(t *testing.T) {
+	c := Controller{}
+	resourceId := "service/mycluster/myservice"
+	d := service.Deploy{
+		Autoscaling: &service.Autoscaling{
+			MaximumCount:           4,
+			TargetTrackingPolicies: []service.AutoscalingTargetTrackingPolicy{{Metric: "cpu", TargetValue: 60}},
+		},
+	}
+	as := &MockApplicationAutoScaling{
+		policies:     map[string]bool{"myservice-cpu-up": true, "myservice-memory-up": true, "myservice-cpu-target": true},
+		deleteErrors: map[string]error{"myservice-memory-up": errors.New("throttled")},
+	}
+	cloudwatch := &MockCloudWatch{alarms: map[string]bool{"myservice-cpu-up": true, "myservice-memory-up": true}}
+	// the first deploy keeps the policies that are not deleted yet
+	autoscaling, err := c.deleteUndeclaredAutoscaling(context.Background(), "myservice", d, resourceId, []string{"myservice-cpu-up", "myservice-memory-up", "myservice-cpu-target"}, as, cloudwatch)
+	if err == nil {
+		t.Fatalf("Expected error")
+	}
+	if strings.Join(autoscaling.PolicyNames, ",") != "myservice-memory-up,myservice-cpu-target" {
+		t.Errorf("Unexpected policies after partial failure: %v", autoscaling.PolicyNames)
+	}
+	// the second deploy converges
+	autoscaling, err = c.deleteUndeclaredAutoscaling(context.Background(), "myservice", d, resourceId, autoscaling.PolicyNames, as, cloudwatch)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if strings.Join(autoscaling.PolicyNames, ",") != "myservice-cpu-target" {
+		t.Errorf("Unexpected policies: %v", autoscaling.PolicyNames)
+	}
+	if len(as.policies) != 1 || len(cloudwatch.alarms) != 0 {
+		t.Errorf("Unexpected policies %v or alarms %v", as.policies, cloudwatch.alarms)
+	}
+	// a deploy without changes doesn't return an error
+	if _, err = c.deleteUndeclaredAutoscaling(context.Background(), "myservice", d, resourceId, autoscaling.PolicyNames, as, cloudwatch); err != nil {
+		t.Errorf("Error: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/in4it/ecs-deploy/provider/ecs"
+	"github.com/in4it/ecs-deploy/service"
+)
+
+// deployProgress keeps track of what has already been sent to a deploy stream
+type deployProgress struct {
+	serviceName    string
+	deploymentTime time.Time
+	taskDefinition string
+	phase          string
+	initialized    bool
+	events         map[string]bool
+	tasks          map[string]string
+}
+
+func newDeployProgress(dd *service.DynamoDeployment) *deployProgress {
+	p := &deployProgress{
+		serviceName:    dd.ServiceName,
+		deploymentTime: dd.Time,
+		events:         make(map[string]bool),
+		tasks:          make(map[string]string),
+	}
+	if dd.TaskDefinitionArn != nil {
+		p.taskDefinition = *dd.TaskDefinitionArn
+	}
+	return p
+}
+
+// update compares the deployment and the running service with the previous state.
+// It returns the progress events to send, and whether the deployment is finished
+func (p *deployProgress) update(dd *service.DynamoDeployment, rs *service.RunningService) ([]service.DeployProgressEvent, bool) {
+	var res []service.DeployProgressEvent
+	now := time.Now()
+
+	if rs != nil {
+		// ecs service events (newest first)
+		for i := len(rs.Events) - 1; i >= 0; i-- {
+			event := rs.Events[i]
+			if p.events[event.Id] {
+				continue
+			}
+			p.events[event.Id] = true
+			if event.CreatedAt.Before(p.deploymentTime) {
+				continue
+			}
+			res = append(res, service.DeployProgressEvent{Type: "event", ServiceName: p.serviceName, Time: event.CreatedAt, Message: event.Message})
+		}
+		// task start / stop
+		for _, task := range rs.Tasks {
+			lastStatus, ok := p.tasks[task.TaskArn]
+			p.tasks[task.TaskArn] = task.LastStatus
+			if ok && lastStatus == task.LastStatus {
+				continue
+			}
+			// tasks of previous deployments are only reported when they change
+			if !ok && !p.initialized && task.TaskDefinitionArn != p.taskDefinition {
+				continue
+			}
+			res = append(res, service.DeployProgressEvent{
+				Type:        "task",
+				ServiceName: p.serviceName,
+				Time:        now,
+				Message:     taskProgressMessage(task, p.taskDefinition),
+				TaskArn:     task.TaskArn,
+				TaskStatus:  task.LastStatus,
+			})
+		}
+	}
+	p.initialized = true
+
+	// phase changes
+	phase, message := deployPhase(dd, rs, p.taskDefinition)
+	if phase+message != p.phase {
+		p.phase = phase + message
+		res = append(res, service.DeployProgressEvent{Type: "phase", ServiceName: p.serviceName, Time: now, Phase: phase, Message: message})
+	}
+
+	// final status
+	if dd.Status != "running" {
+		res = append(res, service.DeployProgressEvent{
+			Type:        "status",
+			ServiceName: p.serviceName,
+			Time:        now,
+			Phase:       dd.Status,
+			Message:     dd.DeployError,
+			Result: &service.DeployResult{
+				ServiceName:       dd.ServiceName,
+				ClusterName:       dd.DeployData.Cluster,
+				TaskDefinitionArn: p.taskDefinition,
+				Status:            dd.Status,
+				DeployError:       dd.DeployError,
+				DeploymentTime:    dd.Time,
+				RequestID:         dd.RequestID,
+			},
+		})
+		return res, true
+	}
+	return res, false
+}
+
+func deployPhase(dd *service.DynamoDeployment, rs *service.RunningService, taskDefinition string) (string, string) {
+	if dd.Status != "running" {
+		return dd.Status, ""
+	}
+	if rs == nil {
+		return "deploying", "waiting for service information"
+	}
+	for _, deployment := range rs.Deployments {
+		if deployment.Status != "PRIMARY" {
+			continue
+		}
+		if deployment.TaskDefinition != taskDefinition {
+			return "deploying", "waiting for new task definition to become primary"
+		}
+		if len(rs.Deployments) > 1 || deployment.RunningCount < deployment.DesiredCount {
+			return "rolling out", fmt.Sprintf("%d/%d tasks running, %d pending, %d active deployments", deployment.RunningCount, deployment.DesiredCount, deployment.PendingCount, len(rs.Deployments))
+		}
+		return "stabilizing", fmt.Sprintf("%d/%d tasks running, waiting for service to become stable", deployment.RunningCount, deployment.DesiredCount)
+	}
+	return "deploying", "no primary deployment found"
+}
+
+func taskProgressMessage(task service.RunningTask, taskDefinition string) string {
+	taskID := task.TaskArn[strings.LastIndex(task.TaskArn, "/")+1:]
+	revision := task.TaskDefinitionArn[strings.LastIndex(task.TaskDefinitionArn, ":")+1:]
+	msg := fmt.Sprintf("task %s (revision %s", taskID, revision)
+	if task.TaskDefinitionArn != taskDefinition {
+		msg += ", previous deployment"
+	}
+	msg += ") is " + task.LastStatus
+	if task.LastStatus == "STOPPED" {
+		if task.StoppedReason != "" {
+			msg += ": " + task.StoppedReason
+		}
+		for _, container := range task.Containers {
+			if container.Reason != "" || container.ExitCode != 0 {
+				msg += fmt.Sprintf(" (container %s exit code %d %s)", container.Name, container.ExitCode, container.Reason)
+			}
+		}
+	}
+	return msg
+}
+
+// watchDeployment sends progress events of a deployment until the deployment is finished or the context is cancelled
+func (c *Controller) watchDeployment(ctx context.Context, serviceName, strTime string, events chan<- service.DeployProgressEvent) error {
+	interval := serverConfig.Deploy.StreamInterval
+	s := service.NewService()
+	dd, err := s.GetDeployment(serviceName, strTime)
+	if err != nil {
+		return err
+	}
+	p := newDeployProgress(dd)
+	e := ecs.ECS{}
+	for {
+		var rs *service.RunningService
+		if dd.Status == "running" {
+			rss, err := e.DescribeServicesWithOptions(dd.DeployData.Cluster, []*string{aws.String(serviceName)}, true, true, true, map[string]string{})
+			if err != nil {
+				controllerLogger.Debugf("watchDeployment: couldn't describe service %s: %s", serviceName, err)
+			} else if len(rss) == 1 {
+				rs = &rss[0]
+			}
+		}
+		progress, finished := p.update(dd, rs)
+		for _, event := range progress {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if finished {
+			return nil
+		}
+		select {
+		case <-time.After(time.Duration(interval) * time.Second):
+		case <-ctx.Done():
+			return nil
+		}
+		dd, err = s.GetDeployment(serviceName, strTime)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/in4it/ecs-deploy/service"
+)
+
+func TestDeployProgressUpdate(t *testing.T) {
+	taskDef := "arn:aws:ecs:us-east-1:123456789012:task-definition/myservice:2"
+	oldTaskDef := "arn:aws:ecs:us-east-1:123456789012:task-definition/myservice:1"
+	deploymentTime := time.Now().Add(-1 * time.Minute)
+	dd := &service.DynamoDeployment{
+		ServiceName:       "myservice",
+		Time:              deploymentTime,
+		Status:            "running",
+		TaskDefinitionArn: &taskDef,
+		DeployData:        &service.Deploy{Cluster: "mycluster"},
+	}
+	rs := &service.RunningService{
+		ServiceName: "myservice",
+		Events: []service.RunningServiceEvent{
+			{Id: "2", CreatedAt: deploymentTime.Add(10 * time.Second), Message: "has started 1 tasks"},
+			{Id: "1", CreatedAt: deploymentTime.Add(-1 * time.Hour), Message: "has reached a steady state"},
+		},
+		Deployments: []service.RunningServiceDeployment{
+			{Status: "PRIMARY", TaskDefinition: taskDef, DesiredCount: 1, PendingCount: 1},
+			{Status: "ACTIVE", TaskDefinition: oldTaskDef, DesiredCount: 1, RunningCount: 1},
+		},
+		Tasks: []service.RunningTask{
+			{TaskArn: "arn:aws:ecs:us-east-1:123456789012:task/mycluster/old", TaskDefinitionArn: oldTaskDef, LastStatus: "RUNNING"},
+			{TaskArn: "arn:aws:ecs:us-east-1:123456789012:task/mycluster/new", TaskDefinitionArn: taskDef, LastStatus: "PENDING"},
+		},
+	}
+	p := newDeployProgress(dd)
+
+	events, finished := p.update(dd, rs)
+	if finished {
+		t.Errorf("deployment shouldn't be finished")
+	}
+	types := []string{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	if len(events) != 3 || events[0].Type != "event" || events[1].Type != "task" || events[2].Type != "phase" {
+		t.Fatalf("unexpected events: %v", types)
+	}
+	if events[0].Message != "has started 1 tasks" {
+		t.Errorf("unexpected ecs event: %s", events[0].Message)
+	}
+	if events[1].Message != "task new (revision 2) is PENDING" {
+		t.Errorf("unexpected task message: %s", events[1].Message)
+	}
+	if events[2].Phase != "rolling out" {
+		t.Errorf("unexpected phase: %s", events[2].Phase)
+	}
+
+	// nothing changed
+	events, _ = p.update(dd, rs)
+	if len(events) != 0 {
+		t.Errorf("expected no events, got %d", len(events))
+	}
+
+	// old task stopped, deployment finished
+	rs.Tasks[0].LastStatus = "STOPPED"
+	rs.Tasks[0].StoppedReason = "Scaling activity initiated by deployment"
+	dd.Status = "success"
+	events, finished = p.update(dd, rs)
+	if !finished {
+		t.Errorf("deployment should be finished")
+	}
+	if len(events) != 3 || events[0].TaskStatus != "STOPPED" || events[2].Type != "status" || events[2].Result.Status != "success" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+	if events[0].Message != "task old (revision 1, previous deployment) is STOPPED: Scaling activity initiated by deployment" {
+		t.Errorf("unexpected task message: %s", events[0].Message)
+	}
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/in4it/ecs-deploy/integrations"
+	"github.com/in4it/ecs-deploy/provider/ecs"
+	"github.com/in4it/ecs-deploy/service"
+	"github.com/juju/loggo"
+)
+
+// logging
+var driftLogger = loggo.GetLogger("drift")
+
+// driftLiveState is the live state of a service that is compared with the last deployment
+type driftLiveState struct {
+	TaskDefinitionArn     string
+	DesiredCount          int64
+	TargetGroup           *elbv2.TargetGroup
+	TargetGroupAttributes map[string]string
+	// protocols of the listeners of the load balancer
+	Listeners []string
+	// rules forwarding to the target group, as listener protocol and conditions
+	Rules  []string
+	Domain string
+}
+
+// getServiceDrift compares the last deployment of a service with the live state
+func (c *Controller) getServiceDrift(serviceName string) (service.ServiceDrift, error) {
+	s := service.NewService()
+	s.ServiceName = serviceName
+	clusterName, err := s.GetClusterName()
+	if err != nil {
+		return service.ServiceDrift{}, err
+	}
+	return c.getDrift(serviceName, clusterName), nil
+}
+
+// getDriftForAllServices compares the last deployment of all services with the live state
+func (c *Controller) getDriftForAllServices() ([]service.ServiceDrift, error) {
+	var drift []service.ServiceDrift
+	var ds service.DynamoServices
+	s := service.NewService()
+	err := s.GetServices(&ds)
+	if err != nil {
+		return nil, err
+	}
+	for _, dsEl := range ds.Services {
+		drift = append(drift, c.getDrift(dsEl.S, dsEl.C))
+	}
+	return drift, nil
+}
+
+// getDrift returns the drift of a service, errors while retrieving the state are returned in the drift report
+func (c *Controller) getDrift(serviceName, clusterName string) service.ServiceDrift {
+	drift := service.ServiceDrift{ServiceName: serviceName, ClusterName: clusterName, CheckedAt: time.Now()}
+	s := service.NewService()
+	s.ServiceName = serviceName
+	s.ClusterName = clusterName
+	dds, err := s.GetDeploysForService(serviceName)
+	if err != nil {
+		drift.Error = err.Error()
+		return drift
+	}
+	dd, err := getDriftDeployment(dds)
+	if err != nil {
+		drift.Error = err.Error()
+		return drift
+	}
+	live, err := c.getDriftLiveState(serviceName, clusterName, dd.DeployData)
+	if err != nil {
+		drift.Error = err.Error()
+		return drift
+	}
+	drift.Differences = compareWithLiveState(serviceName, dd, live)
+	drift.Drifted = len(drift.Differences) > 0
+	return drift
+}
+
+// getDriftDeployment returns the last successful deployment, the deployments are sorted by time (newest first).
+// A failed or aborted deployment is rolled back, so the live state is compared with the deployment before it
+func getDriftDeployment(dds []service.DynamoDeployment) (*service.DynamoDeployment, error) {
+	if len(dds) > 0 && dds[0].Status == "running" {
+		return nil, errors.New("deployment is running")
+	}
+	for i := range dds {
+		if dds[i].Status != "success" {
+			continue
+		}
+		if dds[i].DeployData == nil {
+			return nil, errors.New("no deploy data found for the last successful deployment")
+		}
+		return &dds[i], nil
+	}
+	return nil, errors.New("no successful deployment found")
+}
+
+// getDriftLiveState retrieves the live state of the service and its target group
+func (c *Controller) getDriftLiveState(serviceName, clusterName string, d *service.Deploy) (driftLiveState, error) {
+	var live driftLiveState
+	e := ecs.ECS{}
+	svc, err := e.GetServiceDefinition(clusterName, serviceName)
+	if err != nil {
+		return live, err
+	}
+	live.TaskDefinitionArn = aws.StringValue(svc.TaskDefinition)
+	live.DesiredCount = aws.Int64Value(svc.DesiredCount)
+
+	if strings.ToLower(d.ServiceProtocol) == "none" {
+		return live, nil
+	}
+	loadBalancer := d.LoadBalancer
+	if loadBalancer == "" {
+		loadBalancer = clusterName
+	}
+	alb, err := ecs.NewALB(loadBalancer)
+	if err != nil {
+		return live, err
+	}
+	targetGroupArn, err := alb.GetTargetGroupArn(serviceName)
+	if err != nil {
+		return live, err
+	}
+	if targetGroupArn == nil {
+		return live, errors.New("target group not found")
+	}
+	live.TargetGroup, live.TargetGroupAttributes, err = alb.DescribeTargetGroup(*targetGroupArn)
+	if err != nil {
+		return live, err
+	}
+	err = alb.GetRulesForAllListeners()
+	if err != nil {
+		return live, err
+	}
+	live.Domain = alb.GetDomain()
+	listeners := make(map[string]string)
+	for _, l := range alb.Listeners {
+		listeners[aws.StringValue(l.ListenerArn)] = strings.ToLower(aws.StringValue(l.Protocol))
+		live.Listeners = append(live.Listeners, strings.ToLower(aws.StringValue(l.Protocol)))
+	}
+	for _, ruleArn := range alb.GetRulesByTargetGroupArn(*targetGroupArn) {
+		conditionFields, conditionValues := alb.GetConditionsForRule(ruleArn)
+		live.Rules = append(live.Rules, ruleSignature(listeners[getListenerArn(&elbv2.Rule{RuleArn: aws.String(ruleArn)})], conditionFields, conditionValues))
+	}
+	return live, nil
+}
+
+// ruleSignature returns a comparable representation of a listener rule
+func ruleSignature(listener string, conditionFields, conditionValues []string) string {
+	var conditions []string
+	for k, field := range conditionFields {
+		if k < len(conditionValues) {
+			conditions = append(conditions, field+"="+conditionValues[k])
+		}
+	}
+	sort.Strings(conditions)
+	return listener + ":" + strings.Join(conditions, ",")
+}
+
+// expectedRules returns the rule signatures ecs-deploy creates for the deploy data
+func expectedRules(serviceName string, d *service.Deploy, listeners []string, domain string) []string {
+	var rules []string
+	if len(d.RuleConditions) == 0 {
+		for _, listener := range listeners {
+			rules = append(rules, ruleSignature(listener, []string{"path-pattern"}, []string{"/" + serviceName}))
+			rules = append(rules, ruleSignature(listener, []string{"path-pattern"}, []string{"/" + serviceName + "/*"}))
+		}
+		return rules
+	}
+	c := Controller{}
+	for _, r := range d.RuleConditions {
+		_, conditionFields, conditionValues := c.getALBConditionFieldAndValue(*r, domain)
+		for _, listener := range r.Listeners {
+			// cognito rules on http listeners are redirects, they don't forward to the target group
+			if strings.ToLower(listener) == "http" && r.CognitoAuth.ClientName != "" {
+				continue
+			}
+			rules = append(rules, ruleSignature(strings.ToLower(listener), conditionFields, conditionValues))
+		}
+	}
+	return rules
+}
+
+// compareWithLiveState returns the differences between the last deployment and the live state
+func compareWithLiveState(serviceName string, dd *service.DynamoDeployment, live driftLiveState) []service.DriftDifference {
+	var differences []service.DriftDifference
+	add := func(field, expected, actual string) {
+		if expected != actual {
+			differences = append(differences, service.DriftDifference{Field: field, Expected: expected, Actual: actual})
+		}
+	}
+	d := dd.DeployData
+
+	add("taskDefinition", shortTaskDefinition(aws.StringValue(dd.TaskDefinitionArn)), shortTaskDefinition(live.TaskDefinitionArn))
+	// the desired count is managed by application autoscaling when enabled
+	if dd.Scaling.Autoscaling.ResourceId == "" && d.SchedulingStrategy != "DAEMON" {
+		add("desiredCount", strconv.FormatInt(dd.Scaling.DesiredCount, 10), strconv.FormatInt(live.DesiredCount, 10))
+	}
+	if live.TargetGroup == nil {
+		return differences
+	}
+
+	// health check (only the settings in the deploy data are applied)
+	t := live.TargetGroup
+	if d.HealthCheck.HealthyThreshold != 0 {
+		add("healthCheck.healthyThreshold", strconv.FormatInt(d.HealthCheck.HealthyThreshold, 10), strconv.FormatInt(aws.Int64Value(t.HealthyThresholdCount), 10))
+	}
+	if d.HealthCheck.UnhealthyThreshold != 0 {
+		add("healthCheck.unhealthyThreshold", strconv.FormatInt(d.HealthCheck.UnhealthyThreshold, 10), strconv.FormatInt(aws.Int64Value(t.UnhealthyThresholdCount), 10))
+	}
+	if d.HealthCheck.Path != "" {
+		add("healthCheck.path", d.HealthCheck.Path, aws.StringValue(t.HealthCheckPath))
+	}
+	if d.HealthCheck.Port != "" {
+		add("healthCheck.port", d.HealthCheck.Port, aws.StringValue(t.HealthCheckPort))
+	}
+	if d.HealthCheck.Protocol != "" {
+		add("healthCheck.protocol", d.HealthCheck.Protocol, aws.StringValue(t.HealthCheckProtocol))
+	}
+	if d.HealthCheck.Interval != 0 {
+		add("healthCheck.interval", strconv.FormatInt(d.HealthCheck.Interval, 10), strconv.FormatInt(aws.Int64Value(t.HealthCheckIntervalSeconds), 10))
+	}
+	if d.HealthCheck.Timeout > 0 {
+		add("healthCheck.timeout", strconv.FormatInt(d.HealthCheck.Timeout, 10), strconv.FormatInt(aws.Int64Value(t.HealthCheckTimeoutSeconds), 10))
+	}
+	if d.HealthCheck.Matcher != "" && t.Matcher != nil {
+		add("healthCheck.matcher", d.HealthCheck.Matcher, aws.StringValue(t.Matcher.HttpCode))
+	}
+
+	// target group attributes
+	if d.DeregistrationDelay != -1 {
+		add("deregistrationDelay", strconv.FormatInt(d.DeregistrationDelay, 10), live.TargetGroupAttributes["deregistration_delay.timeout_seconds"])
+	}
+	add("stickiness.enabled", strconv.FormatBool(d.Stickiness.Enabled), live.TargetGroupAttributes["stickiness.enabled"])
+	if d.Stickiness.Enabled && d.Stickiness.Duration != -1 {
+		add("stickiness.duration", strconv.FormatInt(d.Stickiness.Duration, 10), live.TargetGroupAttributes["stickiness.lb_cookie.duration_seconds"])
+	}
+
+	// listener rules
+	expected := expectedRules(serviceName, d, live.Listeners, live.Domain)
+	actual := append([]string{}, live.Rules...)
+	sort.Strings(expected)
+	sort.Strings(actual)
+	add("ruleConditions", strings.Join(expected, " "), strings.Join(actual, " "))
+	return differences
+}
+
+// shortTaskDefinition returns the family and revision of a task definition arn
+func shortTaskDefinition(taskDefinitionArn string) string {
+	return taskDefinitionArn[strings.LastIndex(taskDefinitionArn, "/")+1:]
+}
+
+// startDriftDetection checks all services for drift at every interval, and notifies when the drift of a service changes
+func (c *Controller) startDriftDetection(interval time.Duration) {
+	var notification integrations.Notification
+	if serverConfig.DriftDetection.Notify == "yes" && serverConfig.Slack.Webhooks != "" {
+		notification = integrations.NewSlack(serverConfig.Slack.Webhooks, serverConfig.Slack.Username, serverConfig.AWS.AccountEnv)
+	} else {
+		notification = integrations.NewDummy()
+	}
+	drifted := make(map[string]bool)
+	for {
+		time.Sleep(interval)
+		drift, err := c.getDriftForAllServices()
+		if err != nil {
+			driftLogger.Errorf("Couldn't check services for drift: %v", err)
+			continue
+		}
+		for _, sd := range drift {
+			if sd.Error != "" {
+				driftLogger.Debugf("Couldn't check %v for drift: %v", sd.ServiceName, sd.Error)
+				continue
+			}
+			if sd.Drifted {
+				var fields []string
+				for _, difference := range sd.Differences {
+					fields = append(fields, difference.Field)
+				}
+				driftLogger.Warningf("Drift detected for %v: %v", sd.ServiceName, strings.Join(fields, ", "))
+				if !drifted[sd.ServiceName] {
+					err = notification.LogFailure("Drift detected for " + sd.ServiceName + ": " + strings.Join(fields, ", "))
+				}
+			} else if drifted[sd.ServiceName] {
+				driftLogger.Infof("Drift resolved for %v", sd.ServiceName)
+				err = notification.LogRecovery("Drift resolved for " + sd.ServiceName)
+			}
+			if err != nil {
+				driftLogger.Errorf("Couldn't send notification: %v", err)
+				err = nil
+			}
+			drifted[sd.ServiceName] = sd.Drifted
+		}
+	}
+}
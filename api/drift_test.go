@@ -0,0 +1,129 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/in4it/ecs-deploy/service"
+)
+
+func getTestDriftDeployment() (*service.DynamoDeployment, driftLiveState) {
+	d := &service.Deploy{
+		Cluster:         "mycluster",
+		ServiceProtocol: "HTTP",
+		HealthCheck:     service.DeployHealthCheck{Path: "/health", Matcher: "200"},
+	}
+	service.SetDeployDefaults(d)
+	dd := &service.DynamoDeployment{
+		ServiceName:       "myservice",
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/myservice:3"),
+		DeployData:        d,
+	}
+	dd.Scaling.DesiredCount = 2
+	live := driftLiveState{
+		TaskDefinitionArn: "arn:aws:ecs:us-east-1:123456789012:task-definition/myservice:3",
+		DesiredCount:      2,
+		TargetGroup: &elbv2.TargetGroup{
+			HealthCheckPath: aws.String("/health"),
+			Matcher:         &elbv2.Matcher{HttpCode: aws.String("200")},
+		},
+		TargetGroupAttributes: map[string]string{"stickiness.enabled": "false"},
+		Listeners:             []string{"http"},
+		Rules: []string{
+			ruleSignature("http", []string{"path-pattern"}, []string{"/myservice/*"}),
+			ruleSignature("http", []string{"path-pattern"}, []string{"/myservice"}),
+		},
+	}
+	return dd, live
+}
+
+func TestCompareWithLiveState(t *testing.T) {
+	dd, live := getTestDriftDeployment()
+	if differences := compareWithLiveState("myservice", dd, live); len(differences) != 0 {
+		t.Errorf("unexpected drift: %+v", differences)
+	}
+
+	// changes in the console
+	live.TaskDefinitionArn = "arn:aws:ecs:us-east-1:123456789012:task-definition/myservice:4"
+	live.DesiredCount = 3
+	live.TargetGroup.HealthCheckPath = aws.String("/")
+	live.TargetGroupAttributes["stickiness.enabled"] = "true"
+	live.Rules = live.Rules[1:]
+	differences := compareWithLiveState("myservice", dd, live)
+	fields := make(map[string]service.DriftDifference)
+	for _, difference := range differences {
+		fields[difference.Field] = difference
+	}
+	for _, field := range []string{"taskDefinition", "desiredCount", "healthCheck.path", "stickiness.enabled", "ruleConditions"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("drift of %v not detected: %+v", field, differences)
+		}
+	}
+	if fields["taskDefinition"].Expected != "myservice:3" || fields["taskDefinition"].Actual != "myservice:4" {
+		t.Errorf("unexpected task definition drift: %+v", fields["taskDefinition"])
+	}
+	if len(differences) != 5 {
+		t.Errorf("expected 5 differences, got: %+v", differences)
+	}
+
+	// the desired count is ignored when autoscaling is enabled
+	dd.Scaling.Autoscaling.ResourceId = "service/mycluster/myservice"
+	for _, difference := range compareWithLiveState("myservice", dd, live) {
+		if difference.Field == "desiredCount" {
+			t.Errorf("unexpected desired count drift with autoscaling enabled")
+		}
+	}
+}
+
+func TestExpectedRules(t *testing.T) {
+	d := &service.Deploy{
+		RuleConditions: []*service.DeployRuleConditions{
+			{Listeners: []string{"http", "https"}, Hostname: "myservice"},
+			{Listeners: []string{"http", "https"}, PathPattern: "/api", CognitoAuth: service.DeployRuleConditionsCognitoAuth{ClientName: "client"}},
+		},
+	}
+	rules := expectedRules("myservice", d, []string{"http", "https"}, "example.com")
+	expected := []string{"http:host-header=myservice.example.com", "https:host-header=myservice.example.com", "https:path-pattern=/api"}
+	if len(rules) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, rules)
+	}
+	for k := range expected {
+		if rules[k] != expected[k] {
+			t.Errorf("expected %v, got %v", expected[k], rules[k])
+		}
+	}
+}
+
+func TestGetDriftDeployment(t *testing.T) {
+	dd, _ := getTestDriftDeployment()
+	dd.Status = "success"
+	// a failed deploy is rolled back, the live state is compared with the last successful deployment
+	failed := service.DynamoDeployment{
+		ServiceName:       "myservice",
+		Status:            "failed",
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/myservice:4"),
+		DeployData:        dd.DeployData,
+	}
+	aborted := failed
+	aborted.Status = "aborted"
+	res, err := getDriftDeployment([]service.DynamoDeployment{failed, aborted, *dd})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if aws.StringValue(res.TaskDefinitionArn) != aws.StringValue(dd.TaskDefinitionArn) {
+		t.Errorf("expected the last successful deployment, got: %v", aws.StringValue(res.TaskDefinitionArn))
+	}
+	_, live := getTestDriftDeployment()
+	if differences := compareWithLiveState("myservice", res, live); len(differences) != 0 {
+		t.Errorf("unexpected drift after a failed deploy: %+v", differences)
+	}
+	running := failed
+	running.Status = "running"
+	if _, err = getDriftDeployment([]service.DynamoDeployment{running, *dd}); err == nil {
+		t.Errorf("expected error while a deployment is running")
+	}
+	if _, err = getDriftDeployment([]service.DynamoDeployment{failed}); err == nil {
+		t.Errorf("expected error without successful deployment")
+	}
+}
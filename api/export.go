@@ -3,14 +3,18 @@ package api
 import (
 	"encoding/base64"
 	"errors"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/in4it/ecs-deploy/provider/ecs"
 	"github.com/in4it/ecs-deploy/service"
-	"github.com/in4it/ecs-deploy/util"
 	"github.com/juju/loggo"
 )
 
@@ -20,10 +24,7 @@ var exportLogger = loggo.GetLogger("export")
 type ExportedApps map[string]string
 
 type Export struct {
-	templateMap map[string]string
-	deployData  *service.Deploy
-	alb         map[string]*ecs.ALB
-	p           ecs.Paramstore
+	alb map[string]*ecs.ALB
 }
 
 type RulePriority []int64
@@ -47,295 +48,289 @@ type ListenerRuleCondition struct {
 	Values string `json:"values" binding:"dive"`
 }
 
-func (e *Export) getTemplateMap(serviceName, clusterName string) error {
-	// retrieve data
-	iam := ecs.IAM{}
-	err := iam.GetAccountId()
+// ExportedService is the current state of a service and the resources ecs-deploy created for it
+type ExportedService struct {
+	ServiceName        string
+	ClusterName        string
+	DeployData         *service.Deploy
+	Service            *awsecs.Service
+	TaskDefinition     *awsecs.TaskDefinition
+	TargetGroups       []ExportedTargetGroup
+	ScalableTarget     *applicationautoscaling.ScalableTarget
+	ScalingPolicies    []*applicationautoscaling.ScalingPolicy
+	Alarms             []*cloudwatch.MetricAlarm
+	TaskRole           *iam.Role
+	TaskRolePolicies   map[string]string
+	TaskRolePolicyArns []string
+	Repository         string
+}
+type ExportedTargetGroup struct {
+	TargetGroup   *elbv2.TargetGroup
+	Attributes    map[string]string
+	ListenerRules []*elbv2.Rule
+	Listeners     map[string]*elbv2.Listener
+}
+
+// getListenerArn returns the arn of the listener of a rule
+func getListenerArn(rule *elbv2.Rule) string {
+	listenerArn := aws.StringValue(rule.RuleArn)
+	if !strings.Contains(listenerArn, "/") {
+		return listenerArn
+	}
+	// rule arn: arn:aws:elasticloadbalancing:region:account:listener-rule/app/lb/lb-id/listener-id/rule-id
+	return strings.Replace(listenerArn[:strings.LastIndex(listenerArn, "/")], ":listener-rule/", ":listener/", 1)
+}
+
+// getListenerName returns the port of the listener of a rule, or the listener id when the listener is unknown.
+// ecs-deploy creates the same rule with the same priority on the http and https listener
+func (tg ExportedTargetGroup) getListenerName(rule *elbv2.Rule) string {
+	listenerArn := getListenerArn(rule)
+	if listener, ok := tg.Listeners[listenerArn]; ok && listener.Port != nil {
+		return strconv.FormatInt(aws.Int64Value(listener.Port), 10)
+	}
+	return listenerArn[strings.LastIndex(listenerArn, "/")+1:]
+}
+
+// getService retrieves the current state of a service from AWS
+func (e *Export) getService(serviceName, clusterName string) (*ExportedService, error) {
+	es := &ExportedService{ServiceName: serviceName, ClusterName: clusterName}
+	ecsSvc := ecs.ECS{}
+	var err error
+
+	es.Service, err = ecsSvc.GetServiceDefinition(clusterName, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	es.TaskDefinition, err = ecsSvc.GetTaskDefinitionDetails(aws.StringValue(es.Service.TaskDefinition))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	// get deployment obj
+
+	// last deployment
 	s := service.NewService()
 	s.ServiceName = serviceName
 	s.ClusterName = clusterName
-
 	dd, err := s.GetLastDeploy()
-	if err != nil {
-		return err
-	}
-	if dd.DeployData == nil {
-		return errors.New("DeployData is empty")
+	if err == nil {
+		es.DeployData = dd.DeployData
 	}
-	e.deployData = dd.DeployData
-	exportLogger.Debugf("got: %+v", e.deployData)
 
-	// retrieve alb data
-	var loadBalancer string
-	if e.deployData.LoadBalancer == "" {
-		loadBalancer = clusterName
-	} else {
-		loadBalancer = e.deployData.LoadBalancer
-	}
-	if _, ok := e.alb[loadBalancer]; !ok {
-		e.alb[loadBalancer], err = ecs.NewALB(loadBalancer)
-		if err != nil {
-			return err
+	// target groups and listener rules
+	if len(es.Service.LoadBalancers) > 0 {
+		loadBalancer := clusterName
+		if es.DeployData != nil && es.DeployData.LoadBalancer != "" {
+			loadBalancer = es.DeployData.LoadBalancer
 		}
-		// get rules for all listener
-		err = e.alb[loadBalancer].GetRulesForAllListeners()
-		if err != nil {
-			return err
+		if _, ok := e.alb[loadBalancer]; !ok {
+			e.alb[loadBalancer], err = ecs.NewALB(loadBalancer)
+			if err != nil {
+				return nil, err
+			}
+			err = e.alb[loadBalancer].GetRulesForAllListeners()
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, lb := range es.Service.LoadBalancers {
+			if lb.TargetGroupArn == nil {
+				continue
+			}
+			var tg ExportedTargetGroup
+			tg.TargetGroup, tg.Attributes, err = e.alb[loadBalancer].DescribeTargetGroup(aws.StringValue(lb.TargetGroupArn))
+			if err != nil {
+				return nil, err
+			}
+			tg.ListenerRules = e.getListenerRulesForTargetGroup(e.alb[loadBalancer], aws.StringValue(lb.TargetGroupArn))
+			tg.Listeners = make(map[string]*elbv2.Listener)
+			for _, listener := range e.alb[loadBalancer].Listeners {
+				tg.Listeners[aws.StringValue(listener.ListenerArn)] = listener
+			}
+			es.TargetGroups = append(es.TargetGroups, tg)
 		}
 	}
 
-	// get target group (if service has loadbalancer)
-	var targetGroup *string
-	if strings.ToLower(e.deployData.ServiceProtocol) != "none" {
-		targetGroup, err = e.alb[loadBalancer].GetTargetGroupArn(serviceName)
+	// autoscaling
+	as := ecs.AutoScaling{}
+	resourceId := "service/" + clusterName + "/" + serviceName
+	es.ScalableTarget, err = as.GetScalableTarget(resourceId)
+	if err != nil {
+		return nil, err
+	}
+	if es.ScalableTarget != nil {
+		es.ScalingPolicies, err = as.GetScalingPolicies(resourceId)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		var alarmNames []string
+		for _, policy := range es.ScalingPolicies {
+			// alarms of target tracking policies are managed by application autoscaling
+			if aws.StringValue(policy.PolicyType) != "StepScaling" {
+				continue
+			}
+			for _, alarm := range policy.Alarms {
+				alarmNames = append(alarmNames, aws.StringValue(alarm.AlarmName))
+			}
 		}
-		if targetGroup == nil {
-			return errors.New("No target group found for " + serviceName)
+		cw := ecs.CloudWatch{}
+		es.Alarms, err = cw.GetMetricAlarms(alarmNames)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	// init map
-	e.templateMap = make(map[string]string)
-	e.templateMap["${SERVICE}"] = serviceName
-	e.templateMap["${CLUSTERNAME}"] = clusterName
-	e.templateMap["${LOADBALANCER}"] = loadBalancer
-	if targetGroup != nil {
-		e.templateMap["${TARGET_GROUP_ARN}"] = *targetGroup
-	}
-	e.templateMap["${SERVICE_DESIREDCOUNT}"] = strconv.FormatInt(e.deployData.DesiredCount, 10)
-	if e.deployData.MinimumHealthyPercent == 0 {
-		e.templateMap["${SERVICE_MINIMUMHEALTHYPERCENT}"] = "// no minimum healthy percent set"
-	} else {
-		e.templateMap["${SERVICE_MINIMUMHEALTHYPERCENT}"] = `deployment_minimum_healthy_percent = "` + strconv.FormatInt(e.deployData.MinimumHealthyPercent, 10) + `"`
-	}
-	if e.deployData.MaximumPercent == 0 {
-		e.templateMap["${SERVICE_MAXIMUMPERCENT}"] = "// no maximum percent set"
-	} else {
-		e.templateMap["${SERVICE_MAXIMUMPERCENT}"] = `deployment_maximum_percent = "` + strconv.FormatInt(e.deployData.MaximumPercent, 10) + `"`
-	}
-	e.templateMap["${SERVICE_PORT}"] = strconv.FormatInt(e.deployData.ServicePort, 10)
-	e.templateMap["${SERVICE_PROTOCOL}"] = e.deployData.ServiceProtocol
-	e.templateMap["${AWS_REGION}"] = util.GetEnv("AWS_REGION", "")
-	e.templateMap["${ACCOUNT_ID}"] = iam.AccountId
-	e.templateMap["${PARAMSTORE_PREFIX}"] = util.GetEnv("PARAMSTORE_PREFIX", "")
-	if dd.DeployData.EnvNamespace == "" {
-		e.templateMap["${NAMESPACE}"] = serviceName
-	} else {
-		e.templateMap["${NAMESPACE}"] = dd.DeployData.EnvNamespace
-	}
-	e.templateMap["${AWS_ACCOUNT_ENV}"] = util.GetEnv("AWS_ACCOUNT_ENV", "")
-	e.templateMap["${PARAMSTORE_KMS_ARN}"] = util.GetEnv("PARAMSTORE_KMS_ARN", "")
-	e.templateMap["${VPC_ID}"] = e.alb[loadBalancer].VpcId
-	if e.deployData.HealthCheck.HealthyThreshold != 0 {
-		b, err := os.ReadFile("templates/export/alb_targetgroup_healthcheck.tf")
+	// task role
+	if es.TaskDefinition.TaskRoleArn != nil {
+		iamSvc := ecs.IAM{}
+		roleName := aws.StringValue(es.TaskDefinition.TaskRoleArn)
+		roleName = roleName[strings.LastIndex(roleName, "/")+1:]
+		es.TaskRole, err = iamSvc.GetRole(roleName)
 		if err != nil {
-			exportLogger.Errorf("Can't read template templates/export/alb_targetgroup_healthcheck.tf")
-			return err
-		}
-		str := string(b)
-		if e.deployData.HealthCheck.HealthyThreshold != 0 {
-			str = strings.Replace(str, "${HEALTHCHECK_HEALTHYTHRESHOLD}", strconv.FormatInt(e.deployData.HealthCheck.HealthyThreshold, 10), -1)
-		} else {
-			str = strings.Replace(str, "${HEALTHCHECK_HEALTHYTHRESHOLD}", "3", -1)
-		}
-		if e.deployData.HealthCheck.UnhealthyThreshold != 0 {
-			str = strings.Replace(str, "${HEALTHCHECK_UNHEALTHYTHRESHOLD}", strconv.FormatInt(e.deployData.HealthCheck.UnhealthyThreshold, 10), -1)
-		} else {
-			str = strings.Replace(str, "${HEALTHCHECK_UNHEALTHYTHRESHOLD}", "2", -1)
-		}
-		if e.deployData.HealthCheck.Protocol != "" {
-			str = strings.Replace(str, "${HEALTHCHECK_PROTOCOL}", e.deployData.HealthCheck.Protocol, -1)
-		} else {
-			str = strings.Replace(str, "${HEALTHCHECK_PROTOCOL}", "HTTP", -1)
-		}
-		if e.deployData.HealthCheck.Path != "" {
-			str = strings.Replace(str, "${HEALTHCHECK_PATH}", e.deployData.HealthCheck.Path, -1)
-		} else {
-			str = strings.Replace(str, "${HEALTHCHECK_PATH}", "/", -1)
-		}
-		if e.deployData.HealthCheck.Interval != 0 {
-			str = strings.Replace(str, "${HEALTHCHECK_INTERVAL}", strconv.FormatInt(e.deployData.HealthCheck.Interval, 10), -1)
-		} else {
-			str = strings.Replace(str, "${HEALTHCHECK_INTERVAL}", "30", -1)
-		}
-		if e.deployData.HealthCheck.Matcher != "" {
-			str = strings.Replace(str, "${HEALTHCHECK_MATCHER}", e.deployData.HealthCheck.Matcher, -1)
-		} else {
-			str = strings.Replace(str, "${HEALTHCHECK_MATCHER}", "200", -1)
+			return nil, err
 		}
-		if e.deployData.HealthCheck.Timeout > 0 {
-			str = strings.Replace(str, "${HEALTHCHECK_TIMEOUT}", strconv.FormatInt(e.deployData.HealthCheck.Timeout, 10), -1)
-		} else {
-			str = strings.Replace(str, "${HEALTHCHECK_TIMEOUT}", "5", -1)
+		es.TaskRolePolicies, err = iamSvc.GetRolePolicies(roleName)
+		if err != nil {
+			return nil, err
 		}
-		e.templateMap["${HEALTHCHECK}"] = str
-	}
-	return nil
-}
-
-// check first whether the template is in the parameter store
-// if not, use the default template from the template path
-func (e *Export) getTemplate(template string) (*string, error) {
-	parameter, ok := e.p.Parameters["TEMPLATES_EXPORT_"+strings.Replace(strings.ToUpper(template), ".", "_", -1)]
-	str := parameter.Value
-	if !ok {
-		b, err := os.ReadFile("templates/export/" + template)
+		es.TaskRolePolicyArns, err = iamSvc.GetAttachedRolePolicyArns(roleName)
 		if err != nil {
-			exportLogger.Errorf("Can't read template templates/export/" + template)
 			return nil, err
 		}
-		str = string(b)
 	}
-	// replace
-	for k, v := range e.templateMap {
-		str = strings.Replace(str, k, v, -1)
+
+	// ecr repository
+	ecr := ecs.ECR{}
+	exists, err := ecr.RepositoryExists(serviceName)
+	if err != nil {
+		return nil, err
 	}
-	return &str, nil
+	if exists {
+		es.Repository = serviceName
+	}
+	return es, nil
 }
 
-func (e *Export) terraform() (*map[string]ExportedApps, error) {
-	// get all services
-	export := make(map[string]ExportedApps)
-	export["apps"] = make(ExportedApps)
-	e.alb = make(map[string]*ecs.ALB)
+// getListenerRulesForTargetGroup returns the listener rules forwarding to the target group, sorted by priority
+func (e *Export) getListenerRulesForTargetGroup(alb *ecs.ALB, targetGroupArn string) []*elbv2.Rule {
+	var rules []*elbv2.Rule
+	for _, listenerRules := range alb.Rules {
+		for _, rule := range listenerRules {
+			if aws.BoolValue(rule.IsDefault) {
+				continue
+			}
+			for _, action := range rule.Actions {
+				if aws.StringValue(action.TargetGroupArn) == targetGroupArn {
+					rules = append(rules, rule)
+					break
+				}
+			}
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		pi, _ := strconv.ParseInt(aws.StringValue(rules[i].Priority), 10, 64)
+		pj, _ := strconv.ParseInt(aws.StringValue(rules[j].Priority), 10, 64)
+		return pi < pj
+	})
+	return rules
+}
 
+// getServices retrieves the current state of all services managed by ecs-deploy, optionally only the services of one cluster
+func (e *Export) getServices(clusterName string) ([]*ExportedService, error) {
+	var exportedServices []*ExportedService
 	var ds service.DynamoServices
-	// get possible parameters
-	e.p = ecs.Paramstore{}
-	e.p.GetParameters(e.p.GetPrefix(), true)
-	// ecr obj
-	ecr := ecs.ECR{}
-	// get services
+	e.alb = make(map[string]*ecs.ALB)
 	s := service.NewService()
 	err := s.GetServices(&ds)
 	if err != nil {
 		return nil, err
 	}
 	for _, service := range ds.Services {
-		var ret string
-		err := e.getTemplateMap(service.S, service.C)
+		if clusterName != "" && service.C != clusterName {
+			continue
+		}
+		es, err := e.getService(service.S, service.C)
 		if err != nil {
 			return nil, err
 		}
-		exportLogger.Debugf("Retrieved template map: %+v", e.templateMap)
+		exportedServices = append(exportedServices, es)
+	}
+	return exportedServices, nil
+}
 
-		// check if we have targetGroup
-		var processTargetGroup bool
-		if _, ok := e.templateMap["${TARGET_GROUP_ARN}"]; ok {
-			processTargetGroup = true
-		}
-		// check whether to process ecr
-		processEcr, err := ecr.RepositoryExists(service.S)
+// getServiceByName retrieves the current state of a service managed by ecs-deploy
+func (e *Export) getServiceByName(serviceName string) (*ExportedService, error) {
+	e.alb = make(map[string]*ecs.ALB)
+	s := service.NewService()
+	s.ServiceName = serviceName
+	clusterName, err := s.GetClusterName()
+	if err != nil {
+		return nil, err
+	}
+	return e.getService(serviceName, clusterName)
+}
+
+func (e *Export) terraform() (*map[string]ExportedApps, error) {
+	export := make(map[string]ExportedApps)
+	export["apps"] = make(ExportedApps)
+	exportedServices, err := e.getServices("")
+	if err != nil {
+		return nil, err
+	}
+	for _, es := range exportedServices {
+		tf, err := renderTerraform(es)
 		if err != nil {
 			return nil, err
 		}
+		export["apps"][es.ServiceName] = base64.StdEncoding.EncodeToString(tf)
+	}
+	return &export, nil
+}
 
-		var toProcess []string
-
-		if processEcr {
-			toProcess = append(toProcess, "ecr")
-		}
-		if processTargetGroup {
-			toProcess = append(toProcess, []string{"ecs", "iam", "alb_targetgroup"}...)
-		} else {
-			toProcess = append(toProcess, []string{"ecs", "iam"}...)
-		}
-		if e.p.IsEnabled() {
-			toProcess = append(toProcess, "iam_paramstore")
-		}
-		for _, v := range toProcess {
-			t, err := e.getTemplate(v + ".tf")
-			if err != nil {
-				return nil, err
-			}
-			ret += *t
-		}
+func (e *Export) terraformService(serviceName string) (string, error) {
+	es, err := e.getServiceByName(serviceName)
+	if err != nil {
+		return "", err
+	}
+	tf, err := renderTerraform(es)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(tf), nil
+}
 
-		// get listener rules
-		if processTargetGroup {
-			t, err := e.getListenerRules(service.S, service.C, service.Listeners, e.templateMap["${LOADBALANCER}"])
-			if err != nil {
-				return nil, err
-			}
-			ret += *t
+// cloudFormation returns a CloudFormation template per service, optionally only for the services of one cluster
+func (e *Export) cloudFormation(clusterName string) (map[string]*CloudFormationExport, error) {
+	export := make(map[string]*CloudFormationExport)
+	exportedServices, err := e.getServices(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	for _, es := range exportedServices {
+		export[es.ServiceName], err = renderCloudFormation("ecs-deploy service "+es.ServiceName, []*ExportedService{es})
+		if err != nil {
+			return nil, err
 		}
-		export["apps"][service.S] = base64.StdEncoding.EncodeToString([]byte(ret))
 	}
-	return &export, nil
+	return export, nil
 }
 
-func (e *Export) getListenerRules(serviceName string, clusterName string, listeners []string, loadBalancer string) (*string, error) {
-	var ret string
-	// listeners
-	albListenerRule, err := e.getTemplate("alb_listenerrule.tf")
+func (e *Export) cloudFormationService(serviceName string) (*CloudFormationExport, error) {
+	es, err := e.getServiceByName(serviceName)
 	if err != nil {
 		return nil, err
 	}
-	condition, err := e.getTemplate("alb_listenerrule_condition.tf")
+	return renderCloudFormation("ecs-deploy service "+serviceName, []*ExportedService{es})
+}
+
+// cloudFormationCluster returns one CloudFormation template with all the services of a cluster
+func (e *Export) cloudFormationCluster(clusterName string) (*CloudFormationExport, error) {
+	exportedServices, err := e.getServices(clusterName)
 	if err != nil {
 		return nil, err
 	}
-	if len(e.deployData.RuleConditions) == 0 {
-		exportLogger.Debugf("No rule conditions, going with default rules")
-		for _, l := range listeners {
-			a := strings.Replace(*albListenerRule, "${LISTENER_ARN}", l, -1)
-			for _, v := range []string{"/" + serviceName, "/" + serviceName + "/*"} {
-				// get priority
-				ruleArn, priority, err := e.alb[loadBalancer].FindRule(l, e.templateMap["${TARGET_GROUP_ARN}"], []string{"path-pattern"}, []string{v})
-				if err != nil {
-					return nil, err
-				}
-				// replace listeners and return template
-				a = strings.Replace(a, "${LISTENER_PRIORITY}", *priority, -1)
-				a = strings.Replace(a, "${LISTENER_RULE_ARN}", *ruleArn, -1)
-				c := strings.Replace(*condition, "${LISTENER_CONDITION_FIELD}", "path-pattern", -1)
-				c = strings.Replace(c, "${LISTENER_CONDITION_VALUE}", v, -1)
-				ret += strings.Replace(a, "${LISTENER_CONDITION_RULE}", c, -1)
-			}
-		}
-	} else {
-		exportLogger.Debugf("Found rule conditions in deploy, examining conditions")
-		for _, y := range e.deployData.RuleConditions {
-			for _, l := range e.alb[loadBalancer].Listeners {
-				for _, l2 := range y.Listeners {
-					if l.Protocol != nil && strings.ToLower(*l.Protocol) == strings.ToLower(l2) {
-						a := strings.Replace(*albListenerRule, "${LISTENER_ARN}", *l.ListenerArn, -1)
-						var c, cc string
-						var f []string
-						var v []string
-						if y.PathPattern != "" {
-							f = append(f, "path-pattern")
-							v = append(v, y.PathPattern)
-							c = strings.Replace(*condition, "${LISTENER_CONDITION_FIELD}", "path-pattern", -1)
-							c = strings.Replace(c, "${LISTENER_CONDITION_VALUE}", y.PathPattern, -1)
-						}
-						if y.Hostname != "" {
-							f = append(f, "host-header")
-							v = append(v, y.Hostname+"."+e.alb[loadBalancer].GetDomain())
-							cc = strings.Replace(*condition, "${LISTENER_CONDITION_FIELD}", "host-header", -1)
-							cc = strings.Replace(cc, "${LISTENER_CONDITION_VALUE}", y.Hostname+"."+e.alb[loadBalancer].GetDomain(), -1)
-						}
-						// get priority
-						ruleArn, priority, err := e.alb[loadBalancer].FindRule(*l.ListenerArn, e.templateMap["${TARGET_GROUP_ARN}"], f, v)
-						if err != nil {
-							return nil, err
-						}
-						a = strings.Replace(a, "${LISTENER_PRIORITY}", *priority, -1)
-						a = strings.Replace(a, "${LISTENER_RULE_ARN}", *ruleArn, -1)
-						// get everything together and return template
-						ret += strings.Replace(a, "${LISTENER_CONDITION_RULE}", c+cc, -1)
-					}
-				}
-			}
-		}
+	if len(exportedServices) == 0 {
+		return nil, errors.New("No services found for cluster: " + clusterName)
 	}
-	return &ret, nil
+	return renderCloudFormation("ecs-deploy services in cluster "+clusterName, exportedServices)
 }
 
 func (e *Export) getTargetGroupArn(serviceName string) (*string, error) {
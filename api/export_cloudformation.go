@@ -0,0 +1,632 @@
+package api
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+var cloudFormationInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// CloudFormationTemplate is a CloudFormation template
+type CloudFormationTemplate struct {
+	AWSTemplateFormatVersion string                             `json:"AWSTemplateFormatVersion"`
+	Description              string                             `json:"Description,omitempty"`
+	Resources                map[string]*CloudFormationResource `json:"Resources"`
+}
+
+// CloudFormationResource is a resource in a CloudFormation template
+type CloudFormationResource struct {
+	Type           string                 `json:"Type"`
+	DeletionPolicy string                 `json:"DeletionPolicy,omitempty"`
+	DependsOn      []string               `json:"DependsOn,omitempty"`
+	Metadata       map[string]interface{} `json:"Metadata,omitempty"`
+	Properties     map[string]interface{} `json:"Properties"`
+}
+
+// CloudFormationResourceToImport identifies an existing resource for a CloudFormation import change set
+type CloudFormationResourceToImport struct {
+	ResourceType       string            `json:"ResourceType"`
+	LogicalResourceId  string            `json:"LogicalResourceId"`
+	ResourceIdentifier map[string]string `json:"ResourceIdentifier"`
+}
+
+// CloudFormationExport is a CloudFormation template with the resources to import
+type CloudFormationExport struct {
+	Template          *CloudFormationTemplate
+	ResourcesToImport []CloudFormationResourceToImport
+}
+
+// cdkTree is the construct tree (tree.json) of a CDK cloud assembly
+type cdkTree struct {
+	Version string  `json:"version"`
+	Tree    cdkNode `json:"tree"`
+}
+type cdkNode struct {
+	Id         string                 `json:"id"`
+	Path       string                 `json:"path"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Children   map[string]cdkNode     `json:"children,omitempty"`
+}
+
+// cloudFormationWriter adds the resources of services to a template and keeps track of the resources to import
+type cloudFormationWriter struct {
+	export *CloudFormationExport
+}
+
+func newCloudFormationWriter(description string) *cloudFormationWriter {
+	return &cloudFormationWriter{
+		export: &CloudFormationExport{
+			Template: &CloudFormationTemplate{
+				AWSTemplateFormatVersion: "2010-09-09",
+				Description:              description,
+				Resources:                make(map[string]*CloudFormationResource),
+			},
+		},
+	}
+}
+
+// renderCloudFormation returns a CloudFormation template containing the resources of the services
+func renderCloudFormation(description string, services []*ExportedService) (*CloudFormationExport, error) {
+	w := newCloudFormationWriter(description)
+	for _, es := range services {
+		if err := w.service(es); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(w.export.ResourcesToImport, func(i, j int) bool {
+		return w.export.ResourcesToImport[i].LogicalResourceId < w.export.ResourcesToImport[j].LogicalResourceId
+	})
+	return w.export, nil
+}
+
+// renderCDK returns the template as a CDK construct tree, with a construct per resource
+func renderCDK(stackName string, template *CloudFormationTemplate) cdkTree {
+	stackId := cloudFormationName(stackName)
+	stack := cdkNode{Id: stackId, Path: stackId, Children: make(map[string]cdkNode)}
+	for logicalId, resource := range template.Resources {
+		stack.Children[logicalId] = cdkNode{
+			Id:   logicalId,
+			Path: stackId + "/" + logicalId,
+			Attributes: map[string]interface{}{
+				"aws:cdk:cloudformation:type":  resource.Type,
+				"aws:cdk:cloudformation:props": cdkProps(resource.Properties),
+			},
+		}
+	}
+	return cdkTree{
+		Version: "tree-0.1",
+		Tree: cdkNode{
+			Id:       "App",
+			Path:     "",
+			Children: map[string]cdkNode{stackId: stack},
+		},
+	}
+}
+
+// cdkProps converts CloudFormation properties to the camelCase property names of the L1 constructs
+func cdkProps(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		props := make(map[string]interface{})
+		for k, item := range value {
+			// intrinsic functions are passed as-is
+			if k == "Ref" || strings.HasPrefix(k, "Fn::") {
+				props[k] = item
+				continue
+			}
+			// policy documents are json properties
+			if k == "PolicyDocument" || k == "AssumeRolePolicyDocument" {
+				props[strings.ToLower(k[:1])+k[1:]] = item
+				continue
+			}
+			props[strings.ToLower(k[:1])+k[1:]] = cdkProps(item)
+		}
+		return props
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for k, item := range value {
+			items[k] = cdkProps(item)
+		}
+		return items
+	}
+	return v
+}
+
+// cloudFormationName returns a valid logical id
+func cloudFormationName(name string) string {
+	var parts []string
+	for _, part := range cloudFormationInvalidChars.Split(name, -1) {
+		if part != "" {
+			parts = append(parts, strings.ToUpper(part[:1])+part[1:])
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// cloudFormationValue converts an AWS api struct into CloudFormation properties
+// the struct fields of the sdk match the CloudFormation property names for most resource types
+func cloudFormationValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+	return pruneCloudFormationValue(value), nil
+}
+
+// pruneCloudFormationValue removes the unset fields of a value
+func pruneCloudFormationValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			item = pruneCloudFormationValue(item)
+			if item == nil {
+				delete(value, k)
+			} else {
+				value[k] = item
+			}
+		}
+		if len(value) == 0 {
+			return nil
+		}
+	case []interface{}:
+		if len(value) == 0 {
+			return nil
+		}
+		for k, item := range value {
+			value[k] = pruneCloudFormationValue(item)
+		}
+	}
+	return v
+}
+
+func ref(logicalId string) map[string]interface{} {
+	return map[string]interface{}{"Ref": logicalId}
+}
+func getAtt(logicalId, attribute string) map[string]interface{} {
+	return map[string]interface{}{"Fn::GetAtt": []string{logicalId, attribute}}
+}
+
+func setCloudFormationString(properties map[string]interface{}, name string, value *string) {
+	if value != nil && *value != "" {
+		properties[name] = *value
+	}
+}
+func setCloudFormationInt(properties map[string]interface{}, name string, value *int64) {
+	if value != nil {
+		properties[name] = *value
+	}
+}
+func setCloudFormationBool(properties map[string]interface{}, name string, value *bool) {
+	if value != nil {
+		properties[name] = *value
+	}
+}
+func setCloudFormationValue(properties map[string]interface{}, name string, v interface{}) error {
+	value, err := cloudFormationValue(v)
+	if err != nil {
+		return err
+	}
+	if value != nil {
+		properties[name] = value
+	}
+	return nil
+}
+
+func (w *cloudFormationWriter) resource(resourceType, logicalId string, identifier map[string]string) *CloudFormationResource {
+	resource := &CloudFormationResource{
+		Type: resourceType,
+		// resources need a deletion policy to be imported
+		DeletionPolicy: "Retain",
+		Properties:     make(map[string]interface{}),
+	}
+	w.export.Template.Resources[logicalId] = resource
+	w.export.ResourcesToImport = append(w.export.ResourcesToImport, CloudFormationResourceToImport{
+		ResourceType:       resourceType,
+		LogicalResourceId:  logicalId,
+		ResourceIdentifier: identifier,
+	})
+	return resource
+}
+
+func (w *cloudFormationWriter) service(es *ExportedService) error {
+	name := cloudFormationName(es.ServiceName)
+
+	if es.Repository != "" {
+		w.ecrRepository(name, es.Repository)
+	}
+	if es.TaskRole != nil {
+		if err := w.iamRole(name, es); err != nil {
+			return err
+		}
+	}
+	if err := w.taskDefinition(name, es); err != nil {
+		return err
+	}
+	var listenerRules []string
+	for k, tg := range es.TargetGroups {
+		w.targetGroup(cloudFormationTargetGroupName(name, k), tg)
+		for _, rule := range tg.ListenerRules {
+			logicalId, err := w.listenerRule(name, cloudFormationTargetGroupName(name, k), tg.getListenerName(rule), aws.StringValue(tg.TargetGroup.TargetGroupArn), rule)
+			if err != nil {
+				return err
+			}
+			listenerRules = append(listenerRules, logicalId)
+		}
+	}
+	if err := w.ecsService(name, es, listenerRules); err != nil {
+		return err
+	}
+	if es.ScalableTarget != nil {
+		if err := w.autoscaling(name, es); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cloudFormationTargetGroupName(name string, k int) string {
+	if k == 0 {
+		return name + "TargetGroup"
+	}
+	return name + "TargetGroup" + strconv.Itoa(k+1)
+}
+
+func (w *cloudFormationWriter) ecrRepository(name, repository string) {
+	r := w.resource("AWS::ECR::Repository", name+"Repository", map[string]string{"RepositoryName": repository})
+	r.Properties["RepositoryName"] = repository
+}
+
+func (w *cloudFormationWriter) iamRole(name string, es *ExportedService) error {
+	roleName := aws.StringValue(es.TaskRole.RoleName)
+	r := w.resource("AWS::IAM::Role", name+"TaskRole", map[string]string{"RoleName": roleName})
+	r.Properties["RoleName"] = roleName
+	setCloudFormationString(r.Properties, "Path", es.TaskRole.Path)
+	var assumeRolePolicy interface{}
+	if err := json.Unmarshal([]byte(aws.StringValue(es.TaskRole.AssumeRolePolicyDocument)), &assumeRolePolicy); err != nil {
+		return err
+	}
+	r.Properties["AssumeRolePolicyDocument"] = assumeRolePolicy
+	if es.TaskRole.PermissionsBoundary != nil {
+		setCloudFormationString(r.Properties, "PermissionsBoundary", es.TaskRole.PermissionsBoundary.PermissionsBoundaryArn)
+	}
+	if aws.Int64Value(es.TaskRole.MaxSessionDuration) != 3600 {
+		setCloudFormationInt(r.Properties, "MaxSessionDuration", es.TaskRole.MaxSessionDuration)
+	}
+
+	// inline policies are part of the role resource
+	var policyNames []string
+	for policyName := range es.TaskRolePolicies {
+		policyNames = append(policyNames, policyName)
+	}
+	sort.Strings(policyNames)
+	var policies []interface{}
+	for _, policyName := range policyNames {
+		var policy interface{}
+		if err := json.Unmarshal([]byte(es.TaskRolePolicies[policyName]), &policy); err != nil {
+			return err
+		}
+		policies = append(policies, map[string]interface{}{"PolicyName": policyName, "PolicyDocument": policy})
+	}
+	if len(policies) > 0 {
+		r.Properties["Policies"] = policies
+	}
+	if len(es.TaskRolePolicyArns) > 0 {
+		r.Properties["ManagedPolicyArns"] = es.TaskRolePolicyArns
+	}
+	return nil
+}
+
+func (w *cloudFormationWriter) taskDefinition(name string, es *ExportedService) error {
+	td := es.TaskDefinition
+	r := w.resource("AWS::ECS::TaskDefinition", name+"TaskDefinition", map[string]string{"TaskDefinitionArn": aws.StringValue(td.TaskDefinitionArn)})
+	setCloudFormationString(r.Properties, "Family", td.Family)
+	if err := setCloudFormationValue(r.Properties, "ContainerDefinitions", td.ContainerDefinitions); err != nil {
+		return err
+	}
+	if es.TaskRole != nil && aws.StringValue(es.TaskRole.Arn) == aws.StringValue(td.TaskRoleArn) {
+		r.Properties["TaskRoleArn"] = getAtt(name+"TaskRole", "Arn")
+	} else {
+		setCloudFormationString(r.Properties, "TaskRoleArn", td.TaskRoleArn)
+	}
+	setCloudFormationString(r.Properties, "ExecutionRoleArn", td.ExecutionRoleArn)
+	setCloudFormationString(r.Properties, "NetworkMode", td.NetworkMode)
+	if len(td.RequiresCompatibilities) > 0 {
+		r.Properties["RequiresCompatibilities"] = aws.StringValueSlice(td.RequiresCompatibilities)
+	}
+	setCloudFormationString(r.Properties, "Cpu", td.Cpu)
+	setCloudFormationString(r.Properties, "Memory", td.Memory)
+	setCloudFormationString(r.Properties, "PidMode", td.PidMode)
+	setCloudFormationString(r.Properties, "IpcMode", td.IpcMode)
+	var volumes []interface{}
+	for _, volume := range td.Volumes {
+		v, err := cloudFormationValue(volume)
+		if err != nil {
+			return err
+		}
+		properties, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// the efs volume properties are named differently in CloudFormation
+		if efs, ok := properties["EfsVolumeConfiguration"].(map[string]interface{}); ok {
+			if fileSystemId, ok := efs["FileSystemId"]; ok {
+				efs["FilesystemId"] = fileSystemId
+				delete(efs, "FileSystemId")
+			}
+			properties["EFSVolumeConfiguration"] = efs
+			delete(properties, "EfsVolumeConfiguration")
+		}
+		volumes = append(volumes, properties)
+	}
+	if len(volumes) > 0 {
+		r.Properties["Volumes"] = volumes
+	}
+	return setCloudFormationValue(r.Properties, "PlacementConstraints", td.PlacementConstraints)
+}
+
+func (w *cloudFormationWriter) targetGroup(logicalId string, tg ExportedTargetGroup) {
+	t := tg.TargetGroup
+	r := w.resource("AWS::ElasticLoadBalancingV2::TargetGroup", logicalId, map[string]string{"TargetGroupArn": aws.StringValue(t.TargetGroupArn)})
+	setCloudFormationString(r.Properties, "Name", t.TargetGroupName)
+	setCloudFormationInt(r.Properties, "Port", t.Port)
+	setCloudFormationString(r.Properties, "Protocol", t.Protocol)
+	setCloudFormationString(r.Properties, "VpcId", t.VpcId)
+	setCloudFormationString(r.Properties, "TargetType", t.TargetType)
+	setCloudFormationBool(r.Properties, "HealthCheckEnabled", t.HealthCheckEnabled)
+	setCloudFormationInt(r.Properties, "HealthyThresholdCount", t.HealthyThresholdCount)
+	setCloudFormationInt(r.Properties, "UnhealthyThresholdCount", t.UnhealthyThresholdCount)
+	setCloudFormationInt(r.Properties, "HealthCheckIntervalSeconds", t.HealthCheckIntervalSeconds)
+	setCloudFormationInt(r.Properties, "HealthCheckTimeoutSeconds", t.HealthCheckTimeoutSeconds)
+	setCloudFormationString(r.Properties, "HealthCheckProtocol", t.HealthCheckProtocol)
+	setCloudFormationString(r.Properties, "HealthCheckPath", t.HealthCheckPath)
+	setCloudFormationString(r.Properties, "HealthCheckPort", t.HealthCheckPort)
+	if t.Matcher != nil && t.Matcher.HttpCode != nil {
+		r.Properties["Matcher"] = map[string]interface{}{"HttpCode": aws.StringValue(t.Matcher.HttpCode)}
+	}
+	var keys []string
+	for k := range tg.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var attributes []interface{}
+	for _, k := range keys {
+		attributes = append(attributes, map[string]interface{}{"Key": k, "Value": tg.Attributes[k]})
+	}
+	if len(attributes) > 0 {
+		r.Properties["TargetGroupAttributes"] = attributes
+	}
+}
+
+func (w *cloudFormationWriter) listenerRule(name, targetGroup, listener, targetGroupArn string, rule *elbv2.Rule) (string, error) {
+	logicalId := name + "Listener" + cloudFormationName(listener) + "Rule" + aws.StringValue(rule.Priority)
+	r := w.resource("AWS::ElasticLoadBalancingV2::ListenerRule", logicalId, map[string]string{"RuleArn": aws.StringValue(rule.RuleArn)})
+	r.Properties["ListenerArn"] = getListenerArn(rule)
+	priority, _ := strconv.ParseInt(aws.StringValue(rule.Priority), 10, 64)
+	r.Properties["Priority"] = priority
+
+	var actions []interface{}
+	for _, action := range rule.Actions {
+		v, err := cloudFormationValue(action)
+		if err != nil {
+			return "", err
+		}
+		a, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if len(rule.Actions) == 1 {
+			delete(a, "Order")
+		}
+		if aws.StringValue(action.Type) == "forward" && aws.StringValue(forwardTargetGroupArn(action)) == targetGroupArn {
+			delete(a, "ForwardConfig")
+			a["TargetGroupArn"] = ref(targetGroup)
+		}
+		actions = append(actions, a)
+	}
+	r.Properties["Actions"] = actions
+	if err := setCloudFormationValue(r.Properties, "Conditions", listenerRuleConditions(rule.Conditions)); err != nil {
+		return "", err
+	}
+	return logicalId, nil
+}
+
+// listenerRuleConditions returns the conditions with their values in the config blocks only,
+// CloudFormation doesn't accept both Values and a config block in the same condition
+func listenerRuleConditions(conditions []*elbv2.RuleCondition) []*elbv2.RuleCondition {
+	var ret []*elbv2.RuleCondition
+	for _, condition := range conditions {
+		c := *condition
+		switch aws.StringValue(c.Field) {
+		case "path-pattern":
+			if c.PathPatternConfig == nil {
+				c.PathPatternConfig = &elbv2.PathPatternConditionConfig{Values: c.Values}
+			}
+		case "host-header":
+			if c.HostHeaderConfig == nil {
+				c.HostHeaderConfig = &elbv2.HostHeaderConditionConfig{Values: c.Values}
+			}
+		}
+		c.Values = nil
+		ret = append(ret, &c)
+	}
+	return ret
+}
+
+func (w *cloudFormationWriter) ecsService(name string, es *ExportedService, listenerRules []string) error {
+	s := es.Service
+	r := w.resource("AWS::ECS::Service", name+"Service", map[string]string{
+		"ServiceArn": aws.StringValue(s.ServiceArn),
+		"Cluster":    es.ClusterName,
+	})
+	// the target groups need to be attached to a load balancer before the service is created
+	r.DependsOn = listenerRules
+	if es.DeployData != nil {
+		r.Metadata = map[string]interface{}{"ecs-deploy": map[string]interface{}{"deployData": es.DeployData}}
+	}
+	setCloudFormationString(r.Properties, "ServiceName", s.ServiceName)
+	setCloudFormationString(r.Properties, "Cluster", s.ClusterArn)
+	r.Properties["TaskDefinition"] = ref(name + "TaskDefinition")
+	if aws.StringValue(s.SchedulingStrategy) == "DAEMON" {
+		setCloudFormationString(r.Properties, "SchedulingStrategy", s.SchedulingStrategy)
+	} else {
+		setCloudFormationInt(r.Properties, "DesiredCount", s.DesiredCount)
+	}
+	if len(s.CapacityProviderStrategy) == 0 {
+		setCloudFormationString(r.Properties, "LaunchType", s.LaunchType)
+	}
+	setCloudFormationString(r.Properties, "PlatformVersion", s.PlatformVersion)
+	if c := s.DeploymentConfiguration; c != nil {
+		deploymentConfiguration := make(map[string]interface{})
+		setCloudFormationInt(deploymentConfiguration, "MinimumHealthyPercent", c.MinimumHealthyPercent)
+		setCloudFormationInt(deploymentConfiguration, "MaximumPercent", c.MaximumPercent)
+		if c.DeploymentCircuitBreaker != nil && aws.BoolValue(c.DeploymentCircuitBreaker.Enable) {
+			deploymentConfiguration["DeploymentCircuitBreaker"] = map[string]interface{}{
+				"Enable":   true,
+				"Rollback": aws.BoolValue(c.DeploymentCircuitBreaker.Rollback),
+			}
+		}
+		r.Properties["DeploymentConfiguration"] = deploymentConfiguration
+	}
+	setCloudFormationInt(r.Properties, "HealthCheckGracePeriodSeconds", s.HealthCheckGracePeriodSeconds)
+	setCloudFormationString(r.Properties, "Role", s.RoleArn)
+	if aws.BoolValue(s.EnableExecuteCommand) {
+		setCloudFormationBool(r.Properties, "EnableExecuteCommand", s.EnableExecuteCommand)
+	}
+	if aws.BoolValue(s.EnableECSManagedTags) {
+		setCloudFormationBool(r.Properties, "EnableECSManagedTags", s.EnableECSManagedTags)
+	}
+	if aws.StringValue(s.PropagateTags) != "" && aws.StringValue(s.PropagateTags) != "NONE" {
+		setCloudFormationString(r.Properties, "PropagateTags", s.PropagateTags)
+	}
+	if s.DeploymentController != nil && aws.StringValue(s.DeploymentController.Type) != "ECS" {
+		r.Properties["DeploymentController"] = map[string]interface{}{"Type": aws.StringValue(s.DeploymentController.Type)}
+	}
+	if err := setCloudFormationValue(r.Properties, "CapacityProviderStrategy", s.CapacityProviderStrategy); err != nil {
+		return err
+	}
+	if s.NetworkConfiguration != nil {
+		if err := setCloudFormationValue(r.Properties, "NetworkConfiguration", s.NetworkConfiguration); err != nil {
+			return err
+		}
+	}
+	var loadBalancers []interface{}
+	for _, lb := range s.LoadBalancers {
+		l := make(map[string]interface{})
+		setCloudFormationString(l, "TargetGroupArn", lb.TargetGroupArn)
+		for k, tg := range es.TargetGroups {
+			if aws.StringValue(tg.TargetGroup.TargetGroupArn) == aws.StringValue(lb.TargetGroupArn) {
+				l["TargetGroupArn"] = ref(cloudFormationTargetGroupName(name, k))
+			}
+		}
+		setCloudFormationString(l, "ContainerName", lb.ContainerName)
+		setCloudFormationInt(l, "ContainerPort", lb.ContainerPort)
+		loadBalancers = append(loadBalancers, l)
+	}
+	if len(loadBalancers) > 0 {
+		r.Properties["LoadBalancers"] = loadBalancers
+	}
+	if err := setCloudFormationValue(r.Properties, "ServiceRegistries", s.ServiceRegistries); err != nil {
+		return err
+	}
+	if err := setCloudFormationValue(r.Properties, "PlacementStrategies", s.PlacementStrategy); err != nil {
+		return err
+	}
+	return setCloudFormationValue(r.Properties, "PlacementConstraints", s.PlacementConstraints)
+}
+
+func (w *cloudFormationWriter) autoscaling(name string, es *ExportedService) error {
+	t := es.ScalableTarget
+	r := w.resource("AWS::ApplicationAutoScaling::ScalableTarget", name+"ScalableTarget", map[string]string{
+		"ResourceId":        aws.StringValue(t.ResourceId),
+		"ScalableDimension": aws.StringValue(t.ScalableDimension),
+		"ServiceNamespace":  aws.StringValue(t.ServiceNamespace),
+	})
+	r.DependsOn = []string{name + "Service"}
+	setCloudFormationString(r.Properties, "ServiceNamespace", t.ServiceNamespace)
+	setCloudFormationString(r.Properties, "ResourceId", t.ResourceId)
+	setCloudFormationString(r.Properties, "ScalableDimension", t.ScalableDimension)
+	setCloudFormationInt(r.Properties, "MinCapacity", t.MinCapacity)
+	setCloudFormationInt(r.Properties, "MaxCapacity", t.MaxCapacity)
+	setCloudFormationString(r.Properties, "RoleARN", t.RoleARN)
+
+	policyArns := make(map[string]string)
+	for _, policy := range es.ScalingPolicies {
+		logicalId := cloudFormationName(aws.StringValue(policy.PolicyName)) + "ScalingPolicy"
+		policyArns[aws.StringValue(policy.PolicyARN)] = logicalId
+		if err := w.scalingPolicy(name, logicalId, policy); err != nil {
+			return err
+		}
+	}
+	for _, alarm := range es.Alarms {
+		w.metricAlarm(alarm, policyArns)
+	}
+	return nil
+}
+
+func (w *cloudFormationWriter) scalingPolicy(name, logicalId string, policy *applicationautoscaling.ScalingPolicy) error {
+	r := w.resource("AWS::ApplicationAutoScaling::ScalingPolicy", logicalId, map[string]string{
+		"Arn":               aws.StringValue(policy.PolicyARN),
+		"ScalableDimension": aws.StringValue(policy.ScalableDimension),
+	})
+	setCloudFormationString(r.Properties, "PolicyName", policy.PolicyName)
+	setCloudFormationString(r.Properties, "PolicyType", policy.PolicyType)
+	r.Properties["ScalingTargetId"] = ref(name + "ScalableTarget")
+	if policy.StepScalingPolicyConfiguration != nil {
+		if err := setCloudFormationValue(r.Properties, "StepScalingPolicyConfiguration", policy.StepScalingPolicyConfiguration); err != nil {
+			return err
+		}
+	}
+	if policy.TargetTrackingScalingPolicyConfiguration != nil {
+		if err := setCloudFormationValue(r.Properties, "TargetTrackingScalingPolicyConfiguration", policy.TargetTrackingScalingPolicyConfiguration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *cloudFormationWriter) metricAlarm(alarm *cloudwatch.MetricAlarm, policyArns map[string]string) {
+	r := w.resource("AWS::CloudWatch::Alarm", cloudFormationName(aws.StringValue(alarm.AlarmName))+"Alarm", map[string]string{"AlarmName": aws.StringValue(alarm.AlarmName)})
+	setCloudFormationString(r.Properties, "AlarmName", alarm.AlarmName)
+	setCloudFormationString(r.Properties, "AlarmDescription", alarm.AlarmDescription)
+	setCloudFormationString(r.Properties, "ComparisonOperator", alarm.ComparisonOperator)
+	setCloudFormationInt(r.Properties, "EvaluationPeriods", alarm.EvaluationPeriods)
+	setCloudFormationInt(r.Properties, "DatapointsToAlarm", alarm.DatapointsToAlarm)
+	setCloudFormationString(r.Properties, "MetricName", alarm.MetricName)
+	setCloudFormationString(r.Properties, "Namespace", alarm.Namespace)
+	setCloudFormationInt(r.Properties, "Period", alarm.Period)
+	setCloudFormationString(r.Properties, "Statistic", alarm.Statistic)
+	if alarm.Threshold != nil {
+		r.Properties["Threshold"] = *alarm.Threshold
+	}
+	setCloudFormationBool(r.Properties, "ActionsEnabled", alarm.ActionsEnabled)
+	var actions []interface{}
+	for _, action := range alarm.AlarmActions {
+		if logicalId, ok := policyArns[aws.StringValue(action)]; ok {
+			actions = append(actions, ref(logicalId))
+		} else {
+			actions = append(actions, aws.StringValue(action))
+		}
+	}
+	if len(actions) > 0 {
+		r.Properties["AlarmActions"] = actions
+	}
+	var dimensions []interface{}
+	for _, d := range alarm.Dimensions {
+		dimensions = append(dimensions, map[string]interface{}{"Name": aws.StringValue(d.Name), "Value": aws.StringValue(d.Value)})
+	}
+	if len(dimensions) > 0 {
+		r.Properties["Dimensions"] = dimensions
+	}
+}
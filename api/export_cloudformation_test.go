@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+func TestRenderCloudFormation(t *testing.T) {
+	es := getTestExportedService()
+	es.Service.ServiceArn = aws.String("arn:aws:ecs:us-east-1:123456789012:service/mycluster/myservice")
+	es.TaskDefinition.Volumes = []*awsecs.Volume{
+		{Name: aws.String("data"), EfsVolumeConfiguration: &awsecs.EFSVolumeConfiguration{FileSystemId: aws.String("fs-123")}},
+	}
+	exp, err := renderCloudFormation("test", []*ExportedService{es})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	for logicalId, resourceType := range map[string]string{
+		"MyserviceRepository":         "AWS::ECR::Repository",
+		"MyserviceTaskRole":           "AWS::IAM::Role",
+		"MyserviceTaskDefinition":     "AWS::ECS::TaskDefinition",
+		"MyserviceTargetGroup":        "AWS::ElasticLoadBalancingV2::TargetGroup",
+		"MyserviceListener80Rule10":   "AWS::ElasticLoadBalancingV2::ListenerRule",
+		"MyserviceService":            "AWS::ECS::Service",
+		"MyserviceScalableTarget":     "AWS::ApplicationAutoScaling::ScalableTarget",
+		"MyserviceCpuUpScalingPolicy": "AWS::ApplicationAutoScaling::ScalingPolicy",
+		"MyserviceCpuUpAlarm":         "AWS::CloudWatch::Alarm",
+	} {
+		resource, ok := exp.Template.Resources[logicalId]
+		if !ok {
+			t.Errorf("resource %s not found", logicalId)
+			continue
+		}
+		if resource.Type != resourceType {
+			t.Errorf("resource %s: expected type %s, got %s", logicalId, resourceType, resource.Type)
+		}
+	}
+	if len(exp.ResourcesToImport) != len(exp.Template.Resources) {
+		t.Errorf("expected %d resources to import, got %d", len(exp.Template.Resources), len(exp.ResourcesToImport))
+	}
+	b, err := json.Marshal(exp.Template)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	for _, s := range []string{
+		`"TaskDefinition":{"Ref":"MyserviceTaskDefinition"}`,
+		`"TargetGroupArn":{"Ref":"MyserviceTargetGroup"}`,
+		`"AlarmActions":[{"Ref":"MyserviceCpuUpScalingPolicy"}]`,
+		`"TaskRoleArn":{"Fn::GetAtt":["MyserviceTaskRole","Arn"]}`,
+		`"ListenerArn":"arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/mycluster/abc/def"`,
+		`"EFSVolumeConfiguration":{"FilesystemId":"fs-123"}`,
+		`"DependsOn":["MyserviceListener80Rule10"]`,
+		`"PortMappings":[{"ContainerPort":8080,"HostPort":0,"Protocol":"tcp"}]`,
+	} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("%s not found in:\n%s", s, b)
+		}
+	}
+	if strings.Contains(string(b), "null") {
+		t.Errorf("unset fields found in:\n%s", b)
+	}
+}
+
+func TestRenderCloudFormationListenerRules(t *testing.T) {
+	es := getTestExportedService()
+	addTestHttpsListenerRule(es)
+	// the API returns the values in both forms
+	es.TargetGroups[0].ListenerRules[1].Conditions = []*elbv2.RuleCondition{{
+		Field:             aws.String("path-pattern"),
+		Values:            aws.StringSlice([]string{"/myservice/*"}),
+		PathPatternConfig: &elbv2.PathPatternConditionConfig{Values: aws.StringSlice([]string{"/myservice/*"})},
+	}}
+	exp, err := renderCloudFormation("test", []*ExportedService{es})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	for _, logicalId := range []string{"MyserviceListener80Rule10", "MyserviceListener443Rule10"} {
+		if _, ok := exp.Template.Resources[logicalId]; !ok {
+			t.Errorf("resource %s not found", logicalId)
+			continue
+		}
+		b, err := json.Marshal(exp.Template.Resources[logicalId].Properties["Conditions"])
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		expected := `[{"Field":"path-pattern","PathPatternConfig":{"Values":["/myservice/*"]}}]`
+		if string(b) != expected {
+			t.Errorf("%s: expected conditions %s, got %s", logicalId, expected, b)
+		}
+	}
+	logicalIds := make(map[string]bool)
+	for _, r := range exp.ResourcesToImport {
+		if logicalIds[r.LogicalResourceId] {
+			t.Errorf("duplicate resource to import: %s", r.LogicalResourceId)
+		}
+		logicalIds[r.LogicalResourceId] = true
+	}
+	if len(exp.ResourcesToImport) != len(exp.Template.Resources) {
+		t.Errorf("expected %d resources to import, got %d", len(exp.Template.Resources), len(exp.ResourcesToImport))
+	}
+}
+
+func TestRenderCDK(t *testing.T) {
+	exp, err := renderCloudFormation("test", []*ExportedService{getTestExportedService()})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	tree := renderCDK("myservice", exp.Template)
+	stack, ok := tree.Tree.Children["Myservice"]
+	if !ok {
+		t.Fatalf("stack not found")
+	}
+	role, ok := stack.Children["MyserviceTaskRole"]
+	if !ok {
+		t.Fatalf("role not found")
+	}
+	props := role.Attributes["aws:cdk:cloudformation:props"].(map[string]interface{})
+	if props["roleName"] != "ecs-myservice" {
+		t.Errorf("unexpected roleName: %v", props["roleName"])
+	}
+	// policy documents are not converted
+	if _, ok := props["assumeRolePolicyDocument"].(map[string]interface{})["Statement"]; !ok {
+		t.Errorf("policy document converted: %v", props["assumeRolePolicyDocument"])
+	}
+}
+
+func TestCloudFormationName(t *testing.T) {
+	if name := cloudFormationName("my-service.test"); name != "MyServiceTest" {
+		t.Errorf("unexpected name: %s", name)
+	}
+}
@@ -0,0 +1,575 @@
+package api
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+var terraformInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// terraformImport is an import block for an existing resource
+type terraformImport struct {
+	resourceType string
+	name         string
+	id           string
+}
+
+// terraformWriter writes the terraform resources of a service and keeps track of the import blocks
+type terraformWriter struct {
+	file    *hclwrite.File
+	imports []terraformImport
+}
+
+// renderTerraform returns the terraform resources and import blocks of a service
+func renderTerraform(es *ExportedService) ([]byte, error) {
+	w := &terraformWriter{file: hclwrite.NewEmptyFile()}
+	name := terraformName(es.ServiceName)
+
+	if es.Repository != "" {
+		w.ecrRepository(name, es.Repository)
+	}
+	if es.TaskRole != nil {
+		if err := w.iamRole(name, es); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.taskDefinition(name, es); err != nil {
+		return nil, err
+	}
+	for k, tg := range es.TargetGroups {
+		w.targetGroup(targetGroupName(name, k), tg)
+		for _, rule := range tg.ListenerRules {
+			w.listenerRule(name, targetGroupName(name, k), tg.getListenerName(rule), aws.StringValue(tg.TargetGroup.TargetGroupArn), rule)
+		}
+	}
+	w.ecsService(name, es)
+	if es.ScalableTarget != nil {
+		w.autoscaling(name, es)
+	}
+	w.importBlocks()
+	return w.file.Bytes(), nil
+}
+
+// terraformName returns a valid terraform identifier
+func terraformName(name string) string {
+	name = terraformInvalidChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || name[0] == '-' {
+		name = "_" + name
+	}
+	return name
+}
+
+func targetGroupName(name string, k int) string {
+	if k == 0 {
+		return name
+	}
+	return name + "-" + strconv.Itoa(k+1)
+}
+
+func traversal(parts ...string) hcl.Traversal {
+	t := hcl.Traversal{hcl.TraverseRoot{Name: parts[0]}}
+	for _, part := range parts[1:] {
+		t = append(t, hcl.TraverseAttr{Name: part})
+	}
+	return t
+}
+
+// jsonencode returns a jsonencode() call with the json document converted to HCL
+func jsonencode(document string) (hclwrite.Tokens, error) {
+	t, err := ctyjson.ImpliedType([]byte(document))
+	if err != nil {
+		return nil, err
+	}
+	v, err := ctyjson.Unmarshal([]byte(document), t)
+	if err != nil {
+		return nil, err
+	}
+	return hclwrite.TokensForFunctionCall("jsonencode", hclwrite.TokensForValue(v)), nil
+}
+
+func stringList(values []*string) cty.Value {
+	if len(values) == 0 {
+		return cty.ListValEmpty(cty.String)
+	}
+	var l []cty.Value
+	for _, v := range values {
+		l = append(l, cty.StringVal(aws.StringValue(v)))
+	}
+	return cty.ListVal(l)
+}
+
+func setString(body *hclwrite.Body, name string, value *string) {
+	if value != nil && *value != "" {
+		body.SetAttributeValue(name, cty.StringVal(*value))
+	}
+}
+func setInt(body *hclwrite.Body, name string, value *int64) {
+	if value != nil {
+		body.SetAttributeValue(name, cty.NumberIntVal(*value))
+	}
+}
+func setBool(body *hclwrite.Body, name string, value *bool) {
+	if value != nil {
+		body.SetAttributeValue(name, cty.BoolVal(*value))
+	}
+}
+
+func (w *terraformWriter) resource(resourceType, name, id string) *hclwrite.Body {
+	w.file.Body().AppendNewline()
+	w.imports = append(w.imports, terraformImport{resourceType: resourceType, name: name, id: id})
+	return w.file.Body().AppendNewBlock("resource", []string{resourceType, name}).Body()
+}
+
+func (w *terraformWriter) importBlocks() {
+	for _, i := range w.imports {
+		w.file.Body().AppendNewline()
+		body := w.file.Body().AppendNewBlock("import", nil).Body()
+		body.SetAttributeTraversal("to", traversal(i.resourceType, i.name))
+		body.SetAttributeValue("id", cty.StringVal(i.id))
+	}
+}
+
+func (w *terraformWriter) ecrRepository(name, repository string) {
+	body := w.resource("aws_ecr_repository", name, repository)
+	body.SetAttributeValue("name", cty.StringVal(repository))
+}
+
+func (w *terraformWriter) iamRole(name string, es *ExportedService) error {
+	roleName := aws.StringValue(es.TaskRole.RoleName)
+	body := w.resource("aws_iam_role", name, roleName)
+	body.SetAttributeValue("name", cty.StringVal(roleName))
+	setString(body, "path", es.TaskRole.Path)
+	assumeRolePolicy, err := jsonencode(aws.StringValue(es.TaskRole.AssumeRolePolicyDocument))
+	if err != nil {
+		return err
+	}
+	body.SetAttributeRaw("assume_role_policy", assumeRolePolicy)
+	if es.TaskRole.PermissionsBoundary != nil {
+		setString(body, "permissions_boundary", es.TaskRole.PermissionsBoundary.PermissionsBoundaryArn)
+	}
+	if aws.Int64Value(es.TaskRole.MaxSessionDuration) != 3600 {
+		setInt(body, "max_session_duration", es.TaskRole.MaxSessionDuration)
+	}
+
+	var policyNames []string
+	for policyName := range es.TaskRolePolicies {
+		policyNames = append(policyNames, policyName)
+	}
+	sort.Strings(policyNames)
+	for _, policyName := range policyNames {
+		policy, err := jsonencode(es.TaskRolePolicies[policyName])
+		if err != nil {
+			return err
+		}
+		body := w.resource("aws_iam_role_policy", terraformName(policyName), roleName+":"+policyName)
+		body.SetAttributeValue("name", cty.StringVal(policyName))
+		body.SetAttributeTraversal("role", traversal("aws_iam_role", name, "id"))
+		body.SetAttributeRaw("policy", policy)
+	}
+	for _, policyArn := range es.TaskRolePolicyArns {
+		body := w.resource("aws_iam_role_policy_attachment", terraformName(name+"-"+policyArn[strings.LastIndex(policyArn, "/")+1:]), roleName+"/"+policyArn)
+		body.SetAttributeTraversal("role", traversal("aws_iam_role", name, "name"))
+		body.SetAttributeValue("policy_arn", cty.StringVal(policyArn))
+	}
+	return nil
+}
+
+func (w *terraformWriter) taskDefinition(name string, es *ExportedService) error {
+	td := es.TaskDefinition
+	body := w.resource("aws_ecs_task_definition", name, aws.StringValue(td.TaskDefinitionArn))
+	body.SetAttributeValue("family", cty.StringVal(aws.StringValue(td.Family)))
+	// the api json encoding of the container definitions is the format terraform expects
+	containerDefinitions, err := jsonutil.BuildJSON(td.ContainerDefinitions)
+	if err != nil {
+		return err
+	}
+	tokens, err := jsonencode(string(containerDefinitions))
+	if err != nil {
+		return err
+	}
+	body.SetAttributeRaw("container_definitions", tokens)
+	if es.TaskRole != nil && aws.StringValue(es.TaskRole.Arn) == aws.StringValue(td.TaskRoleArn) {
+		body.SetAttributeTraversal("task_role_arn", traversal("aws_iam_role", name, "arn"))
+	} else {
+		setString(body, "task_role_arn", td.TaskRoleArn)
+	}
+	setString(body, "execution_role_arn", td.ExecutionRoleArn)
+	setString(body, "network_mode", td.NetworkMode)
+	if len(td.RequiresCompatibilities) > 0 {
+		body.SetAttributeValue("requires_compatibilities", stringList(td.RequiresCompatibilities))
+	}
+	setString(body, "cpu", td.Cpu)
+	setString(body, "memory", td.Memory)
+	setString(body, "pid_mode", td.PidMode)
+	setString(body, "ipc_mode", td.IpcMode)
+	for _, volume := range td.Volumes {
+		v := body.AppendNewBlock("volume", nil).Body()
+		setString(v, "name", volume.Name)
+		if volume.Host != nil {
+			setString(v, "host_path", volume.Host.SourcePath)
+		}
+		if volume.EfsVolumeConfiguration != nil {
+			efs := v.AppendNewBlock("efs_volume_configuration", nil).Body()
+			setString(efs, "file_system_id", volume.EfsVolumeConfiguration.FileSystemId)
+			setString(efs, "root_directory", volume.EfsVolumeConfiguration.RootDirectory)
+			setString(efs, "transit_encryption", volume.EfsVolumeConfiguration.TransitEncryption)
+		}
+	}
+	for _, constraint := range td.PlacementConstraints {
+		c := body.AppendNewBlock("placement_constraints", nil).Body()
+		setString(c, "type", constraint.Type)
+		setString(c, "expression", constraint.Expression)
+	}
+	return nil
+}
+
+func (w *terraformWriter) targetGroup(name string, tg ExportedTargetGroup) {
+	t := tg.TargetGroup
+	body := w.resource("aws_lb_target_group", name, aws.StringValue(t.TargetGroupArn))
+	setString(body, "name", t.TargetGroupName)
+	setInt(body, "port", t.Port)
+	setString(body, "protocol", t.Protocol)
+	setString(body, "vpc_id", t.VpcId)
+	setString(body, "target_type", t.TargetType)
+	if v, ok := tg.Attributes["deregistration_delay.timeout_seconds"]; ok {
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			body.SetAttributeValue("deregistration_delay", cty.NumberIntVal(i))
+		}
+	}
+	if v, ok := tg.Attributes["slow_start.duration_seconds"]; ok && v != "0" {
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			body.SetAttributeValue("slow_start", cty.NumberIntVal(i))
+		}
+	}
+	healthCheck := body.AppendNewBlock("health_check", nil).Body()
+	setBool(healthCheck, "enabled", t.HealthCheckEnabled)
+	setInt(healthCheck, "healthy_threshold", t.HealthyThresholdCount)
+	setInt(healthCheck, "unhealthy_threshold", t.UnhealthyThresholdCount)
+	setInt(healthCheck, "interval", t.HealthCheckIntervalSeconds)
+	setInt(healthCheck, "timeout", t.HealthCheckTimeoutSeconds)
+	setString(healthCheck, "protocol", t.HealthCheckProtocol)
+	setString(healthCheck, "path", t.HealthCheckPath)
+	setString(healthCheck, "port", t.HealthCheckPort)
+	if t.Matcher != nil {
+		setString(healthCheck, "matcher", t.Matcher.HttpCode)
+	}
+	if tg.Attributes["stickiness.enabled"] == "true" {
+		stickiness := body.AppendNewBlock("stickiness", nil).Body()
+		stickiness.SetAttributeValue("enabled", cty.True)
+		stickiness.SetAttributeValue("type", cty.StringVal(tg.Attributes["stickiness.type"]))
+		if v, err := strconv.ParseInt(tg.Attributes["stickiness.lb_cookie.duration_seconds"], 10, 64); err == nil {
+			stickiness.SetAttributeValue("cookie_duration", cty.NumberIntVal(v))
+		}
+	}
+}
+
+func (w *terraformWriter) listenerRule(name, targetGroup, listener, targetGroupArn string, rule *elbv2.Rule) {
+	priority, _ := strconv.ParseInt(aws.StringValue(rule.Priority), 10, 64)
+	body := w.resource("aws_lb_listener_rule", name+"-rule-"+listener+"-"+aws.StringValue(rule.Priority), aws.StringValue(rule.RuleArn))
+	body.SetAttributeValue("listener_arn", cty.StringVal(getListenerArn(rule)))
+	body.SetAttributeValue("priority", cty.NumberIntVal(priority))
+
+	for _, action := range rule.Actions {
+		a := body.AppendNewBlock("action", nil).Body()
+		setString(a, "type", action.Type)
+		if len(rule.Actions) > 1 {
+			setInt(a, "order", action.Order)
+		}
+		switch aws.StringValue(action.Type) {
+		case "forward":
+			if aws.StringValue(forwardTargetGroupArn(action)) == targetGroupArn {
+				a.SetAttributeTraversal("target_group_arn", traversal("aws_lb_target_group", targetGroup, "arn"))
+			} else {
+				setString(a, "target_group_arn", forwardTargetGroupArn(action))
+			}
+		case "redirect":
+			if action.RedirectConfig != nil {
+				r := a.AppendNewBlock("redirect", nil).Body()
+				setString(r, "protocol", action.RedirectConfig.Protocol)
+				setString(r, "port", action.RedirectConfig.Port)
+				setString(r, "host", action.RedirectConfig.Host)
+				setString(r, "path", action.RedirectConfig.Path)
+				setString(r, "query", action.RedirectConfig.Query)
+				setString(r, "status_code", action.RedirectConfig.StatusCode)
+			}
+		case "fixed-response":
+			if action.FixedResponseConfig != nil {
+				r := a.AppendNewBlock("fixed_response", nil).Body()
+				setString(r, "content_type", action.FixedResponseConfig.ContentType)
+				setString(r, "message_body", action.FixedResponseConfig.MessageBody)
+				setString(r, "status_code", action.FixedResponseConfig.StatusCode)
+			}
+		case "authenticate-cognito":
+			if action.AuthenticateCognitoConfig != nil {
+				c := action.AuthenticateCognitoConfig
+				r := a.AppendNewBlock("authenticate_cognito", nil).Body()
+				setString(r, "user_pool_arn", c.UserPoolArn)
+				setString(r, "user_pool_client_id", c.UserPoolClientId)
+				setString(r, "user_pool_domain", c.UserPoolDomain)
+				setString(r, "on_unauthenticated_request", c.OnUnauthenticatedRequest)
+				setString(r, "scope", c.Scope)
+				setString(r, "session_cookie_name", c.SessionCookieName)
+				setInt(r, "session_timeout", c.SessionTimeout)
+			}
+		}
+	}
+
+	for _, condition := range rule.Conditions {
+		c := body.AppendNewBlock("condition", nil).Body()
+		switch aws.StringValue(condition.Field) {
+		case "path-pattern":
+			values := condition.Values
+			if condition.PathPatternConfig != nil {
+				values = condition.PathPatternConfig.Values
+			}
+			c.AppendNewBlock("path_pattern", nil).Body().SetAttributeValue("values", stringList(values))
+		case "host-header":
+			values := condition.Values
+			if condition.HostHeaderConfig != nil {
+				values = condition.HostHeaderConfig.Values
+			}
+			c.AppendNewBlock("host_header", nil).Body().SetAttributeValue("values", stringList(values))
+		case "http-request-method":
+			if condition.HttpRequestMethodConfig != nil {
+				c.AppendNewBlock("http_request_method", nil).Body().SetAttributeValue("values", stringList(condition.HttpRequestMethodConfig.Values))
+			}
+		case "source-ip":
+			if condition.SourceIpConfig != nil {
+				c.AppendNewBlock("source_ip", nil).Body().SetAttributeValue("values", stringList(condition.SourceIpConfig.Values))
+			}
+		case "http-header":
+			if condition.HttpHeaderConfig != nil {
+				h := c.AppendNewBlock("http_header", nil).Body()
+				setString(h, "http_header_name", condition.HttpHeaderConfig.HttpHeaderName)
+				h.SetAttributeValue("values", stringList(condition.HttpHeaderConfig.Values))
+			}
+		case "query-string":
+			if condition.QueryStringConfig != nil {
+				for _, kv := range condition.QueryStringConfig.Values {
+					q := c.AppendNewBlock("query_string", nil).Body()
+					setString(q, "key", kv.Key)
+					setString(q, "value", kv.Value)
+				}
+			}
+		}
+	}
+}
+
+// forwardTargetGroupArn returns the target group of a forward action
+func forwardTargetGroupArn(action *elbv2.Action) *string {
+	if action.TargetGroupArn != nil {
+		return action.TargetGroupArn
+	}
+	if action.ForwardConfig != nil && len(action.ForwardConfig.TargetGroups) > 0 {
+		return action.ForwardConfig.TargetGroups[0].TargetGroupArn
+	}
+	return nil
+}
+
+func (w *terraformWriter) ecsService(name string, es *ExportedService) {
+	s := es.Service
+	body := w.resource("aws_ecs_service", name, es.ClusterName+"/"+aws.StringValue(s.ServiceName))
+	setString(body, "name", s.ServiceName)
+	setString(body, "cluster", s.ClusterArn)
+	body.SetAttributeTraversal("task_definition", traversal("aws_ecs_task_definition", name, "arn"))
+	if aws.StringValue(s.SchedulingStrategy) == "DAEMON" {
+		setString(body, "scheduling_strategy", s.SchedulingStrategy)
+	} else {
+		setInt(body, "desired_count", s.DesiredCount)
+	}
+	if len(s.CapacityProviderStrategy) == 0 {
+		setString(body, "launch_type", s.LaunchType)
+	}
+	setString(body, "platform_version", s.PlatformVersion)
+	if s.DeploymentConfiguration != nil {
+		setInt(body, "deployment_minimum_healthy_percent", s.DeploymentConfiguration.MinimumHealthyPercent)
+		setInt(body, "deployment_maximum_percent", s.DeploymentConfiguration.MaximumPercent)
+	}
+	setInt(body, "health_check_grace_period_seconds", s.HealthCheckGracePeriodSeconds)
+	setString(body, "iam_role", s.RoleArn)
+	if aws.BoolValue(s.EnableExecuteCommand) {
+		setBool(body, "enable_execute_command", s.EnableExecuteCommand)
+	}
+	if aws.BoolValue(s.EnableECSManagedTags) {
+		setBool(body, "enable_ecs_managed_tags", s.EnableECSManagedTags)
+	}
+	if aws.StringValue(s.PropagateTags) != "" && aws.StringValue(s.PropagateTags) != "NONE" {
+		setString(body, "propagate_tags", s.PropagateTags)
+	}
+	if s.DeploymentConfiguration != nil && s.DeploymentConfiguration.DeploymentCircuitBreaker != nil && aws.BoolValue(s.DeploymentConfiguration.DeploymentCircuitBreaker.Enable) {
+		c := body.AppendNewBlock("deployment_circuit_breaker", nil).Body()
+		setBool(c, "enable", s.DeploymentConfiguration.DeploymentCircuitBreaker.Enable)
+		setBool(c, "rollback", s.DeploymentConfiguration.DeploymentCircuitBreaker.Rollback)
+	}
+	if s.DeploymentController != nil && aws.StringValue(s.DeploymentController.Type) != "ECS" {
+		setString(body.AppendNewBlock("deployment_controller", nil).Body(), "type", s.DeploymentController.Type)
+	}
+	for _, cp := range s.CapacityProviderStrategy {
+		c := body.AppendNewBlock("capacity_provider_strategy", nil).Body()
+		setString(c, "capacity_provider", cp.CapacityProvider)
+		setInt(c, "weight", cp.Weight)
+		setInt(c, "base", cp.Base)
+	}
+	if s.NetworkConfiguration != nil && s.NetworkConfiguration.AwsvpcConfiguration != nil {
+		vpc := s.NetworkConfiguration.AwsvpcConfiguration
+		n := body.AppendNewBlock("network_configuration", nil).Body()
+		n.SetAttributeValue("subnets", stringList(vpc.Subnets))
+		n.SetAttributeValue("security_groups", stringList(vpc.SecurityGroups))
+		n.SetAttributeValue("assign_public_ip", cty.BoolVal(aws.StringValue(vpc.AssignPublicIp) == "ENABLED"))
+	}
+	for _, lb := range s.LoadBalancers {
+		l := body.AppendNewBlock("load_balancer", nil).Body()
+		for k, tg := range es.TargetGroups {
+			if aws.StringValue(tg.TargetGroup.TargetGroupArn) == aws.StringValue(lb.TargetGroupArn) {
+				l.SetAttributeTraversal("target_group_arn", traversal("aws_lb_target_group", targetGroupName(name, k), "arn"))
+			}
+		}
+		setString(l, "container_name", lb.ContainerName)
+		setInt(l, "container_port", lb.ContainerPort)
+	}
+	for _, sr := range s.ServiceRegistries {
+		r := body.AppendNewBlock("service_registries", nil).Body()
+		setString(r, "registry_arn", sr.RegistryArn)
+		setInt(r, "port", sr.Port)
+		setString(r, "container_name", sr.ContainerName)
+		setInt(r, "container_port", sr.ContainerPort)
+	}
+	for _, strategy := range s.PlacementStrategy {
+		p := body.AppendNewBlock("ordered_placement_strategy", nil).Body()
+		setString(p, "type", strategy.Type)
+		setString(p, "field", strategy.Field)
+	}
+	for _, constraint := range s.PlacementConstraints {
+		p := body.AppendNewBlock("placement_constraints", nil).Body()
+		setString(p, "type", constraint.Type)
+		setString(p, "expression", constraint.Expression)
+	}
+	if es.ScalableTarget != nil {
+		lifecycle := body.AppendNewBlock("lifecycle", nil).Body()
+		lifecycle.SetAttributeRaw("ignore_changes", hclwrite.TokensForTuple([]hclwrite.Tokens{hclwrite.TokensForIdentifier("desired_count")}))
+	}
+}
+
+func (w *terraformWriter) autoscaling(name string, es *ExportedService) {
+	t := es.ScalableTarget
+	targetId := aws.StringValue(t.ServiceNamespace) + "/" + aws.StringValue(t.ResourceId) + "/" + aws.StringValue(t.ScalableDimension)
+	body := w.resource("aws_appautoscaling_target", name, targetId)
+	setString(body, "service_namespace", t.ServiceNamespace)
+	setString(body, "resource_id", t.ResourceId)
+	setString(body, "scalable_dimension", t.ScalableDimension)
+	setInt(body, "min_capacity", t.MinCapacity)
+	setInt(body, "max_capacity", t.MaxCapacity)
+	setString(body, "role_arn", t.RoleARN)
+	body.AppendNewline()
+	body.SetAttributeRaw("depends_on", hclwrite.TokensForTuple([]hclwrite.Tokens{hclwrite.TokensForTraversal(traversal("aws_ecs_service", name))}))
+
+	policyArns := make(map[string]string)
+	for _, policy := range es.ScalingPolicies {
+		policyName := terraformName(aws.StringValue(policy.PolicyName))
+		policyArns[aws.StringValue(policy.PolicyARN)] = policyName
+		w.scalingPolicy(name, policyName, policy)
+	}
+	for _, alarm := range es.Alarms {
+		w.metricAlarm(alarm, policyArns)
+	}
+}
+
+func (w *terraformWriter) scalingPolicy(name, policyName string, policy *applicationautoscaling.ScalingPolicy) {
+	body := w.resource("aws_appautoscaling_policy", policyName, aws.StringValue(policy.ServiceNamespace)+"/"+aws.StringValue(policy.ResourceId)+"/"+aws.StringValue(policy.ScalableDimension)+"/"+aws.StringValue(policy.PolicyName))
+	setString(body, "name", policy.PolicyName)
+	setString(body, "policy_type", policy.PolicyType)
+	body.SetAttributeTraversal("resource_id", traversal("aws_appautoscaling_target", name, "resource_id"))
+	body.SetAttributeTraversal("scalable_dimension", traversal("aws_appautoscaling_target", name, "scalable_dimension"))
+	body.SetAttributeTraversal("service_namespace", traversal("aws_appautoscaling_target", name, "service_namespace"))
+	if c := policy.StepScalingPolicyConfiguration; c != nil {
+		s := body.AppendNewBlock("step_scaling_policy_configuration", nil).Body()
+		setString(s, "adjustment_type", c.AdjustmentType)
+		setInt(s, "cooldown", c.Cooldown)
+		setString(s, "metric_aggregation_type", c.MetricAggregationType)
+		setInt(s, "min_adjustment_magnitude", c.MinAdjustmentMagnitude)
+		for _, step := range c.StepAdjustments {
+			a := s.AppendNewBlock("step_adjustment", nil).Body()
+			// the bounds are strings in the terraform schema
+			if step.MetricIntervalLowerBound != nil {
+				a.SetAttributeValue("metric_interval_lower_bound", cty.StringVal(strconv.FormatFloat(*step.MetricIntervalLowerBound, 'f', -1, 64)))
+			}
+			if step.MetricIntervalUpperBound != nil {
+				a.SetAttributeValue("metric_interval_upper_bound", cty.StringVal(strconv.FormatFloat(*step.MetricIntervalUpperBound, 'f', -1, 64)))
+			}
+			setInt(a, "scaling_adjustment", step.ScalingAdjustment)
+		}
+	}
+	if c := policy.TargetTrackingScalingPolicyConfiguration; c != nil {
+		t := body.AppendNewBlock("target_tracking_scaling_policy_configuration", nil).Body()
+		if c.TargetValue != nil {
+			t.SetAttributeValue("target_value", cty.NumberFloatVal(*c.TargetValue))
+		}
+		setBool(t, "disable_scale_in", c.DisableScaleIn)
+		setInt(t, "scale_in_cooldown", c.ScaleInCooldown)
+		setInt(t, "scale_out_cooldown", c.ScaleOutCooldown)
+		if m := c.PredefinedMetricSpecification; m != nil {
+			p := t.AppendNewBlock("predefined_metric_specification", nil).Body()
+			setString(p, "predefined_metric_type", m.PredefinedMetricType)
+			setString(p, "resource_label", m.ResourceLabel)
+		}
+		if m := c.CustomizedMetricSpecification; m != nil {
+			p := t.AppendNewBlock("customized_metric_specification", nil).Body()
+			setString(p, "metric_name", m.MetricName)
+			setString(p, "namespace", m.Namespace)
+			setString(p, "statistic", m.Statistic)
+			setString(p, "unit", m.Unit)
+			for _, d := range m.Dimensions {
+				dimension := p.AppendNewBlock("dimensions", nil).Body()
+				setString(dimension, "name", d.Name)
+				setString(dimension, "value", d.Value)
+			}
+		}
+	}
+}
+
+func (w *terraformWriter) metricAlarm(alarm *cloudwatch.MetricAlarm, policyArns map[string]string) {
+	body := w.resource("aws_cloudwatch_metric_alarm", terraformName(aws.StringValue(alarm.AlarmName)), aws.StringValue(alarm.AlarmName))
+	setString(body, "alarm_name", alarm.AlarmName)
+	setString(body, "alarm_description", alarm.AlarmDescription)
+	setString(body, "comparison_operator", alarm.ComparisonOperator)
+	setInt(body, "evaluation_periods", alarm.EvaluationPeriods)
+	setInt(body, "datapoints_to_alarm", alarm.DatapointsToAlarm)
+	setString(body, "metric_name", alarm.MetricName)
+	setString(body, "namespace", alarm.Namespace)
+	setInt(body, "period", alarm.Period)
+	setString(body, "statistic", alarm.Statistic)
+	if alarm.Threshold != nil {
+		body.SetAttributeValue("threshold", cty.NumberFloatVal(*alarm.Threshold))
+	}
+	setBool(body, "actions_enabled", alarm.ActionsEnabled)
+	if len(alarm.AlarmActions) > 0 {
+		var actions []hclwrite.Tokens
+		for _, action := range alarm.AlarmActions {
+			if policyName, ok := policyArns[aws.StringValue(action)]; ok {
+				actions = append(actions, hclwrite.TokensForTraversal(traversal("aws_appautoscaling_policy", policyName, "arn")))
+			} else {
+				actions = append(actions, hclwrite.TokensForValue(cty.StringVal(aws.StringValue(action))))
+			}
+		}
+		body.SetAttributeRaw("alarm_actions", hclwrite.TokensForTuple(actions))
+	}
+	if len(alarm.Dimensions) > 0 {
+		dimensions := make(map[string]cty.Value)
+		for _, d := range alarm.Dimensions {
+			dimensions[aws.StringValue(d.Name)] = cty.StringVal(aws.StringValue(d.Value))
+		}
+		body.SetAttributeValue("dimensions", cty.MapVal(dimensions))
+	}
+}
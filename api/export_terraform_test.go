@@ -0,0 +1,242 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+func getTestExportedService() *ExportedService {
+	targetGroupArn := "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/myservice/0123456789abcdef"
+	policyArn := "arn:aws:autoscaling:us-east-1:123456789012:scalingPolicy:1234:resource/ecs/service/mycluster/myservice:policyName/myservice-cpu-up"
+	return &ExportedService{
+		ServiceName: "myservice",
+		ClusterName: "mycluster",
+		Service: &awsecs.Service{
+			ServiceName:    aws.String("myservice"),
+			ClusterArn:     aws.String("arn:aws:ecs:us-east-1:123456789012:cluster/mycluster"),
+			TaskDefinition: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/myservice:3"),
+			DesiredCount:   aws.Int64(2),
+			LaunchType:     aws.String("EC2"),
+			DeploymentConfiguration: &awsecs.DeploymentConfiguration{
+				MinimumHealthyPercent: aws.Int64(100),
+				MaximumPercent:        aws.Int64(200),
+			},
+			LoadBalancers: []*awsecs.LoadBalancer{
+				{TargetGroupArn: aws.String(targetGroupArn), ContainerName: aws.String("myservice"), ContainerPort: aws.Int64(8080)},
+			},
+		},
+		TaskDefinition: &awsecs.TaskDefinition{
+			TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/myservice:3"),
+			Family:            aws.String("myservice"),
+			TaskRoleArn:       aws.String("arn:aws:iam::123456789012:role/ecs-myservice"),
+			ContainerDefinitions: []*awsecs.ContainerDefinition{
+				{
+					Name:         aws.String("myservice"),
+					Image:        aws.String("123456789012.dkr.ecr.us-east-1.amazonaws.com/myservice:latest"),
+					Essential:    aws.Bool(true),
+					Memory:       aws.Int64(128),
+					PortMappings: []*awsecs.PortMapping{{ContainerPort: aws.Int64(8080), HostPort: aws.Int64(0), Protocol: aws.String("tcp")}},
+					Environment:  []*awsecs.KeyValuePair{{Name: aws.String("TEMPLATE"), Value: aws.String("${NOT_INTERPOLATED}")}},
+				},
+			},
+		},
+		TargetGroups: []ExportedTargetGroup{
+			{
+				TargetGroup: &elbv2.TargetGroup{
+					TargetGroupArn:  aws.String(targetGroupArn),
+					TargetGroupName: aws.String("myservice"),
+					Port:            aws.Int64(8080),
+					Protocol:        aws.String("HTTP"),
+					VpcId:           aws.String("vpc-123"),
+					HealthCheckPath: aws.String("/health"),
+					Matcher:         &elbv2.Matcher{HttpCode: aws.String("200")},
+				},
+				Attributes: map[string]string{"deregistration_delay.timeout_seconds": "300"},
+				ListenerRules: []*elbv2.Rule{
+					{
+						RuleArn:  aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:listener-rule/app/mycluster/abc/def/ghi"),
+						Priority: aws.String("10"),
+						Actions:  []*elbv2.Action{{Type: aws.String("forward"), TargetGroupArn: aws.String(targetGroupArn)}},
+						Conditions: []*elbv2.RuleCondition{
+							{Field: aws.String("path-pattern"), Values: aws.StringSlice([]string{"/myservice/*"})},
+						},
+					},
+				},
+				Listeners: map[string]*elbv2.Listener{
+					"arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/mycluster/abc/def": {
+						ListenerArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/mycluster/abc/def"),
+						Port:        aws.Int64(80),
+						Protocol:    aws.String("HTTP"),
+					},
+				},
+			},
+		},
+		ScalableTarget: &applicationautoscaling.ScalableTarget{
+			ServiceNamespace:  aws.String("ecs"),
+			ResourceId:        aws.String("service/mycluster/myservice"),
+			ScalableDimension: aws.String("ecs:service:DesiredCount"),
+			MinCapacity:       aws.Int64(1),
+			MaxCapacity:       aws.Int64(4),
+			RoleARN:           aws.String("arn:aws:iam::123456789012:role/ecs-app-autoscaling-role"),
+		},
+		ScalingPolicies: []*applicationautoscaling.ScalingPolicy{
+			{
+				PolicyARN:         aws.String(policyArn),
+				PolicyName:        aws.String("myservice-cpu-up"),
+				PolicyType:        aws.String("StepScaling"),
+				ServiceNamespace:  aws.String("ecs"),
+				ResourceId:        aws.String("service/mycluster/myservice"),
+				ScalableDimension: aws.String("ecs:service:DesiredCount"),
+				StepScalingPolicyConfiguration: &applicationautoscaling.StepScalingPolicyConfiguration{
+					AdjustmentType:  aws.String("ChangeInCapacity"),
+					Cooldown:        aws.Int64(300),
+					StepAdjustments: []*applicationautoscaling.StepAdjustment{{MetricIntervalLowerBound: aws.Float64(0), ScalingAdjustment: aws.Int64(1)}},
+				},
+			},
+		},
+		Alarms: []*cloudwatch.MetricAlarm{
+			{
+				AlarmName:          aws.String("myservice-cpu-up"),
+				AlarmActions:       aws.StringSlice([]string{policyArn}),
+				ComparisonOperator: aws.String("GreaterThanThreshold"),
+				EvaluationPeriods:  aws.Int64(3),
+				MetricName:         aws.String("CPUUtilization"),
+				Namespace:          aws.String("AWS/ECS"),
+				Period:             aws.Int64(60),
+				Statistic:          aws.String("Average"),
+				Threshold:          aws.Float64(75),
+				Dimensions: []*cloudwatch.Dimension{
+					{Name: aws.String("ClusterName"), Value: aws.String("mycluster")},
+					{Name: aws.String("ServiceName"), Value: aws.String("myservice")},
+				},
+			},
+		},
+		TaskRole: &iam.Role{
+			Arn:                      aws.String("arn:aws:iam::123456789012:role/ecs-myservice"),
+			RoleName:                 aws.String("ecs-myservice"),
+			Path:                     aws.String("/"),
+			MaxSessionDuration:       aws.Int64(3600),
+			AssumeRolePolicyDocument: aws.String(`{"Version":"2012-10-17","Statement":[{"Action":"sts:AssumeRole","Principal":{"Service":"ecs-tasks.amazonaws.com"},"Effect":"Allow"}]}`),
+		},
+		TaskRolePolicies: map[string]string{
+			"paramstore-myservice": `{"Version":"2012-10-17","Statement":[{"Action":["ssm:GetParameter"],"Resource":["arn:aws:ssm:us-east-1:123456789012:parameter/myenv/myservice/*"],"Effect":"Allow"}]}`,
+		},
+		Repository: "myservice",
+	}
+}
+
+func TestRenderTerraform(t *testing.T) {
+	tf, err := renderTerraform(getTestExportedService())
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	f, diags := hclsyntax.ParseConfig(tf, "myservice.tf", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatalf("invalid HCL: %s\n%s", diags.Error(), tf)
+	}
+	resources := make(map[string]bool)
+	imports := 0
+	for _, block := range f.Body.(*hclsyntax.Body).Blocks {
+		switch block.Type {
+		case "resource":
+			resources[block.Labels[0]+"."+block.Labels[1]] = true
+		case "import":
+			imports++
+		}
+	}
+	for _, r := range []string{
+		"aws_ecr_repository.myservice",
+		"aws_iam_role.myservice",
+		"aws_iam_role_policy.paramstore-myservice",
+		"aws_ecs_task_definition.myservice",
+		"aws_lb_target_group.myservice",
+		"aws_lb_listener_rule.myservice-rule-80-10",
+		"aws_ecs_service.myservice",
+		"aws_appautoscaling_target.myservice",
+		"aws_appautoscaling_policy.myservice-cpu-up",
+		"aws_cloudwatch_metric_alarm.myservice-cpu-up",
+	} {
+		if !resources[r] {
+			t.Errorf("resource %s not found in:\n%s", r, tf)
+		}
+	}
+	if imports != len(resources) {
+		t.Errorf("expected %d import blocks, got %d", len(resources), imports)
+	}
+	// ignore the alignment of the attributes
+	normalized := strings.Join(strings.Fields(string(tf)), " ")
+	for _, s := range []string{
+		`id = "mycluster/myservice"`,
+		`id = "ecs/service/mycluster/myservice/ecs:service:DesiredCount"`,
+		`id = "ecs-myservice:paramstore-myservice"`,
+		`listener_arn = "arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/mycluster/abc/def"`,
+		`task_definition = aws_ecs_task_definition.myservice.arn`,
+		`alarm_actions = [aws_appautoscaling_policy.myservice-cpu-up.arn]`,
+		`"$${NOT_INTERPOLATED}"`,
+	} {
+		if !strings.Contains(normalized, s) {
+			t.Errorf("%s not found in:\n%s", s, tf)
+		}
+	}
+}
+
+// addTestHttpsListenerRule adds the https rule ecs-deploy creates next to the http rule, with the same priority
+func addTestHttpsListenerRule(es *ExportedService) {
+	tg := &es.TargetGroups[0]
+	tg.ListenerRules = append(tg.ListenerRules, &elbv2.Rule{
+		RuleArn:    aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:listener-rule/app/mycluster/abc/jkl/mno"),
+		Priority:   aws.String("10"),
+		Actions:    tg.ListenerRules[0].Actions,
+		Conditions: tg.ListenerRules[0].Conditions,
+	})
+	tg.Listeners["arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/mycluster/abc/jkl"] = &elbv2.Listener{
+		ListenerArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/mycluster/abc/jkl"),
+		Port:        aws.Int64(443),
+		Protocol:    aws.String("HTTPS"),
+	}
+}
+
+func TestRenderTerraformListenerRules(t *testing.T) {
+	es := getTestExportedService()
+	addTestHttpsListenerRule(es)
+	tf, err := renderTerraform(es)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	f, diags := hclsyntax.ParseConfig(tf, "myservice.tf", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatalf("invalid HCL: %s\n%s", diags.Error(), tf)
+	}
+	rules := make(map[string]int)
+	for _, block := range f.Body.(*hclsyntax.Body).Blocks {
+		if block.Type == "resource" && block.Labels[0] == "aws_lb_listener_rule" {
+			rules[block.Labels[1]]++
+		}
+	}
+	if len(rules) != 2 || rules["myservice-rule-80-10"] != 1 || rules["myservice-rule-443-10"] != 1 {
+		t.Errorf("unexpected listener rules: %v", rules)
+	}
+	// without the listener, the listener id is used
+	delete(es.TargetGroups[0].Listeners, "arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/mycluster/abc/jkl")
+	if name := es.TargetGroups[0].getListenerName(es.TargetGroups[0].ListenerRules[1]); name != "jkl" {
+		t.Errorf("unexpected listener name: %s", name)
+	}
+}
+
+func TestTerraformName(t *testing.T) {
+	if name := terraformName("my.service"); name != "my_service" {
+		t.Errorf("unexpected name: %s", name)
+	}
+	if name := terraformName("1service"); name != "_1service" {
+		t.Errorf("unexpected name: %s", name)
+	}
+}
@@ -28,6 +28,17 @@ type Flags struct {
 	DeleteCluster         string
 	LoadBalancers         []service.LoadBalancer
 	ProdCode              string
+	CapacityProvider      bool
+	// additional instance types of the mixed instances policy (comma separated)
+	InstanceTypes          string
+	OnDemandBaseCapacity   string
+	OnDemandPercentage     string
+	SpotAllocationStrategy string
+	// target capacity (in percent) of the capacity provider managed scaling
+	CapacityProviderTargetCapacity string
+	// server configuration file (yaml)
+	ConfigFile  string
+	PrintConfig bool
 }
 
 func NewFlags() *Flags {
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/in4it/ecs-deploy/provider/ecs"
+	"github.com/in4it/ecs-deploy/service"
+	"github.com/in4it/ecs-deploy/util"
+)
+
+// severity levels of ECR scan findings, from low to high
+var imageScanSeverities = map[string]int{
+	"INFORMATIONAL": 1,
+	"LOW":           2,
+	"MEDIUM":        3,
+	"HIGH":          4,
+	"CRITICAL":      5,
+}
+
+// checkImageScan returns the findings of the ECR images that are blocked by the image scan policy of the cluster
+func (c *Controller) checkImageScan(ctx context.Context, clusterName string, containers []*service.DeployContainer) ([]service.ImageScanFinding, error) {
+	logger := loggerFromContext(ctx)
+	s := service.NewService()
+	policy, err := s.GetImageScanPolicy(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil || !policy.Enabled {
+		return nil, nil
+	}
+	ecr := ecs.ECR{}
+	var blocked []service.ImageScanFinding
+	for _, container := range containers {
+		// only images in ecr have scan results
+		if container.ContainerURI != "" {
+			continue
+		}
+		repository := container.ContainerImage
+		if repository == "" {
+			repository = container.ContainerName
+		}
+		tag := container.ContainerTag
+		if tag == "" {
+			tag = "latest"
+		}
+		status, findings, err := ecr.GetImageScanFindings(repository, container.ContainerDigest, tag)
+		if err != nil {
+			return nil, fmt.Errorf("could not get image scan findings for %v: %v", repository, err)
+		}
+		if status != "COMPLETE" {
+			if policy.RequireScan {
+				blocked = append(blocked, service.ImageScanFinding{Name: "scan status of " + repository + ": " + status, Severity: policy.SeverityThreshold})
+			} else {
+				logger.Warningf("Image scan of %v not complete (status: %v), deploying without scan results", repository, status)
+			}
+			continue
+		}
+		blocked = append(blocked, evaluateImageScanFindings(*policy, findings)...)
+	}
+	return blocked, nil
+}
+
+// evaluateImageScanFindings returns the findings at or above the severity threshold that are not in the allowlist
+func evaluateImageScanFindings(policy service.ImageScanPolicy, findings []service.ImageScanFinding) []service.ImageScanFinding {
+	var blocked []service.ImageScanFinding
+	threshold := imageScanSeverities[strings.ToUpper(policy.SeverityThreshold)]
+	for _, finding := range findings {
+		if imageScanSeverities[strings.ToUpper(finding.Severity)] < threshold {
+			continue
+		}
+		if allowed, _ := util.InArray(policy.Allowlist, finding.Name); allowed {
+			continue
+		}
+		blocked = append(blocked, finding)
+	}
+	return blocked
+}
+
+// formatImageScanFindings returns the findings as a comma separated list with the severity
+func formatImageScanFindings(findings []service.ImageScanFinding) string {
+	var f []string
+	for _, finding := range findings {
+		f = append(f, finding.Name+" ("+finding.Severity+")")
+	}
+	sort.Strings(f)
+	return strings.Join(f, ", ")
+}
+
+func (c *Controller) getImageScanPolicy(clusterName string) (service.ImageScanPolicy, error) {
+	s := service.NewService()
+	policy, err := s.GetImageScanPolicy(clusterName)
+	if err != nil {
+		return service.ImageScanPolicy{}, err
+	}
+	if policy == nil {
+		return service.ImageScanPolicy{ClusterName: clusterName}, nil
+	}
+	return *policy, nil
+}
+
+func (c *Controller) putImageScanPolicy(clusterName, user string, policy service.ImageScanPolicy) (service.ImageScanPolicy, error) {
+	policy.SeverityThreshold = strings.ToUpper(policy.SeverityThreshold)
+	if _, ok := imageScanSeverities[policy.SeverityThreshold]; !ok {
+		return policy, fmt.Errorf("invalid severityThreshold %v (CRITICAL, HIGH, MEDIUM, LOW or INFORMATIONAL)", policy.SeverityThreshold)
+	}
+	policy.ClusterName = clusterName
+	policy.UpdatedBy = user
+	policy.UpdatedAt = time.Now()
+	s := service.NewService()
+	err := s.PutImageScanPolicy(policy)
+	if err != nil {
+		return policy, err
+	}
+	controllerLogger.Infof("Image scan policy of cluster %v updated by %v", clusterName, user)
+	return policy, nil
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/in4it/ecs-deploy/service"
+)
+
+func TestEvaluateImageScanFindings(t *testing.T) {
+	policy := service.ImageScanPolicy{
+		Enabled:           true,
+		SeverityThreshold: "HIGH",
+		Allowlist:         []string{"CVE-2020-0002"},
+	}
+	findings := []service.ImageScanFinding{
+		{Name: "CVE-2020-0001", Severity: "CRITICAL"},
+		{Name: "CVE-2020-0002", Severity: "HIGH"},
+		{Name: "CVE-2020-0003", Severity: "HIGH"},
+		{Name: "CVE-2020-0004", Severity: "MEDIUM"},
+		{Name: "CVE-2020-0005", Severity: "UNDEFINED"},
+	}
+	blocked := evaluateImageScanFindings(policy, findings)
+	if len(blocked) != 2 {
+		t.Fatalf("expected 2 blocked findings, got: %v", blocked)
+	}
+	if blocked[0].Name != "CVE-2020-0001" || blocked[1].Name != "CVE-2020-0003" {
+		t.Errorf("unexpected blocked findings: %v", blocked)
+	}
+	if s := formatImageScanFindings(blocked); s != "CVE-2020-0001 (CRITICAL), CVE-2020-0003 (HIGH)" {
+		t.Errorf("unexpected format: %s", s)
+	}
+}
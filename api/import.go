@@ -0,0 +1,438 @@
+package api
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/in4it/ecs-deploy/provider/ecs"
+	"github.com/in4it/ecs-deploy/service"
+	"github.com/in4it/ecs-deploy/util"
+	"github.com/juju/loggo"
+)
+
+// logging
+var importLogger = loggo.GetLogger("import")
+
+// image in ecr, with the account id and region of the registry
+var ecrImage = regexp.MustCompile(`^([0-9]{12})\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com/`)
+
+// Import adopts services that weren't created by ecs-deploy
+type Import struct{}
+
+// importService synthesises the deploy data of a live service, registers the service and writes an initial deployment
+// with dryRun set, only the deploy data is returned
+func (i *Import) importService(clusterName, serviceName string, dryRun bool) (*service.ImportResult, error) {
+	s := service.NewService()
+	s.ServiceName = serviceName
+	s.ClusterName = clusterName
+	exists, err := s.ServiceExistsInDynamo()
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("Service already managed by ecs-deploy: " + serviceName)
+	}
+
+	// live state
+	ecsSvc := ecs.ECS{}
+	svc, err := ecsSvc.GetServiceDefinition(clusterName, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	td, err := ecsSvc.GetTaskDefinitionDetails(aws.StringValue(svc.TaskDefinition))
+	if err != nil {
+		return nil, err
+	}
+	var tg *ExportedTargetGroup
+	var listeners map[string]string
+	var domain string
+	for _, lb := range svc.LoadBalancers {
+		if lb.TargetGroupArn == nil {
+			continue
+		}
+		tg, listeners, domain, err = i.getTargetGroup(aws.StringValue(lb.TargetGroupArn))
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	// images in the ecr registry of ecs-deploy are referenced by repository name
+	iam := ecs.IAM{}
+	if err = iam.GetAccountId(); err != nil {
+		return nil, err
+	}
+	d, warnings := newDeployFromService(clusterName, svc, td, tg, listeners, domain, iam.AccountId, serverConfig.AWS.Region)
+	result := &service.ImportResult{
+		ServiceName:       serviceName,
+		ClusterName:       clusterName,
+		TaskDefinitionArn: aws.StringValue(td.TaskDefinitionArn),
+		Warnings:          warnings,
+		DeployData:        d,
+	}
+	for _, warning := range warnings {
+		importLogger.Warningf("Import of %v: %v", serviceName, warning)
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	// register the service, with the listeners of the rules forwarding to the service
+	if tg != nil {
+		for _, rule := range tg.ListenerRules {
+			listenerArn := getListenerArn(rule)
+			if found, _ := util.InArray(s.Listeners, listenerArn); !found {
+				s.Listeners = append(s.Listeners, listenerArn)
+			}
+		}
+	}
+	controller := Controller{}
+	err = controller.createServiceInDynamo(s, *d)
+	if err != nil {
+		return nil, err
+	}
+
+	// initial deployment, so the next deploy updates the service instead of creating it
+	dd, err := s.NewDeployment(td.TaskDefinitionArn, d)
+	if err != nil {
+		return nil, err
+	}
+	err = s.SetDeploymentStatus(dd, "success")
+	if err != nil {
+		return nil, err
+	}
+	importLogger.Infof("Imported service %v (cluster %v)", serviceName, clusterName)
+	result.Imported = true
+	result.DeploymentTime = dd.Time
+	return result, nil
+}
+
+// getTargetGroup returns the target group with its listener rules, the protocols of the listeners and the domain of the load balancer
+func (i *Import) getTargetGroup(targetGroupArn string) (*ExportedTargetGroup, map[string]string, string, error) {
+	var err error
+	tg := &ExportedTargetGroup{}
+	a := ecs.ALB{}
+	tg.TargetGroup, tg.Attributes, err = a.DescribeTargetGroup(targetGroupArn)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if len(tg.TargetGroup.LoadBalancerArns) == 0 {
+		return tg, nil, "", nil
+	}
+	alb, err := ecs.NewALB(loadBalancerNameFromArn(aws.StringValue(tg.TargetGroup.LoadBalancerArns[0])))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	err = alb.GetRulesForAllListeners()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	e := Export{}
+	tg.ListenerRules = e.getListenerRulesForTargetGroup(alb, targetGroupArn)
+	listeners := make(map[string]string)
+	for _, l := range alb.Listeners {
+		listeners[aws.StringValue(l.ListenerArn)] = strings.ToLower(aws.StringValue(l.Protocol))
+	}
+	return tg, listeners, alb.GetDomain(), nil
+}
+
+// loadBalancerNameFromArn returns the name of a load balancer (arn:aws:elasticloadbalancing:region:account:loadbalancer/app/name/id)
+func loadBalancerNameFromArn(arn string) string {
+	parts := strings.Split(arn, "/")
+	if len(parts) < 3 {
+		return arn
+	}
+	return parts[len(parts)-2]
+}
+
+// newDeployFromService converts the live state of a service into deploy data
+// settings that can't be expressed in the deploy data are returned as warnings
+func newDeployFromService(clusterName string, svc *awsecs.Service, td *awsecs.TaskDefinition, tg *ExportedTargetGroup, listeners map[string]string, domain, accountId, region string) (*service.Deploy, []string) {
+	var warnings []string
+	serviceName := aws.StringValue(svc.ServiceName)
+	d := &service.Deploy{
+		Cluster:      clusterName,
+		ServiceName:  serviceName,
+		DesiredCount: aws.Int64Value(svc.DesiredCount),
+		NetworkMode:  aws.StringValue(td.NetworkMode),
+	}
+	service.SetDeployDefaults(d)
+
+	if aws.StringValue(svc.SchedulingStrategy) == "DAEMON" {
+		d.SchedulingStrategy = "DAEMON"
+	}
+	if len(svc.CapacityProviderStrategy) > 0 {
+		warnings = append(warnings, "capacity provider strategy is not imported")
+	} else {
+		d.LaunchType = aws.StringValue(svc.LaunchType)
+	}
+	if svc.DeploymentConfiguration != nil {
+		d.MinimumHealthyPercent = aws.Int64Value(svc.DeploymentConfiguration.MinimumHealthyPercent)
+		d.MaximumPercent = aws.Int64Value(svc.DeploymentConfiguration.MaximumPercent)
+	}
+	if svc.NetworkConfiguration != nil && svc.NetworkConfiguration.AwsvpcConfiguration != nil {
+		vpc := svc.NetworkConfiguration.AwsvpcConfiguration
+		d.NetworkConfiguration.AssignPublicIp = aws.StringValue(vpc.AssignPublicIp)
+		d.NetworkConfiguration.SecurityGroups = aws.StringValueSlice(vpc.SecurityGroups)
+		d.NetworkConfiguration.Subnets = aws.StringValueSlice(vpc.Subnets)
+	}
+	for _, pc := range td.PlacementConstraints {
+		d.PlacementConstraints = append(d.PlacementConstraints, service.DeployPlacementConstraint{
+			Expression: aws.StringValue(pc.Expression),
+			Type:       aws.StringValue(pc.Type),
+		})
+	}
+	if len(svc.ServiceRegistries) > 0 {
+		warnings = append(warnings, "service registries are not imported, set serviceRegistry to the service discovery namespace")
+	}
+	if len(svc.LoadBalancers) > 1 {
+		warnings = append(warnings, "only the first load balancer of the service is imported")
+	}
+	if td.TaskRoleArn != nil && !strings.HasSuffix(aws.StringValue(td.TaskRoleArn), "/ecs-"+serviceName) {
+		warnings = append(warnings, "the task role ("+aws.StringValue(td.TaskRoleArn)+") will be replaced by the role ecs-"+serviceName+" on the next deploy")
+	}
+
+	// volumes
+	for _, volume := range td.Volumes {
+		v := service.DeployVolume{Name: aws.StringValue(volume.Name)}
+		if volume.Host != nil {
+			v.Host.SourcePath = aws.StringValue(volume.Host.SourcePath)
+		}
+		if c := volume.DockerVolumeConfiguration; c != nil {
+			v.DockerVolumeConfiguration = service.DeployVolumeDockerVolumeConfiguration{
+				Scope:         aws.StringValue(c.Scope),
+				Autoprovision: aws.BoolValue(c.Autoprovision),
+				Driver:        aws.StringValue(c.Driver),
+				DriverOpts:    aws.StringValueMap(c.DriverOpts),
+				Labels:        aws.StringValueMap(c.Labels),
+			}
+		}
+		if volume.EfsVolumeConfiguration != nil || volume.FsxWindowsFileServerVolumeConfiguration != nil {
+			warnings = append(warnings, "volume "+v.Name+": only host and docker volumes are imported")
+		}
+		d.Volumes = append(d.Volumes, v)
+	}
+
+	// containers
+	containerPorts := make(map[string]int64)
+	for _, lb := range svc.LoadBalancers {
+		containerPorts[aws.StringValue(lb.ContainerName)] = aws.Int64Value(lb.ContainerPort)
+	}
+	for _, cd := range td.ContainerDefinitions {
+		container, containerWarnings := newDeployContainer(cd, containerPorts, accountId, region)
+		d.Containers = append(d.Containers, container)
+		warnings = append(warnings, containerWarnings...)
+	}
+
+	// target group and listener rules
+	if tg == nil {
+		d.ServiceProtocol = "none"
+		return d, warnings
+	}
+	t := tg.TargetGroup
+	d.ServicePort = aws.Int64Value(t.Port)
+	d.ServiceProtocol = aws.StringValue(t.Protocol)
+	if aws.StringValue(t.TargetGroupName) != util.TruncateString(serviceName, 32) {
+		warnings = append(warnings, "the target group name ("+aws.StringValue(t.TargetGroupName)+") doesn't match the service name, ecs-deploy looks up the target group by service name")
+	}
+	if len(t.LoadBalancerArns) > 0 {
+		loadBalancer := loadBalancerNameFromArn(aws.StringValue(t.LoadBalancerArns[0]))
+		if loadBalancer != clusterName {
+			d.LoadBalancer = loadBalancer
+		}
+	}
+	d.HealthCheck = service.DeployHealthCheck{
+		HealthyThreshold:   aws.Int64Value(t.HealthyThresholdCount),
+		UnhealthyThreshold: aws.Int64Value(t.UnhealthyThresholdCount),
+		Path:               aws.StringValue(t.HealthCheckPath),
+		Port:               aws.StringValue(t.HealthCheckPort),
+		Protocol:           aws.StringValue(t.HealthCheckProtocol),
+		Interval:           aws.Int64Value(t.HealthCheckIntervalSeconds),
+		Timeout:            aws.Int64Value(t.HealthCheckTimeoutSeconds),
+		GracePeriodSeconds: aws.Int64Value(svc.HealthCheckGracePeriodSeconds),
+	}
+	if t.Matcher != nil {
+		d.HealthCheck.Matcher = aws.StringValue(t.Matcher.HttpCode)
+	}
+	if v, err := strconv.ParseInt(tg.Attributes["deregistration_delay.timeout_seconds"], 10, 64); err == nil {
+		d.DeregistrationDelay = v
+	}
+	if tg.Attributes["stickiness.enabled"] == "true" {
+		d.Stickiness.Enabled = true
+		if v, err := strconv.ParseInt(tg.Attributes["stickiness.lb_cookie.duration_seconds"], 10, 64); err == nil {
+			d.Stickiness.Duration = v
+		}
+	}
+	var ruleWarnings []string
+	d.RuleConditions, ruleWarnings = newDeployRuleConditions(serviceName, tg, listeners, domain)
+	warnings = append(warnings, ruleWarnings...)
+	return d, warnings
+}
+
+// newDeployContainer converts a container definition into the container of the deploy data.
+// The account id and region are the ecr registry the images of the deploy data are pulled from
+func newDeployContainer(cd *awsecs.ContainerDefinition, containerPorts map[string]int64, accountId, region string) (*service.DeployContainer, []string) {
+	var warnings []string
+	container := &service.DeployContainer{
+		ContainerName:       aws.StringValue(cd.Name),
+		ContainerCommand:    cd.Command,
+		ContainerEntryPoint: cd.EntryPoint,
+		// essential is true when not set
+		Essential:         cd.Essential == nil || aws.BoolValue(cd.Essential),
+		Memory:            aws.Int64Value(cd.Memory),
+		MemoryReservation: aws.Int64Value(cd.MemoryReservation),
+		CPU:               aws.Int64Value(cd.Cpu),
+		DockerLabels:      aws.StringValueMap(cd.DockerLabels),
+		Links:             cd.Links,
+	}
+
+	// image: repositories in the ecr registry are referenced by name, other images by uri
+	image := aws.StringValue(cd.Image)
+	uri, tag := image, "latest"
+	if strings.Contains(image, "@") {
+		uri, tag = image[:strings.Index(image, "@")], image[strings.Index(image, "@")+1:]
+	} else if strings.LastIndex(image, ":") > strings.LastIndex(image, "/") {
+		uri, tag = image[:strings.LastIndex(image, ":")], image[strings.LastIndex(image, ":")+1:]
+	}
+	container.ContainerTag = tag
+	if m := ecrImage.FindStringSubmatch(uri); m != nil && m[1] == accountId && m[2] == region && !strings.Contains(image, "@") {
+		repository := ecrImage.ReplaceAllString(uri, "")
+		if repository != container.ContainerName {
+			container.ContainerImage = repository
+		}
+	} else {
+		container.ContainerURI = image
+	}
+
+	if port, ok := containerPorts[container.ContainerName]; ok {
+		container.ContainerPort = port
+	} else if len(cd.PortMappings) > 0 {
+		container.ContainerPort = aws.Int64Value(cd.PortMappings[0].ContainerPort)
+	}
+	if len(cd.PortMappings) > 1 || (len(cd.PortMappings) == 1 && aws.Int64Value(cd.PortMappings[0].HostPort) != 0 && aws.Int64Value(cd.PortMappings[0].HostPort) != aws.Int64Value(cd.PortMappings[0].ContainerPort)) {
+		for _, pm := range cd.PortMappings {
+			container.PortMappings = append(container.PortMappings, service.DeployContainerPortMapping{
+				Protocol:      aws.StringValue(pm.Protocol),
+				HostPort:      aws.Int64Value(pm.HostPort),
+				ContainerPort: aws.Int64Value(pm.ContainerPort),
+			})
+		}
+	}
+	if hc := cd.HealthCheck; hc != nil {
+		container.HealthCheck = service.DeployContainerHealthCheck{
+			Command:     hc.Command,
+			Interval:    aws.Int64Value(hc.Interval),
+			Timeout:     aws.Int64Value(hc.Timeout),
+			Retries:     aws.Int64Value(hc.Retries),
+			StartPeriod: aws.Int64Value(hc.StartPeriod),
+		}
+	}
+	for _, env := range cd.Environment {
+		container.Environment = append(container.Environment, &service.DeployContainerEnvironment{Name: aws.StringValue(env.Name), Value: aws.StringValue(env.Value)})
+	}
+	for _, mp := range cd.MountPoints {
+		container.MountPoints = append(container.MountPoints, &service.DeployContainerMountPoint{
+			ContainerPath: aws.StringValue(mp.ContainerPath),
+			SourceVolume:  aws.StringValue(mp.SourceVolume),
+			ReadOnly:      aws.BoolValue(mp.ReadOnly),
+		})
+	}
+	for _, ulimit := range cd.Ulimits {
+		container.Ulimits = append(container.Ulimits, &service.DeployContainerUlimit{
+			Name:      aws.StringValue(ulimit.Name),
+			SoftLimit: aws.Int64Value(ulimit.SoftLimit),
+			HardLimit: aws.Int64Value(ulimit.HardLimit),
+		})
+	}
+	// awslogs is configured by ecs-deploy when CLOUDWATCH_LOGS_ENABLED is set
+	if lc := cd.LogConfiguration; lc != nil && aws.StringValue(lc.LogDriver) != "awslogs" {
+		container.LogConfiguration.LogDriver = aws.StringValue(lc.LogDriver)
+		container.LogConfiguration.Options.MaxSize = aws.StringValue(lc.Options["max-size"])
+		container.LogConfiguration.Options.MaxFile = aws.StringValue(lc.Options["max-file"])
+	}
+	if len(cd.Secrets) > 0 {
+		warnings = append(warnings, "container "+container.ContainerName+": secrets are not imported, use the parameter store integration")
+	}
+	return container, warnings
+}
+
+// newDeployRuleConditions converts the listener rules into rule conditions
+// no rule conditions are returned when the rules are the default rules (/service and /service/* on all listeners)
+func newDeployRuleConditions(serviceName string, tg *ExportedTargetGroup, listeners map[string]string, domain string) ([]*service.DeployRuleConditions, []string) {
+	var warnings []string
+	var ruleConditions []*service.DeployRuleConditions
+	conditions := make(map[string]*service.DeployRuleConditions)
+	for _, rule := range tg.ListenerRules {
+		r := &service.DeployRuleConditions{}
+		supported := true
+		for _, condition := range rule.Conditions {
+			values := aws.StringValueSlice(condition.Values)
+			switch aws.StringValue(condition.Field) {
+			case "path-pattern":
+				if condition.PathPatternConfig != nil {
+					values = aws.StringValueSlice(condition.PathPatternConfig.Values)
+				}
+				if len(values) == 1 {
+					r.PathPattern = values[0]
+				} else {
+					supported = false
+				}
+			case "host-header":
+				if condition.HostHeaderConfig != nil {
+					values = aws.StringValueSlice(condition.HostHeaderConfig.Values)
+				}
+				// the hostname of a rule condition is relative to the domain of the load balancer
+				if len(values) == 1 && domain != "" && strings.HasSuffix(values[0], "."+domain) {
+					r.Hostname = strings.TrimSuffix(values[0], "."+domain)
+				} else {
+					supported = false
+				}
+			default:
+				supported = false
+			}
+		}
+		for _, action := range rule.Actions {
+			if aws.StringValue(action.Type) == "authenticate-cognito" {
+				warnings = append(warnings, "listener rule "+aws.StringValue(rule.Priority)+": cognito authentication is not imported")
+			}
+		}
+		if !supported {
+			warnings = append(warnings, "listener rule "+aws.StringValue(rule.Priority)+": conditions can't be expressed as a rule condition, rule not imported")
+			continue
+		}
+		listener, ok := listeners[getListenerArn(rule)]
+		if !ok {
+			warnings = append(warnings, "listener rule "+aws.StringValue(rule.Priority)+": listener not found, rule not imported")
+			continue
+		}
+		key := r.PathPattern + "|" + r.Hostname
+		if _, ok := conditions[key]; !ok {
+			conditions[key] = r
+			ruleConditions = append(ruleConditions, r)
+		}
+		if found, _ := util.InArray(conditions[key].Listeners, listener); !found {
+			conditions[key].Listeners = append(conditions[key].Listeners, listener)
+		}
+	}
+
+	// default rules
+	if len(ruleConditions) == 2 {
+		isDefault := true
+		for _, r := range ruleConditions {
+			if r.Hostname != "" || (r.PathPattern != "/"+serviceName && r.PathPattern != "/"+serviceName+"/*") || len(r.Listeners) != len(listeners) {
+				isDefault = false
+			}
+		}
+		if isDefault && ruleConditions[0].PathPattern != ruleConditions[1].PathPattern {
+			return nil, warnings
+		}
+	}
+	if len(ruleConditions) == 0 {
+		warnings = append(warnings, "no listener rules imported")
+	}
+	return ruleConditions, warnings
+}
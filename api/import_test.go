@@ -0,0 +1,93 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+func TestNewDeployFromService(t *testing.T) {
+	es := getTestExportedService()
+	es.TargetGroups[0].TargetGroup.LoadBalancerArns = aws.StringSlice([]string{"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/mycluster/abc"})
+	es.TargetGroups[0].TargetGroup.HealthCheckPath = aws.String("/health")
+	listeners := map[string]string{
+		"arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/mycluster/abc/def": "http",
+	}
+	d, warnings := newDeployFromService("mycluster", es.Service, es.TaskDefinition, &es.TargetGroups[0], listeners, "example.com", "123456789012", "us-east-1")
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if d.Cluster != "mycluster" || d.LoadBalancer != "" {
+		t.Errorf("unexpected cluster / loadbalancer: %v / %v", d.Cluster, d.LoadBalancer)
+	}
+	if d.ServicePort != 8080 || d.ServiceProtocol != "HTTP" || d.DesiredCount != 2 {
+		t.Errorf("unexpected service settings: %+v", d)
+	}
+	if d.HealthCheck.Path != "/health" || d.HealthCheck.Matcher != "200" || d.DeregistrationDelay != 300 {
+		t.Errorf("unexpected target group settings: %+v", d)
+	}
+	if len(d.Containers) != 1 {
+		t.Fatalf("expected 1 container, got %d", len(d.Containers))
+	}
+	container := d.Containers[0]
+	if container.ContainerName != "myservice" || container.ContainerTag != "latest" || container.ContainerImage != "" || container.ContainerURI != "" {
+		t.Errorf("unexpected image: %+v", container)
+	}
+	if container.ContainerPort != 8080 || container.Memory != 128 || !container.Essential {
+		t.Errorf("unexpected container settings: %+v", container)
+	}
+	if len(d.RuleConditions) != 1 || d.RuleConditions[0].PathPattern != "/myservice/*" || d.RuleConditions[0].Listeners[0] != "http" {
+		t.Errorf("unexpected rule conditions: %+v", d.RuleConditions)
+	}
+}
+
+func TestNewDeployRuleConditionsDefault(t *testing.T) {
+	listenerArn := "arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/mycluster/abc/def"
+	tg := &ExportedTargetGroup{
+		ListenerRules: []*elbv2.Rule{
+			{
+				RuleArn:    aws.String(listenerArn + "/1"),
+				Priority:   aws.String("10"),
+				Conditions: []*elbv2.RuleCondition{{Field: aws.String("path-pattern"), Values: aws.StringSlice([]string{"/myservice"})}},
+			},
+			{
+				RuleArn:    aws.String(listenerArn + "/2"),
+				Priority:   aws.String("11"),
+				Conditions: []*elbv2.RuleCondition{{Field: aws.String("path-pattern"), Values: aws.StringSlice([]string{"/myservice/*"})}},
+			},
+			{
+				RuleArn:    aws.String(listenerArn + "/3"),
+				Priority:   aws.String("12"),
+				Conditions: []*elbv2.RuleCondition{{Field: aws.String("host-header"), Values: aws.StringSlice([]string{"myservice.other.com"})}},
+			},
+		},
+	}
+	ruleConditions, warnings := newDeployRuleConditions("myservice", tg, map[string]string{listenerArn: "http"}, "example.com")
+	if ruleConditions != nil {
+		t.Errorf("expected default rules, got: %+v", ruleConditions)
+	}
+	// the host of the last rule is not in the domain of the load balancer
+	if len(warnings) != 1 {
+		t.Errorf("expected 1 warning, got: %v", warnings)
+	}
+}
+
+func TestNewDeployContainerImage(t *testing.T) {
+	container, _ := newDeployContainer(&awsecs.ContainerDefinition{Name: aws.String("web"), Image: aws.String("nginx:1.25")}, nil, "123456789012", "us-east-1")
+	if container.ContainerURI != "nginx:1.25" || container.ContainerTag != "1.25" {
+		t.Errorf("unexpected image: %+v", container)
+	}
+	container, _ = newDeployContainer(&awsecs.ContainerDefinition{Name: aws.String("web"), Image: aws.String("123456789012.dkr.ecr.us-east-1.amazonaws.com/frontend:v2")}, nil, "123456789012", "us-east-1")
+	if container.ContainerURI != "" || container.ContainerImage != "frontend" || container.ContainerTag != "v2" {
+		t.Errorf("unexpected image: %+v", container)
+	}
+	// images in the registry of another account or region are referenced by uri
+	for _, image := range []string{"210987654321.dkr.ecr.us-east-1.amazonaws.com/frontend:v2", "123456789012.dkr.ecr.eu-west-1.amazonaws.com/frontend:v2"} {
+		container, _ = newDeployContainer(&awsecs.ContainerDefinition{Name: aws.String("web"), Image: aws.String(image)}, nil, "123456789012", "us-east-1")
+		if container.ContainerURI != image || container.ContainerImage != "" {
+			t.Errorf("unexpected image: %+v", container)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"github.com/in4it/ecs-deploy/util"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+type loggerKey struct{}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// contextWithLogger stores a logger with the deploy fields in the context
+func contextWithLogger(ctx context.Context, logger util.FieldLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFromContext returns the logger stored in the context, or the controller logger without fields
+func loggerFromContext(ctx context.Context) util.FieldLogger {
+	if logger, ok := ctx.Value(loggerKey{}).(util.FieldLogger); ok {
+		return logger
+	}
+	return util.WithFields(controllerLogger, nil)
+}
+
+// requestIDMiddleware takes the request id from the X-Request-ID header (or generates one)
+// and returns it in the response headers
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = newRequestID()
+		}
+		c.Set("requestID", requestID)
+		c.Header(requestIDHeader, requestID)
+		c.Next()
+	}
+}
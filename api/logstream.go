@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/in4it/ecs-deploy/provider/ecs"
+	"github.com/in4it/ecs-deploy/service"
+)
+
+// logTail keeps track of the log events already sent while following logs
+type logTail struct {
+	lastTimestamp time.Time
+	seen          map[string]time.Time
+}
+
+func newLogTail(start time.Time) *logTail {
+	return &logTail{lastTimestamp: start, seen: make(map[string]time.Time)}
+}
+
+// add returns the events that weren't sent yet, sorted by timestamp
+func (l *logTail) add(events []ecs.CloudWatchLogEvent) []service.ServiceLogEvent {
+	var res []service.ServiceLogEvent
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	for _, event := range events {
+		if _, ok := l.seen[event.EventId]; ok {
+			continue
+		}
+		l.seen[event.EventId] = event.Timestamp
+		if event.Timestamp.After(l.lastTimestamp) {
+			l.lastTimestamp = event.Timestamp
+		}
+		containerName, taskId := parseLogStreamName(event.LogStreamName)
+		res = append(res, service.ServiceLogEvent{
+			Timestamp:     event.Timestamp,
+			TaskId:        taskId,
+			ContainerName: containerName,
+			Message:       strings.TrimRight(event.Message, "\n"),
+		})
+	}
+	// the next query starts at lastTimestamp, only events with that timestamp can be returned again
+	for eventId, timestamp := range l.seen {
+		if timestamp.Before(l.lastTimestamp) {
+			delete(l.seen, eventId)
+		}
+	}
+	return res
+}
+
+// parseLogStreamName returns the container name and task id of a log stream (prefix/container/taskid)
+func parseLogStreamName(logStream string) (string, string) {
+	parts := strings.Split(logStream, "/")
+	if len(parts) != 3 {
+		return "", logStream
+	}
+	return parts[1], parts[2]
+}
+
+// getServiceLogStreams returns the log streams of all containers of the running tasks of a service
+func (c *Controller) getServiceLogStreams(serviceName string) ([]string, error) {
+	var logStreams []string
+	s := service.NewService()
+	s.ServiceName = serviceName
+	clusterName, err := s.GetClusterName()
+	if err != nil {
+		return logStreams, err
+	}
+	e := ecs.ECS{}
+	taskArns, err := e.ListTasks(clusterName, serviceName, "RUNNING", "service")
+	if err != nil {
+		return logStreams, err
+	}
+	if len(taskArns) == 0 {
+		return logStreams, nil
+	}
+	tasks, err := e.DescribeTasks(clusterName, taskArns)
+	if err != nil {
+		return logStreams, err
+	}
+	for _, task := range tasks {
+		taskId := task.TaskArn[strings.LastIndex(task.TaskArn, "/")+1:]
+		for _, container := range task.Containers {
+			logStreams = append(logStreams, container.Name+"/"+container.Name+"/"+taskId)
+		}
+	}
+	return logStreams, nil
+}
+
+// followServiceLogs sends the log events of all running tasks of a service, starting at start.
+// When follow is true, it keeps polling for new events until the context is cancelled
+func (c *Controller) followServiceLogs(ctx context.Context, serviceName, filterPattern string, start time.Time, follow bool, events chan<- service.ServiceLogEvent) error {
+	interval := serverConfig.Deploy.LogsFollowInterval
+	cw := ecs.CloudWatch{}
+	logGroup := serverConfig.Deploy.CloudwatchLogsPrefix + "-" + serverConfig.AWS.AccountEnv
+	tail := newLogTail(start)
+	var logStreams []string
+	var lastRefresh time.Time
+	for {
+		// refresh the log streams, to pick up new tasks
+		if time.Since(lastRefresh) > 30*time.Second {
+			var err error
+			logStreams, err = c.getServiceLogStreams(serviceName)
+			if err != nil {
+				return err
+			}
+			lastRefresh = time.Now()
+		}
+		var collected []ecs.CloudWatchLogEvent
+		// max 100 log streams per call
+		for i := 0; i < len(logStreams); i += 100 {
+			chunk := logStreams[i:min(i+100, len(logStreams))]
+			var nextToken string
+			for page := 0; page < 50; page++ {
+				res, err := cw.FilterLogEvents(logGroup, chunk, filterPattern, tail.lastTimestamp, nextToken)
+				if err != nil {
+					if !follow {
+						return err
+					}
+					// log streams of new tasks might not exist yet
+					controllerLogger.Debugf("followServiceLogs: couldn't filter log events of %s: %s", serviceName, err)
+					break
+				}
+				collected = append(collected, res.LogEvents...)
+				if res.NextForwardToken == "" {
+					break
+				}
+				nextToken = res.NextForwardToken
+			}
+		}
+		for _, event := range tail.add(collected) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if !follow {
+			return nil
+		}
+		select {
+		case <-time.After(time.Duration(interval) * time.Second):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
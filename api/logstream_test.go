@@ -0,0 +1,48 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/in4it/ecs-deploy/provider/ecs"
+)
+
+func TestLogTailAdd(t *testing.T) {
+	start := time.Now().Add(-5 * time.Minute)
+	tail := newLogTail(start)
+	events := []ecs.CloudWatchLogEvent{
+		{EventId: "2", LogStreamName: "web/web/task2", Message: "second\n", Timestamp: start.Add(2 * time.Second)},
+		{EventId: "1", LogStreamName: "web/web/task1", Message: "first", Timestamp: start.Add(1 * time.Second)},
+	}
+	res := tail.add(events)
+	if len(res) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(res))
+	}
+	if res[0].Message != "first" || res[0].TaskId != "task1" || res[0].ContainerName != "web" {
+		t.Errorf("unexpected first event: %+v", res[0])
+	}
+	if res[1].Message != "second" || res[1].TaskId != "task2" {
+		t.Errorf("unexpected second event: %+v", res[1])
+	}
+	if !tail.lastTimestamp.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("unexpected last timestamp: %s", tail.lastTimestamp)
+	}
+	// the next query returns the last event again
+	res = tail.add([]ecs.CloudWatchLogEvent{
+		{EventId: "2", LogStreamName: "web/web/task2", Message: "second", Timestamp: start.Add(2 * time.Second)},
+		{EventId: "3", LogStreamName: "web/web/task1", Message: "third", Timestamp: start.Add(3 * time.Second)},
+	})
+	if len(res) != 1 || res[0].Message != "third" {
+		t.Errorf("unexpected events: %+v", res)
+	}
+	if len(tail.seen) != 1 {
+		t.Errorf("expected seen events to be pruned, got %d", len(tail.seen))
+	}
+}
+
+func TestParseLogStreamName(t *testing.T) {
+	containerName, taskId := parseLogStreamName("web/web/0123456789abcdef")
+	if containerName != "web" || taskId != "0123456789abcdef" {
+		t.Errorf("unexpected result: %s %s", containerName, taskId)
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"github.com/crewjam/saml/samlsp"
 	"github.com/gin-contrib/location"
 	"github.com/gin-gonic/gin"
-	"github.com/in4it/ecs-deploy/util"
 	"github.com/juju/loggo"
 
 	"crypto/rsa"
@@ -78,7 +77,7 @@ func newSAML(strIdpMetadataURL string, X509KeyPair, keyPEMBlock []byte) (*SAML,
 		return nil, err
 	}
 
-	rootURL, err := url.Parse(util.GetEnv("SAML_ACS_URL", ""))
+	rootURL, err := url.Parse(serverConfig.SAML.AcsURL)
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +111,7 @@ func (s *SAML) getIDPCert() string {
 }
 
 func (s *SAML) samlEnabledHandler(c *gin.Context) {
-	if util.GetEnv("SAML_ENABLED", "") == "yes" {
+	if serverConfig.SAML.Enabled == "yes" {
 		c.JSON(200, gin.H{
 			"saml": "enabled",
 		})
@@ -141,7 +140,7 @@ func (s *SAML) samlResponseHandler(c *gin.Context) {
 	claims["exp"] = expire.Unix()
 	claims["orig_iat"] = s.TimeFunc().Unix()
 
-	tokenString, err := token.SignedString([]byte(util.GetEnv("JWT_SECRET", "unsecure secret key 8a045eb")))
+	tokenString, err := token.SignedString([]byte(serverConfig.Auth.JWTSecret))
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -150,7 +149,7 @@ func (s *SAML) samlResponseHandler(c *gin.Context) {
 		return
 	}
 	// redirect to UI with jwt token
-	c.Redirect(http.StatusFound, util.GetEnv("URL_PREFIX", "")+"/webapp/saml?token="+tokenString)
+	c.Redirect(http.StatusFound, serverConfig.Server.UrlPrefix+"/webapp/saml?token="+tokenString)
 }
 
 // samlsp/middleware.go adapted for gin gonic
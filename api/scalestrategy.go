@@ -0,0 +1,613 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/in4it/ecs-deploy/service"
+	"github.com/in4it/ecs-deploy/util"
+)
+
+// ScaleStrategy makes the scale up and scale down decisions of a cluster
+type ScaleStrategy interface {
+	Name() string
+	// ScaleUp returns whether an instance needs to be added to the cluster
+	ScaleUp(snapshot ClusterSnapshot) ScaleDecision
+	// ScaleDown returns whether an instance can be removed from the cluster
+	ScaleDown(snapshot ClusterSnapshot) ScaleDecision
+}
+
+// taskAwareScaleStrategy is implemented by strategies that need the tasks in the cluster snapshot
+type taskAwareScaleStrategy interface {
+	needsTasks() bool
+}
+
+// ScaleDecision is the outcome of a strategy, with the reasons that explain it
+type ScaleDecision struct {
+	Scale   bool     `json:"scale"`
+	Reasons []string `json:"reasons"`
+}
+
+func (d *ScaleDecision) addReason(format string, a ...interface{}) {
+	d.Reasons = append(d.Reasons, fmt.Sprintf(format, a...))
+}
+
+// ClusterSnapshot is the state of a cluster a strategy decides on
+type ClusterSnapshot struct {
+	ClusterName        string                    `json:"clusterName"`
+	Time               time.Time                 `json:"time"`
+	ContainerInstances []ClusterSnapshotInstance `json:"containerInstances"`
+	// registered resources of instances that don't have their registered resources set
+	RegisteredInstanceCpu    int64 `json:"registeredInstanceCpu"`
+	RegisteredInstanceMemory int64 `json:"registeredInstanceMemory"`
+	// resources of the largest task in the cluster
+	CpuNeeded    int64                 `json:"cpuNeeded"`
+	MemoryNeeded int64                 `json:"memoryNeeded"`
+	PendingTasks []ClusterSnapshotTask `json:"pendingTasks"`
+	RunningTasks []ClusterSnapshotTask `json:"runningTasks"`
+}
+type ClusterSnapshotInstance struct {
+	ContainerInstanceId string `json:"containerInstanceId"`
+	AvailabilityZone    string `json:"availabilityZone"`
+	Status              string `json:"status"`
+	FreeCpu             int64  `json:"freeCpu"`
+	FreeMemory          int64  `json:"freeMemory"`
+	RegisteredCpu       int64  `json:"registeredCpu,omitempty"`
+	RegisteredMemory    int64  `json:"registeredMemory,omitempty"`
+}
+type ClusterSnapshotTask struct {
+	ServiceName string `json:"serviceName"`
+	// container instance the task is running on (running tasks only)
+	ContainerInstanceId string  `json:"containerInstanceId,omitempty"`
+	Cpu                 int64   `json:"cpu"`
+	Memory              int64   `json:"memory"`
+	Ports               []int64 `json:"ports,omitempty"`
+}
+
+// newClusterSnapshot converts the container instances of the cluster into a snapshot
+func newClusterSnapshot(clusterName string, containerInstances []service.DynamoClusterContainerInstance, registeredInstanceCpu, registeredInstanceMemory, cpuNeeded, memoryNeeded int64) ClusterSnapshot {
+	snapshot := ClusterSnapshot{
+		ClusterName:              clusterName,
+		Time:                     time.Now(),
+		RegisteredInstanceCpu:    registeredInstanceCpu,
+		RegisteredInstanceMemory: registeredInstanceMemory,
+		CpuNeeded:                cpuNeeded,
+		MemoryNeeded:             memoryNeeded,
+	}
+	for _, dcci := range containerInstances {
+		if dcci.ClusterName == clusterName {
+			snapshot.ContainerInstances = append(snapshot.ContainerInstances, ClusterSnapshotInstance{
+				ContainerInstanceId: dcci.ContainerInstanceId,
+				AvailabilityZone:    dcci.AvailabilityZone,
+				Status:              dcci.Status,
+				FreeCpu:             dcci.FreeCpu,
+				FreeMemory:          dcci.FreeMemory,
+				RegisteredCpu:       dcci.RegisteredCpu,
+				RegisteredMemory:    dcci.RegisteredMemory,
+			})
+		}
+	}
+	return snapshot
+}
+
+// activeInstances returns the instances that can run tasks
+func (s ClusterSnapshot) activeInstances() []ClusterSnapshotInstance {
+	var instances []ClusterSnapshotInstance
+	for _, v := range s.ContainerInstances {
+		if v.Status != "DRAINING" {
+			instances = append(instances, v)
+		}
+	}
+	return instances
+}
+
+// registeredResources returns the registered cpu and memory of the instance
+func (s ClusterSnapshot) registeredResources(instance ClusterSnapshotInstance) (int64, int64) {
+	cpu, memory := instance.RegisteredCpu, instance.RegisteredMemory
+	if cpu == 0 {
+		cpu = s.RegisteredInstanceCpu
+	}
+	if memory == 0 {
+		memory = s.RegisteredInstanceMemory
+	}
+	return cpu, memory
+}
+
+// largestInstanceResources returns the registered cpu and memory of the largest active instance.
+// The autoscaling group decides which instance is removed, so scaling down must account for the largest one
+func (s ClusterSnapshot) largestInstanceResources() (int64, int64) {
+	var largestCpu, largestMemory int64
+	for _, v := range s.activeInstances() {
+		cpu, memory := s.registeredResources(v)
+		if cpu > largestCpu {
+			largestCpu = cpu
+		}
+		if memory > largestMemory {
+			largestMemory = memory
+		}
+	}
+	if largestCpu == 0 && largestMemory == 0 {
+		return s.RegisteredInstanceCpu, s.RegisteredInstanceMemory
+	}
+	return largestCpu, largestMemory
+}
+
+// getScaleStrategy returns the strategy of the cluster (AUTOSCALING_CLUSTER_STRATEGIES, e.g. mycluster=binpacking,othercluster=headroom:30)
+func (c *AutoscalingController) getScaleStrategy(clusterName string) (ScaleStrategy, error) {
+	strategyName := "largestcontainer"
+	for _, v := range strings.Split(c.getConfig().ClusterStrategies, ",") {
+		kv := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(kv) == 2 && kv[0] == clusterName {
+			strategyName = kv[1]
+		}
+	}
+	return c.newScaleStrategy(strategyName)
+}
+
+// newScaleStrategy creates a strategy by name, with an optional argument after the colon (headroom:30, schedule:binpacking)
+func (c *AutoscalingController) newScaleStrategy(strategyName string) (ScaleStrategy, error) {
+	name, arg := strategyName, ""
+	if i := strings.Index(strategyName, ":"); i != -1 {
+		name, arg = strategyName[:i], strategyName[i+1:]
+	}
+	switch strings.ToLower(name) {
+	case "largestcontainer":
+		up, down := c.getAutoscalingStrategy()
+		return LargestContainerStrategy{Up: up, Down: down}, nil
+	case "binpacking":
+		return BinPackingStrategy{}, nil
+	case "headroom":
+		if arg == "" {
+			arg = strconv.FormatInt(c.getConfig().HeadroomPercent, 10)
+		}
+		percent, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || percent < 0 || percent >= 100 {
+			return nil, fmt.Errorf("invalid headroom percentage: %v", arg)
+		}
+		return HeadroomStrategy{Percent: percent}, nil
+	case "schedule":
+		if arg == "" {
+			arg = c.getConfig().ScheduleBaseStrategy
+		}
+		if strings.HasPrefix(strings.ToLower(arg), "schedule") {
+			return nil, errors.New("the base strategy of the schedule strategy can't be schedule")
+		}
+		base, err := c.newScaleStrategy(arg)
+		if err != nil {
+			return nil, err
+		}
+		windows, err := parseScheduleWindows(c.getConfig().ScheduleWindows)
+		if err != nil {
+			return nil, err
+		}
+		location, err := time.LoadLocation(c.getConfig().ScheduleTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTOSCALING_SCHEDULE_TIMEZONE: %v", err)
+		}
+		return ScheduleStrategy{Base: base, Windows: windows, Location: location}, nil
+	}
+	return nil, fmt.Errorf("unknown scale strategy: %v", strategyName)
+}
+
+// LargestContainerStrategy scales up when the largest container can't be scheduled in every availability zone,
+// and scales down when an instance can be removed while keeping room for the largest container (+buffer)
+type LargestContainerStrategy struct {
+	Up   bool
+	Down bool
+}
+
+func (l LargestContainerStrategy) Name() string {
+	return "largestcontainer"
+}
+func (l LargestContainerStrategy) ScaleUp(snapshot ClusterSnapshot) ScaleDecision {
+	var decision ScaleDecision
+	if !l.Up {
+		decision.addReason("LargestContainerUp is not enabled in AUTOSCALING_STRATEGIES")
+		return decision
+	}
+	resourcesFit := make(map[string]bool)
+	for _, dcci := range snapshot.ContainerInstances {
+		if dcci.Status != "DRAINING" && dcci.FreeCpu > snapshot.CpuNeeded && dcci.FreeMemory > snapshot.MemoryNeeded {
+			resourcesFit[dcci.AvailabilityZone] = true
+		} else if _, ok := resourcesFit[dcci.AvailabilityZone]; !ok {
+			// set resourcesFit[az] in case it's not set to true
+			resourcesFit[dcci.AvailabilityZone] = false
+		}
+	}
+	for _, az := range sortedKeys(resourcesFit) {
+		if resourcesFit[az] {
+			decision.addReason("%v has an instance with %d cpu and %d memory free", az, snapshot.CpuNeeded, snapshot.MemoryNeeded)
+		} else {
+			decision.Scale = true
+			decision.addReason("No instance found in %v with %d cpu and %d memory free", az, snapshot.CpuNeeded, snapshot.MemoryNeeded)
+		}
+	}
+	return decision
+}
+func (l LargestContainerStrategy) ScaleDown(snapshot ClusterSnapshot) ScaleDecision {
+	var decision ScaleDecision
+	if !l.Down {
+		decision.addReason("LargestContainerDown is not enabled in AUTOSCALING_STRATEGIES")
+		return decision
+	}
+	instanceCpu, instanceMemory := snapshot.largestInstanceResources()
+	var clusterMemoryNeeded = instanceMemory + snapshot.MemoryNeeded            // capacity of full container node + biggest task
+	clusterMemoryNeeded += int64(math.Ceil(float64(snapshot.MemoryNeeded) / 2)) // + buffer
+	var clusterCpuNeeded = instanceCpu + snapshot.CpuNeeded
+	clusterCpuNeeded += int64(math.Ceil(float64(snapshot.CpuNeeded) / 2)) // + buffer
+	// if we're not using the LargestContainerUp strategy, scale down only when there's a full instance size of extra resources
+	if !l.Up {
+		clusterMemoryNeeded = instanceMemory
+		clusterCpuNeeded = instanceCpu
+	}
+	totalFreeCpu := make(map[string]int64)
+	totalFreeMemory := make(map[string]int64)
+	for _, dcci := range snapshot.activeInstances() {
+		totalFreeCpu[dcci.AvailabilityZone] += dcci.FreeCpu
+		totalFreeMemory[dcci.AvailabilityZone] += dcci.FreeMemory
+	}
+	hasFreeResources := make(map[string]bool)
+	for _, az := range sortedKeys(totalFreeCpu) {
+		hasFreeResources[az] = totalFreeCpu[az] >= clusterCpuNeeded && totalFreeMemory[az] >= clusterMemoryNeeded
+		decision.addReason("%v: have %d cpu and %d memory available, need %d cpu and %d memory", az, totalFreeCpu[az], totalFreeMemory[az], clusterCpuNeeded, clusterMemoryNeeded)
+	}
+	if len(hasFreeResources) == 0 {
+		decision.addReason("No active instances found")
+		return decision
+	}
+	if l.Up {
+		// when using LargestContainerUp, only downscale when all AZs have too much capacity, otherwise a scaleUp will immediately be triggered
+		decision.Scale = true
+		for _, v := range hasFreeResources {
+			if !v {
+				decision.Scale = false
+			}
+		}
+	} else {
+		// when not using LargestContainerUp, downscale if any of the AZs has too many resources
+		for _, v := range hasFreeResources {
+			if v {
+				decision.Scale = true
+			}
+		}
+	}
+	return decision
+}
+
+// BinPackingStrategy simulates the placement of tasks onto the instances using their cpu, memory and port requirements
+type BinPackingStrategy struct{}
+
+func (b BinPackingStrategy) Name() string {
+	return "binpacking"
+}
+func (b BinPackingStrategy) needsTasks() bool {
+	return true
+}
+func (b BinPackingStrategy) ScaleUp(snapshot ClusterSnapshot) ScaleDecision {
+	var decision ScaleDecision
+	instances := newBinPackingInstances(snapshot.activeInstances(), snapshot.RunningTasks)
+	unplaced := binPackTasks(instances, snapshot.PendingTasks)
+	if len(unplaced) == 0 {
+		decision.addReason("All %d pending tasks can be placed on %d instances", len(snapshot.PendingTasks), len(instances))
+		return decision
+	}
+	decision.Scale = true
+	for _, task := range unplaced {
+		decision.addReason("Task of %v (%d cpu, %d memory, ports %v) can't be placed on any instance", task.ServiceName, task.Cpu, task.Memory, task.Ports)
+	}
+	return decision
+}
+
+// ScaleDown checks that every active instance can be removed: the autoscaling group chooses the instance to terminate
+func (b BinPackingStrategy) ScaleDown(snapshot ClusterSnapshot) ScaleDecision {
+	var decision ScaleDecision
+	active := snapshot.activeInstances()
+	if len(active) < 2 {
+		decision.addReason("Need at least 2 active instances to scale down, found %d", len(active))
+		return decision
+	}
+	for i, candidate := range active {
+		remaining := append(append([]ClusterSnapshotInstance{}, active[:i]...), active[i+1:]...)
+		tasks, unplaced := b.removeInstance(snapshot, candidate, remaining)
+		if len(unplaced) > 0 {
+			for _, task := range unplaced {
+				decision.addReason("Instance %v can't be removed: task of %v (%d cpu, %d memory, ports %v) can't be placed on the remaining instances", candidate.ContainerInstanceId, task.ServiceName, task.Cpu, task.Memory, task.Ports)
+			}
+			return decision
+		}
+		decision.addReason("Instance %v can be removed: its %d tasks and %d pending tasks can be placed on the remaining %d instances", candidate.ContainerInstanceId, len(tasks), len(snapshot.PendingTasks), len(remaining))
+	}
+	decision.Scale = true
+	return decision
+}
+
+// removeInstance simulates the removal of the instance, it returns the tasks of the instance and the tasks that can't be placed on the remaining instances
+func (b BinPackingStrategy) removeInstance(snapshot ClusterSnapshot, candidate ClusterSnapshotInstance, remaining []ClusterSnapshotInstance) ([]ClusterSnapshotTask, []ClusterSnapshotTask) {
+	var tasks []ClusterSnapshotTask
+	var runningTasks []ClusterSnapshotTask
+	for _, task := range snapshot.RunningTasks {
+		if task.ContainerInstanceId == candidate.ContainerInstanceId {
+			tasks = append(tasks, task)
+		} else {
+			runningTasks = append(runningTasks, task)
+		}
+	}
+	if len(tasks) == 0 {
+		// tasks of the instance are unknown, approximate them by the reserved resources of the instance
+		registeredCpu, registeredMemory := snapshot.registeredResources(candidate)
+		usedCpu := registeredCpu - candidate.FreeCpu
+		usedMemory := registeredMemory - candidate.FreeMemory
+		if usedCpu > 0 || usedMemory > 0 {
+			tasks = append(tasks, ClusterSnapshotTask{ServiceName: "reserved resources of " + candidate.ContainerInstanceId, Cpu: usedCpu, Memory: usedMemory})
+		}
+	}
+	instances := newBinPackingInstances(remaining, runningTasks)
+	return tasks, binPackTasks(instances, append(append([]ClusterSnapshotTask{}, snapshot.PendingTasks...), tasks...))
+}
+
+type binPackingInstance struct {
+	id         string
+	freeCpu    int64
+	freeMemory int64
+	usedPorts  map[int64]bool
+}
+
+func newBinPackingInstances(containerInstances []ClusterSnapshotInstance, runningTasks []ClusterSnapshotTask) []*binPackingInstance {
+	var instances []*binPackingInstance
+	for _, v := range containerInstances {
+		instance := &binPackingInstance{id: v.ContainerInstanceId, freeCpu: v.FreeCpu, freeMemory: v.FreeMemory, usedPorts: make(map[int64]bool)}
+		for _, task := range runningTasks {
+			if task.ContainerInstanceId == v.ContainerInstanceId {
+				for _, port := range task.Ports {
+					instance.usedPorts[port] = true
+				}
+			}
+		}
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+func (i *binPackingInstance) fits(task ClusterSnapshotTask) bool {
+	if task.Cpu > i.freeCpu || task.Memory > i.freeMemory {
+		return false
+	}
+	for _, port := range task.Ports {
+		if i.usedPorts[port] {
+			return false
+		}
+	}
+	return true
+}
+func (i *binPackingInstance) place(task ClusterSnapshotTask) {
+	i.freeCpu -= task.Cpu
+	i.freeMemory -= task.Memory
+	for _, port := range task.Ports {
+		i.usedPorts[port] = true
+	}
+}
+
+// binPackTasks places the largest tasks first on the first instance they fit on (first fit decreasing) and returns the tasks that don't fit
+func binPackTasks(instances []*binPackingInstance, tasks []ClusterSnapshotTask) []ClusterSnapshotTask {
+	var unplaced []ClusterSnapshotTask
+	sorted := append([]ClusterSnapshotTask{}, tasks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Memory == sorted[j].Memory {
+			return sorted[i].Cpu > sorted[j].Cpu
+		}
+		return sorted[i].Memory > sorted[j].Memory
+	})
+	for _, task := range sorted {
+		placed := false
+		for _, instance := range instances {
+			if instance.fits(task) {
+				instance.place(task)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			unplaced = append(unplaced, task)
+		}
+	}
+	return unplaced
+}
+
+// HeadroomStrategy keeps a percentage of the cpu and memory of the cluster free
+type HeadroomStrategy struct {
+	Percent int64
+}
+
+func (h HeadroomStrategy) Name() string {
+	return "headroom"
+}
+func (h HeadroomStrategy) ScaleUp(snapshot ClusterSnapshot) ScaleDecision {
+	var decision ScaleDecision
+	if len(snapshot.activeInstances()) == 0 {
+		decision.Scale = true
+		decision.addReason("No active instances found")
+		return decision
+	}
+	freeCpu, freeMemory, registeredCpu, registeredMemory := h.getResources(snapshot)
+	cpuPercent := percentage(freeCpu, registeredCpu)
+	memoryPercent := percentage(freeMemory, registeredMemory)
+	if cpuPercent < h.Percent {
+		decision.Scale = true
+		decision.addReason("%d%% of the cpu is free, need %d%% headroom", cpuPercent, h.Percent)
+	}
+	if memoryPercent < h.Percent {
+		decision.Scale = true
+		decision.addReason("%d%% of the memory is free, need %d%% headroom", memoryPercent, h.Percent)
+	}
+	if !decision.Scale {
+		decision.addReason("%d%% of the cpu and %d%% of the memory is free, need %d%% headroom", cpuPercent, memoryPercent, h.Percent)
+	}
+	return decision
+}
+func (h HeadroomStrategy) ScaleDown(snapshot ClusterSnapshot) ScaleDecision {
+	var decision ScaleDecision
+	instances := len(snapshot.activeInstances())
+	if instances < 2 {
+		decision.addReason("Need at least 2 active instances to scale down, found %d", instances)
+		return decision
+	}
+	// free resources after removing the largest instance
+	freeCpu, freeMemory, registeredCpu, registeredMemory := h.getResources(snapshot)
+	instanceCpu, instanceMemory := snapshot.largestInstanceResources()
+	cpuPercent := percentage(freeCpu-instanceCpu, registeredCpu-instanceCpu)
+	memoryPercent := percentage(freeMemory-instanceMemory, registeredMemory-instanceMemory)
+	decision.Scale = cpuPercent >= h.Percent && memoryPercent >= h.Percent
+	decision.addReason("After removing an instance %d%% of the cpu and %d%% of the memory would be free, need %d%% headroom", cpuPercent, memoryPercent, h.Percent)
+	return decision
+}
+
+// getResources returns the free and registered cpu and memory of the active instances
+func (h HeadroomStrategy) getResources(snapshot ClusterSnapshot) (int64, int64, int64, int64) {
+	var freeCpu, freeMemory, registeredCpu, registeredMemory int64
+	for _, v := range snapshot.activeInstances() {
+		cpu, memory := snapshot.registeredResources(v)
+		freeCpu += v.FreeCpu
+		freeMemory += v.FreeMemory
+		registeredCpu += cpu
+		registeredMemory += memory
+	}
+	return freeCpu, freeMemory, registeredCpu, registeredMemory
+}
+
+func percentage(value, total int64) int64 {
+	if total <= 0 {
+		return 0
+	}
+	return value * 100 / total
+}
+
+// ScheduleStrategy blocks scaling down during the schedule windows (e.g. business hours), the base strategy makes the other decisions
+type ScheduleStrategy struct {
+	Base     ScaleStrategy
+	Windows  []ScheduleWindow
+	Location *time.Location
+}
+
+// ScheduleWindow is a time range on one or more days (e.g. mon-fri 08:00-18:00)
+type ScheduleWindow struct {
+	Days  []time.Weekday
+	Start int // minutes since midnight
+	End   int // minutes since midnight
+	Spec  string
+}
+
+func (s ScheduleStrategy) Name() string {
+	return "schedule:" + s.Base.Name()
+}
+func (s ScheduleStrategy) needsTasks() bool {
+	if t, ok := s.Base.(taskAwareScaleStrategy); ok {
+		return t.needsTasks()
+	}
+	return false
+}
+func (s ScheduleStrategy) ScaleUp(snapshot ClusterSnapshot) ScaleDecision {
+	return s.Base.ScaleUp(snapshot)
+}
+func (s ScheduleStrategy) ScaleDown(snapshot ClusterSnapshot) ScaleDecision {
+	now := snapshot.Time
+	if s.Location != nil {
+		now = now.In(s.Location)
+	}
+	for _, w := range s.Windows {
+		if w.contains(now) {
+			var decision ScaleDecision
+			decision.addReason("Scaling down is blocked during schedule window %v", w.Spec)
+			return decision
+		}
+	}
+	return s.Base.ScaleDown(snapshot)
+}
+
+func (w ScheduleWindow) contains(t time.Time) bool {
+	minutes := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if w.End <= w.Start && minutes < w.End {
+		// window crosses midnight, the time belongs to the window of the previous day
+		day = (day + 6) % 7
+	}
+	found := false
+	for _, d := range w.Days {
+		if d == day {
+			found = true
+		}
+	}
+	if !found {
+		return false
+	}
+	if w.End > w.Start {
+		return minutes >= w.Start && minutes < w.End
+	}
+	return minutes >= w.Start || minutes < w.End
+}
+
+var scheduleWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// parseScheduleWindows parses windows separated by a semicolon (e.g. mon-fri 08:00-18:00;sat 10:00-14:00)
+func parseScheduleWindows(spec string) ([]ScheduleWindow, error) {
+	var windows []ScheduleWindow
+	for _, v := range strings.Split(spec, ";") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		fields := strings.Fields(v)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid schedule window: %v (format: mon-fri 08:00-18:00)", v)
+		}
+		window := ScheduleWindow{Spec: v}
+		days := strings.SplitN(strings.ToLower(fields[0]), "-", 2)
+		_, first := util.InArray(scheduleWeekdays, days[0])
+		last := first
+		if len(days) == 2 {
+			_, last = util.InArray(scheduleWeekdays, days[1])
+		}
+		if first == -1 || last == -1 {
+			return nil, fmt.Errorf("invalid days in schedule window: %v", v)
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			window.Days = append(window.Days, time.Weekday(d))
+			if d == last {
+				break
+			}
+		}
+		times := strings.SplitN(fields[1], "-", 2)
+		if len(times) != 2 {
+			return nil, fmt.Errorf("invalid time range in schedule window: %v", v)
+		}
+		var err error
+		if window.Start, err = parseScheduleTime(times[0]); err != nil {
+			return nil, err
+		}
+		if window.End, err = parseScheduleTime(times[1]); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+func parseScheduleTime(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time in schedule window: %v", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/in4it/ecs-deploy/service"
+)
+
+func getTestClusterSnapshot() ClusterSnapshot {
+	return ClusterSnapshot{
+		ClusterName:              "testCluster",
+		Time:                     time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), // monday
+		RegisteredInstanceCpu:    1024,
+		RegisteredInstanceMemory: 2048,
+		CpuNeeded:                256,
+		MemoryNeeded:             512,
+		ContainerInstances: []ClusterSnapshotInstance{
+			{ContainerInstanceId: "i-1", AvailabilityZone: "eu-west-1a", Status: "ACTIVE", FreeCpu: 768, FreeMemory: 1536},
+			{ContainerInstanceId: "i-2", AvailabilityZone: "eu-west-1b", Status: "ACTIVE", FreeCpu: 1024, FreeMemory: 2048},
+			{ContainerInstanceId: "i-3", AvailabilityZone: "eu-west-1b", Status: "ACTIVE", FreeCpu: 512, FreeMemory: 1024},
+		},
+	}
+}
+
+func TestLargestContainerStrategy(t *testing.T) {
+	snapshot := getTestClusterSnapshot()
+	strategy := LargestContainerStrategy{Up: true, Down: true}
+	if decision := strategy.ScaleUp(snapshot); decision.Scale {
+		t.Errorf("Expected no scale up: %v", decision.Reasons)
+	}
+	// eu-west-1a has 768 cpu free, needs 1024 + 256 + 128 cpu
+	if decision := strategy.ScaleDown(snapshot); decision.Scale {
+		t.Errorf("Expected no scale down: %v", decision.Reasons)
+	}
+	// without LargestContainerUp, a full instance of free resources in one AZ is enough
+	strategy.Up = false
+	if decision := strategy.ScaleDown(snapshot); !decision.Scale {
+		t.Errorf("Expected scale down: %v", decision.Reasons)
+	}
+	snapshot.CpuNeeded = 800
+	strategy.Up = true
+	if decision := strategy.ScaleUp(snapshot); !decision.Scale {
+		t.Errorf("Expected scale up: %v", decision.Reasons)
+	}
+	strategy.Up = false
+	if decision := strategy.ScaleUp(snapshot); decision.Scale {
+		t.Errorf("Expected no scale up when LargestContainerUp is disabled")
+	}
+}
+
+func TestBinPackingStrategy(t *testing.T) {
+	snapshot := getTestClusterSnapshot()
+	strategy := BinPackingStrategy{}
+	snapshot.PendingTasks = []ClusterSnapshotTask{
+		{ServiceName: "web", Cpu: 512, Memory: 1024, Ports: []int64{80}},
+		{ServiceName: "web", Cpu: 512, Memory: 1024, Ports: []int64{80}},
+		{ServiceName: "web", Cpu: 512, Memory: 1024, Ports: []int64{80}},
+	}
+	if decision := strategy.ScaleUp(snapshot); decision.Scale {
+		t.Errorf("Expected no scale up: %v", decision.Reasons)
+	}
+	// port 80 is already used on i-2
+	snapshot.RunningTasks = []ClusterSnapshotTask{
+		{ServiceName: "proxy", ContainerInstanceId: "i-2", Ports: []int64{80}},
+	}
+	decision := strategy.ScaleUp(snapshot)
+	if !decision.Scale {
+		t.Errorf("Expected scale up because of the port conflict: %v", decision.Reasons)
+	}
+	if len(decision.Reasons) != 1 {
+		t.Errorf("Expected 1 task that can't be placed, got: %v", decision.Reasons)
+	}
+	// scale down: i-2 is empty
+	snapshot.PendingTasks = []ClusterSnapshotTask{}
+	snapshot.RunningTasks = []ClusterSnapshotTask{}
+	if decision := strategy.ScaleDown(snapshot); !decision.Scale {
+		t.Errorf("Expected scale down of the empty instance: %v", decision.Reasons)
+	}
+	snapshot.ContainerInstances[1].FreeCpu = 0
+	snapshot.ContainerInstances[1].FreeMemory = 0
+	snapshot.ContainerInstances[2].FreeCpu = 128
+	snapshot.ContainerInstances[2].FreeMemory = 256
+	// the reserved resources of i-1 don't fit on the other instances
+	if decision := strategy.ScaleDown(snapshot); decision.Scale {
+		t.Errorf("Expected no scale down: %v", decision.Reasons)
+	}
+	// the least loaded instance (i-2) can be removed, but the autoscaling group can terminate i-1
+	snapshot = getTestClusterSnapshot()
+	snapshot.ContainerInstances[0].FreeCpu, snapshot.ContainerInstances[0].FreeMemory = 128, 256
+	snapshot.ContainerInstances[1].FreeCpu, snapshot.ContainerInstances[1].FreeMemory = 896, 1792
+	snapshot.ContainerInstances[2].FreeCpu, snapshot.ContainerInstances[2].FreeMemory = 256, 512
+	snapshot.RunningTasks = []ClusterSnapshotTask{
+		{ServiceName: "web", ContainerInstanceId: "i-1", Cpu: 512, Memory: 1024, Ports: []int64{80}},
+		{ServiceName: "proxy", ContainerInstanceId: "i-1", Cpu: 384, Memory: 768, Ports: []int64{443}},
+		{ServiceName: "proxy", ContainerInstanceId: "i-2", Cpu: 128, Memory: 256, Ports: []int64{443}},
+		{ServiceName: "web", ContainerInstanceId: "i-3", Cpu: 128, Memory: 256, Ports: []int64{80}},
+		{ServiceName: "worker", ContainerInstanceId: "i-3", Cpu: 640, Memory: 1280},
+	}
+	decision = strategy.ScaleDown(snapshot)
+	if decision.Scale {
+		t.Errorf("Expected no scale down: %v", decision.Reasons)
+	}
+	if len(decision.Reasons) != 1 || !strings.Contains(decision.Reasons[0], "Instance i-1 can't be removed") {
+		t.Errorf("Unexpected reasons: %v", decision.Reasons)
+	}
+}
+
+func TestGetClusterSnapshotWithTasks(t *testing.T) {
+	asc := AutoscalingController{}
+	mc := &MockController{
+		getServicesOutput: []*service.DynamoServicesElement{
+			{S: "web", C: "testCluster", CpuReservation: 256, MemoryReservation: 512},
+		},
+		clusterTasks: clusterTasks{
+			services: []service.RunningService{
+				{
+					ServiceName:  "web",
+					ClusterName:  "testCluster",
+					RunningCount: 2,
+					DesiredCount: 3,
+					Deployments:  []service.RunningServiceDeployment{{Status: "PRIMARY", TaskDefinition: "web:2"}},
+					Tasks: []service.RunningTask{
+						{ContainerInstanceArn: "arn-1", TaskDefinitionArn: "web:2"},
+						{ContainerInstanceArn: "arn-2", TaskDefinitionArn: "web:2"},
+					},
+				},
+			},
+			instanceIds: map[string]string{"arn-1": "i-1", "arn-2": "i-2"},
+			hostPorts:   map[string][]int64{"web:2": {80}},
+		},
+	}
+	containerInstances := []service.DynamoClusterContainerInstance{
+		{ClusterName: "testCluster", ContainerInstanceId: "i-1", Status: "ACTIVE", FreeCpu: 1024, FreeMemory: 2048},
+		{ClusterName: "testCluster", ContainerInstanceId: "i-2", Status: "ACTIVE", FreeCpu: 1024, FreeMemory: 2048},
+	}
+	snapshot := asc.getClusterSnapshot(BinPackingStrategy{}, "testCluster", containerInstances, 2048, 4096, 256, 512, mc)
+	if len(snapshot.RunningTasks) != 2 || snapshot.RunningTasks[1].ContainerInstanceId != "i-2" || snapshot.RunningTasks[1].Ports[0] != 80 {
+		t.Fatalf("Unexpected running tasks: %+v", snapshot.RunningTasks)
+	}
+	if len(snapshot.PendingTasks) != 1 || len(snapshot.PendingTasks[0].Ports) != 1 {
+		t.Fatalf("Unexpected pending tasks: %+v", snapshot.PendingTasks)
+	}
+	// enough resources, but host port 80 is taken on both instances
+	if decision := (BinPackingStrategy{}).ScaleUp(snapshot); !decision.Scale {
+		t.Errorf("Expected scale up because of the port conflict: %+v", decision)
+	}
+	// strategies without tasks don't need them
+	snapshot = asc.getClusterSnapshot(LargestContainerStrategy{}, "testCluster", containerInstances, 2048, 4096, 256, 512, mc)
+	if len(snapshot.RunningTasks) != 0 || len(snapshot.PendingTasks) != 0 {
+		t.Errorf("Unexpected tasks: %+v", snapshot)
+	}
+}
+
+func TestHeadroomStrategy(t *testing.T) {
+	snapshot := getTestClusterSnapshot()
+	// 2304 of 3072 cpu free (75%), 4608 of 6144 memory free (75%)
+	if decision := (HeadroomStrategy{Percent: 80}).ScaleUp(snapshot); !decision.Scale {
+		t.Errorf("Expected scale up: %v", decision.Reasons)
+	}
+	if decision := (HeadroomStrategy{Percent: 50}).ScaleUp(snapshot); decision.Scale {
+		t.Errorf("Expected no scale up: %v", decision.Reasons)
+	}
+	// after removing an instance: 1280 of 2048 cpu free (62%)
+	if decision := (HeadroomStrategy{Percent: 60}).ScaleDown(snapshot); !decision.Scale {
+		t.Errorf("Expected scale down: %v", decision.Reasons)
+	}
+	if decision := (HeadroomStrategy{Percent: 65}).ScaleDown(snapshot); decision.Scale {
+		t.Errorf("Expected no scale down: %v", decision.Reasons)
+	}
+}
+
+func TestScheduleStrategy(t *testing.T) {
+	windows, err := parseScheduleWindows("mon-fri 08:00-18:00; sat 22:00-02:00")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(windows) != 2 || len(windows[0].Days) != 5 || len(windows[1].Days) != 1 {
+		t.Fatalf("Unexpected windows: %+v", windows)
+	}
+	snapshot := getTestClusterSnapshot()
+	strategy := ScheduleStrategy{Base: LargestContainerStrategy{Up: false, Down: true}, Windows: windows, Location: time.UTC}
+	if decision := strategy.ScaleDown(snapshot); decision.Scale {
+		t.Errorf("Expected scale down to be blocked during the window: %v", decision.Reasons)
+	}
+	snapshot.Time = time.Date(2026, 10, 19, 19, 0, 0, 0, time.UTC)
+	if decision := strategy.ScaleDown(snapshot); !decision.Scale {
+		t.Errorf("Expected scale down outside the window: %v", decision.Reasons)
+	}
+	// sunday 01:00 is within the window that started on saturday
+	snapshot.Time = time.Date(2026, 10, 25, 1, 0, 0, 0, time.UTC)
+	if decision := strategy.ScaleDown(snapshot); decision.Scale {
+		t.Errorf("Expected scale down to be blocked after midnight: %v", decision.Reasons)
+	}
+	for _, spec := range []string{"mon 08:00", "xyz 08:00-18:00", "mon-fri 8h-18h"} {
+		if _, err := parseScheduleWindows(spec); err == nil {
+			t.Errorf("Expected error for schedule window %v", spec)
+		}
+	}
+}
+
+func TestGetScaleStrategy(t *testing.T) {
+	config := NewConfig()
+	config.Autoscaling.ClusterStrategies = "cluster1=binpacking, cluster2=headroom:30,cluster3=schedule:binpacking,cluster4=unknown"
+	asc := AutoscalingController{config: config}
+	expected := map[string]string{
+		"cluster1": "binpacking",
+		"cluster2": "headroom",
+		"cluster3": "schedule:binpacking",
+		"cluster5": "largestcontainer",
+	}
+	for clusterName, name := range expected {
+		strategy, err := asc.getScaleStrategy(clusterName)
+		if err != nil {
+			t.Errorf("Error: %v", err)
+			continue
+		}
+		if strategy.Name() != name {
+			t.Errorf("Expected strategy %v for %v, got %v", name, clusterName, strategy.Name())
+		}
+	}
+	if strategy, _ := asc.getScaleStrategy("cluster2"); strategy.(HeadroomStrategy).Percent != 30 {
+		t.Errorf("Expected headroom of 30%%")
+	}
+	if _, err := asc.getScaleStrategy("cluster4"); err == nil {
+		t.Errorf("Expected error for unknown strategy")
+	}
+	// simulate with a snapshot
+	snapshot := getTestClusterSnapshot()
+	simulation, err := asc.simulateScaleStrategy("cluster2", "", &snapshot)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if simulation.Strategy != "headroom" || simulation.ScaleUp.Scale || !simulation.ScaleDown.Scale {
+		t.Errorf("Unexpected simulation: %+v", simulation)
+	}
+}
+
+func TestScaleStrategyWithMixedInstances(t *testing.T) {
+	snapshot := getTestClusterSnapshot()
+	// i-2 is a larger instance type
+	snapshot.ContainerInstances[1].RegisteredCpu = 2048
+	snapshot.ContainerInstances[1].RegisteredMemory = 4096
+	snapshot.ContainerInstances[1].FreeCpu = 2048
+	snapshot.ContainerInstances[1].FreeMemory = 4096
+	if cpu, memory := snapshot.largestInstanceResources(); cpu != 2048 || memory != 4096 {
+		t.Errorf("Unexpected largest instance: %d cpu, %d memory", cpu, memory)
+	}
+	// eu-west-1b has 2560 cpu free, but removing the largest instance needs 2048 + 256 + 128
+	if decision := (LargestContainerStrategy{Up: true, Down: true}).ScaleDown(snapshot); decision.Scale {
+		t.Errorf("Expected no scale down: %v", decision.Reasons)
+	}
+	// 3328 of 4096 cpu free, after removing the largest instance 1280 of 2048 cpu (62%)
+	if decision := (HeadroomStrategy{Percent: 60}).ScaleDown(snapshot); !decision.Scale {
+		t.Errorf("Expected scale down: %v", decision.Reasons)
+	}
+	if decision := (HeadroomStrategy{Percent: 85}).ScaleUp(snapshot); !decision.Scale {
+		t.Errorf("Expected scale up: %v", decision.Reasons)
+	}
+}
+
+func TestNewScalingDecision(t *testing.T) {
+	snapshot := getTestClusterSnapshot()
+	snapshot.ContainerInstances = append(snapshot.ContainerInstances, ClusterSnapshotInstance{ContainerInstanceId: "i-4", AvailabilityZone: "eu-west-1a", Status: "DRAINING", FreeCpu: 1024, FreeMemory: 2048})
+	asc := AutoscalingController{}
+	decision := asc.newScalingDecision("test", "up", HeadroomStrategy{Percent: 80}, ScaleDecision{Scale: true, Reasons: []string{"reason"}}, snapshot)
+	if decision.ClusterName != "testCluster" || decision.Strategy != "headroom" || decision.CpuNeeded != 256 || decision.MemoryNeeded != 512 {
+		t.Errorf("Unexpected scaling decision: %+v", decision)
+	}
+	// draining instances are not counted
+	if len(decision.AvailabilityZones) != 2 {
+		t.Fatalf("Expected 2 availability zones, got: %+v", decision.AvailabilityZones)
+	}
+	if zone := decision.AvailabilityZones[1]; zone.AvailabilityZone != "eu-west-1b" || zone.Instances != 2 || zone.FreeCpu != 1536 || zone.FreeMemory != 3072 {
+		t.Errorf("Unexpected availability zone: %+v", zone)
+	}
+	if zone := decision.AvailabilityZones[0]; zone.Instances != 1 || zone.FreeCpu != 768 {
+		t.Errorf("Unexpected availability zone: %+v", zone)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"sort"
+	"time"
+
+	"github.com/in4it/ecs-deploy/service"
+)
+
+// newScalingDecision returns the decision of the strategy with the state of the cluster it was based on
+func (c *AutoscalingController) newScalingDecision(trigger, scalingOp string, strategy ScaleStrategy, decision ScaleDecision, snapshot ClusterSnapshot) service.ScalingDecision {
+	return service.ScalingDecision{
+		ClusterName:       snapshot.ClusterName,
+		Time:              time.Now(),
+		Trigger:           trigger,
+		ScalingOp:         scalingOp,
+		Strategy:          strategy.Name(),
+		Scale:             decision.Scale,
+		Reasons:           decision.Reasons,
+		CpuNeeded:         snapshot.CpuNeeded,
+		MemoryNeeded:      snapshot.MemoryNeeded,
+		AvailabilityZones: getScalingDecisionZones(snapshot),
+	}
+}
+
+// getScalingDecisionZones returns the free resources of the active instances per availability zone
+func getScalingDecisionZones(snapshot ClusterSnapshot) []service.ScalingDecisionZone {
+	zones := make(map[string]*service.ScalingDecisionZone)
+	for _, ci := range snapshot.ContainerInstances {
+		if ci.Status != "ACTIVE" {
+			continue
+		}
+		if _, ok := zones[ci.AvailabilityZone]; !ok {
+			zones[ci.AvailabilityZone] = &service.ScalingDecisionZone{AvailabilityZone: ci.AvailabilityZone}
+		}
+		zones[ci.AvailabilityZone].Instances++
+		zones[ci.AvailabilityZone].FreeCpu += ci.FreeCpu
+		zones[ci.AvailabilityZone].FreeMemory += ci.FreeMemory
+	}
+	result := []service.ScalingDecisionZone{}
+	for _, zone := range zones {
+		result = append(result, *zone)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AvailabilityZone < result[j].AvailabilityZone
+	})
+	return result
+}
+
+// recordScalingDecision saves the decision, errors are only logged to not block scaling
+func (c *AutoscalingController) recordScalingDecision(s service.ServiceIf, decision service.ScalingDecision, outcome string) {
+	decision.Outcome = outcome
+	asAutoscalingControllerLogger.Infof("Scaling decision for %v: scale %v, strategy %v, outcome: %v", decision.ClusterName, decision.ScalingOp, decision.Strategy, outcome)
+	if err := s.PutScalingDecision(decision); err != nil {
+		asAutoscalingControllerLogger.Errorf("Could not record scaling decision: %v", err)
+	}
+}
+
+// getScalingDecisions returns the scaling decisions of a cluster, or of all clusters in the service registry, newest first
+func (c *Controller) getScalingDecisions(clusterName string, since time.Time) ([]service.ScalingDecision, error) {
+	s := service.NewService()
+	if clusterName != "" {
+		return s.GetScalingDecisions(clusterName, since)
+	}
+	dss, err := c.getServices()
+	if err != nil {
+		return nil, err
+	}
+	decisions := []service.ScalingDecision{}
+	clusters := make(map[string]bool)
+	for _, ds := range dss {
+		if clusters[ds.C] {
+			continue
+		}
+		clusters[ds.C] = true
+		clusterDecisions, err := s.GetScalingDecisions(ds.C, since)
+		if err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, clusterDecisions...)
+	}
+	sort.SliceStable(decisions, func(i, j int) bool {
+		return decisions[i].Time.After(decisions[j].Time)
+	})
+	return decisions, nil
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/in4it/ecs-deploy/util"
+	"github.com/juju/loggo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// logging
+var tracingLogger = loggo.GetLogger("tracing")
+
+// tracer used by the deploy pipeline. Without InitTracing this is a no-op tracer
+var tracer = otel.Tracer("github.com/in4it/ecs-deploy/api")
+
+// InitTracing sets up the OTLP trace exporter when OTEL_EXPORTER_OTLP_ENDPOINT is set.
+// The returned function flushes and stops the exporter
+func InitTracing() (func(), error) {
+	if serverConfig.Tracing.Endpoint == "" && !util.EnvExists("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") {
+		tracingLogger.Debugf("OTEL_EXPORTER_OTLP_ENDPOINT not set, tracing disabled")
+		return func() {}, nil
+	}
+	// endpoint, headers and protocol settings are read by the exporter from the OTEL_EXPORTER_OTLP_* variables
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		tracingLogger.Errorf("Could not create OTLP exporter: %v", err)
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serverConfig.Tracing.ServiceName),
+		attribute.String("service.version", apiVersion),
+		attribute.String("deployment.environment", serverConfig.AWS.AccountEnv),
+	))
+	if err != nil {
+		tracingLogger.Errorf("Could not create tracing resource: %v", err)
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	tracingLogger.Infof("Tracing enabled, exporting spans with OTLP")
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			tracingLogger.Errorf("Could not shutdown tracer provider: %v", err)
+		}
+	}, nil
+}
+
+// startSpan starts a child span of ctx with the service and cluster attributes set
+func startSpan(ctx context.Context, name string, serviceName string, clusterName string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("ecs.service", serviceName),
+		attribute.String("ecs.cluster", clusterName),
+	))
+}
+
+// endSpan records the error (if any) and ends the span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
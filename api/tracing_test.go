@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInitTracingDisabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	shutdown, err := InitTracing()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	// the no-op shutdown can always be called
+	shutdown()
+}
+
+func TestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := tracer
+	tracer = tp.Tracer("test")
+	defer func() { tracer = previous }()
+
+	ctx, parent := startSpan(context.Background(), "Deploy", "myservice", "mycluster")
+	_, child := startSpan(ctx, "CreateTaskDefinition", "myservice", "mycluster")
+	endSpan(child, errors.New("invalid task definition"))
+	endSpan(parent, nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name != "CreateTaskDefinition" || spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Errorf("Expected CreateTaskDefinition to be a child of Deploy, got: %+v", spans[0])
+	}
+	if spans[0].Status.Code != codes.Error || spans[0].Status.Description != "invalid task definition" || len(spans[0].Events) != 1 {
+		t.Errorf("Error not recorded: %+v", spans[0].Status)
+	}
+	if spans[1].Status.Code != codes.Unset {
+		t.Errorf("Unexpected status: %+v", spans[1].Status)
+	}
+	attributes := make(map[attribute.Key]string)
+	for _, kv := range spans[1].Attributes {
+		attributes[kv.Key] = kv.Value.AsString()
+	}
+	if attributes["ecs.service"] != "myservice" || attributes["ecs.cluster"] != "mycluster" {
+		t.Errorf("Unexpected attributes: %v", attributes)
+	}
+}
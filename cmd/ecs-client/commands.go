@@ -0,0 +1,882 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
+	"github.com/in4it/ecs-deploy/service"
+	"github.com/spf13/cobra"
+)
+
+type ServiceParameter struct {
+	Arn     string `json:"arn"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Version int64  `json:"version"`
+}
+
+type AutoscalingFlags struct {
+	Filename     string
+	MinimumCount int64
+	DesiredCount int64
+	MaximumCount int64
+}
+
+type RepositoryFlags struct {
+	Filename           string
+	TagImmutable       bool
+	ScanOnPush         bool
+	KmsKey             string
+	KeepImages         int64
+	ExpireUntaggedDays int64
+	PullAccounts       []string
+	Replicate          []string
+}
+
+type ImportFlags struct {
+	DryRun   bool
+	Filename string
+}
+
+type ParameterFlags struct {
+	Name      string
+	Value     string
+	Encrypted bool
+}
+
+// cli holds the state shared between the commands
+type cli struct {
+	session Session
+	output  string
+}
+
+func newRootCommand() *cobra.Command {
+	c := &cli{}
+	root := &cobra.Command{
+		Use:           filepath.Base(os.Args[0]),
+		Short:         "ecs-deploy client",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if c.output != "table" && c.output != "json" {
+				return fmt.Errorf("invalid output format %v (table or json)", c.output)
+			}
+			var err error
+			c.session, err = readSession()
+			return err
+		},
+	}
+	root.PersistentFlags().StringVarP(&c.output, "output", "o", "table", "output format (table or json)")
+
+	root.AddCommand(
+		c.loginCommand(),
+		c.createRepoCommand(),
+		c.updateRepoCommand(),
+		c.deployCommand(),
+		c.renderCommand(),
+		c.validateCommand(),
+		c.runTaskCommand(),
+		c.statusCommand(),
+		c.historyCommand(),
+		c.versionsCommand(),
+		c.rollbackCommand(),
+		c.scaleCommand(),
+		c.paramsCommand(),
+		c.autoscalingCommand(),
+		c.clusterCommand(),
+		c.logsCommand(),
+		c.importCommand(),
+	)
+
+	// usage is only printed on flag errors
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		cmd.Usage()
+		return err
+	})
+	return root
+}
+
+func (c *cli) loginCommand() *cobra.Command {
+	loginFlags := &LoginFlags{}
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "login",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if loginFlags.Url == "" {
+				cmd.Usage()
+				return errors.New("--url is required")
+			}
+			return login(loginFlags)
+		},
+	}
+	cmd.Flags().StringVar(&loginFlags.Url, "url", loginFlags.Url, "ecs-deploy url, e.g. https://127.0.0.1:8080/ecs-deploy")
+	return cmd
+}
+
+func (c *cli) createRepoCommand() *cobra.Command {
+	repositoryFlags := &RepositoryFlags{}
+	cmd := &cobra.Command{
+		Use:   "createrepo <repository>",
+		Short: "create repository",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := getRepositorySettings(cmd, repositoryFlags)
+			if err != nil {
+				return err
+			}
+			result, err := createRepository(c.session, args[0], settings)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%v\n", result)
+			return nil
+		},
+	}
+	addRepositoryFlags(cmd, repositoryFlags)
+	cmd.Flags().StringVar(&repositoryFlags.KmsKey, "kms-key", repositoryFlags.KmsKey, "KMS key to encrypt the repository with")
+	return cmd
+}
+
+func (c *cli) updateRepoCommand() *cobra.Command {
+	repositoryFlags := &RepositoryFlags{}
+	cmd := &cobra.Command{
+		Use:   "updaterepo <repository>",
+		Short: "update the settings of a repository (settings that are not passed are left unchanged)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Message string `json:"message"`
+			}
+			settings, err := getRepositorySettings(cmd, repositoryFlags)
+			if err != nil {
+				return err
+			}
+			b, err := json.Marshal(settings)
+			if err != nil {
+				return err
+			}
+			body, err := doAPICall(c.session, "ecr/update/"+args[0], string(b))
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			return printOutput(c.output, res, func(w *tabwriter.Writer) {
+				fmt.Fprintln(w, res.Message)
+			})
+		},
+	}
+	addRepositoryFlags(cmd, repositoryFlags)
+	return cmd
+}
+
+func addRepositoryFlags(cmd *cobra.Command, f *RepositoryFlags) {
+	cmd.Flags().StringVarP(&f.Filename, "filename", "f", f.Filename, "json/yaml file with the repository settings")
+	cmd.Flags().BoolVar(&f.TagImmutable, "tag-immutable", f.TagImmutable, "make image tags immutable")
+	cmd.Flags().BoolVar(&f.ScanOnPush, "scan-on-push", f.ScanOnPush, "scan images on push")
+	cmd.Flags().Int64Var(&f.KeepImages, "keep-images", f.KeepImages, "lifecycle rule: keep this number of images")
+	cmd.Flags().Int64Var(&f.ExpireUntaggedDays, "expire-untagged-days", f.ExpireUntaggedDays, "lifecycle rule: expire untagged images after this number of days")
+	cmd.Flags().StringArrayVar(&f.PullAccounts, "pull-account", f.PullAccounts, "AWS account that is allowed to pull images (can be repeated)")
+	cmd.Flags().StringArrayVar(&f.Replicate, "replicate", f.Replicate, "replicate images to region[:registryId] (can be repeated)")
+}
+
+// getRepositorySettings reads the repository settings from the file and applies the flags that were passed on top
+func getRepositorySettings(cmd *cobra.Command, f *RepositoryFlags) (service.RepositorySettings, error) {
+	var settings service.RepositorySettings
+	if f.Filename != "" {
+		content, err := os.ReadFile(f.Filename)
+		if err != nil {
+			return settings, err
+		}
+		if err = yaml.Unmarshal(content, &settings); err != nil {
+			return settings, fmt.Errorf("file %v in wrong format: %v", f.Filename, err)
+		}
+	}
+	if cmd.Flags().Changed("tag-immutable") {
+		settings.TagImmutability = &f.TagImmutable
+	}
+	if cmd.Flags().Changed("scan-on-push") {
+		settings.ScanOnPush = &f.ScanOnPush
+	}
+	if cmd.Flags().Changed("kms-key") {
+		settings.KmsKey = f.KmsKey
+	}
+	if cmd.Flags().Changed("expire-untagged-days") || cmd.Flags().Changed("keep-images") {
+		settings.LifecycleRules = []service.RepositoryLifecycleRule{}
+		if f.ExpireUntaggedDays > 0 {
+			settings.LifecycleRules = append(settings.LifecycleRules, service.RepositoryLifecycleRule{Description: "expire untagged images", TagStatus: "untagged", CountType: "sinceImagePushed", CountNumber: f.ExpireUntaggedDays})
+		}
+		if f.KeepImages > 0 {
+			settings.LifecycleRules = append(settings.LifecycleRules, service.RepositoryLifecycleRule{Description: "cleanup", TagStatus: "any", CountType: "imageCountMoreThan", CountNumber: f.KeepImages})
+		}
+	}
+	if cmd.Flags().Changed("pull-account") {
+		settings.PullAccounts = f.PullAccounts
+	}
+	if cmd.Flags().Changed("replicate") {
+		settings.Replication = []service.RepositoryReplicationDestination{}
+		for _, r := range f.Replicate {
+			destination := service.RepositoryReplicationDestination{Region: r}
+			if i := strings.Index(r, ":"); i != -1 {
+				destination = service.RepositoryReplicationDestination{Region: r[:i], RegistryId: r[i+1:]}
+			}
+			settings.Replication = append(settings.Replication, destination)
+		}
+	}
+	return settings, nil
+}
+
+func addDeployFlags(cmd *cobra.Command, f *DeployFlags) {
+	cmd.Flags().StringVar(&f.ServiceName, "service-name", f.ServiceName, "Service name to deploy")
+	cmd.Flags().StringVarP(&f.Filename, "filename", "f", f.Filename, "filename to deploy")
+}
+
+func addTemplateFlags(cmd *cobra.Command, f *DeployFlags) {
+	cmd.Flags().StringVarP(&f.Environment, "env", "e", f.Environment, "environment, merges the overlay <dir>/<env>/<file> when present and sets ${ENVIRONMENT}")
+	cmd.Flags().StringArrayVar(&f.Overlays, "overlay", f.Overlays, "overlay file to merge on top of the deploy file (can be repeated)")
+	cmd.Flags().BoolVar(&f.Interpolate, "interpolate", f.Interpolate, "replace ${VAR} in the string values of the deploy files with environment variables and CI metadata")
+}
+
+func (c *cli) deployCommand() *cobra.Command {
+	deployFlags := &DeployFlags{}
+	cmd := &cobra.Command{
+		Use:   "deploy",
+		Short: "deploy services",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			failure, err := deploy(c.session, deployFlags)
+			if err != nil {
+				return err
+			}
+			if failure {
+				return errors.New("deployment failed")
+			}
+			return nil
+		},
+	}
+	addDeployFlags(cmd, deployFlags)
+	addTemplateFlags(cmd, deployFlags)
+	cmd.Flags().StringVar(&deployFlags.ImageScanOverride, "image-scan-override", deployFlags.ImageScanOverride, "reason to deploy images that are blocked by the image scan policy (recorded with the deployment)")
+	return cmd
+}
+
+func (c *cli) renderCommand() *cobra.Command {
+	deployFlags := &DeployFlags{}
+	var format string
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "print the deploy payload after merging overlays and interpolating variables",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "json" && format != "yaml" {
+				return fmt.Errorf("invalid format %v (json or yaml)", format)
+			}
+			deployServices, err := getDeployServices(deployFlags)
+			if err != nil {
+				return err
+			}
+			var out []byte
+			if format == "yaml" {
+				out, err = yaml.Marshal(deployServices)
+			} else {
+				out, err = json.MarshalIndent(deployServices, "", "  ")
+				out = append(out, '\n')
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(out))
+			return nil
+		},
+	}
+	addDeployFlags(cmd, deployFlags)
+	addTemplateFlags(cmd, deployFlags)
+	cmd.Flags().StringVar(&format, "format", "json", "output format (json or yaml)")
+	return cmd
+}
+
+func (c *cli) validateCommand() *cobra.Command {
+	deployFlags := &DeployFlags{}
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "validate deploy files against the deploy schema",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			deployServices, err := getDeployServices(deployFlags)
+			if err != nil {
+				return err
+			}
+			failure := false
+			for _, s := range deployServices.Services {
+				errs := service.ValidateDeployService(s.ServiceName, s)
+				if len(s.ServiceName) < 3 {
+					errs = append(service.ValidationErrors{{Field: "serviceName", Message: "service name needs to be at least 3 characters"}}, errs...)
+				}
+				if len(errs) > 0 {
+					failure = true
+					fmt.Printf("%v: invalid\n%v\n", s.ServiceName, formatValidationErrors(errs))
+				} else {
+					fmt.Printf("%v: valid\n", s.ServiceName)
+				}
+			}
+			if failure {
+				return errors.New("validation failed")
+			}
+			return nil
+		},
+	}
+	addDeployFlags(cmd, deployFlags)
+	addTemplateFlags(cmd, deployFlags)
+	return cmd
+}
+
+func (c *cli) runTaskCommand() *cobra.Command {
+	deployFlags := &DeployFlags{}
+	cmd := &cobra.Command{
+		Use:   "runtask",
+		Short: "run task on service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := runtask(c.session, deployFlags)
+			return err
+		},
+	}
+	addDeployFlags(cmd, deployFlags)
+	return cmd
+}
+
+func (c *cli) statusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status <service>",
+		Short: "show the status, deployments, tasks and events of a service",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Service service.RunningService `json:"service"`
+			}
+			body, err := doAPIGetCall(c.session, "service/describe/"+args[0])
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			rs := res.Service
+			return printOutput(c.output, rs, func(w *tabwriter.Writer) {
+				printRow(w, "SERVICE", "CLUSTER", "STATUS", "RUNNING", "PENDING", "DESIRED")
+				printRow(w, rs.ServiceName, rs.ClusterName, rs.Status, rs.RunningCount, rs.PendingCount, rs.DesiredCount)
+				printRow(w)
+				printRow(w, "DEPLOYMENT", "TASK DEFINITION", "RUNNING", "PENDING", "DESIRED", "CREATED")
+				for _, d := range rs.Deployments {
+					printRow(w, d.Status, shortArn(d.TaskDefinition), d.RunningCount, d.PendingCount, d.DesiredCount, formatTime(d.CreatedAt))
+				}
+				printRow(w)
+				printRow(w, "TASK", "STATUS", "TASK DEFINITION", "STARTED", "STOPPED REASON")
+				for _, t := range rs.Tasks {
+					printRow(w, shortArn(t.TaskArn), t.LastStatus, shortArn(t.TaskDefinitionArn), formatTime(t.StartedAt), t.StoppedReason)
+				}
+				printRow(w)
+				printRow(w, "EVENT TIME", "MESSAGE")
+				for k, e := range rs.Events {
+					if k == 5 {
+						break
+					}
+					printRow(w, formatTime(e.CreatedAt), e.Message)
+				}
+			})
+		},
+	}
+}
+
+func (c *cli) historyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "history <service>",
+		Short: "show the last deployments of a service",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Deployments []service.DynamoDeployment `json:"deployments"`
+			}
+			body, err := doAPIGetCall(c.session, "deploy/list/"+args[0])
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			return printOutput(c.output, res.Deployments, func(w *tabwriter.Writer) {
+				printRow(w, "TIME", "STATUS", "TASK DEFINITION", "ERROR")
+				for _, dd := range res.Deployments {
+					var taskDefinition string
+					if dd.TaskDefinitionArn != nil {
+						taskDefinition = shortArn(*dd.TaskDefinitionArn)
+					}
+					printRow(w, formatTime(dd.Time), dd.Status, taskDefinition, dd.DeployError)
+				}
+			})
+		},
+	}
+}
+
+func (c *cli) versionsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "versions <service>",
+		Short: "show the image versions available for a service",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Versions []service.ServiceVersion `json:"versions"`
+			}
+			body, err := doAPIGetCall(c.session, "service/describe/"+args[0]+"/versions")
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			return printOutput(c.output, res.Versions, func(w *tabwriter.Writer) {
+				printRow(w, "IMAGE", "TAG", "IMAGE ID", "LAST DEPLOY")
+				for _, v := range res.Versions {
+					printRow(w, v.ImageName, v.Tag, v.ImageId, formatTime(v.LastDeploy))
+				}
+			})
+		},
+	}
+}
+
+func (c *cli) rollbackCommand() *cobra.Command {
+	var noWait bool
+	cmd := &cobra.Command{
+		Use:   "rollback <service> <time>",
+		Short: "redeploy a previous deployment (see history for the deployment times)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Message service.DeployResult `json:"message"`
+			}
+			body, err := doAPICall(c.session, "deploy/"+args[0]+"/"+args[1], "")
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			if noWait {
+				return printOutput(c.output, res.Message, func(w *tabwriter.Writer) {
+					printRow(w, "SERVICE", "CLUSTER", "TASK DEFINITION", "DEPLOYMENT TIME")
+					printRow(w, res.Message.ServiceName, res.Message.ClusterName, shortArn(res.Message.TaskDefinitionArn), formatTime(res.Message.DeploymentTime))
+				})
+			}
+			response, err := json.Marshal(DeployResponse{Messages: []service.DeployResult{res.Message}})
+			if err != nil {
+				return err
+			}
+			deployed, err := waitForDeploy(c.session, response)
+			if err != nil {
+				return err
+			}
+			if deployed[res.Message.ServiceName] != "success" {
+				return fmt.Errorf("rollback of %v: %v", res.Message.ServiceName, deployed[res.Message.ServiceName])
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "don't wait for the deployment to finish")
+	return cmd
+}
+
+func (c *cli) scaleCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "scale <service> <count>",
+		Short: "set the desired count of a service",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			count, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid count: %v", args[1])
+			}
+			var res map[string]string
+			body, err := doAPICall(c.session, "service/scale/"+args[0]+"/"+args[1], "")
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			scaled := map[string]interface{}{"service": args[0], "desiredCount": count}
+			return printOutput(c.output, scaled, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "Service %v scaled to %v\n", args[0], count)
+			})
+		},
+	}
+}
+
+func (c *cli) paramsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "params",
+		Short: "manage the parameters of a service",
+	}
+	list := &cobra.Command{
+		Use:   "list <service>",
+		Short: "list parameters",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Parameters map[string]ServiceParameter `json:"parameters"`
+			}
+			body, err := doAPIGetCall(c.session, "service/parameter/"+args[0]+"/list")
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			return printOutput(c.output, res.Parameters, func(w *tabwriter.Writer) {
+				var names []string
+				for name := range res.Parameters {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				printRow(w, "NAME", "TYPE", "VERSION", "VALUE")
+				for _, name := range names {
+					p := res.Parameters[name]
+					printRow(w, name, p.Type, p.Version, p.Value)
+				}
+			})
+		},
+	}
+	parameterFlags := &ParameterFlags{}
+	put := &cobra.Command{
+		Use:   "put <service>",
+		Short: "create or update a parameter",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if parameterFlags.Name == "" {
+				return errors.New("--name is required")
+			}
+			data, err := json.Marshal(service.DeployServiceParameter{Name: parameterFlags.Name, Value: parameterFlags.Value, Encrypted: parameterFlags.Encrypted})
+			if err != nil {
+				return err
+			}
+			var res struct {
+				Parameters map[string]int64 `json:"parameters"`
+			}
+			body, err := doAPICall(c.session, "service/parameter/"+args[0]+"/put", string(data))
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			return printOutput(c.output, res.Parameters, func(w *tabwriter.Writer) {
+				printRow(w, "NAME", "VERSION")
+				for name, version := range res.Parameters {
+					printRow(w, name, version)
+				}
+			})
+		},
+	}
+	put.Flags().StringVar(&parameterFlags.Name, "name", "", "parameter name")
+	put.Flags().StringVar(&parameterFlags.Value, "value", "", "parameter value")
+	put.Flags().BoolVar(&parameterFlags.Encrypted, "encrypted", false, "store the parameter encrypted")
+	del := &cobra.Command{
+		Use:   "delete <service> <name>",
+		Short: "delete a parameter",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res map[string]string
+			body, err := doAPICall(c.session, "service/parameter/"+args[0]+"/delete/"+args[1], "")
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			deleted := map[string]string{"service": args[0], "parameter": args[1]}
+			return printOutput(c.output, deleted, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "Parameter %v deleted\n", args[1])
+			})
+		},
+	}
+	cmd.AddCommand(list, put, del)
+	return cmd
+}
+
+func (c *cli) autoscalingCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "autoscaling",
+		Short: "manage the autoscaling of a service",
+	}
+	get := &cobra.Command{
+		Use:   "get <service>",
+		Short: "show the autoscaling settings and policies",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Autoscaling service.Autoscaling `json:"autoscaling"`
+			}
+			body, err := doAPIGetCall(c.session, "service/autoscaling/"+args[0]+"/get")
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			a := res.Autoscaling
+			return printOutput(c.output, a, func(w *tabwriter.Writer) {
+				printRow(w, "MINIMUM", "DESIRED", "MAXIMUM")
+				printRow(w, a.MinimumCount, a.DesiredCount, a.MaximumCount)
+				printRow(w)
+				printRow(w, "POLICY", "METRIC", "OPERATOR", "THRESHOLD", "STATISTIC", "ADJUSTMENT", "PERIOD", "EVALUATION PERIODS")
+				for _, p := range a.Policies {
+					printRow(w, p.PolicyName, p.Metric, p.ComparisonOperator, p.Threshold, p.ThresholdStatistic, p.ScalingAdjustment, p.Period, p.EvaluationPeriods)
+				}
+				if len(a.TargetTrackingPolicies) > 0 {
+					printRow(w)
+					printRow(w, "TARGET TRACKING POLICY", "METRIC", "TARGET", "SCALE IN COOLDOWN", "SCALE OUT COOLDOWN", "SCALE IN DISABLED")
+					for _, p := range a.TargetTrackingPolicies {
+						metric := p.Metric
+						if p.CustomMetric != nil {
+							metric = p.CustomMetric.Namespace + "/" + p.CustomMetric.MetricName
+						}
+						printRow(w, p.PolicyName, metric, p.TargetValue, p.ScaleInCooldown, p.ScaleOutCooldown, p.DisableScaleIn)
+					}
+				}
+				if len(a.ScheduledActions) > 0 {
+					printRow(w)
+					printRow(w, "SCHEDULED ACTION", "SCHEDULE", "TIMEZONE", "MINIMUM", "MAXIMUM")
+					for _, action := range a.ScheduledActions {
+						printRow(w, action.ActionName, action.Schedule, action.Timezone, formatOptionalCount(action.MinimumCount), formatOptionalCount(action.MaximumCount))
+					}
+				}
+			})
+		},
+	}
+	autoscalingFlags := &AutoscalingFlags{}
+	put := &cobra.Command{
+		Use:   "put <service>",
+		Short: "set the autoscaling settings from a file (json or yaml) or flags",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var autoscaling service.Autoscaling
+			if autoscalingFlags.Filename != "" {
+				content, err := os.ReadFile(autoscalingFlags.Filename)
+				if err != nil {
+					return err
+				}
+				if err = yaml.Unmarshal(content, &autoscaling); err != nil {
+					return err
+				}
+			}
+			if cmd.Flags().Changed("minimum") {
+				autoscaling.MinimumCount = autoscalingFlags.MinimumCount
+			}
+			if cmd.Flags().Changed("desired") {
+				autoscaling.DesiredCount = autoscalingFlags.DesiredCount
+			}
+			if cmd.Flags().Changed("maximum") {
+				autoscaling.MaximumCount = autoscalingFlags.MaximumCount
+			}
+			data, err := json.Marshal(autoscaling)
+			if err != nil {
+				return err
+			}
+			var res map[string]string
+			body, err := doAPICall(c.session, "service/autoscaling/"+args[0]+"/put", string(data))
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			return printOutput(c.output, res, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "Autoscaling of %v: %v\n", args[0], res["autoscaling"])
+			})
+		},
+	}
+	put.Flags().StringVarP(&autoscalingFlags.Filename, "filename", "f", "", "file with the autoscaling settings (json or yaml)")
+	put.Flags().Int64Var(&autoscalingFlags.MinimumCount, "minimum", 0, "minimum count")
+	put.Flags().Int64Var(&autoscalingFlags.DesiredCount, "desired", 0, "desired count")
+	put.Flags().Int64Var(&autoscalingFlags.MaximumCount, "maximum", 0, "maximum count")
+	cmd.AddCommand(get, put)
+	return cmd
+}
+
+func (c *cli) clusterCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "manage the container instances of a cluster",
+	}
+	upgrade := &cobra.Command{
+		Use:   "upgrade",
+		Short: "rolling upgrade of the container instances to the latest ECS AMI",
+	}
+	printUpgrade := func(body []byte) error {
+		var res struct {
+			Upgrade service.ClusterUpgrade `json:"upgrade"`
+		}
+		if err := decodeAPIResponse(body, &res); err != nil {
+			return err
+		}
+		u := res.Upgrade
+		return printOutput(c.output, u, func(w *tabwriter.Writer) {
+			printRow(w, "CLUSTER", "AMI", "STATUS", "STARTED BY", "STARTED AT", "UPDATED AT")
+			printRow(w, u.ClusterName, u.AmiId, u.Status, u.StartedBy, formatTime(u.StartedAt), formatTime(u.UpdatedAt))
+			if u.Error != "" {
+				printRow(w)
+				printRow(w, "ERROR")
+				printRow(w, u.Error)
+			}
+			printRow(w)
+			printRow(w, "INSTANCE", "AMI", "STATUS", "SCALED UP")
+			for _, instance := range u.Instances {
+				printRow(w, instance.InstanceId, instance.AmiId, instance.Status, instance.ScaledUp)
+			}
+		})
+	}
+	start := &cobra.Command{
+		Use:   "start <cluster>",
+		Short: "start replacing the container instances",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			body, err := doAPICall(c.session, "cluster/upgrade/"+args[0]+"/start", "")
+			if err != nil {
+				return err
+			}
+			return printUpgrade(body)
+		},
+	}
+	status := &cobra.Command{
+		Use:   "status <cluster>",
+		Short: "show the progress of the upgrade",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			body, err := doAPIGetCall(c.session, "cluster/upgrade/"+args[0]+"/get")
+			if err != nil {
+				return err
+			}
+			return printUpgrade(body)
+		},
+	}
+	cancel := &cobra.Command{
+		Use:   "cancel <cluster>",
+		Short: "stop the upgrade after the current step",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			body, err := doAPICall(c.session, "cluster/upgrade/"+args[0]+"/cancel", "")
+			if err != nil {
+				return err
+			}
+			return printUpgrade(body)
+		},
+	}
+	upgrade.AddCommand(start, status, cancel)
+	capacity := &cobra.Command{
+		Use:   "capacity <cluster>",
+		Short: "show the capacity and utilisation of a cluster",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Capacity service.ClusterCapacityReport `json:"capacity"`
+			}
+			body, err := doAPIGetCall(c.session, "cluster/capacity/"+args[0])
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			r := res.Capacity
+			return printOutput(c.output, r, func(w *tabwriter.Writer) {
+				printRow(w, "INSTANCES", "DRAINING", "CPU", "FREE CPU", "CPU USED %", "MEMORY", "FREE MEMORY", "MEMORY USED %")
+				printRow(w, r.Instances, r.DrainingInstances, r.RegisteredCpu, r.FreeCpu, r.CpuUtilisation, r.RegisteredMemory, r.FreeMemory, r.MemoryUtilisation)
+				printRow(w)
+				printRow(w, "AVAILABILITY ZONE", "INSTANCES", "CPU", "FREE CPU", "MEMORY", "FREE MEMORY")
+				for _, z := range r.AvailabilityZones {
+					printRow(w, z.AvailabilityZone, z.Instances, z.RegisteredCpu, z.FreeCpu, z.RegisteredMemory, z.FreeMemory)
+				}
+				printRow(w)
+				printRow(w, "SERVICE", "RUNNING", "CPU", "MEMORY", "CPU SHARE %", "MEMORY SHARE %")
+				for _, s := range r.Services {
+					printRow(w, s.ServiceName, s.RunningCount, s.ReservedCpu, s.ReservedMemory, s.CpuShare, s.MemoryShare)
+				}
+				printRow(w)
+				printRow(w, "FRAGMENTATION CPU %", "FRAGMENTATION MEMORY %", "LARGEST TASK CPU", "LARGEST TASK MEMORY", "ADDITIONAL COPIES")
+				printRow(w, r.Fragmentation.Cpu, r.Fragmentation.Memory, r.LargestTask.Cpu, r.LargestTask.Memory, r.LargestTask.AdditionalCopies)
+			})
+		},
+	}
+	cmd.AddCommand(upgrade, capacity)
+	return cmd
+}
+
+func (c *cli) logsCommand() *cobra.Command {
+	logsFlags := &LogsFlags{Since: "5m"}
+	cmd := &cobra.Command{
+		Use:   "logs <service>",
+		Short: "show (or follow with -f) the logs of all running tasks of a service",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return logs(c.session, args[0], logsFlags, c.output)
+		},
+	}
+	cmd.Flags().BoolVarP(&logsFlags.Follow, "follow", "f", logsFlags.Follow, "follow the logs")
+	cmd.Flags().StringVar(&logsFlags.Filter, "filter", logsFlags.Filter, "CloudWatch logs filter pattern, e.g. ERROR")
+	cmd.Flags().StringVar(&logsFlags.Since, "since", logsFlags.Since, "show logs since duration, e.g. 10m or 1h")
+	return cmd
+}
+
+func (c *cli) importCommand() *cobra.Command {
+	importFlags := &ImportFlags{}
+	cmd := &cobra.Command{
+		Use:   "import <cluster> <service>",
+		Short: "adopt an existing service and write its deploy file (yaml)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Import service.ImportResult `json:"import"`
+			}
+			url := "import/" + args[0] + "/" + args[1]
+			if importFlags.DryRun {
+				url += "?dryRun=true"
+			}
+			body, err := doAPICall(c.session, url, "")
+			if err != nil {
+				return err
+			}
+			if err = decodeAPIResponse(body, &res); err != nil {
+				return err
+			}
+			for _, warning := range res.Import.Warnings {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", warning)
+			}
+			deployFile, err := yaml.Marshal(res.Import.DeployData)
+			if err != nil {
+				return err
+			}
+			if importFlags.Filename == "" {
+				fmt.Print(string(deployFile))
+			} else if err = os.WriteFile(importFlags.Filename, deployFile, 0644); err != nil {
+				return err
+			}
+			if res.Import.Imported {
+				fmt.Fprintf(os.Stderr, "Service %v imported (deployment time %v)\n", res.Import.ServiceName, formatTime(res.Import.DeploymentTime))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&importFlags.DryRun, "dry-run", false, "only show the deploy file, don't register the service")
+	cmd.Flags().StringVarP(&importFlags.Filename, "filename", "f", "", "write the deploy file to this file instead of stdout")
+	return cmd
+}
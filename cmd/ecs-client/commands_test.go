@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCommand runs the ecs-client with the arguments against a test api, and returns what was printed on stdout
+func runCommand(t *testing.T, handler http.HandlerFunc, args ...string) (string, error) {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	session, err := json.Marshal(Session{Token: "token", Url: server.URL})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if err = os.MkdirAll(filepath.Join(home, ".ecsdeploy"), 0700); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if err = os.WriteFile(filepath.Join(home, ".ecsdeploy", "session.json"), session, 0600); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	root := newRootCommand()
+	root.SetArgs(args)
+	err = root.Execute()
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out), err
+}
+
+// apiResponse returns a handler that checks the url of the api call and responds with the body
+func apiResponse(t *testing.T, url, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/"+url {
+			t.Errorf("unexpected api call: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected authorization header: %s", r.Header.Get("Authorization"))
+		}
+		w.Write([]byte(body))
+	}
+}
+
+func TestCommandOutput(t *testing.T) {
+	tests := []struct {
+		args     []string
+		url      string
+		response string
+		table    string
+		json     map[string]interface{}
+	}{
+		{
+			args:     []string{"scale", "myservice", "3"},
+			url:      "service/scale/myservice/3",
+			response: `{"message": "OK"}`,
+			table:    "Service myservice scaled to 3\n",
+			json:     map[string]interface{}{"service": "myservice", "desiredCount": float64(3)},
+		},
+		{
+			args:     []string{"params", "delete", "myservice", "MYPARAM"},
+			url:      "service/parameter/myservice/delete/MYPARAM",
+			response: `{"message": "OK"}`,
+			table:    "Parameter MYPARAM deleted\n",
+			json:     map[string]interface{}{"service": "myservice", "parameter": "MYPARAM"},
+		},
+		{
+			args:     []string{"autoscaling", "put", "myservice", "--minimum", "1", "--maximum", "4"},
+			url:      "service/autoscaling/myservice/put",
+			response: `{"autoscaling": "OK"}`,
+			table:    "Autoscaling of myservice: OK\n",
+			json:     map[string]interface{}{"autoscaling": "OK"},
+		},
+	}
+	for _, test := range tests {
+		out, err := runCommand(t, apiResponse(t, test.url, test.response), test.args...)
+		if err != nil {
+			t.Errorf("%v: error: %s", test.args, err)
+		}
+		if out != test.table {
+			t.Errorf("%v: expected %q, got %q", test.args, test.table, out)
+		}
+		out, err = runCommand(t, apiResponse(t, test.url, test.response), append(test.args, "-o", "json")...)
+		if err != nil {
+			t.Errorf("%v: error: %s", test.args, err)
+		}
+		var v map[string]interface{}
+		if err = json.Unmarshal([]byte(out), &v); err != nil {
+			t.Errorf("%v: invalid json output %q: %s", test.args, out, err)
+		}
+		for k, value := range test.json {
+			if v[k] != value {
+				t.Errorf("%v: expected %v=%v, got %v", test.args, k, value, v[k])
+			}
+		}
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	noCall := func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected api call: %s", r.URL.Path)
+	}
+	tests := []struct {
+		args  []string
+		error string
+	}{
+		{[]string{"scale", "myservice", "three"}, "invalid count: three"},
+		{[]string{"scale", "myservice"}, "accepts 2 arg(s), received 1"},
+		{[]string{"status", "myservice", "-o", "xml"}, "invalid output format xml (table or json)"},
+		{[]string{"params", "put", "myservice"}, "--name is required"},
+	}
+	for _, test := range tests {
+		_, err := runCommand(t, noCall, test.args...)
+		if err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("%v: expected error %q, got %v", test.args, test.error, err)
+		}
+	}
+}
+
+func TestCommandAPIError(t *testing.T) {
+	_, err := runCommand(t, apiResponse(t, "service/scale/myservice/3", `{"error": "service not found"}`), "scale", "myservice", "3")
+	if err == nil || err.Error() != "service not found" {
+		t.Errorf("expected the api error, got %v", err)
+	}
+}
@@ -3,21 +3,26 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/in4it/ecs-deploy/service"
+	"github.com/in4it/ecs-deploy/util"
 	"github.com/juju/loggo"
-	"github.com/spf13/pflag"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -39,6 +44,17 @@ type LoginFlags struct {
 type DeployFlags struct {
 	ServiceName string
 	Filename    string
+	Environment string
+	Overlays    []string
+	Interpolate bool
+	// reason to deploy images that are blocked by the image scan policy
+	ImageScanOverride string
+}
+
+type LogsFlags struct {
+	Follow bool
+	Filter string
+	Since  string
 }
 
 type DeployResponse struct {
@@ -50,17 +66,7 @@ type DeployStatusResponse struct {
 	Service service.DeployResult `json:"service" binding:"required"`
 }
 
-func addLoginFlags(f *LoginFlags, fs *pflag.FlagSet) {
-	fs.StringVar(&f.Url, "url", f.Url, "ecs-deploy url, e.g. https://127.0.0.1:8080/ecs-deploy")
-}
-func addDeployFlags(f *DeployFlags, fs *pflag.FlagSet) {
-	fs.StringVar(&f.ServiceName, "service-name", f.ServiceName, "Service name to deploy")
-	fs.StringVarP(&f.Filename, "filename", "f", f.Filename, "filename to deploy")
-}
-
 func main() {
-	var err error
-
 	// set logging
 	if os.Getenv("DEBUG") == "true" {
 		loggo.ConfigureLoggers(`<root>=DEBUG`)
@@ -68,80 +74,51 @@ func main() {
 		loggo.ConfigureLoggers(`<root>=INFO`)
 	}
 
-	session, err := readSession()
-	if err != nil {
-		fmt.Printf("%v", err.Error())
+	if err := newRootCommand().Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err.Error())
 		os.Exit(1)
 	}
+}
 
-	if len(os.Args) > 1 && os.Args[1] == "login" {
-		// login
-		loginFlags := &LoginFlags{}
-		addLoginFlags(loginFlags, pflag.CommandLine)
-
-		if len(os.Args) > 2 && os.Args[2] != "" {
-			pflag.CommandLine.Parse(os.Args[2:])
-			if loginFlags.Url == "" {
-				fmt.Fprintf(os.Stderr, "Usage of %s login:\n", os.Args[0])
-				pflag.PrintDefaults()
-				os.Exit(1)
-			}
-			err = login(loginFlags)
-		} else {
-			fmt.Fprintf(os.Stderr, "Usage of %s login:\n", os.Args[0])
-			pflag.PrintDefaults()
-		}
-	} else if len(os.Args) > 2 && os.Args[1] == "createrepo" && os.Args[2] != "" {
-		// create repo
-		var result string
-		result, err = createRepository(session, os.Args[2])
-		fmt.Printf("%v\n", result)
-	} else if len(os.Args) > 1 && os.Args[1] == "deploy" {
-		// deploy
-		deployFlags := &DeployFlags{}
-		addDeployFlags(deployFlags, pflag.CommandLine)
-
-		if len(os.Args) > 2 && os.Args[2] != "" {
-			pflag.CommandLine.Parse(os.Args[2:])
-			failure, err := deploy(session, deployFlags)
-			if failure {
-				if err != nil {
-					fmt.Printf("%v", err.Error())
-				}
-				os.Exit(1)
-			}
-		} else {
-			fmt.Fprintf(os.Stderr, "Usage of %s deploy:\n", os.Args[0])
-			pflag.PrintDefaults()
-		}
-	} else if len(os.Args) > 1 && os.Args[1] == "runtask" {
-		deployFlags := &DeployFlags{}
-		addDeployFlags(deployFlags, pflag.CommandLine)
-
-		if len(os.Args) > 2 && os.Args[2] != "" {
-			pflag.CommandLine.Parse(os.Args[2:])
-			failure, err := runtask(session, deployFlags)
-			if failure {
-				if err != nil {
-					fmt.Printf("%v", err.Error())
-				}
-				os.Exit(1)
-			}
-		} else {
-			fmt.Fprintf(os.Stderr, "Usage of %s runtask:\n", os.Args[0])
-			pflag.PrintDefaults()
-		}
-	} else {
-		fmt.Println("Usage: ")
-		fmt.Printf("%v login        login\n", os.Args[0])
-		fmt.Printf("%v createrepo   create repository\n", os.Args[0])
-		fmt.Printf("%v deploy       deploy services\n", os.Args[0])
-		fmt.Printf("%v runtask      run task on service\n", os.Args[0])
+func logs(session Session, serviceName string, logsFlags *LogsFlags, output string) error {
+	if _, err := time.ParseDuration(logsFlags.Since); err != nil {
+		return fmt.Errorf("Invalid --since: %v\n", err)
+	}
+	params := url.Values{}
+	params.Set("since", logsFlags.Since)
+	params.Set("follow", strconv.FormatBool(logsFlags.Follow))
+	if logsFlags.Filter != "" {
+		params.Set("filter", logsFlags.Filter)
 	}
+	var timeout time.Duration
+	if !logsFlags.Follow {
+		timeout = 120 * time.Second
+	}
+	resp, err := openEventStream(context.Background(), session, "service/log/"+serviceName+"/follow?"+params.Encode(), timeout)
 	if err != nil {
-		fmt.Printf("%v", err.Error())
-		os.Exit(1)
+		return err
 	}
+	defer resp.Body.Close()
+	return readEventStream(resp.Body, func(eventType, data string) error {
+		switch eventType {
+		case "error":
+			var streamError map[string]string
+			json.Unmarshal([]byte(data), &streamError)
+			return fmt.Errorf("Error while retrieving logs of %v: %v\n", serviceName, streamError["error"])
+		case "log":
+			var event service.ServiceLogEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				clientLogger.Debugf("Couldn't parse event %v: %v", data, err)
+				return nil
+			}
+			if output == "json" {
+				fmt.Println(data)
+			} else {
+				fmt.Printf("%v [%v/%v] %v\n", event.Timestamp.Local().Format("2006-01-02 15:04:05"), util.TruncateString(event.TaskId, 8), event.ContainerName, event.Message)
+			}
+		}
+		return nil
+	})
 }
 
 func runtask(session Session, deployFlags *DeployFlags) (bool, error) {
@@ -174,7 +151,7 @@ func deploy(session Session, deployFlags *DeployFlags) (bool, error) {
 	if err != nil {
 		return true, err
 	}
-	response, err := doDeployAPICall(session, deployData)
+	response, err := doDeployAPICall(session, deployData, deployFlags.ImageScanOverride)
 	if err != nil {
 		return true, err
 	}
@@ -195,47 +172,198 @@ func deploy(session Session, deployFlags *DeployFlags) (bool, error) {
 }
 func waitForDeploy(session Session, response []byte) (map[string]string, error) {
 	// api call returned info to follow-up on deployment
-	var deploymentsFinished bool
 	var deployResponse DeployResponse
-	var finished int64
 	deployed := make(map[string]string)
-	maxWait := 1200
 	err := json.Unmarshal(response, &deployResponse)
 	if err != nil {
 		return deployed, err
 	}
-	for _, v := range deployResponse.Messages {
-		deployed[v.ServiceName] = "running"
-	}
 	for k, v := range deployResponse.Errors {
 		fmt.Printf("Service %v: %v\n", k, v)
 		deployed[k] = "error"
-		finished++
-	}
-	if int64(len(deployed)) == finished {
-		deploymentsFinished = true
-	}
-	for i := 0; i < (maxWait/15) && !deploymentsFinished; i++ {
-		time.Sleep(15 * time.Second)
-		for _, v := range deployResponse.Messages {
-			if deployed[v.ServiceName] == "running" {
-				status, err := checkDeployStatus(session, v.ServiceName, v.DeploymentTime.Format("2006-01-02T15:04:05.999999999Z"))
-				if err != nil {
-					return deployed, err
-				}
-				fmt.Printf(".")
-				if status != "running" {
-					deployed[v.ServiceName] = status
-					fmt.Printf("%v=%v", v.ServiceName, status)
-					finished++
+	}
+	type deployStatus struct {
+		serviceName string
+		status      string
+		err         error
+	}
+	// the deployments that are still followed are stopped after the first error
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	results := make(chan deployStatus, len(deployResponse.Messages))
+	for _, v := range deployResponse.Messages {
+		if v.DeployError != "" {
+			fmt.Printf("Service %v: %v\n", v.ServiceName, v.DeployError)
+		}
+		go func(v service.DeployResult) {
+			deploymentTime := v.DeploymentTime.Format("2006-01-02T15:04:05.999999999Z")
+			status, err := streamDeployStatus(ctx, session, v.ServiceName, deploymentTime, deployStatusTimeout, func(event service.DeployProgressEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				printDeployProgress(event)
+			})
+			if err == errStreamNotSupported {
+				status, err = pollDeployStatus(ctx, session, v.ServiceName, deploymentTime)
+			}
+			results <- deployStatus{serviceName: v.ServiceName, status: status, err: err}
+		}(v)
+	}
+	var firstErr error
+	for range deployResponse.Messages {
+		res := <-results
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = res.err
+				cancel()
+			}
+			continue
+		}
+		deployed[res.serviceName] = res.status
+	}
+	return deployed, firstErr
+}
+
+// time to follow a deployment, the deployment is reported as running when it takes longer
+var deployStatusTimeout = 1200 * time.Second
+
+var errStreamNotSupported = errors.New("deploy status stream not supported by server")
+
+// streamDeployStatus follows the deployment using the server-sent events endpoint and returns the final status,
+// or running when the deployment didn't finish within the timeout
+func streamDeployStatus(ctx context.Context, session Session, serviceName, deploymentTime string, timeout time.Duration, progress func(service.DeployProgressEvent)) (string, error) {
+	status := "running"
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	resp, err := openEventStream(timeoutCtx, session, "deploy/status/"+serviceName+"/"+deploymentTime+"/stream", 0)
+	if err != nil {
+		return status, deployTimeoutError(ctx, timeoutCtx, err)
+	}
+	defer resp.Body.Close()
+	err = readEventStream(resp.Body, func(eventType, data string) error {
+		switch eventType {
+		case "error":
+			var streamError map[string]string
+			json.Unmarshal([]byte(data), &streamError)
+			return fmt.Errorf("Error while following deployment of %v: %v", serviceName, streamError["error"])
+		case "phase", "event", "task", "status":
+			var event service.DeployProgressEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				clientLogger.Debugf("Couldn't parse event %v: %v", data, err)
+				return nil
+			}
+			progress(event)
+			if event.Type == "status" {
+				status = event.Phase
+			}
+		}
+		return nil
+	})
+	return status, deployTimeoutError(ctx, timeoutCtx, err)
+}
+
+// deployTimeoutError ignores the error when the deployment is followed longer than the timeout
+func deployTimeoutError(ctx, timeoutCtx context.Context, err error) error {
+	if err != nil && ctx.Err() == nil && timeoutCtx.Err() == context.DeadlineExceeded {
+		return nil
+	}
+	return err
+}
+
+// openEventStream does a GET request to a server-sent events endpoint.
+// A timeout of 0 means no timeout
+func openEventStream(ctx context.Context, session Session, url string, timeout time.Duration) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", session.Url+"/api/v1/"+url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+session.Token)
+	req.Header.Set("Accept", "text/event-stream")
+	var client = &http.Client{
+		Timeout: timeout,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode == 404 {
+			return nil, errStreamNotSupported
+		} else if resp.StatusCode == 401 {
+			return nil, fmt.Errorf("Invalid credentials: use %v login --url <url> to login again\n", os.Args[0])
+		} else {
+			return nil, fmt.Errorf("Error %d: %v", resp.StatusCode, string(body))
+		}
+	}
+	return resp, nil
+}
+
+// readEventStream calls handle for every server-sent event until the stream is closed or handle returns an error
+func readEventStream(body io.Reader, handle func(eventType, data string) error) error {
+	var eventType, data string
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		case line == "":
+			if eventType != "" || data != "" {
+				if err := handle(eventType, data); err != nil {
+					return err
 				}
 			}
+			eventType, data = "", ""
 		}
-		if int64(len(deployed)) == finished {
-			deploymentsFinished = true
+	}
+	return scanner.Err()
+}
+
+func printDeployProgress(event service.DeployProgressEvent) {
+	timestamp := event.Time.Local().Format("15:04:05")
+	switch event.Type {
+	case "phase":
+		if event.Message == "" {
+			fmt.Printf("%v [%v] %v\n", timestamp, event.ServiceName, event.Phase)
+		} else {
+			fmt.Printf("%v [%v] %v: %v\n", timestamp, event.ServiceName, event.Phase, event.Message)
+		}
+	case "status":
+		if event.Message == "" {
+			fmt.Printf("%v [%v] deployment finished: %v\n", timestamp, event.ServiceName, event.Phase)
+		} else {
+			fmt.Printf("%v [%v] deployment finished: %v (%v)\n", timestamp, event.ServiceName, event.Phase, event.Message)
+		}
+	default:
+		fmt.Printf("%v [%v] %v\n", timestamp, event.ServiceName, event.Message)
+	}
+}
+
+// pollDeployStatus checks the deployment status every 15 seconds (for servers without the stream endpoint)
+func pollDeployStatus(ctx context.Context, session Session, serviceName, deploymentTime string) (string, error) {
+	maxWait := 1200
+	for i := 0; i < (maxWait / 15); i++ {
+		select {
+		case <-ctx.Done():
+			return "running", ctx.Err()
+		case <-time.After(15 * time.Second):
+		}
+		status, err := checkDeployStatus(session, serviceName, deploymentTime)
+		if err != nil {
+			return status, err
+		}
+		fmt.Printf(".")
+		if status != "running" {
+			fmt.Printf("%v=%v", serviceName, status)
+			return status, nil
 		}
 	}
-	return deployed, nil
+	return "running", nil
 }
 func checkDeployStatus(session Session, serviceName, deploymentTime string) (string, error) {
 	var status string
@@ -275,14 +403,22 @@ func doRunTaskAPICall(session Session, service string, deployData string) ([]byt
 	url := fmt.Sprintf("service/runtask/%v", service)
 	return doAPICall(session, url, deployData)
 }
-func doDeployAPICall(session Session, deployData string) ([]byte, error) {
-	url := "deploy"
-	return doAPICall(session, url, deployData)
+func doDeployAPICall(session Session, deployData, imageScanOverride string) ([]byte, error) {
+	if imageScanOverride != "" {
+		return doAPICall(session, "deploy?imageScanOverride="+url.QueryEscape(imageScanOverride), deployData)
+	}
+	return doAPICall(session, "deploy", deployData)
 }
 func doAPICall(session Session, url string, deployData string) ([]byte, error) {
+	return doAPIRequest(session, "POST", url, deployData)
+}
+func doAPIGetCall(session Session, url string) ([]byte, error) {
+	return doAPIRequest(session, "GET", url, "")
+}
+func doAPIRequest(session Session, method, url string, deployData string) ([]byte, error) {
 	var body []byte
 	clientLogger.Debugf("API Call data: %v", deployData)
-	req, err := http.NewRequest("POST", session.Url+"/api/v1/"+url, bytes.NewBuffer([]byte(deployData)))
+	req, err := http.NewRequest(method, session.Url+"/api/v1/"+url, bytes.NewBuffer([]byte(deployData)))
 	if err != nil {
 		return body, err
 	}
@@ -311,24 +447,9 @@ func doAPICall(session Session, url string, deployData string) ([]byte, error) {
 }
 func getDeployData(session Session, deployFlags *DeployFlags) (string, error) {
 	var deployData string
-	var deployServices service.DeployServices
-	var err error
-	if deployFlags.ServiceName != "" {
-		// serviceName is set
-		deployServices, err = getDeployDataWithService(deployFlags.ServiceName, deployFlags.Filename)
-		if err != nil {
-			return deployData, err
-		}
-	} else if deployFlags.ServiceName == "" && deployFlags.Filename != "" {
-		// serviceName is not set
-		deployServices, err = getDeployDataWithoutService(deployFlags.ServiceName, deployFlags.Filename)
-		if err != nil {
-			return deployData, err
-		}
-	} else {
-		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
-		pflag.PrintDefaults()
-		return deployData, errors.New("InvalidFlags")
+	deployServices, err := getDeployServices(deployFlags)
+	if err != nil {
+		return deployData, err
 	}
 	// convert to JSON
 	deployData, err = convertDeployServiceToJson(deployServices)
@@ -337,6 +458,16 @@ func getDeployData(session Session, deployFlags *DeployFlags) (string, error) {
 	}
 	return deployData, nil
 }
+func getDeployServices(deployFlags *DeployFlags) (service.DeployServices, error) {
+	if deployFlags.ServiceName != "" {
+		// serviceName is set
+		return getDeployDataWithService(deployFlags)
+	} else if deployFlags.ServiceName == "" && deployFlags.Filename != "" {
+		// serviceName is not set
+		return getDeployDataWithoutService(deployFlags)
+	}
+	return service.DeployServices{}, errors.New("--service-name or --filename needs to be set")
+}
 func convertDeployServiceToJson(deployServices service.DeployServices) (string, error) {
 	var deployData string
 	b, err := json.Marshal(deployServices)
@@ -349,9 +480,10 @@ func convertDeployServiceToJson(deployServices service.DeployServices) (string,
 	}
 	return deployData, nil
 }
-func getDeployDataWithoutService(serviceName, filename string) (service.DeployServices, error) {
+func getDeployDataWithoutService(deployFlags *DeployFlags) (service.DeployServices, error) {
 	var deployServices service.DeployServices
 	var err error
+	filename := deployFlags.Filename
 	if ok, _ := isDir(filename); ok {
 		return deployServices, fmt.Errorf("%v is a directory. Specify a file or use the --service-name argument\n", filename)
 	}
@@ -361,17 +493,21 @@ func getDeployDataWithoutService(serviceName, filename string) (service.DeploySe
 	} else if filepath.Ext(filename) == ".yaml" || filepath.Ext(filename) == ".yml" {
 		fType = "yaml"
 	}
-	deployService, err := parseFile(filename, fType, "")
+	deployService, err := parseAndValidateFile(filename, fType, "", deployFlags)
 	if err != nil {
-		return deployServices, nil
+		return deployServices, err
 	}
 	deployServices.Services = append(deployServices.Services, deployService...)
 	return deployServices, nil
 }
-func getDeployDataWithService(serviceName, filename string) (service.DeployServices, error) {
+func getDeployDataWithService(deployFlags *DeployFlags) (service.DeployServices, error) {
 	var readDir string
 	var deployServices service.DeployServices
 	var err error
+	filename := deployFlags.Filename
+	if len(deployFlags.Overlays) > 0 {
+		return deployServices, errors.New("--overlay can only be used with a single file, use --env to apply overlays to a directory")
+	}
 	if filename != "" {
 		if ok, _ := isDir(filename); !ok {
 			return deployServices, fmt.Errorf("%v needs to be a directory if --service-name is specified\n", filename)
@@ -381,7 +517,7 @@ func getDeployDataWithService(serviceName, filename string) (service.DeployServi
 		readDir = "./"
 	}
 	// parse JSON/YAML files
-	deployServices, err = parseFiles(readDir, serviceName)
+	deployServices, err = parseFiles(readDir, deployFlags)
 	if err != nil {
 		return deployServices, err
 	}
@@ -390,7 +526,7 @@ func getDeployDataWithService(serviceName, filename string) (service.DeployServi
 	}
 	return deployServices, nil
 }
-func parseFiles(readDir, serviceName string) (service.DeployServices, error) {
+func parseFiles(readDir string, deployFlags *DeployFlags) (service.DeployServices, error) {
 	var deployServices service.DeployServices
 	fs := make(map[string]string)
 	files, err := ioutil.ReadDir(readDir)
@@ -409,9 +545,9 @@ func parseFiles(readDir, serviceName string) (service.DeployServices, error) {
 		}
 	}
 	for f, fType := range fs {
-		deploy, err := parseFile(filepath.Join(readDir, f), fType, serviceName)
+		deploy, err := parseAndValidateFile(filepath.Join(readDir, f), fType, deployFlags.ServiceName, deployFlags)
 		if err != nil {
-			return deployServices, nil
+			return deployServices, err
 		}
 		deployServices.Services = append(deployServices.Services, deploy...)
 	}
@@ -441,47 +577,52 @@ func unmarshalWithDefaults(fType string, content []byte, deploy *service.DeployS
 	}
 	return nil
 }
-func parseFile(filename, fType, serviceName string) ([]service.Deploy, error) {
+
+// renderFile merges the overlays and interpolates the variables of a deploy file, the result is json
+func renderFile(filename, fType string, deployFlags *DeployFlags) ([]byte, error) {
+	if fType != "json" && fType != "yaml" {
+		return nil, fmt.Errorf("Wrong file extension (needs to be json, yaml, or yml)\n")
+	}
+	overlays, err := getOverlays(filename, deployFlags)
+	if err != nil {
+		return nil, err
+	}
+	return renderTemplate(filename, overlays, getTemplateVariables(deployFlags))
+}
+
+// parseAndValidateFile validates a deploy file against the schema before parsing it,
+// unknown keys and wrong types are ignored by json.Unmarshal
+func parseAndValidateFile(filename, fType, serviceName string, deployFlags *DeployFlags) ([]service.Deploy, error) {
+	content, err := renderFile(filename, fType, deployFlags)
+	if err != nil {
+		return nil, err
+	}
+	if errs := service.ValidateDeployFile(content); len(errs) > 0 {
+		return nil, fmt.Errorf("%v file %v is invalid:\n%v", fType, filename, formatValidationErrors(errs))
+	}
+	return parseFile(filename, fType, serviceName, content)
+}
+
+// parseFile parses the rendered content of the deploy file
+func parseFile(filename, fType, serviceName string, content []byte) ([]service.Deploy, error) {
 	var deploy service.DeployServices
 	var singleDeploy service.Deploy
 	fileBase := filepath.Base(filename)
 	// set defaults for singledeploy
 	service.SetDeployDefaults(&singleDeploy)
 
-	content, err := os.ReadFile(filename)
+	err := json.Unmarshal(content, &deploy)
 	if err != nil {
-		return deploy.Services, fmt.Errorf("Could not read file: %v\n", filename)
+		return deploy.Services, fmt.Errorf("%v file %v in wrong format: %v", fType, filename, err.Error())
 	}
-	if fType == "json" {
-		err = json.Unmarshal(content, &deploy)
-		if err != nil {
-			return deploy.Services, fmt.Errorf("json file %v in wrong format: %v", filename, err.Error())
-		}
-		if len(deploy.Services) == 0 {
-			err = json.Unmarshal(content, &singleDeploy)
-			if err != nil {
-				return deploy.Services, fmt.Errorf("json file %v in wrong format: %v", filename, err.Error())
-			}
-			deploy.Services = append(deploy.Services, singleDeploy)
-		} else {
-			unmarshalWithDefaults("json", content, &deploy)
-		}
-	} else if fType == "yaml" {
-		err = yaml.Unmarshal(content, &deploy)
+	if len(deploy.Services) == 0 {
+		err = json.Unmarshal(content, &singleDeploy)
 		if err != nil {
-			return deploy.Services, fmt.Errorf("yaml file %v in wrong format: %v", filename, err.Error())
-		}
-		if len(deploy.Services) == 0 {
-			err = yaml.Unmarshal(content, &singleDeploy)
-			if err != nil {
-				return deploy.Services, fmt.Errorf("yaml file %v in wrong format: %v", filename, err.Error())
-			}
-			deploy.Services = append(deploy.Services, singleDeploy)
-		} else {
-			unmarshalWithDefaults("yaml", content, &deploy)
+			return deploy.Services, fmt.Errorf("%v file %v in wrong format: %v", fType, filename, err.Error())
 		}
+		deploy.Services = append(deploy.Services, singleDeploy)
 	} else {
-		return deploy.Services, fmt.Errorf("Wrong file extension (needs to be json, yaml, or yml)\n")
+		unmarshalWithDefaults("json", content, &deploy)
 	}
 	// check whether we have services
 	if len(deploy.Services) == 0 {
@@ -508,9 +649,26 @@ func parseFile(filename, fType, serviceName string) ([]service.Deploy, error) {
 	return deploy.Services, nil
 }
 
-func createRepository(session Session, repository string) (string, error) {
+// formatValidationErrors returns the validation errors one per line
+func formatValidationErrors(errs service.ValidationErrors) string {
+	var lines []string
+	for _, e := range errs {
+		if e.Field == "" {
+			lines = append(lines, "  "+e.Message)
+		} else {
+			lines = append(lines, "  "+e.Field+": "+e.Message)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func createRepository(session Session, repository string, settings service.RepositorySettings) (string, error) {
 	var res string
-	req, err := http.NewRequest("POST", session.Url+"/api/v1/ecr/create/"+repository, bytes.NewBuffer([]byte("")))
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return res, err
+	}
+	req, err := http.NewRequest("POST", session.Url+"/api/v1/ecr/create/"+repository, bytes.NewBuffer(b))
 	if err != nil {
 		return res, err
 	}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deployStreams returns a handler that fails the deploy status stream of the broken service and keeps the other streams open
+func deployStreams(broken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if strings.HasPrefix(r.URL.Path, "/api/v1/deploy/status/"+broken+"/") {
+			w.Write([]byte("event: error\ndata: {\"error\": \"deployment failed\"}\n\n"))
+			return
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}
+}
+
+func TestWaitForDeployCancelsOtherStreams(t *testing.T) {
+	server := httptest.NewServer(deployStreams("service2"))
+	defer server.Close()
+	response := []byte(`{"messages": [{"serviceName": "service1"}, {"serviceName": "service2"}, {"serviceName": "service3"}]}`)
+
+	done := make(chan error)
+	go func() {
+		_, err := waitForDeploy(Session{Token: "token", Url: server.URL}, response)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "deployment failed") {
+			t.Errorf("expected the deployment error, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("waitForDeploy didn't stop the other streams")
+	}
+}
+
+func TestStreamDeployStatusTimeout(t *testing.T) {
+	server := httptest.NewServer(deployStreams(""))
+	defer server.Close()
+	previous := deployStatusTimeout
+	deployStatusTimeout = 100 * time.Millisecond
+	defer func() { deployStatusTimeout = previous }()
+
+	deployed, err := waitForDeploy(Session{Token: "token", Url: server.URL}, []byte(`{"messages": [{"serviceName": "service1"}]}`))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if deployed["service1"] != "running" {
+		t.Errorf("expected running, got %v", deployed["service1"])
+	}
+}
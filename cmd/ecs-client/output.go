@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// decodeAPIResponse unmarshals the api response, and returns the error if the api returned one
+func decodeAPIResponse(body []byte, v interface{}) error {
+	var apiError struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiError); err == nil && apiError.Error != "" {
+		return errors.New(apiError.Error)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// printOutput prints v as JSON, or as a table using printTable
+func printOutput(output string, v interface{}, printTable func(w *tabwriter.Writer)) error {
+	if output == "json" {
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	printTable(w)
+	return w.Flush()
+}
+
+// printRow prints a tab separated row
+func printRow(w *tabwriter.Writer, columns ...interface{}) {
+	values := make([]string, len(columns))
+	for k, v := range columns {
+		values[k] = fmt.Sprintf("%v", v)
+	}
+	fmt.Fprintln(w, strings.Join(values, "\t"))
+}
+
+// formatTime formats the time the way the api expects it in urls (e.g. for rollback)
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.999999999Z")
+}
+
+// shortArn returns the last part of an arn (e.g. myservice:12 for a task definition)
+func shortArn(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// formatOptionalCount formats a count that is not always set (e.g. of a scheduled action)
+func formatOptionalCount(count *int64) string {
+	if count == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", *count)
+}
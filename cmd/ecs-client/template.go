@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// list elements with one of these keys are merged by key, other lists are replaced by the overlay
+var mergeKeys = []string{"serviceName", "containerName", "name", "containerPath", "containerPort", "policyName", "actionName"}
+
+// matches $${VAR} (escaped), ${VAR} and ${VAR:-default}
+var interpolateRegexp = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ciVariables maps the variables available in deploy files to the environment variables set by CI systems
+var ciVariables = map[string][]string{
+	"CI_COMMIT_SHA":   {"GITHUB_SHA", "CI_COMMIT_SHA", "CIRCLE_SHA1", "BITBUCKET_COMMIT", "TRAVIS_COMMIT", "BUILDKITE_COMMIT", "CODEBUILD_RESOLVED_SOURCE_VERSION", "GIT_COMMIT"},
+	"CI_BRANCH":       {"GITHUB_HEAD_REF", "CI_COMMIT_BRANCH", "CIRCLE_BRANCH", "BITBUCKET_BRANCH", "TRAVIS_BRANCH", "BUILDKITE_BRANCH", "GIT_BRANCH"},
+	"CI_TAG":          {"CI_COMMIT_TAG", "CIRCLE_TAG", "BITBUCKET_TAG", "TRAVIS_TAG", "BUILDKITE_TAG"},
+	"CI_BUILD_NUMBER": {"GITHUB_RUN_NUMBER", "CI_PIPELINE_IID", "CIRCLE_BUILD_NUM", "BITBUCKET_BUILD_NUMBER", "TRAVIS_BUILD_NUMBER", "BUILDKITE_BUILD_NUMBER", "CODEBUILD_BUILD_NUMBER", "BUILD_NUMBER"},
+}
+
+// getTemplateVariables returns the CI metadata and environment name that can be used in deploy files,
+// or nil when interpolation is not enabled
+func getTemplateVariables(deployFlags *DeployFlags) map[string]string {
+	if !deployFlags.Interpolate {
+		return nil
+	}
+	environment := deployFlags.Environment
+	vars := make(map[string]string)
+	for name, sources := range ciVariables {
+		for _, source := range sources {
+			if value := os.Getenv(source); value != "" {
+				vars[name] = value
+				break
+			}
+		}
+	}
+	// github actions sets the branch or tag in GITHUB_REF_NAME
+	if refName := os.Getenv("GITHUB_REF_NAME"); refName != "" {
+		if os.Getenv("GITHUB_REF_TYPE") == "tag" {
+			if _, ok := vars["CI_TAG"]; !ok {
+				vars["CI_TAG"] = refName
+			}
+		} else if _, ok := vars["CI_BRANCH"]; !ok {
+			vars["CI_BRANCH"] = refName
+		}
+	}
+	if sha, ok := vars["CI_COMMIT_SHA"]; ok && len(sha) > 7 {
+		vars["CI_COMMIT_SHORT_SHA"] = sha[:7]
+	} else if ok {
+		vars["CI_COMMIT_SHORT_SHA"] = sha
+	}
+	if environment != "" {
+		vars["ENVIRONMENT"] = environment
+	}
+	return vars
+}
+
+// interpolate replaces ${VAR} and ${VAR:-default} with environment variables (which take precedence) or template variables
+func interpolate(value string, vars map[string]string) (string, error) {
+	var missing []string
+	res := interpolateRegexp.ReplaceAllStringFunc(value, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		m := interpolateRegexp.FindStringSubmatch(match)
+		if value, ok := os.LookupEnv(m[1]); ok {
+			return value
+		}
+		if value, ok := vars[m[1]]; ok {
+			return value
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		missing = append(missing, m[1])
+		return match
+	})
+	if len(missing) > 0 {
+		return res, fmt.Errorf("variable(s) not set: %v", strings.Join(missing, ", "))
+	}
+	return res, nil
+}
+
+// interpolateTree interpolates the string values of a parsed deploy file, keys and other types are left untouched
+func interpolateTree(tree interface{}, vars map[string]string) (interface{}, error) {
+	switch v := tree.(type) {
+	case string:
+		return interpolate(v, vars)
+	case map[string]interface{}:
+		res := make(map[string]interface{})
+		var errs []string
+		for k, value := range v {
+			newValue, err := interpolateTree(value, vars)
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+			res[k] = newValue
+		}
+		if len(errs) > 0 {
+			sort.Strings(errs)
+			return res, errors.New(strings.Join(errs, "; "))
+		}
+		return res, nil
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		var errs []string
+		for i, value := range v {
+			newValue, err := interpolateTree(value, vars)
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+			res[i] = newValue
+		}
+		if len(errs) > 0 {
+			return res, errors.New(strings.Join(errs, "; "))
+		}
+		return res, nil
+	}
+	return tree, nil
+}
+
+// readTemplate reads a json or yaml deploy file and returns it as a generic tree.
+// Variables are only interpolated when vars is not nil
+func readTemplate(filename string, vars map[string]string) (interface{}, error) {
+	var tree interface{}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return tree, fmt.Errorf("Could not read file: %v\n", filename)
+	}
+	// json is valid yaml
+	err = yaml.Unmarshal(content, &tree)
+	if err != nil {
+		return tree, fmt.Errorf("file %v in wrong format: %v", filename, err)
+	}
+	if vars != nil {
+		tree, err = interpolateTree(tree, vars)
+		if err != nil {
+			return tree, fmt.Errorf("file %v: %v", filename, err)
+		}
+	}
+	return tree, nil
+}
+
+// renderTemplate merges the overlays on top of the base file and returns the result as json
+func renderTemplate(filename string, overlays []string, vars map[string]string) ([]byte, error) {
+	tree, err := readTemplate(filename, vars)
+	if err != nil {
+		return nil, err
+	}
+	for _, overlay := range overlays {
+		overlayTree, err := readTemplate(overlay, vars)
+		if err != nil {
+			return nil, err
+		}
+		tree = mergeTemplate(tree, overlayTree)
+	}
+	return json.Marshal(tree)
+}
+
+// getOverlays returns the overlays for a deploy file: <dir>/<environment>/<file> (when present) followed by the explicit overlays
+func getOverlays(filename string, deployFlags *DeployFlags) ([]string, error) {
+	var overlays []string
+	if deployFlags.Environment != "" {
+		overlay := filepath.Join(filepath.Dir(filename), deployFlags.Environment, filepath.Base(filename))
+		if _, err := os.Stat(overlay); err == nil {
+			overlays = append(overlays, overlay)
+		}
+	}
+	for _, overlay := range deployFlags.Overlays {
+		if _, err := os.Stat(overlay); err != nil {
+			return overlays, fmt.Errorf("overlay %v not found", overlay)
+		}
+		overlays = append(overlays, overlay)
+	}
+	return overlays, nil
+}
+
+// mergeTemplate merges the overlay into the base with strategic merge semantics:
+// maps are merged recursively, null removes a key, lists of objects with a merge key are merged by that key
+// and all other values (including lists without merge key) are replaced by the overlay
+func mergeTemplate(base, overlay interface{}) interface{} {
+	switch o := overlay.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			return removeNulls(o)
+		}
+		res := make(map[string]interface{})
+		for k, v := range b {
+			res[k] = v
+		}
+		for k, v := range o {
+			if v == nil {
+				delete(res, k)
+			} else if _, ok := res[k]; ok {
+				res[k] = mergeTemplate(res[k], v)
+			} else {
+				res[k] = removeNulls(v)
+			}
+		}
+		return res
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok {
+			return o
+		}
+		key := getMergeKey(b, o)
+		if key == "" {
+			return o
+		}
+		res := append([]interface{}{}, b...)
+		for _, v := range o {
+			found := false
+			for i := range res {
+				if res[i].(map[string]interface{})[key] == v.(map[string]interface{})[key] {
+					res[i] = mergeTemplate(res[i], v)
+					found = true
+				}
+			}
+			if !found {
+				res = append(res, removeNulls(v))
+			}
+		}
+		return res
+	}
+	return overlay
+}
+
+// getMergeKey returns the merge key present in all elements of both lists
+func getMergeKey(base, overlay []interface{}) string {
+	for _, key := range mergeKeys {
+		found := true
+		for _, v := range append(append([]interface{}{}, base...), overlay...) {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			if _, ok := m[key]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return key
+		}
+	}
+	return ""
+}
+
+// removeNulls removes null values from maps, used when an overlay adds new keys
+func removeNulls(v interface{}) interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		res := make(map[string]interface{})
+		for k, value := range m {
+			if value != nil {
+				res[k] = removeNulls(value)
+			}
+		}
+		return res
+	}
+	return v
+}
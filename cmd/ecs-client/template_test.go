@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+)
+
+func TestMergeTemplate(t *testing.T) {
+	var base, overlay interface{}
+	err := yaml.Unmarshal([]byte(`
+services:
+- serviceName: web
+  desiredCount: 1
+  ruleConditions:
+  - hostname: web
+  containers:
+  - containerName: web
+    memoryReservation: 128
+    environment:
+    - name: A
+      value: "1"
+    - name: B
+      value: "2"
+- serviceName: worker
+  desiredCount: 1
+`), &base)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	err = yaml.Unmarshal([]byte(`
+services:
+- serviceName: web
+  desiredCount: 3
+  ruleConditions:
+  - hostname: web-prod
+  - hostname: www
+  containers:
+  - containerName: web
+    memoryReservation: null
+    environment:
+    - name: B
+      value: "3"
+    - name: C
+      value: "4"
+`), &overlay)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	out, err := json.Marshal(mergeTemplate(base, overlay))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	expected := `{"services":[{"containers":[{"containerName":"web","environment":[{"name":"A","value":"1"},{"name":"B","value":"3"},{"name":"C","value":"4"}]}],"desiredCount":3,"ruleConditions":[{"hostname":"web-prod"},{"hostname":"www"}],"serviceName":"web"},{"desiredCount":1,"serviceName":"worker"}]}`
+	if string(out) != expected {
+		t.Errorf("unexpected merge result: %s", out)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("ECS_CLIENT_TEST_VAR", "fromenv")
+	defer os.Unsetenv("ECS_CLIENT_TEST_VAR")
+	vars := map[string]string{"ENVIRONMENT": "prod", "ECS_CLIENT_TEST_VAR": "fromvars"}
+
+	out, err := interpolate(`${ECS_CLIENT_TEST_VAR} ${ENVIRONMENT} ${ECS_CLIENT_UNSET:-default} $${ENVIRONMENT}`, vars)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if out != "fromenv prod default ${ENVIRONMENT}" {
+		t.Errorf("unexpected interpolation result: %s", out)
+	}
+	if _, err = interpolate(`${ECS_CLIENT_UNSET}`, vars); err == nil {
+		t.Errorf("expected error for unset variable")
+	}
+}
+
+func TestReadTemplateInterpolation(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "ecs.json")
+	err := os.WriteFile(filename, []byte(`{"cluster": "mycluster", "containers": [{"containerName": "web", "containerCommand": ["sh", "-c", "echo ${HOME}"], "environment": [{"name": "GREETING", "value": "${ECS_CLIENT_TEST_VAR}"}]}]}`), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	os.Setenv("ECS_CLIENT_TEST_VAR", "say \"hi\"\nbye")
+	defer os.Unsetenv("ECS_CLIENT_TEST_VAR")
+
+	// without interpolation the file is left untouched
+	tree, err := readTemplate(filename, nil)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	out, _ := json.Marshal(tree)
+	if !strings.Contains(string(out), `"echo ${HOME}"`) || !strings.Contains(string(out), `"${ECS_CLIENT_TEST_VAR}"`) {
+		t.Errorf("unexpected interpolation: %s", out)
+	}
+	// values with quotes and newlines don't break the file
+	tree, err = readTemplate(filename, map[string]string{})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	container := tree.(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})
+	value := container["environment"].([]interface{})[0].(map[string]interface{})["value"]
+	if value != "say \"hi\"\nbye" {
+		t.Errorf("unexpected value: %v", value)
+	}
+}
+
+func TestParseFileWithEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	err := os.Mkdir(filepath.Join(dir, "prod"), 0755)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "ecs.yaml"), []byte("cluster: mycluster\ndesiredCount: 1\ncontainers:\n- containerName: web\n  containerURI: web:${ENVIRONMENT}\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "prod", "ecs.yaml"), []byte("desiredCount: 3\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	content, err := renderFile(filepath.Join(dir, "ecs.yaml"), "yaml", &DeployFlags{Environment: "prod", Interpolate: true})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	services, err := parseFile(filepath.Join(dir, "ecs.yaml"), "yaml", "web", content)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if len(services) != 1 {
+		t.Fatalf("expected 1 service, got %d", len(services))
+	}
+	if services[0].ServiceName != "web" || services[0].DesiredCount != 3 || services[0].Containers[0].ContainerURI != "web:prod" {
+		t.Errorf("unexpected service: %+v", services[0])
+	}
+	// defaults are still applied
+	if services[0].DeregistrationDelay != -1 {
+		t.Errorf("expected default deregistrationDelay, got %d", services[0].DeregistrationDelay)
+	}
+}
+
+func TestParseAndValidateFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "ecs.yaml")
+	err := os.WriteFile(filename, []byte("cluster: mycluster\ndesiredCount: 1\ncontainers:\n- containerName: web\n  containerURI: web\n  memoryLimit: 128\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	_, err = parseAndValidateFile(filename, "yaml", "web", &DeployFlags{})
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, field := range []string{"serviceProtocol", "containers[0].containerTag", "containers[0].memoryLimit"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error for %s, got: %s", field, err)
+		}
+	}
+	err = os.WriteFile(filename, []byte("cluster: mycluster\nserviceProtocol: none\ndesiredCount: 1\ncontainers:\n- containerName: web\n  containerTag: latest\n  containerURI: web\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	services, err := parseAndValidateFile(filename, "yaml", "web", &DeployFlags{})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if len(services) != 1 || services[0].Containers[0].ContainerTag != "latest" {
+		t.Errorf("unexpected services: %+v", services)
+	}
+}
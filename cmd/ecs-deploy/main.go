@@ -2,33 +2,23 @@ package main
 
 import (
 	"github.com/in4it/ecs-deploy/api"
-	"github.com/in4it/ecs-deploy/provider/ecs"
 	"github.com/in4it/ecs-deploy/util"
 	"github.com/juju/loggo"
 	"github.com/spf13/pflag"
 
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
-func startup_checks() {
-	mandatoryEnvVars := []string{
-		"AWS_REGION",
-		"JWT_SECRET",
-		"DEPLOY_PASSWORD",
-	}
-	paramstore := ecs.Paramstore{}
-	err := paramstore.RetrieveKeys()
+func startup_checks(config *api.Config) {
+	// the configuration is validated once
+	err := config.Validate()
 	if err != nil {
-		fmt.Printf("Couldn't retrieve variables from parameter store\n")
+		fmt.Printf("%v\n", err.Error())
 		os.Exit(1)
 	}
-	for _, envVar := range mandatoryEnvVars {
-		if !util.EnvExists(envVar) {
-			fmt.Printf("Environment variable missing: %v\n", envVar)
-			os.Exit(1)
-		}
-	}
 	// start controller, check database and pick up any remaining work
 	controller := api.Controller{}
 	err = controller.Resume()
@@ -51,6 +41,10 @@ func addFlags(f *api.Flags, fs *pflag.FlagSet) {
 	fs.BoolVar(&f.CloudwatchLogsEnabled, "cloudwatch-logs-enabled", f.CloudwatchLogsEnabled, "enable cloudwatch logs")
 	fs.StringVar(&f.KeyName, "key-name", f.KeyName, "ssh key name")
 	fs.StringVar(&f.InstanceType, "instance-type", f.InstanceType, "AWS instance type (e.g. t2.micro)")
+	fs.StringVar(&f.InstanceTypes, "instance-types", f.InstanceTypes, "additional AWS instance types the autoscaling group can launch (comma separated, e.g. t3.micro,t3a.micro)")
+	fs.StringVar(&f.OnDemandBaseCapacity, "on-demand-base-capacity", "0", "minimum number of on-demand instances in the autoscaling group")
+	fs.StringVar(&f.OnDemandPercentage, "on-demand-percentage", "100", "percentage of on-demand instances above the base capacity, the rest are spot instances")
+	fs.StringVar(&f.SpotAllocationStrategy, "spot-allocation-strategy", "capacity-optimized", "spot allocation strategy (lowest-price, capacity-optimized, capacity-optimized-prioritized, price-capacity-optimized)")
 	fs.StringVar(&f.EcsSecurityGroups, "ecs-security-groups", f.EcsSecurityGroups, "ECS security groups to use")
 	fs.StringVar(&f.EcsMinSize, "ecs-min-size", f.EcsMinSize, "ECS minimal size")
 	fs.StringVar(&f.EcsMaxSize, "ecs-max-size", f.EcsMaxSize, "ECS maxium size")
@@ -63,9 +57,28 @@ func addFlags(f *api.Flags, fs *pflag.FlagSet) {
 	fs.StringVar(&f.DeleteCluster, "delete-cluster", f.DeleteCluster, "delete-cluster <cluster name>")
 	fs.BoolVar(&f.DisableEcsDeploy, "disable-ecs-deploy", f.DisableEcsDeploy, "disable ecs deploy during bootstrap")
 	fs.StringVar(&f.ProdCode, "aws-prod-code", f.ProdCode, "aws marketplace product code")
+	fs.BoolVar(&f.CapacityProvider, "capacity-provider", f.CapacityProvider, "use an ECS capacity provider with managed scaling instead of the ecs-deploy cluster autoscaler")
+	fs.StringVar(&f.CapacityProviderTargetCapacity, "capacity-provider-target-capacity", "100", "target capacity (in percent) of the capacity provider managed scaling")
+	fs.StringVar(&f.ConfigFile, "config", util.GetEnv("ECS_DEPLOY_CONFIG", ""), "server configuration file (yaml), environment variables and parameter store take precedence")
+	fs.BoolVar(&f.PrintConfig, "print-config", f.PrintConfig, "print the effective server configuration (secrets are redacted) and exit")
 	fs.MarkHidden("disable-ecs-deploy")
 }
 
+func configureLogging(config *api.Config) {
+	// set logging to debug
+	if config.Server.Debug == "true" {
+		loggo.ConfigureLoggers(`<root>=DEBUG`)
+	} else {
+		loggo.ConfigureLoggers(`<root>=INFO`)
+	}
+	// json logging
+	if config.Server.LogFormat == "json" {
+		if err := util.EnableJSONLogging(os.Stderr); err != nil {
+			fmt.Printf("Couldn't enable json logging: %v\n", err.Error())
+		}
+	}
+}
+
 // @title ecs-deploy
 // @version 0.0.1
 // @description ecs-deploy is the glue between your CI and ECS. It automates deploys based a simple JSON file Edit
@@ -74,13 +87,6 @@ func addFlags(f *api.Flags, fs *pflag.FlagSet) {
 // @contact.email	ward@in4it.io
 // license.name	Apache 2.0
 func main() {
-	// set logging to debug
-	if util.GetEnv("DEBUG", "") == "true" {
-		loggo.ConfigureLoggers(`<root>=DEBUG`)
-	} else {
-		loggo.ConfigureLoggers(`<root>=INFO`)
-	}
-
 	// parse flags
 	flags := api.NewFlags()
 	addFlags(flags, pflag.CommandLine)
@@ -93,6 +99,14 @@ func main() {
 	if flags.Profile != "" {
 		os.Setenv("AWS_REGION", flags.Region)
 	}
+	// load the configuration file, environment variables and parameter store, logging can be set in the configuration
+	config, err := api.LoadConfig(flags.ConfigFile)
+	if err != nil {
+		fmt.Printf("Couldn't load configuration: %v\n", err.Error())
+		os.Exit(1)
+	}
+	configureLogging(config)
+	api.SetServerConfig(config)
 	if flags.Bootstrap {
 		if ok, _ := util.AskForConfirmation("Bootstrap ECS Cluster?"); ok {
 			controller := api.Controller{}
@@ -110,14 +124,36 @@ func main() {
 				fmt.Printf("Error: %v\n", err.Error())
 			}
 		}
+	} else if flags.PrintConfig {
+		config.Print(os.Stdout)
+		if err = config.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+			os.Exit(1)
+		}
 	} else if flags.Server {
 		// startup checks
-		startup_checks()
+		startup_checks(config)
+
+		// tracing (only enabled when OTEL_EXPORTER_OTLP_ENDPOINT is set)
+		shutdownTracing, err := api.InitTracing()
+		if err != nil {
+			fmt.Printf("Couldn't initialize tracing: %v\n", err.Error())
+			os.Exit(1)
+		}
+		// the server only stops with a signal, the spans are flushed before exiting
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-signals
+			shutdownTracing()
+			os.Exit(0)
+		}()
 
 		// Launch API
 		api := api.API{}
-		err := api.Launch()
+		err = api.Launch()
 		if err != nil {
+			shutdownTracing()
 			panic(err)
 		}
 	} else {
@@ -18,7 +18,7 @@ func TestDummyIntegration(t *testing.T) {
 }
 func TestSlackIntegration(t *testing.T) {
 	var notification integrations.Notification
-	notification = integrations.NewSlack()
+	notification = integrations.NewSlack("", "ecs-deploy", "")
 	err := notification.LogRecovery("Deployed successfully")
 	if err != nil && !strings.HasSuffix(err.Error(), "SLACK_WEBHOOKS not set") {
 		t.Errorf("Could not send notification: %s", err)
@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/in4it/ecs-deploy/util"
 	"github.com/juju/loggo"
 )
 
@@ -22,10 +21,14 @@ type payload struct {
 }
 
 type Slack struct {
+	// comma separated webhooks, a channel can be added with :#channel
+	webhooks    string
+	username    string
+	environment string
 }
 
-func NewSlack() *Slack {
-	return &Slack{}
+func NewSlack(webhooks, username, environment string) *Slack {
+	return &Slack{webhooks: webhooks, username: username, environment: environment}
 }
 
 func (s *Slack) LogFailure(message string) error {
@@ -37,19 +40,19 @@ func (s *Slack) LogRecovery(message string) error {
 }
 
 func (s *Slack) sendMsg(message, status string) error {
-	if util.GetEnv("SLACK_WEBHOOKS", "") == "" {
+	if s.webhooks == "" {
 		return fmt.Errorf("SLACK_WEBHOOKS not set")
 	}
 
-	username := util.GetEnv("SLACK_USERNAME", "ecs-deploy")
+	username := s.username
 
 	// add environment
-	if util.GetEnv("AWS_ACCOUNT_ENV", "") != "" {
-		message = "[" + util.GetEnv("AWS_ACCOUNT_ENV", "") + "] " + message
+	if s.environment != "" {
+		message = "[" + s.environment + "] " + message
 	}
 
 	icon := ":vertical_traffic_light:"
-	webhooks := strings.Split(util.GetEnv("SLACK_WEBHOOKS", ""), ",")
+	webhooks := strings.Split(s.webhooks, ",")
 
 	for _, v := range webhooks {
 		webhook := strings.Split(v, ":#")
@@ -3,7 +3,7 @@ package integrations
 import "testing"
 
 func TestSendMsg(t *testing.T) {
-	slack := NewSlack()
+	slack := NewSlack("", "ecs-deploy", "")
 	err := slack.sendMsg("test message", "failure")
 
 	if err != nil {
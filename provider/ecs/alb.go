@@ -723,6 +723,21 @@ func (a *ALB) GetTargetGroupArn(serviceName string) (*string, error) {
 		}
 	}
 }
+
+// GetResourceLabel returns the resource label of a target group of the loadbalancer (used by the ALBRequestCountPerTarget metric)
+func (a *ALB) GetResourceLabel(targetGroupArn string) (string, error) {
+	return getResourceLabel(a.loadBalancerArn, targetGroupArn)
+}
+
+// getResourceLabel returns app/<lb name>/<lb id>/targetgroup/<tg name>/<tg id>
+func getResourceLabel(loadBalancerArn, targetGroupArn string) (string, error) {
+	lb := strings.SplitN(loadBalancerArn, ":loadbalancer/", 2)
+	tg := strings.SplitN(targetGroupArn, ":targetgroup/", 2)
+	if len(lb) != 2 || len(tg) != 2 {
+		return "", fmt.Errorf("could not get resource label from loadbalancer %v and target group %v", loadBalancerArn, targetGroupArn)
+	}
+	return lb[1] + "/targetgroup/" + tg[1], nil
+}
 func (a *ALB) GetDomain() string {
 	return util.GetEnv("LOADBALANCER_DOMAIN", a.Domain)
 }
@@ -885,3 +900,38 @@ func (a *ALB) getCognitoAction(targetGroupArn string, cognitoAuth service.Deploy
 		},
 	}, nil
 }
+
+// DescribeTargetGroup returns the target group and its attributes
+func (a *ALB) DescribeTargetGroup(targetGroupArn string) (*elbv2.TargetGroup, map[string]string, error) {
+	attributes := make(map[string]string)
+	svc := elbv2.New(session.New())
+	result, err := svc.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+		TargetGroupArns: aws.StringSlice([]string{targetGroupArn}),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			albLogger.Errorf(aerr.Error())
+		} else {
+			albLogger.Errorf(err.Error())
+		}
+		return nil, attributes, err
+	}
+	if len(result.TargetGroups) == 0 {
+		return nil, attributes, errors.New("Target group not found: " + targetGroupArn)
+	}
+	resultAttributes, err := svc.DescribeTargetGroupAttributes(&elbv2.DescribeTargetGroupAttributesInput{
+		TargetGroupArn: aws.String(targetGroupArn),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			albLogger.Errorf(aerr.Error())
+		} else {
+			albLogger.Errorf(err.Error())
+		}
+		return nil, attributes, err
+	}
+	for _, attribute := range resultAttributes.Attributes {
+		attributes[aws.StringValue(attribute.Key)] = aws.StringValue(attribute.Value)
+	}
+	return result.TargetGroups[0], attributes, nil
+}
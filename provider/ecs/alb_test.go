@@ -170,3 +170,16 @@ func TestGetListenerArnForProtocol(t *testing.T) {
 		t.Errorf("didn't get expected result: got %s, expected %s", retListener, expectedResult)
 	}
 }
+
+func TestGetResourceLabel(t *testing.T) {
+	label, err := getResourceLabel("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/mycluster/50dc6c495c0c9188", "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/myservice/73e2d6bc24d8a067")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if label != "app/mycluster/50dc6c495c0c9188/targetgroup/myservice/73e2d6bc24d8a067" {
+		t.Errorf("unexpected resource label: %v", label)
+	}
+	if _, err = getResourceLabel("", "myservice"); err == nil {
+		t.Errorf("expected error for invalid arns")
+	}
+}
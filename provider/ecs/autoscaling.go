@@ -9,8 +9,9 @@ import (
 	"github.com/in4it/ecs-deploy/service"
 	"github.com/juju/loggo"
 
-	"encoding/base64"
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -24,6 +25,15 @@ type AutoScaling struct {
 type AutoScalingIf interface {
 	GetAutoScalingGroupByTag(clusterName string) (string, error)
 	ScaleClusterNodes(autoScalingGroupName string, change int64) error
+	GetClusterNodeDesiredCount(autoScalingGroupName string) (int64, int64, int64, error)
+	TerminateInstanceInAutoScalingGroup(instanceId string, decrementDesiredCapacity bool) error
+}
+
+// ApplicationAutoScalingIf is the application autoscaling of ecs services
+type ApplicationAutoScalingIf interface {
+	DeleteScalingPolicy(policyName, resourceId string) error
+	DescribeScheduledActions(resourceId string) ([]service.AutoscalingScheduledAction, error)
+	DeleteScheduledAction(actionName, resourceId string) error
 }
 
 func (a *AutoScaling) CompleteLifecycleAction(autoScalingGroupName, instanceId, action, lifecycleHookName, lifecycleToken string) error {
@@ -96,39 +106,6 @@ func (a *AutoScaling) GetLifecycleHookNames(autoScalingGroupName, lifecycleHookT
 	return lifecycleHookNames, nil
 }
 
-func (a *AutoScaling) CreateLaunchConfiguration(clusterName string, keyName string, instanceType string, instanceProfile string, securitygroups []string) error {
-	ecs := ECS{}
-	svc := autoscaling.New(session.New())
-	amiId, err := ecs.GetECSAMI()
-	if err != nil {
-		return err
-	}
-	input := &autoscaling.CreateLaunchConfigurationInput{
-		IamInstanceProfile:      aws.String(instanceProfile),
-		ImageId:                 aws.String(amiId),
-		InstanceType:            aws.String(instanceType),
-		KeyName:                 aws.String(keyName),
-		LaunchConfigurationName: aws.String(clusterName),
-		SecurityGroups:          aws.StringSlice(securitygroups),
-		UserData:                aws.String(base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho 'ECS_CLUSTER=" + clusterName + "'  > /etc/ecs/ecs.config\nstart ecs\n"))),
-	}
-	ecsLogger.Debugf("createLaunchConfiguration with: %+v", input)
-	_, err = svc.CreateLaunchConfiguration(input)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if strings.Contains(aerr.Message(), "Invalid IamInstanceProfile") {
-				ecsLogger.Debugf("Caught RetryableError: %v", aerr.Message())
-				return errors.New("RetryableError: Invalid IamInstanceProfile")
-			} else {
-				ecsLogger.Errorf("%v", aerr.Error())
-			}
-		} else {
-			ecsLogger.Errorf("%v", err.Error())
-		}
-		return err
-	}
-	return nil
-}
 func (a *AutoScaling) DeleteLaunchConfiguration(clusterName string) error {
 	svc := autoscaling.New(session.New())
 	input := &autoscaling.DeleteLaunchConfigurationInput{
@@ -145,25 +122,77 @@ func (a *AutoScaling) DeleteLaunchConfiguration(clusterName string) error {
 	}
 	return nil
 }
-func (a *AutoScaling) CreateAutoScalingGroup(clusterName string, desiredCapacity int64, maxSize int64, minSize int64, subnets []string) error {
+
+// MixedInstancesConfig describes the instance types and the on-demand/spot distribution of an autoscaling group
+type MixedInstancesConfig struct {
+	InstanceTypes                       []string
+	OnDemandBaseCapacity                int64
+	OnDemandPercentageAboveBaseCapacity int64
+	SpotAllocationStrategy              string
+}
+
+// HasSpot returns true when the autoscaling group launches spot instances
+func (m MixedInstancesConfig) HasSpot() bool {
+	return m.OnDemandPercentageAboveBaseCapacity < 100
+}
+
+func (a *AutoScaling) getMixedInstancesPolicy(launchTemplateName string, mixedInstances MixedInstancesConfig) *autoscaling.MixedInstancesPolicy {
+	policy := &autoscaling.MixedInstancesPolicy{
+		LaunchTemplate: &autoscaling.LaunchTemplate{
+			LaunchTemplateSpecification: &autoscaling.LaunchTemplateSpecification{
+				LaunchTemplateName: aws.String(launchTemplateName),
+				Version:            aws.String("$Latest"),
+			},
+		},
+		InstancesDistribution: &autoscaling.InstancesDistribution{
+			OnDemandBaseCapacity:                aws.Int64(mixedInstances.OnDemandBaseCapacity),
+			OnDemandPercentageAboveBaseCapacity: aws.Int64(mixedInstances.OnDemandPercentageAboveBaseCapacity),
+		},
+	}
+	for _, instanceType := range mixedInstances.InstanceTypes {
+		policy.LaunchTemplate.Overrides = append(policy.LaunchTemplate.Overrides, &autoscaling.LaunchTemplateOverrides{
+			InstanceType: aws.String(instanceType),
+		})
+	}
+	if mixedInstances.HasSpot() && mixedInstances.SpotAllocationStrategy != "" {
+		policy.InstancesDistribution.SpotAllocationStrategy = aws.String(mixedInstances.SpotAllocationStrategy)
+	}
+	return policy
+}
+
+// CreateAutoScalingGroup creates the autoscaling group of the cluster using the launch template with the cluster name
+func (a *AutoScaling) CreateAutoScalingGroup(clusterName string, desiredCapacity int64, maxSize int64, minSize int64, subnets []string, mixedInstances MixedInstancesConfig, newInstancesProtectedFromScaleIn bool) error {
 	svc := autoscaling.New(session.New())
 	input := &autoscaling.CreateAutoScalingGroupInput{
-		AutoScalingGroupName:    aws.String(clusterName),
-		DesiredCapacity:         aws.Int64(desiredCapacity),
-		HealthCheckType:         aws.String("EC2"),
-		LaunchConfigurationName: aws.String(clusterName),
-		MaxSize:                 aws.Int64(maxSize),
-		MinSize:                 aws.Int64(minSize),
+		AutoScalingGroupName: aws.String(clusterName),
+		DesiredCapacity:      aws.Int64(desiredCapacity),
+		HealthCheckType:      aws.String("EC2"),
+		MaxSize:              aws.Int64(maxSize),
+		MinSize:              aws.Int64(minSize),
 		Tags: []*autoscaling.Tag{
 			{Key: aws.String("Name"), Value: aws.String("ecs-" + clusterName), PropagateAtLaunch: aws.Bool(true)},
 			{Key: aws.String("Cluster"), Value: aws.String(clusterName), PropagateAtLaunch: aws.Bool(true)},
 		},
-		TerminationPolicies: []*string{aws.String("OldestLaunchConfiguration"), aws.String("Default")},
-		VPCZoneIdentifier:   aws.String(strings.Join(subnets, ",")),
+		TerminationPolicies:              []*string{aws.String("OldestLaunchTemplate"), aws.String("Default")},
+		VPCZoneIdentifier:                aws.String(strings.Join(subnets, ",")),
+		NewInstancesProtectedFromScaleIn: aws.Bool(newInstancesProtectedFromScaleIn),
+	}
+	if len(mixedInstances.InstanceTypes) > 1 || mixedInstances.HasSpot() {
+		input.MixedInstancesPolicy = a.getMixedInstancesPolicy(clusterName, mixedInstances)
+	} else {
+		input.LaunchTemplate = &autoscaling.LaunchTemplateSpecification{
+			LaunchTemplateName: aws.String(clusterName),
+			Version:            aws.String("$Latest"),
+		}
 	}
+	ecsLogger.Debugf("createAutoScalingGroup with: %+v", input)
 	_, err := svc.CreateAutoScalingGroup(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
+			if strings.Contains(aerr.Message(), "Invalid IamInstanceProfile") {
+				ecsLogger.Debugf("Caught RetryableError: %v", aerr.Message())
+				return errors.New("RetryableError: Invalid IamInstanceProfile")
+			}
 			ecsLogger.Errorf("%v", aerr.Error())
 		} else {
 			ecsLogger.Errorf("%v", err.Error())
@@ -172,6 +201,25 @@ func (a *AutoScaling) CreateAutoScalingGroup(clusterName string, desiredCapacity
 	}
 	return nil
 }
+func (a *AutoScaling) GetAutoScalingGroupArn(autoScalingGroupName string) (string, error) {
+	svc := autoscaling.New(session.New())
+	input := &autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String(autoScalingGroupName)},
+	}
+	result, err := svc.DescribeAutoScalingGroups(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return "", err
+	}
+	if len(result.AutoScalingGroups) == 0 {
+		return "", errors.New("Could not find autoscaling group " + autoScalingGroupName)
+	}
+	return aws.StringValue(result.AutoScalingGroups[0].AutoScalingGroupARN), nil
+}
 func (a *AutoScaling) WaitForAutoScalingGroupInService(clusterName string) error {
 	svc := autoscaling.New(session.New())
 	input := &autoscaling.DescribeAutoScalingGroupsInput{
@@ -249,6 +297,23 @@ func (a *AutoScaling) ScaleClusterNodes(autoScalingGroupName string, change int6
 	}
 	return nil
 }
+func (a *AutoScaling) TerminateInstanceInAutoScalingGroup(instanceId string, decrementDesiredCapacity bool) error {
+	svc := autoscaling.New(session.New())
+	input := &autoscaling.TerminateInstanceInAutoScalingGroupInput{
+		InstanceId:                     aws.String(instanceId),
+		ShouldDecrementDesiredCapacity: aws.Bool(decrementDesiredCapacity),
+	}
+	_, err := svc.TerminateInstanceInAutoScalingGroup(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return err
+	}
+	return nil
+}
 func (a *AutoScaling) GetClusterNodeDesiredCount(autoScalingGroupName string) (int64, int64, int64, error) {
 	svc := autoscaling.New(session.New())
 	input := &autoscaling.DescribeAutoScalingGroupsInput{
@@ -272,6 +337,35 @@ func (a *AutoScaling) GetClusterNodeDesiredCount(autoScalingGroupName string) (i
 		aws.Int64Value(result.AutoScalingGroups[0].MaxSize),
 		nil
 }
+
+// GetAutoScalingGroupLaunchTemplate returns the name and version of the launch template used by the autoscaling group
+func (a *AutoScaling) GetAutoScalingGroupLaunchTemplate(autoScalingGroupName string) (string, string, error) {
+	svc := autoscaling.New(session.New())
+	input := &autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String(autoScalingGroupName)},
+	}
+	result, err := svc.DescribeAutoScalingGroups(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return "", "", err
+	}
+	if len(result.AutoScalingGroups) == 0 {
+		return "", "", errors.New("No autoscaling groups returned")
+	}
+	group := result.AutoScalingGroups[0]
+	launchTemplate := group.LaunchTemplate
+	if launchTemplate == nil && group.MixedInstancesPolicy != nil && group.MixedInstancesPolicy.LaunchTemplate != nil {
+		launchTemplate = group.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+	}
+	if launchTemplate == nil {
+		return "", "", errors.New("Autoscaling group " + autoScalingGroupName + " doesn't use a launch template")
+	}
+	return aws.StringValue(launchTemplate.LaunchTemplateName), aws.StringValue(launchTemplate.Version), nil
+}
 func (a *AutoScaling) GetAutoScalingGroupByTag(clusterName string) (string, error) {
 	var result string
 	svc := autoscaling.New(session.New())
@@ -408,8 +502,11 @@ func (a *AutoScaling) DescribeScalableTargets(resourceIds []string) ([]service.A
 
 	return as, nil
 }
-func (a *AutoScaling) DescribeScalingPolicies(policyNames []string, resourceId string) ([]service.AutoscalingPolicy, error) {
+
+// DescribeScalingPolicies returns the step scaling and target tracking policies
+func (a *AutoScaling) DescribeScalingPolicies(policyNames []string, resourceId string) ([]service.AutoscalingPolicy, []service.AutoscalingTargetTrackingPolicy, error) {
 	var aps []service.AutoscalingPolicy
+	var tps []service.AutoscalingTargetTrackingPolicy
 	var scalingPolicies []*applicationautoscaling.ScalingPolicy
 	svc := applicationautoscaling.New(session.New())
 	input := &applicationautoscaling.DescribeScalingPoliciesInput{
@@ -432,21 +529,248 @@ func (a *AutoScaling) DescribeScalingPolicies(policyNames []string, resourceId s
 		} else {
 			ecsLogger.Errorf("%v", err.Error())
 		}
-		return aps, err
+		return aps, tps, err
 	}
 
 	for _, v := range scalingPolicies {
+		if v.TargetTrackingScalingPolicyConfiguration != nil {
+			tps = append(tps, a.getTargetTrackingPolicy(aws.StringValue(v.PolicyName), v.TargetTrackingScalingPolicyConfiguration))
+			continue
+		}
 		ap := service.AutoscalingPolicy{}
 		ap.PolicyName = aws.StringValue(v.PolicyName)
-		if len(v.StepScalingPolicyConfiguration.StepAdjustments) > 0 {
+		if v.StepScalingPolicyConfiguration != nil && len(v.StepScalingPolicyConfiguration.StepAdjustments) > 0 {
 			ap.ScalingAdjustment = aws.Int64Value(v.StepScalingPolicyConfiguration.StepAdjustments[0].ScalingAdjustment)
 		}
 		aps = append(aps, ap)
 	}
 
-	return aps, nil
+	return aps, tps, nil
+}
+
+// predefined metrics of the target tracking policies
+var targetTrackingMetrics = map[string]string{
+	"cpu":                      "ECSServiceAverageCPUUtilization",
+	"memory":                   "ECSServiceAverageMemoryUtilization",
+	"albRequestCountPerTarget": "ALBRequestCountPerTarget",
+}
+
+// getTargetTrackingConfiguration returns the target tracking configuration of a policy, the resource label is only used for albRequestCountPerTarget
+func (a *AutoScaling) getTargetTrackingConfiguration(p service.AutoscalingTargetTrackingPolicy, resourceLabel string) (*applicationautoscaling.TargetTrackingScalingPolicyConfiguration, error) {
+	config := &applicationautoscaling.TargetTrackingScalingPolicyConfiguration{
+		TargetValue: aws.Float64(p.TargetValue),
+	}
+	if p.ScaleInCooldown > 0 {
+		config.SetScaleInCooldown(p.ScaleInCooldown)
+	}
+	if p.ScaleOutCooldown > 0 {
+		config.SetScaleOutCooldown(p.ScaleOutCooldown)
+	}
+	if p.DisableScaleIn {
+		config.SetDisableScaleIn(true)
+	}
+	if p.Metric == "custom" {
+		if p.CustomMetric == nil || p.CustomMetric.Namespace == "" || p.CustomMetric.MetricName == "" {
+			return nil, errors.New("customMetric with namespace and metricName is required for metric custom")
+		}
+		metric := &applicationautoscaling.CustomizedMetricSpecification{
+			Namespace:  aws.String(p.CustomMetric.Namespace),
+			MetricName: aws.String(p.CustomMetric.MetricName),
+			Statistic:  aws.String("Average"),
+		}
+		if p.CustomMetric.Statistic != "" {
+			metric.SetStatistic(p.CustomMetric.Statistic)
+		}
+		if p.CustomMetric.Unit != "" {
+			metric.SetUnit(p.CustomMetric.Unit)
+		}
+		var dimensionNames []string
+		for k := range p.CustomMetric.Dimensions {
+			dimensionNames = append(dimensionNames, k)
+		}
+		sort.Strings(dimensionNames)
+		for _, k := range dimensionNames {
+			metric.Dimensions = append(metric.Dimensions, &applicationautoscaling.MetricDimension{
+				Name:  aws.String(k),
+				Value: aws.String(p.CustomMetric.Dimensions[k]),
+			})
+		}
+		config.SetCustomizedMetricSpecification(metric)
+		return config, nil
+	}
+	metricType, ok := targetTrackingMetrics[p.Metric]
+	if !ok {
+		return nil, fmt.Errorf("invalid metric %v (cpu, memory, albRequestCountPerTarget or custom)", p.Metric)
+	}
+	metric := &applicationautoscaling.PredefinedMetricSpecification{
+		PredefinedMetricType: aws.String(metricType),
+	}
+	if metricType == "ALBRequestCountPerTarget" {
+		if resourceLabel == "" {
+			return nil, errors.New("albRequestCountPerTarget needs a service with a target group")
+		}
+		metric.SetResourceLabel(resourceLabel)
+	}
+	config.SetPredefinedMetricSpecification(metric)
+	return config, nil
+}
+
+// getTargetTrackingPolicy converts the target tracking configuration to a policy
+func (a *AutoScaling) getTargetTrackingPolicy(policyName string, config *applicationautoscaling.TargetTrackingScalingPolicyConfiguration) service.AutoscalingTargetTrackingPolicy {
+	p := service.AutoscalingTargetTrackingPolicy{
+		PolicyName:       policyName,
+		TargetValue:      aws.Float64Value(config.TargetValue),
+		ScaleInCooldown:  aws.Int64Value(config.ScaleInCooldown),
+		ScaleOutCooldown: aws.Int64Value(config.ScaleOutCooldown),
+		DisableScaleIn:   aws.BoolValue(config.DisableScaleIn),
+	}
+	if config.PredefinedMetricSpecification != nil {
+		for k, v := range targetTrackingMetrics {
+			if v == aws.StringValue(config.PredefinedMetricSpecification.PredefinedMetricType) {
+				p.Metric = k
+			}
+		}
+	}
+	if config.CustomizedMetricSpecification != nil {
+		p.Metric = "custom"
+		p.CustomMetric = &service.AutoscalingCustomMetric{
+			Namespace:  aws.StringValue(config.CustomizedMetricSpecification.Namespace),
+			MetricName: aws.StringValue(config.CustomizedMetricSpecification.MetricName),
+			Statistic:  aws.StringValue(config.CustomizedMetricSpecification.Statistic),
+			Unit:       aws.StringValue(config.CustomizedMetricSpecification.Unit),
+		}
+		if len(config.CustomizedMetricSpecification.Dimensions) > 0 {
+			p.CustomMetric.Dimensions = make(map[string]string)
+			for _, d := range config.CustomizedMetricSpecification.Dimensions {
+				p.CustomMetric.Dimensions[aws.StringValue(d.Name)] = aws.StringValue(d.Value)
+			}
+		}
+	}
+	return p
+}
+
+// PutTargetTrackingScalingPolicy creates or updates a target tracking policy
+func (a *AutoScaling) PutTargetTrackingScalingPolicy(policyName, resourceId, resourceLabel string, p service.AutoscalingTargetTrackingPolicy) (string, error) {
+	config, err := a.getTargetTrackingConfiguration(p, resourceLabel)
+	if err != nil {
+		return "", err
+	}
+	svc := applicationautoscaling.New(session.New())
+	input := &applicationautoscaling.PutScalingPolicyInput{
+		PolicyName:                               aws.String(policyName),
+		PolicyType:                               aws.String("TargetTrackingScaling"),
+		ResourceId:                               aws.String(resourceId), // serviceName/clusterName/app
+		ScalableDimension:                        aws.String("ecs:service:DesiredCount"),
+		ServiceNamespace:                         aws.String("ecs"),
+		TargetTrackingScalingPolicyConfiguration: config,
+	}
+	result, err := svc.PutScalingPolicy(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return "", err
+	}
+	return aws.StringValue(result.PolicyARN), nil
 }
 
+// PutScheduledAction creates or updates a scheduled action that changes the minimum and/or maximum count
+func (a *AutoScaling) PutScheduledAction(resourceId string, action service.AutoscalingScheduledAction) error {
+	if action.ActionName == "" || action.Schedule == "" {
+		return errors.New("actionName and schedule are required for a scheduled action")
+	}
+	if action.MinimumCount == nil && action.MaximumCount == nil {
+		return fmt.Errorf("minimumCount or maximumCount is required for scheduled action %v", action.ActionName)
+	}
+	svc := applicationautoscaling.New(session.New())
+	input := &applicationautoscaling.PutScheduledActionInput{
+		ScheduledActionName: aws.String(action.ActionName),
+		Schedule:            aws.String(action.Schedule),
+		ResourceId:          aws.String(resourceId), // serviceName/clusterName/app
+		ScalableDimension:   aws.String("ecs:service:DesiredCount"),
+		ServiceNamespace:    aws.String("ecs"),
+		ScalableTargetAction: &applicationautoscaling.ScalableTargetAction{
+			MinCapacity: action.MinimumCount,
+			MaxCapacity: action.MaximumCount,
+		},
+	}
+	if action.Timezone != "" {
+		input.SetTimezone(action.Timezone)
+	}
+	_, err := svc.PutScheduledAction(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// DescribeScheduledActions returns the scheduled actions of an ecs service
+func (a *AutoScaling) DescribeScheduledActions(resourceId string) ([]service.AutoscalingScheduledAction, error) {
+	var actions []service.AutoscalingScheduledAction
+	svc := applicationautoscaling.New(session.New())
+	input := &applicationautoscaling.DescribeScheduledActionsInput{
+		ResourceId:        aws.String(resourceId), // serviceName/clusterName/app
+		ScalableDimension: aws.String("ecs:service:DesiredCount"),
+		ServiceNamespace:  aws.String("ecs"),
+	}
+	pageNum := 0
+	err := svc.DescribeScheduledActionsPages(input,
+		func(page *applicationautoscaling.DescribeScheduledActionsOutput, lastPage bool) bool {
+			pageNum++
+			for _, v := range page.ScheduledActions {
+				action := service.AutoscalingScheduledAction{
+					ActionName: aws.StringValue(v.ScheduledActionName),
+					Schedule:   aws.StringValue(v.Schedule),
+					Timezone:   aws.StringValue(v.Timezone),
+				}
+				if v.ScalableTargetAction != nil {
+					action.MinimumCount = v.ScalableTargetAction.MinCapacity
+					action.MaximumCount = v.ScalableTargetAction.MaxCapacity
+				}
+				actions = append(actions, action)
+			}
+			return pageNum <= 100
+		})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return actions, err
+	}
+	return actions, nil
+}
+
+// DeleteScheduledAction deletes a scheduled action of an ecs service
+func (a *AutoScaling) DeleteScheduledAction(actionName, resourceId string) error {
+	svc := applicationautoscaling.New(session.New())
+	input := &applicationautoscaling.DeleteScheduledActionInput{
+		ScheduledActionName: aws.String(actionName),
+		ResourceId:          aws.String(resourceId), // serviceName/clusterName/app
+		ScalableDimension:   aws.String("ecs:service:DesiredCount"),
+		ServiceNamespace:    aws.String("ecs"),
+	}
+	_, err := svc.DeleteScheduledAction(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteScalingPolicy deletes a scaling policy of an ecs service, a policy that doesn't exist is already deleted
 func (a *AutoScaling) DeleteScalingPolicy(policyName, resourceId string) error {
 	svc := applicationautoscaling.New(session.New())
 
@@ -459,6 +783,10 @@ func (a *AutoScaling) DeleteScalingPolicy(policyName, resourceId string) error {
 
 	_, err := svc.DeleteScalingPolicy(input)
 
+	if isAWSErrorCode(err, applicationautoscaling.ErrCodeObjectNotFoundException) {
+		ecsLogger.Debugf("Scaling policy %v of %v is already deleted", policyName, resourceId)
+		return nil
+	}
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			ecsLogger.Errorf("%v", aerr.Error())
@@ -470,3 +798,53 @@ func (a *AutoScaling) DeleteScalingPolicy(policyName, resourceId string) error {
 
 	return nil
 }
+
+// GetScalableTarget returns the scalable target of an ecs service, or nil when the service has no autoscaling
+func (a *AutoScaling) GetScalableTarget(resourceId string) (*applicationautoscaling.ScalableTarget, error) {
+	svc := applicationautoscaling.New(session.New())
+	input := &applicationautoscaling.DescribeScalableTargetsInput{
+		ResourceIds:       aws.StringSlice([]string{resourceId}), // serviceName/clusterName/app
+		ScalableDimension: aws.String("ecs:service:DesiredCount"),
+		ServiceNamespace:  aws.String("ecs"),
+	}
+	result, err := svc.DescribeScalableTargets(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return nil, err
+	}
+	if len(result.ScalableTargets) == 0 {
+		return nil, nil
+	}
+	return result.ScalableTargets[0], nil
+}
+
+// GetScalingPolicies returns all scaling policies of an ecs service
+func (a *AutoScaling) GetScalingPolicies(resourceId string) ([]*applicationautoscaling.ScalingPolicy, error) {
+	var scalingPolicies []*applicationautoscaling.ScalingPolicy
+	svc := applicationautoscaling.New(session.New())
+	input := &applicationautoscaling.DescribeScalingPoliciesInput{
+		ResourceId:        aws.String(resourceId), // serviceName/clusterName/app
+		ScalableDimension: aws.String("ecs:service:DesiredCount"),
+		ServiceNamespace:  aws.String("ecs"),
+	}
+	pageNum := 0
+	err := svc.DescribeScalingPoliciesPages(input,
+		func(page *applicationautoscaling.DescribeScalingPoliciesOutput, lastPage bool) bool {
+			pageNum++
+			scalingPolicies = append(scalingPolicies, page.ScalingPolicies...)
+			return pageNum <= 100
+		})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return scalingPolicies, err
+	}
+	return scalingPolicies, nil
+}
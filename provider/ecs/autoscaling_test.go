@@ -0,0 +1,66 @@
+package ecs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/in4it/ecs-deploy/service"
+)
+
+func TestGetTargetTrackingConfiguration(t *testing.T) {
+	as := AutoScaling{}
+	resourceLabel := "app/mycluster/50dc6c495c0c9188/targetgroup/myservice/73e2d6bc24d8a067"
+	config, err := as.getTargetTrackingConfiguration(service.AutoscalingTargetTrackingPolicy{Metric: "albRequestCountPerTarget", TargetValue: 1000, ScaleInCooldown: 300}, resourceLabel)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if aws.StringValue(config.PredefinedMetricSpecification.PredefinedMetricType) != "ALBRequestCountPerTarget" || aws.StringValue(config.PredefinedMetricSpecification.ResourceLabel) != resourceLabel {
+		t.Errorf("unexpected metric: %v", config.PredefinedMetricSpecification)
+	}
+	if aws.Int64Value(config.ScaleInCooldown) != 300 || config.ScaleOutCooldown != nil {
+		t.Errorf("unexpected cooldown: %v", config)
+	}
+	if _, err = as.getTargetTrackingConfiguration(service.AutoscalingTargetTrackingPolicy{Metric: "albRequestCountPerTarget", TargetValue: 1000}, ""); err == nil {
+		t.Errorf("expected error without resource label")
+	}
+	if _, err = as.getTargetTrackingConfiguration(service.AutoscalingTargetTrackingPolicy{Metric: "disk", TargetValue: 50}, ""); err == nil {
+		t.Errorf("expected error for invalid metric")
+	}
+
+	custom := service.AutoscalingTargetTrackingPolicy{
+		PolicyName:  "myservice-custom-target",
+		Metric:      "custom",
+		TargetValue: 100,
+		CustomMetric: &service.AutoscalingCustomMetric{
+			Namespace:  "MyApp",
+			MetricName: "QueueDepth",
+			Dimensions: map[string]string{"Queue": "jobs", "Env": "prod"},
+			Statistic:  "Average",
+		},
+	}
+	config, err = as.getTargetTrackingConfiguration(custom, "")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if len(config.CustomizedMetricSpecification.Dimensions) != 2 || aws.StringValue(config.CustomizedMetricSpecification.Dimensions[0].Name) != "Env" {
+		t.Errorf("unexpected dimensions: %v", config.CustomizedMetricSpecification.Dimensions)
+	}
+	p := as.getTargetTrackingPolicy(custom.PolicyName, config)
+	if p.Metric != "custom" || p.CustomMetric.MetricName != "QueueDepth" || p.CustomMetric.Dimensions["Queue"] != "jobs" || p.TargetValue != 100 {
+		t.Errorf("unexpected policy: %+v", p)
+	}
+}
+
+func TestIsAWSErrorCode(t *testing.T) {
+	err := awserr.New(applicationautoscaling.ErrCodeObjectNotFoundException, "No scaling policy found", nil)
+	if !isAWSErrorCode(err, applicationautoscaling.ErrCodeObjectNotFoundException) {
+		t.Errorf("expected ObjectNotFoundException")
+	}
+	if isAWSErrorCode(err, cloudwatch.ErrCodeResourceNotFound) || isAWSErrorCode(errors.New("ResourceNotFound"), cloudwatch.ErrCodeResourceNotFound) || isAWSErrorCode(nil, cloudwatch.ErrCodeResourceNotFound) {
+		t.Errorf("unexpected error code match")
+	}
+}
@@ -0,0 +1,144 @@
+package ecs
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// GetCapacityProviderName returns the name of the capacity provider of a cluster (names can't start with aws, ecs or fargate)
+func GetCapacityProviderName(clusterName string) string {
+	return "asg-" + clusterName
+}
+
+// CreateCapacityProvider creates a capacity provider for the autoscaling group with managed scaling and managed termination protection
+func (e *ECS) CreateCapacityProvider(name, autoScalingGroupArn string, targetCapacity int64) error {
+	svc := ecs.New(session.New())
+	input := &ecs.CreateCapacityProviderInput{
+		Name: aws.String(name),
+		AutoScalingGroupProvider: &ecs.AutoScalingGroupProvider{
+			AutoScalingGroupArn: aws.String(autoScalingGroupArn),
+			ManagedScaling: &ecs.ManagedScaling{
+				Status:         aws.String(ecs.ManagedScalingStatusEnabled),
+				TargetCapacity: aws.Int64(targetCapacity),
+			},
+			ManagedTerminationProtection: aws.String(ecs.ManagedTerminationProtectionEnabled),
+		},
+	}
+	_, err := svc.CreateCapacityProvider(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// PutClusterCapacityProviders sets the capacity providers of the cluster, the first one is the default capacity provider strategy
+func (e *ECS) PutClusterCapacityProviders(clusterName string, capacityProviders []string) error {
+	svc := ecs.New(session.New())
+	input := &ecs.PutClusterCapacityProvidersInput{
+		Cluster:                         aws.String(clusterName),
+		CapacityProviders:               aws.StringSlice(capacityProviders),
+		DefaultCapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{},
+	}
+	if len(capacityProviders) > 0 {
+		input.DefaultCapacityProviderStrategy = []*ecs.CapacityProviderStrategyItem{
+			{
+				CapacityProvider: aws.String(capacityProviders[0]),
+				Weight:           aws.Int64(1),
+			},
+		}
+	}
+	_, err := svc.PutClusterCapacityProviders(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// GetClusterCapacityProviders returns the names of the capacity providers of the cluster
+func (e *ECS) GetClusterCapacityProviders(clusterName string) ([]string, error) {
+	svc := ecs.New(session.New())
+	input := &ecs.DescribeClustersInput{
+		Clusters: aws.StringSlice([]string{clusterName}),
+	}
+	result, err := svc.DescribeClusters(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return nil, err
+	}
+	if len(result.Clusters) == 0 {
+		return nil, nil
+	}
+	return aws.StringValueSlice(result.Clusters[0].CapacityProviders), nil
+}
+
+// IsManagedScalingEnabled returns true when the cluster has an autoscaling group capacity provider with managed scaling
+func (e *ECS) IsManagedScalingEnabled(clusterName string) (bool, error) {
+	capacityProviders, err := e.GetClusterCapacityProviders(clusterName)
+	if err != nil {
+		return false, err
+	}
+	var names []string
+	for _, v := range capacityProviders {
+		// fargate capacity providers don't have an autoscaling group
+		if !strings.HasPrefix(v, "FARGATE") {
+			names = append(names, v)
+		}
+	}
+	if len(names) == 0 {
+		return false, nil
+	}
+	svc := ecs.New(session.New())
+	result, err := svc.DescribeCapacityProviders(&ecs.DescribeCapacityProvidersInput{
+		CapacityProviders: aws.StringSlice(names),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return false, err
+	}
+	for _, v := range result.CapacityProviders {
+		if v.AutoScalingGroupProvider != nil && v.AutoScalingGroupProvider.ManagedScaling != nil && aws.StringValue(v.AutoScalingGroupProvider.ManagedScaling.Status) == ecs.ManagedScalingStatusEnabled {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteCapacityProvider deletes a capacity provider, it needs to be removed from the cluster first
+func (e *ECS) DeleteCapacityProvider(name string) error {
+	svc := ecs.New(session.New())
+	input := &ecs.DeleteCapacityProviderInput{
+		CapacityProvider: aws.String(name),
+	}
+	_, err := svc.DeleteCapacityProvider(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return err
+	}
+	return nil
+}
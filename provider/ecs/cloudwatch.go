@@ -18,7 +18,9 @@ type CloudWatchLog struct {
 	LogEvents         []CloudWatchLogEvent `json:"logEvents"`
 }
 type CloudWatchLogEvent struct {
+	EventId       string    `json:"eventId,omitempty"`
 	IngestionTime time.Time `json:"ingestionTime"`
+	LogStreamName string    `json:"logStreamName,omitempty"`
 	Message       string    `json:"message"`
 	Timestamp     time.Time `json:"timestamp"`
 }
@@ -26,6 +28,11 @@ type CloudWatchLogEvent struct {
 // logging
 var cloudwatchLogger = loggo.GetLogger("cloudwatch")
 
+// CloudWatchIf deletes the alarms of step scaling policies
+type CloudWatchIf interface {
+	DeleteAlarms(alarmNames []string) error
+}
+
 type CloudWatch struct{}
 
 func (cloudwatch *CloudWatch) CreateLogGroup(clusterName, logGroup string) error {
@@ -102,6 +109,45 @@ func (cloudwatch *CloudWatch) GetLogEventsByTime(logGroup, logStream string, sta
 	return logEvents, nil
 }
 
+// FilterLogEvents returns the log events of multiple log streams (max 100), interleaved.
+// The next token is returned in NextForwardToken
+func (cloudwatch *CloudWatch) FilterLogEvents(logGroup string, logStreams []string, filterPattern string, startTime time.Time, nextToken string) (CloudWatchLog, error) {
+	var logEvents CloudWatchLog
+	svc := cloudwatchlogs.New(session.New())
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:   aws.String(logGroup),
+		LogStreamNames: aws.StringSlice(logStreams),
+		StartTime:      aws.Int64(startTime.UnixNano() / 1000000),
+	}
+	if filterPattern != "" {
+		input.SetFilterPattern(filterPattern)
+	}
+	if nextToken != "" {
+		input.SetNextToken(nextToken)
+	}
+
+	result, err := svc.FilterLogEvents(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return logEvents, err
+	}
+	logEvents.NextForwardToken = aws.StringValue(result.NextToken)
+	for _, v := range result.Events {
+		var l CloudWatchLogEvent
+		l.EventId = aws.StringValue(v.EventId)
+		l.IngestionTime = time.Unix(0, aws.Int64Value(v.IngestionTime)*1000000)
+		l.LogStreamName = aws.StringValue(v.LogStreamName)
+		l.Timestamp = time.Unix(0, aws.Int64Value(v.Timestamp)*1000000)
+		l.Message = aws.StringValue(v.Message)
+		logEvents.LogEvents = append(logEvents.LogEvents, l)
+	}
+	return logEvents, nil
+}
+
 func (c *CloudWatch) PutMetricAlarm(serviceName, clusterName, alarmName string, alarmActions []string, alarmDescription string, datapointsToAlarm int64, metricName string, namespace string, period int64, threshold float64, comparisonOperator string, statistic string, evaluationPeriods int64) error {
 	svc := cloudwatch.New(session.New())
 	input := &cloudwatch.PutMetricAlarmInput{
@@ -175,6 +221,8 @@ func (c *CloudWatch) DescribeAlarms(alarmNames []string) ([]service.AutoscalingP
 	return aps, nil
 }
 
+// DeleteAlarms deletes the metric alarms. Alarms that don't exist (e.g. of target tracking policies) are ignored,
+// CloudWatch doesn't delete any alarm when one of them doesn't exist
 func (c *CloudWatch) DeleteAlarms(alarmNames []string) error {
 	svc := cloudwatch.New(session.New())
 
@@ -183,6 +231,19 @@ func (c *CloudWatch) DeleteAlarms(alarmNames []string) error {
 	}
 
 	_, err := svc.DeleteAlarms(input)
+	if isAWSErrorCode(err, cloudwatch.ErrCodeResourceNotFound) {
+		if len(alarmNames) == 1 {
+			cloudwatchLogger.Debugf("Alarm %v doesn't exist", alarmNames[0])
+			return nil
+		}
+		// delete the alarms one by one, to skip the ones that don't exist
+		for _, alarmName := range alarmNames {
+			if err = c.DeleteAlarms([]string{alarmName}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			ecsLogger.Errorf("%v", aerr.Error())
@@ -193,3 +254,31 @@ func (c *CloudWatch) DeleteAlarms(alarmNames []string) error {
 	}
 	return nil
 }
+
+// GetMetricAlarms returns the full metric alarms
+func (c *CloudWatch) GetMetricAlarms(alarmNames []string) ([]*cloudwatch.MetricAlarm, error) {
+	var metricAlarms []*cloudwatch.MetricAlarm
+	if len(alarmNames) == 0 {
+		return metricAlarms, nil
+	}
+	svc := cloudwatch.New(session.New())
+	input := &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: aws.StringSlice(alarmNames),
+	}
+	pageNum := 0
+	err := svc.DescribeAlarmsPages(input,
+		func(page *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+			pageNum++
+			metricAlarms = append(metricAlarms, page.MetricAlarms...)
+			return pageNum <= 100
+		})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf("%v", aerr.Error())
+		} else {
+			ecsLogger.Errorf("%v", err.Error())
+		}
+		return metricAlarms, err
+	}
+	return metricAlarms, nil
+}
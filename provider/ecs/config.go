@@ -0,0 +1,33 @@
+package ecs
+
+// Config contains the server settings the provider uses, the api sets them at startup with SetConfig
+type Config struct {
+	Region                           string
+	AccountEnv                       string
+	EcsServiceRole                   string
+	DeployMaxWaitSeconds             int64
+	DefaultContainerCpuLimit         int64
+	EcrScanOnPush                    bool
+	CloudwatchLogsEnabled            bool
+	CloudwatchLogsPrefix             string
+	ServiceDiscoveryTTL              int64
+	ServiceDiscoveryFailureThreshold int64
+	ParamstoreEnabled                bool
+	ParamstorePrefix                 string
+	ParamstoreEnvPath                string
+	ParamstoreKmsArn                 string
+	ParamstoreInject                 bool
+}
+
+// providerConfig has the defaults of the server configuration until SetConfig is called
+var providerConfig = Config{
+	EcsServiceRole:                   "ecs-service-role",
+	DeployMaxWaitSeconds:             900,
+	ServiceDiscoveryTTL:              60,
+	ServiceDiscoveryFailureThreshold: 1,
+}
+
+// SetConfig sets the server settings the provider uses
+func SetConfig(c Config) {
+	providerConfig = c
+}
@@ -6,7 +6,9 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/juju/loggo"
 
+	"encoding/base64"
 	"fmt"
+	"strconv"
 )
 
 // logging
@@ -16,6 +18,10 @@ var ec2Logger = loggo.GetLogger("ec2")
 type EC2 struct {
 }
 
+type EC2If interface {
+	GetInstanceImageIds(instanceIds []string) (map[string]string, error)
+}
+
 /*
  * GetSecurityGroupID retrieves the id from the security group based on the name
  */
@@ -63,6 +69,115 @@ func (e *EC2) CreateSecurityGroup(name, description, vpcID string) (string, erro
 	return aws.StringValue(result.GroupId), nil
 }
 
+/*
+ * CreateLaunchTemplate creates the launch template of the cluster instances with the ECS optimized AMI
+ */
+func (e *EC2) CreateLaunchTemplate(clusterName, keyName, instanceType, instanceProfile string, securityGroups []string) error {
+	ecs := ECS{}
+	svc := ec2.New(session.New())
+	amiId, err := ecs.GetECSAMI()
+	if err != nil {
+		return err
+	}
+	input := &ec2.CreateLaunchTemplateInput{
+		LaunchTemplateName: aws.String(clusterName),
+		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
+			IamInstanceProfile: &ec2.LaunchTemplateIamInstanceProfileSpecificationRequest{
+				Name: aws.String(instanceProfile),
+			},
+			ImageId:          aws.String(amiId),
+			InstanceType:     aws.String(instanceType),
+			KeyName:          aws.String(keyName),
+			SecurityGroupIds: aws.StringSlice(securityGroups),
+			UserData:         aws.String(base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho 'ECS_CLUSTER=" + clusterName + "'  > /etc/ecs/ecs.config\nstart ecs\n"))),
+		},
+	}
+	ec2Logger.Debugf("createLaunchTemplate with: %+v", input)
+	_, err = svc.CreateLaunchTemplate(input)
+	if err != nil {
+		ec2Logger.Errorf("%v", err.Error())
+		return err
+	}
+	return nil
+}
+
+/*
+ * CreateLaunchTemplateVersion creates a new version of the launch template with another AMI
+ */
+func (e *EC2) CreateLaunchTemplateVersion(launchTemplateName, amiId string) (int64, error) {
+	svc := ec2.New(session.New())
+	input := &ec2.CreateLaunchTemplateVersionInput{
+		LaunchTemplateName: aws.String(launchTemplateName),
+		SourceVersion:      aws.String("$Latest"),
+		VersionDescription: aws.String("ecs-deploy AMI upgrade to " + amiId),
+		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
+			ImageId: aws.String(amiId),
+		},
+	}
+	result, err := svc.CreateLaunchTemplateVersion(input)
+	if err != nil {
+		ec2Logger.Errorf("%v", err.Error())
+		return 0, err
+	}
+	return aws.Int64Value(result.LaunchTemplateVersion.VersionNumber), nil
+}
+
+/*
+ * SetDefaultLaunchTemplateVersion sets the default version of the launch template
+ */
+func (e *EC2) SetDefaultLaunchTemplateVersion(launchTemplateName string, version int64) error {
+	svc := ec2.New(session.New())
+	input := &ec2.ModifyLaunchTemplateInput{
+		LaunchTemplateName: aws.String(launchTemplateName),
+		DefaultVersion:     aws.String(strconv.FormatInt(version, 10)),
+	}
+	_, err := svc.ModifyLaunchTemplate(input)
+	if err != nil {
+		ec2Logger.Errorf("%v", err.Error())
+		return err
+	}
+	return nil
+}
+
+/*
+ * GetInstanceImageIds returns the AMI of every instance
+ */
+func (e *EC2) GetInstanceImageIds(instanceIds []string) (map[string]string, error) {
+	svc := ec2.New(session.New())
+	imageIds := make(map[string]string)
+	input := &ec2.DescribeInstancesInput{
+		InstanceIds: aws.StringSlice(instanceIds),
+	}
+	err := svc.DescribeInstancesPages(input, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				imageIds[aws.StringValue(instance.InstanceId)] = aws.StringValue(instance.ImageId)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		ec2Logger.Errorf("%v", err.Error())
+		return imageIds, err
+	}
+	return imageIds, nil
+}
+
+/*
+ * DeleteLaunchTemplate deletes the launch template of the cluster instances
+ */
+func (e *EC2) DeleteLaunchTemplate(clusterName string) error {
+	svc := ec2.New(session.New())
+	input := &ec2.DeleteLaunchTemplateInput{
+		LaunchTemplateName: aws.String(clusterName),
+	}
+	_, err := svc.DeleteLaunchTemplate(input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 /*
  * DeleteSecurityGroup deletes a security group
  */
@@ -1,13 +1,19 @@
 package ecs
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecr"
-	"github.com/in4it/ecs-deploy/util"
+	"github.com/in4it/ecs-deploy/service"
 	"github.com/juju/loggo"
 )
 
@@ -20,11 +26,14 @@ type ECR struct {
 }
 
 // Creates ECR repository
-func (e *ECR) CreateRepository() error {
-	scanOnPush, err := strconv.ParseBool(util.GetEnv("ECR_SCAN_ON_PUSH", "false"))
-	if err != nil {
-		ecrLogger.Errorf(err.Error())
-		scanOnPush = false
+func (e *ECR) CreateRepository(settings service.RepositorySettings) error {
+	scanOnPush := providerConfig.EcrScanOnPush
+	if settings.ScanOnPush != nil {
+		scanOnPush = *settings.ScanOnPush
+	}
+	// validate the lifecycle rules before the repository is created
+	if _, err := getLifecyclePolicyText(settings.LifecycleRules); err != nil {
+		return err
 	}
 	svc := ecr.New(session.New())
 	input := &ecr.CreateRepositoryInput{
@@ -33,40 +42,343 @@ func (e *ECR) CreateRepository() error {
 			ScanOnPush: aws.Bool(scanOnPush),
 		},
 	}
+	if settings.TagImmutability != nil && *settings.TagImmutability {
+		input.SetImageTagMutability(ecr.ImageTagMutabilityImmutable)
+	}
+	if settings.KmsKey != "" {
+		input.SetEncryptionConfiguration(&ecr.EncryptionConfiguration{
+			EncryptionType: aws.String(ecr.EncryptionTypeKms),
+			KmsKey:         aws.String(settings.KmsKey),
+		})
+	}
 
 	res, err := svc.CreateRepository(input)
-	if err == nil && res.Repository.RepositoryUri != nil {
-		e.RepositoryURI = *res.Repository.RepositoryUri
-
-		lifecyclePolicyText := `{
-			"rules": [
-				{
-					"rulePriority": 10,
-					"description": "cleanup",
-					"selection": {
-						"tagStatus": "any",
-						"countType": "imageCountMoreThan",
-						"countNumber": 100
-					},
-					"action": {
-						"type": "expire"
-					}
-				}
-			]
-		}`
+	if err != nil {
+		return err
+	}
+	if res.Repository.RepositoryUri == nil {
+		return errors.New("repository created without uri")
+	}
+	e.RepositoryURI = *res.Repository.RepositoryUri
+
+	// the default lifecycle policy is always set on new repositories
+	if settings.LifecycleRules == nil {
+		settings.LifecycleRules = []service.RepositoryLifecycleRule{}
+	}
+	return e.putRepositorySettings(svc, aws.StringValue(res.Repository.RegistryId), settings)
+}
 
-		lifecycleInput := &ecr.PutLifecyclePolicyInput{
+// UpdateRepository updates the settings of an existing repository, settings that are not set are left unchanged
+func (e *ECR) UpdateRepository(settings service.RepositorySettings) error {
+	svc := ecr.New(session.New())
+	res, err := svc.DescribeRepositories(&ecr.DescribeRepositoriesInput{
+		RepositoryNames: aws.StringSlice([]string{e.RepositoryName}),
+	})
+	if err != nil {
+		return err
+	}
+	if len(res.Repositories) == 0 {
+		return errors.New("repository not found")
+	}
+	repository := res.Repositories[0]
+	e.RepositoryURI = aws.StringValue(repository.RepositoryUri)
+
+	if settings.KmsKey != "" && (repository.EncryptionConfiguration == nil || aws.StringValue(repository.EncryptionConfiguration.KmsKey) != settings.KmsKey) {
+		return errors.New("the encryption of a repository can only be set when the repository is created")
+	}
+	if settings.TagImmutability != nil {
+		mutability := ecr.ImageTagMutabilityMutable
+		if *settings.TagImmutability {
+			mutability = ecr.ImageTagMutabilityImmutable
+		}
+		_, err = svc.PutImageTagMutability(&ecr.PutImageTagMutabilityInput{
+			RepositoryName:     aws.String(e.RepositoryName),
+			ImageTagMutability: aws.String(mutability),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if settings.ScanOnPush != nil {
+		_, err = svc.PutImageScanningConfiguration(&ecr.PutImageScanningConfigurationInput{
+			RepositoryName:             aws.String(e.RepositoryName),
+			ImageScanningConfiguration: &ecr.ImageScanningConfiguration{ScanOnPush: settings.ScanOnPush},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return e.putRepositorySettings(svc, aws.StringValue(repository.RegistryId), settings)
+}
+
+// putRepositorySettings puts the lifecycle policy, repository policy and replication when set
+func (e *ECR) putRepositorySettings(svc *ecr.ECR, registryId string, settings service.RepositorySettings) error {
+	if settings.LifecycleRules != nil {
+		lifecyclePolicyText, err := getLifecyclePolicyText(settings.LifecycleRules)
+		if err != nil {
+			return err
+		}
+		_, err = svc.PutLifecyclePolicy(&ecr.PutLifecyclePolicyInput{
 			RepositoryName:      aws.String(e.RepositoryName),
 			LifecyclePolicyText: aws.String(lifecyclePolicyText),
-			RegistryId:          aws.String(*res.Repository.RegistryId),
+			RegistryId:          aws.String(registryId),
+		})
+		if err != nil {
+			return err
 		}
+	}
+	if settings.PullAccounts != nil {
+		if len(settings.PullAccounts) == 0 {
+			_, err := svc.DeleteRepositoryPolicy(&ecr.DeleteRepositoryPolicyInput{
+				RepositoryName: aws.String(e.RepositoryName),
+			})
+			if err != nil {
+				if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != ecr.ErrCodeRepositoryPolicyNotFoundException {
+					return err
+				}
+			}
+		} else {
+			policyText, err := getPullRepositoryPolicyText(settings.PullAccounts)
+			if err != nil {
+				return err
+			}
+			_, err = svc.SetRepositoryPolicy(&ecr.SetRepositoryPolicyInput{
+				RepositoryName: aws.String(e.RepositoryName),
+				PolicyText:     aws.String(policyText),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if settings.Replication != nil {
+		return e.putReplication(svc, registryId, settings.Replication)
+	}
+	return nil
+}
+
+// getLifecyclePolicyText returns the lifecycle policy for the rules, without rules the last 100 images are kept
+func getLifecyclePolicyText(rules []service.RepositoryLifecycleRule) (string, error) {
+	type selection struct {
+		TagStatus     string   `json:"tagStatus"`
+		TagPrefixList []string `json:"tagPrefixList,omitempty"`
+		CountType     string   `json:"countType"`
+		CountUnit     string   `json:"countUnit,omitempty"`
+		CountNumber   int64    `json:"countNumber"`
+	}
+	type action struct {
+		Type string `json:"type"`
+	}
+	type rule struct {
+		RulePriority int64     `json:"rulePriority"`
+		Description  string    `json:"description"`
+		Selection    selection `json:"selection"`
+		Action       action    `json:"action"`
+	}
+	if len(rules) == 0 {
+		rules = []service.RepositoryLifecycleRule{
+			{Description: "cleanup", TagStatus: "any", CountType: "imageCountMoreThan", CountNumber: 100},
+		}
+	}
+	var policy struct {
+		Rules []rule `json:"rules"`
+	}
+	for k, r := range rules {
+		if r.TagStatus != "tagged" && r.TagStatus != "untagged" && r.TagStatus != "any" {
+			return "", fmt.Errorf("lifecycle rule %d: tagStatus needs to be tagged, untagged or any", k+1)
+		}
+		if r.TagStatus == "tagged" && len(r.TagPrefixes) == 0 {
+			return "", fmt.Errorf("lifecycle rule %d: tagPrefixes are required when tagStatus is tagged", k+1)
+		}
+		if r.CountNumber < 1 {
+			return "", fmt.Errorf("lifecycle rule %d: countNumber needs to be at least 1", k+1)
+		}
+		s := selection{TagStatus: r.TagStatus, TagPrefixList: r.TagPrefixes, CountType: r.CountType, CountNumber: r.CountNumber}
+		switch r.CountType {
+		case "imageCountMoreThan":
+		case "sinceImagePushed":
+			s.CountUnit = "days"
+		default:
+			return "", fmt.Errorf("lifecycle rule %d: countType needs to be imageCountMoreThan or sinceImagePushed", k+1)
+		}
+		description := r.Description
+		if description == "" {
+			description = "rule " + strconv.Itoa(k+1)
+		}
+		// rules with tagStatus any need to have the highest priority value
+		priority := int64((k + 1) * 10)
+		if r.TagStatus == "any" {
+			priority += 1000
+		}
+		policy.Rules = append(policy.Rules, rule{RulePriority: priority, Description: description, Selection: s, Action: action{Type: "expire"}})
+	}
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// getPullRepositoryPolicyText returns a repository policy that allows the accounts to pull images
+func getPullRepositoryPolicyText(accounts []string) (string, error) {
+	type principal struct {
+		AWS []string `json:"AWS"`
+	}
+	type statement struct {
+		Sid       string    `json:"Sid"`
+		Effect    string    `json:"Effect"`
+		Principal principal `json:"Principal"`
+		Action    []string  `json:"Action"`
+	}
+	var policy struct {
+		Version   string      `json:"Version"`
+		Statement []statement `json:"Statement"`
+	}
+	pull := statement{
+		Sid:    "ecs-deploy-pull",
+		Effect: "Allow",
+		Action: []string{"ecr:BatchCheckLayerAvailability", "ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"},
+	}
+	for _, account := range accounts {
+		if !accountIdRegexp.MatchString(account) {
+			return "", fmt.Errorf("invalid pull account %q: an account id has 12 digits", account)
+		}
+		pull.Principal.AWS = append(pull.Principal.AWS, "arn:aws:iam::"+account+":root")
+	}
+	policy.Version = "2012-10-17"
+	policy.Statement = []statement{pull}
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
 
-		_, err := svc.PutLifecyclePolicy(lifecycleInput)
+var accountIdRegexp = regexp.MustCompile(`^[0-9]{12}$`)
+
+// limits of the replication configuration of a registry
+const (
+	maxReplicationRules   = 10
+	maxReplicationFilters = 100
+)
+
+// the replication configuration is registry wide, the updates of ecs-deploy are serialized.
+// Changes made outside ecs-deploy between the describe and the put are overwritten
+var replicationMu sync.Mutex
+
+// putReplication replaces the replication rule of the repository in the (registry wide) replication configuration
+func (e *ECR) putReplication(svc *ecr.ECR, registryId string, destinations []service.RepositoryReplicationDestination) error {
+	replicationMu.Lock()
+	defer replicationMu.Unlock()
+	registry, err := svc.DescribeRegistry(&ecr.DescribeRegistryInput{})
+	if err != nil {
 		return err
-	} else {
+	}
+	var current []*ecr.ReplicationRule
+	if registry.ReplicationConfiguration != nil {
+		current = registry.ReplicationConfiguration.Rules
+	}
+	rules, err := getReplicationRules(current, e.RepositoryName, registryId, destinations)
+	if err != nil {
 		return err
 	}
+	_, err = svc.PutReplicationConfiguration(&ecr.PutReplicationConfigurationInput{
+		ReplicationConfiguration: &ecr.ReplicationConfiguration{Rules: rules},
+	})
+	if err != nil {
+		return err
+	}
+	if len(destinations) > 0 {
+		e.warnReplicationPrefixMatches(svc)
+	}
+	return nil
+}
+
+// warnReplicationPrefixMatches logs the other repositories that are replicated by the filter of the repository:
+// ECR only supports prefix filters, so the filter also matches repositories that start with the repository name
+func (e *ECR) warnReplicationPrefixMatches(svc *ecr.ECR) {
+	var matches []string
+	err := svc.DescribeRepositoriesPages(&ecr.DescribeRepositoriesInput{}, func(page *ecr.DescribeRepositoriesOutput, lastPage bool) bool {
+		for _, repository := range page.Repositories {
+			name := aws.StringValue(repository.RepositoryName)
+			if name != e.RepositoryName && strings.HasPrefix(name, e.RepositoryName) {
+				matches = append(matches, name)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		ecrLogger.Debugf("Couldn't list the repositories that match the replication filter of %v: %v", e.RepositoryName, err)
+		return
+	}
+	if len(matches) > 0 {
+		ecrLogger.Warningf("The replication filter of %v also replicates the repositories %v", e.RepositoryName, strings.Join(matches, ", "))
+	}
+}
+
+// getReplicationRules returns the replication rules with the filter of the repository replaced.
+// Repositories with the same destinations share a rule, a registry has at most 10 rules
+func getReplicationRules(current []*ecr.ReplicationRule, repositoryName, registryId string, destinations []service.RepositoryReplicationDestination) ([]*ecr.ReplicationRule, error) {
+	var rules []*ecr.ReplicationRule
+	for _, rule := range current {
+		var filters []*ecr.RepositoryFilter
+		for _, filter := range rule.RepositoryFilters {
+			if aws.StringValue(filter.Filter) != repositoryName {
+				filters = append(filters, filter)
+			}
+		}
+		// a rule without filters replicates all repositories, it's only dropped when the repository was its last filter
+		if len(rule.RepositoryFilters) > 0 && len(filters) == 0 {
+			continue
+		}
+		rules = append(rules, &ecr.ReplicationRule{Destinations: rule.Destinations, RepositoryFilters: filters})
+	}
+	if len(destinations) == 0 {
+		return rules, nil
+	}
+	var replicationDestinations []*ecr.ReplicationDestination
+	for _, d := range destinations {
+		if d.RegistryId == "" {
+			d.RegistryId = registryId
+		}
+		replicationDestinations = append(replicationDestinations, &ecr.ReplicationDestination{
+			Region:     aws.String(d.Region),
+			RegistryId: aws.String(d.RegistryId),
+		})
+	}
+	// ECR only supports prefix filters, the filter also matches repositories that start with the repository name
+	filter := &ecr.RepositoryFilter{Filter: aws.String(repositoryName), FilterType: aws.String(ecr.RepositoryFilterTypePrefixMatch)}
+	for _, rule := range rules {
+		if len(rule.RepositoryFilters) > 0 && len(rule.RepositoryFilters) < maxReplicationFilters && sameReplicationDestinations(rule.Destinations, replicationDestinations) {
+			rule.RepositoryFilters = append(rule.RepositoryFilters, filter)
+			return rules, nil
+		}
+	}
+	if len(rules) >= maxReplicationRules {
+		return nil, fmt.Errorf("the replication configuration of the registry already has the maximum of %d rules", maxReplicationRules)
+	}
+	return append(rules, &ecr.ReplicationRule{Destinations: replicationDestinations, RepositoryFilters: []*ecr.RepositoryFilter{filter}}), nil
+}
+
+// sameReplicationDestinations returns whether both lists have the same destinations, in any order
+func sameReplicationDestinations(a, b []*ecr.ReplicationDestination) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	destinations := make(map[string]int)
+	for _, d := range a {
+		destinations[aws.StringValue(d.RegistryId)+"/"+aws.StringValue(d.Region)]++
+	}
+	for _, d := range b {
+		key := aws.StringValue(d.RegistryId) + "/" + aws.StringValue(d.Region)
+		if destinations[key] == 0 {
+			return false
+		}
+		destinations[key]--
+	}
+	return true
 }
+
 func (e *ECR) ListImagesWithTag(repositoryName string) (map[string]string, error) {
 	svc := ecr.New(session.New())
 
@@ -133,3 +445,67 @@ func (e *ECR) RepositoryExists(repositoryName string) (bool, error) {
 	}
 	return exists, nil
 }
+
+// GetImageScanFindings returns the scan status and the findings of an image, by digest or (when the digest is empty) by tag
+func (e *ECR) GetImageScanFindings(repositoryName, imageDigest, imageTag string) (string, []service.ImageScanFinding, error) {
+	svc := ecr.New(session.New())
+
+	var status string
+	var findings []service.ImageScanFinding
+
+	imageId := &ecr.ImageIdentifier{}
+	if imageDigest != "" {
+		imageId.SetImageDigest(imageDigest)
+	} else {
+		imageId.SetImageTag(imageTag)
+	}
+	input := &ecr.DescribeImageScanFindingsInput{
+		RepositoryName: aws.String(repositoryName),
+		ImageId:        imageId,
+	}
+
+	err := svc.DescribeImageScanFindingsPages(input,
+		func(page *ecr.DescribeImageScanFindingsOutput, lastPage bool) bool {
+			if page.ImageScanStatus != nil {
+				status = aws.StringValue(page.ImageScanStatus.Status)
+			}
+			if page.ImageScanFindings == nil {
+				return true
+			}
+			for _, finding := range page.ImageScanFindings.Findings {
+				findings = append(findings, service.ImageScanFinding{
+					Name:     aws.StringValue(finding.Name),
+					Severity: aws.StringValue(finding.Severity),
+					Uri:      aws.StringValue(finding.Uri),
+				})
+			}
+			// enhanced scanning (inspector)
+			for _, finding := range page.ImageScanFindings.EnhancedFindings {
+				f := service.ImageScanFinding{
+					Name:     aws.StringValue(finding.Title),
+					Severity: aws.StringValue(finding.Severity),
+				}
+				if finding.PackageVulnerabilityDetails != nil {
+					f.Name = aws.StringValue(finding.PackageVulnerabilityDetails.VulnerabilityId)
+					f.Uri = aws.StringValue(finding.PackageVulnerabilityDetails.SourceUrl)
+				}
+				findings = append(findings, f)
+			}
+			return true
+		})
+
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case ecr.ErrCodeScanNotFoundException:
+				return "NOT_FOUND", findings, nil
+			default:
+				ecrLogger.Errorf(aerr.Error())
+			}
+		} else {
+			ecrLogger.Errorf(err.Error())
+		}
+		return status, findings, err
+	}
+	return status, findings, nil
+}
@@ -1,9 +1,15 @@
 package ecs
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/in4it/ecs-deploy/service"
 	"github.com/in4it/ecs-deploy/util"
 )
 
@@ -32,3 +38,127 @@ func TestRepositoryExists(t *testing.T) {
 		return
 	}
 }
+func TestGetLifecyclePolicyText(t *testing.T) {
+	var policy struct {
+		Rules []struct {
+			RulePriority int64 `json:"rulePriority"`
+			Selection    struct {
+				TagStatus   string `json:"tagStatus"`
+				CountUnit   string `json:"countUnit"`
+				CountNumber int64  `json:"countNumber"`
+			} `json:"selection"`
+		} `json:"rules"`
+	}
+	// default policy
+	text, err := getLifecyclePolicyText(nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err = json.Unmarshal([]byte(text), &policy); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(policy.Rules) != 1 || policy.Rules[0].Selection.CountNumber != 100 {
+		t.Errorf("Unexpected default policy: %v", text)
+	}
+	// the rule with tagStatus any needs the highest priority
+	text, err = getLifecyclePolicyText([]service.RepositoryLifecycleRule{
+		{TagStatus: "any", CountType: "imageCountMoreThan", CountNumber: 50},
+		{TagStatus: "untagged", CountType: "sinceImagePushed", CountNumber: 7},
+	})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err = json.Unmarshal([]byte(text), &policy); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if policy.Rules[0].RulePriority <= policy.Rules[1].RulePriority {
+		t.Errorf("Rule with tagStatus any doesn't have the highest priority: %v", text)
+	}
+	if policy.Rules[1].Selection.CountUnit != "days" {
+		t.Errorf("countUnit not set for sinceImagePushed: %v", text)
+	}
+	// invalid rule
+	_, err = getLifecyclePolicyText([]service.RepositoryLifecycleRule{{TagStatus: "tagged", CountType: "imageCountMoreThan", CountNumber: 1}})
+	if err == nil {
+		t.Errorf("Expected error for tagged rule without prefixes")
+	}
+}
+func TestGetPullRepositoryPolicyText(t *testing.T) {
+	policyText, err := getPullRepositoryPolicyText([]string{"123456789012", "210987654321"})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	var policy struct {
+		Statement []struct {
+			Principal struct {
+				AWS []string
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(policyText), &policy); err != nil {
+		t.Fatalf("Invalid policy: %v", err)
+	}
+	if len(policy.Statement) != 1 || len(policy.Statement[0].Principal.AWS) != 2 || policy.Statement[0].Principal.AWS[1] != "arn:aws:iam::210987654321:root" {
+		t.Errorf("Unexpected policy: %v", policyText)
+	}
+	for _, account := range []string{"12345678901", "12345678901a", `123456789012"`} {
+		if _, err := getPullRepositoryPolicyText([]string{account}); err == nil {
+			t.Errorf("Expected error for account %v", account)
+		}
+	}
+}
+
+func TestGetReplicationRules(t *testing.T) {
+	newRule := func(region string, filters ...string) *ecr.ReplicationRule {
+		rule := &ecr.ReplicationRule{
+			Destinations: []*ecr.ReplicationDestination{{Region: aws.String(region), RegistryId: aws.String("123456789012")}},
+		}
+		for _, filter := range filters {
+			rule.RepositoryFilters = append(rule.RepositoryFilters, &ecr.RepositoryFilter{Filter: aws.String(filter), FilterType: aws.String(ecr.RepositoryFilterTypePrefixMatch)})
+		}
+		return rule
+	}
+	getFilters := func(rule *ecr.ReplicationRule) string {
+		var filters []string
+		for _, filter := range rule.RepositoryFilters {
+			filters = append(filters, aws.StringValue(filter.Filter))
+		}
+		return strings.Join(filters, ",")
+	}
+	current := []*ecr.ReplicationRule{newRule("eu-west-1", "web", "api"), newRule("us-east-1", "webhooks")}
+	// the repository moves to the rule with the same destinations
+	rules, err := getReplicationRules(current, "web", "123456789012", []service.RepositoryReplicationDestination{{Region: "us-east-1"}})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(rules) != 2 || getFilters(rules[0]) != "api" || getFilters(rules[1]) != "webhooks,web" {
+		t.Errorf("Unexpected rules: %v", rules)
+	}
+	if getFilters(current[0]) != "web,api" {
+		t.Errorf("Current rules are modified: %v", current)
+	}
+	// the rule is dropped when the last repository is removed
+	rules, err = getReplicationRules(current, "webhooks", "123456789012", nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(rules) != 1 || getFilters(rules[0]) != "web,api" {
+		t.Errorf("Unexpected rules: %v", rules)
+	}
+	// new destinations need a new rule
+	current = nil
+	for i := 0; i < 10; i++ {
+		current = append(current, newRule("region-"+strconv.Itoa(i), "repository-"+strconv.Itoa(i)))
+	}
+	if _, err = getReplicationRules(current, "web", "123456789012", []service.RepositoryReplicationDestination{{Region: "eu-west-1"}}); err == nil {
+		t.Errorf("Expected error for more than 10 rules")
+	}
+	// replacing the rule of a repository doesn't add a rule
+	rules, err = getReplicationRules(current, "repository-0", "123456789012", []service.RepositoryReplicationDestination{{Region: "eu-west-1"}})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(rules) != 10 {
+		t.Errorf("Expected 10 rules, got %d", len(rules))
+	}
+}
@@ -44,6 +44,13 @@ type ECS struct {
 
 type ECSIf interface {
 	GetInstanceResources(clusterName string) ([]FreeInstanceResource, []RegisteredInstanceResource, error)
+	ListContainerInstances(clusterName string) ([]string, error)
+	DescribeContainerInstances(clusterName string, containerInstances []string) ([]ContainerInstance, error)
+	GetContainerInstanceArnByInstanceId(clusterName, instanceId string) (string, error)
+	DrainNode(clusterName, instance string) error
+	WaitForDrainedNode(clusterName, containerInstanceArn string) (bool, error)
+	ListServices(clusterName string) ([]*string, error)
+	WaitUntilServicesStable(clusterName, serviceName string, maxWaitMinutes int) error
 }
 
 // Task definition and Container definition
@@ -344,11 +351,14 @@ func (e *ECS) CreateTaskDefinitionInput(d service.Deploy, secrets map[string]str
 		var imageUri string
 		if container.ContainerURI == "" {
 			if container.ContainerImage == "" {
-				imageUri = accountId + ".dkr.ecr." + util.GetEnv("AWS_REGION", "") + ".amazonaws.com" + "/" + container.ContainerName
+				imageUri = accountId + ".dkr.ecr." + providerConfig.Region + ".amazonaws.com" + "/" + container.ContainerName
 			} else {
-				imageUri = accountId + ".dkr.ecr." + util.GetEnv("AWS_REGION", "") + ".amazonaws.com" + "/" + container.ContainerImage
+				imageUri = accountId + ".dkr.ecr." + providerConfig.Region + ".amazonaws.com" + "/" + container.ContainerImage
 			}
-			if container.ContainerTag != "" {
+			// the digest pins the image that was resolved at deploy time
+			if container.ContainerDigest != "" {
+				imageUri += "@" + container.ContainerDigest
+			} else if container.ContainerTag != "" {
 				imageUri += ":" + container.ContainerTag
 			}
 		} else {
@@ -420,16 +430,16 @@ func (e *ECS) CreateTaskDefinitionInput(d service.Deploy, secrets map[string]str
 			containerDefinition.SetEntryPoint(container.ContainerEntryPoint)
 		}
 		// set cloudwacht logs if enabled
-		if util.GetEnv("CLOUDWATCH_LOGS_ENABLED", "no") == "yes" {
+		if providerConfig.CloudwatchLogsEnabled {
 			var logPrefix string
-			if util.GetEnv("CLOUDWATCH_LOGS_PREFIX", "") != "" {
-				logPrefix = util.GetEnv("CLOUDWATCH_LOGS_PREFIX", "") + "-" + util.GetEnv("AWS_ACCOUNT_ENV", "")
+			if providerConfig.CloudwatchLogsPrefix != "" {
+				logPrefix = providerConfig.CloudwatchLogsPrefix + "-" + providerConfig.AccountEnv
 			}
 			containerDefinition.SetLogConfiguration(&ecs.LogConfiguration{
 				LogDriver: aws.String("awslogs"),
 				Options: map[string]*string{
 					"awslogs-group":         aws.String(logPrefix),
-					"awslogs-region":        aws.String(util.GetEnv("AWS_REGION", "")),
+					"awslogs-region":        aws.String(providerConfig.Region),
 					"awslogs-stream-prefix": aws.String(container.ContainerName),
 				},
 			})
@@ -457,12 +467,8 @@ func (e *ECS) CreateTaskDefinitionInput(d service.Deploy, secrets map[string]str
 		if container.CPU > 0 {
 			containerDefinition.Cpu = aws.Int64(container.CPU)
 		} else {
-			if container.CPU == 0 && util.GetEnv("DEFAULT_CONTAINER_CPU_LIMIT", "") != "" {
-				defaultCpuLimit, err := strconv.ParseInt(util.GetEnv("DEFAULT_CONTAINER_CPU_LIMIT", ""), 10, 64)
-				if err != nil {
-					return err
-				}
-				containerDefinition.Cpu = aws.Int64(defaultCpuLimit)
+			if container.CPU == 0 && providerConfig.DefaultContainerCpuLimit > 0 {
+				containerDefinition.Cpu = aws.Int64(providerConfig.DefaultContainerCpuLimit)
 			}
 		}
 
@@ -477,13 +483,13 @@ func (e *ECS) CreateTaskDefinitionInput(d service.Deploy, secrets map[string]str
 				environment = append(environment, &ecs.KeyValuePair{Name: aws.String(v.Name), Value: aws.String(v.Value)})
 			}
 		}
-		if util.GetEnv("PARAMSTORE_ENABLED", "no") == "yes" {
+		if providerConfig.ParamstoreEnabled {
 			namespace := d.EnvNamespace
 			if namespace == "" {
 				namespace = e.ServiceName
 			}
-			environment = append(environment, &ecs.KeyValuePair{Name: aws.String("AWS_REGION"), Value: aws.String(util.GetEnv("AWS_REGION", ""))})
-			environment = append(environment, &ecs.KeyValuePair{Name: aws.String("AWS_ENV_PATH"), Value: aws.String("/" + util.GetEnv("PARAMSTORE_PREFIX", "") + "-" + util.GetEnv("AWS_ACCOUNT_ENV", "") + "/" + namespace + "/")})
+			environment = append(environment, &ecs.KeyValuePair{Name: aws.String("AWS_REGION"), Value: aws.String(providerConfig.Region)})
+			environment = append(environment, &ecs.KeyValuePair{Name: aws.String("AWS_ENV_PATH"), Value: aws.String("/" + providerConfig.ParamstorePrefix + "-" + providerConfig.AccountEnv + "/" + namespace + "/")})
 		}
 
 		if len(environment) > 0 {
@@ -522,7 +528,7 @@ func (e *ECS) CreateTaskDefinitionInput(d service.Deploy, secrets map[string]str
 		}
 
 		// inject parameter store entries as secrets
-		if util.GetEnv("PARAMSTORE_INJECT", "no") == "yes" {
+		if providerConfig.ParamstoreInject {
 			ecsSecrets := []*ecs.Secret{}
 			for k, v := range secrets {
 				ecsSecrets = append(ecsSecrets, &ecs.Secret{
@@ -537,7 +543,7 @@ func (e *ECS) CreateTaskDefinitionInput(d service.Deploy, secrets map[string]str
 	}
 
 	// add execution role
-	if util.GetEnv("PARAMSTORE_INJECT", "no") == "yes" {
+	if providerConfig.ParamstoreInject {
 		iam := IAM{}
 		iamExecutionRoleName := util.GetEnv("AWS_ECS_EXECUTION_ROLE", "ecs-"+d.Cluster+"-task-execution-role")
 		iamExecutionRoleArn, err := iam.RoleExists(iamExecutionRoleName)
@@ -829,6 +835,14 @@ func (e *ECS) UpdateService(serviceName string, taskDefArn *string, d service.De
 		input.SetHealthCheckGracePeriodSeconds(d.HealthCheck.GracePeriodSeconds)
 	}
 
+	// update ServiceRegistry, errors are logged and the service is updated without service registry
+	serviceRegistries, err := e.getServiceRegistries(d)
+	if err != nil {
+		ecsLogger.Warningf("Could not apply ServiceRegistry Config: %s", err.Error())
+	} else if len(serviceRegistries) > 0 {
+		input.SetServiceRegistries(serviceRegistries)
+	}
+
 	ecsLogger.Debugf("Running UpdateService with input: %+v", input)
 
 	result, err := svc.UpdateService(input)
@@ -899,6 +913,27 @@ func (e *ECS) DeleteService(clusterName, serviceName string) error {
 	return nil
 }
 
+// getServiceRegistries returns the service registry of the service, the service discovery service is created or updated
+func (e *ECS) getServiceRegistries(d service.Deploy) ([]*ecs.ServiceRegistry, error) {
+	if d.ServiceRegistry == "" || strings.ToLower(d.ServiceProtocol) == "none" {
+		return nil, nil
+	}
+	sd := ServiceDiscovery{}
+	// the deploy data of a single service doesn't have the service name
+	serviceDiscoveryServiceArn, err := sd.GetOrCreateService(e.ServiceName, d.ServiceRegistry, d.ServiceDiscovery)
+	if err != nil {
+		return nil, err
+	}
+	ecsLogger.Debugf("Applying ServiceRegistry for %s with Arn %s", e.ServiceName, serviceDiscoveryServiceArn)
+	return []*ecs.ServiceRegistry{
+		{
+			ContainerName: aws.String(e.ServiceName),
+			ContainerPort: aws.Int64(d.ServicePort),
+			RegistryArn:   aws.String(serviceDiscoveryServiceArn),
+		},
+	}, nil
+}
+
 // create service
 func (e *ECS) CreateService(d service.Deploy) error {
 	svc := ecs.New(session.New())
@@ -954,7 +989,7 @@ func (e *ECS) CreateService(d service.Deploy) error {
 		// only set role if serviceregistry is not defined
 		// only set the role if there's a loadbalancer necessary
 		if d.ServiceRegistry == "" && strings.ToLower(d.ServiceProtocol) != "none" {
-			input.SetRole(util.GetEnv("AWS_ECS_SERVICE_ROLE", "ecs-service-role"))
+			input.SetRole(providerConfig.EcsServiceRole)
 		}
 	}
 
@@ -976,35 +1011,15 @@ func (e *ECS) CreateService(d service.Deploy) error {
 	}
 
 	// set ServiceRegistry
-	if d.ServiceRegistry != "" && strings.ToLower(d.ServiceProtocol) != "none" {
-		sd := ServiceDiscovery{}
-		_, serviceDiscoveryNamespaceID, err := sd.getNamespaceArnAndId(d.ServiceRegistry)
-		if err != nil {
-			ecsLogger.Warningf("Could not apply ServiceRegistry Config: %s", err.Error())
-		} else {
-			serviceDiscoveryServiceArn, err := sd.getServiceArn(d.ServiceName, serviceDiscoveryNamespaceID)
-			if err != nil && strings.HasPrefix(err.Error(), "Service not found") {
-				// Service not found, create service in service registry
-				serviceDiscoveryServiceArn, err = sd.createService(d.ServiceName, serviceDiscoveryNamespaceID)
-			}
-			// check for error, else set service registry
-			if err != nil && !strings.HasPrefix(err.Error(), "Service not found") {
-				ecsLogger.Warningf("Could not get service from ServiceRegistry: %s", err.Error())
-			} else {
-				ecsLogger.Debugf("Applying ServiceRegistry for %s with Arn %s", e.ServiceName, serviceDiscoveryServiceArn)
-				input.SetServiceRegistries([]*ecs.ServiceRegistry{
-					{
-						ContainerName: aws.String(e.ServiceName),
-						ContainerPort: aws.Int64(d.ServicePort),
-						RegistryArn:   aws.String(serviceDiscoveryServiceArn),
-					},
-				})
-			}
-		}
+	serviceRegistries, err := e.getServiceRegistries(d)
+	if err != nil {
+		ecsLogger.Warningf("Could not apply ServiceRegistry Config: %s", err.Error())
+	} else if len(serviceRegistries) > 0 {
+		input.SetServiceRegistries(serviceRegistries)
 	}
 
 	// create service
-	_, err := svc.CreateService(input)
+	_, err = svc.CreateService(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
@@ -1074,13 +1089,8 @@ func (e *ECS) WaitUntilServicesStable(clusterName, serviceName string, maxWaitMi
 
 func (e *ECS) getMaxWaitMinutes(gracePeriodSeconds int64) int {
 	// check whether service exists, otherwise wait might give error
-	if maxWaitSecondsString := util.GetEnv("DEPLOY_MAX_WAIT_SECONDS", "900"); maxWaitSecondsString != "900" {
-		maxWaitSeconds, err := strconv.Atoi(maxWaitSecondsString)
-		if err != nil {
-			return 15
-		} else {
-			return int(math.Ceil(float64(maxWaitSeconds) / 60))
-		}
+	if maxWaitSeconds := providerConfig.DeployMaxWaitSeconds; maxWaitSeconds != 900 {
+		return int(math.Ceil(float64(maxWaitSeconds) / 60))
 	} else {
 		if gracePeriodSeconds > 0 {
 			return (1 + int(math.Ceil(float64(gracePeriodSeconds)/60/10))) * 10
@@ -1091,11 +1101,17 @@ func (e *ECS) getMaxWaitMinutes(gracePeriodSeconds int64) int {
 
 func (e *ECS) LaunchWaitUntilServicesStable(dd, ddLast *service.DynamoDeployment, notification integrations.Notification) error {
 	var failed bool
+	logger := util.WithFields(ecsLogger, util.Fields{
+		"service":        dd.ServiceName,
+		"cluster":        dd.DeployData.Cluster,
+		"deploymentTime": dd.Time.Format(time.RFC3339Nano),
+		"requestId":      dd.RequestID,
+	})
 
 	s := service.NewService()
 	err := e.WaitUntilServicesStable(dd.DeployData.Cluster, dd.ServiceName, e.getMaxWaitMinutes(dd.DeployData.HealthCheck.GracePeriodSeconds))
 	if err != nil {
-		ecsLogger.Debugf("waitUntilServiceStable didn't succeed: %v", err)
+		logger.Debugf("waitUntilServiceStable didn't succeed: %v", err)
 		failed = true
 	}
 	// check whether deployment has latest task definition
@@ -1105,16 +1121,16 @@ func (e *ECS) LaunchWaitUntilServicesStable(dd, ddLast *service.DynamoDeployment
 	}
 	if len(runningService.Deployments) != 1 {
 		reason := "Deployment failed: deployment was still running after 10 minutes"
-		ecsLogger.Debugf(reason)
+		logger.Debugf(reason)
 		err := s.SetDeploymentStatusWithReason(dd, "failed", reason)
 		if err != nil {
 			return err
 		}
 		err = notification.LogFailure(dd.ServiceName + ": " + reason)
 		if err != nil {
-			ecsLogger.Errorf("Could not send notification: %s", err)
+			logger.Errorf("Could not send notification: %s", err)
 		}
-		err = e.Rollback(dd.DeployData.Cluster, dd.ServiceName)
+		err = e.rollback(logger, dd.DeployData.Cluster, dd.ServiceName)
 		if err != nil {
 			return err
 		}
@@ -1122,16 +1138,16 @@ func (e *ECS) LaunchWaitUntilServicesStable(dd, ddLast *service.DynamoDeployment
 	}
 	if runningService.Deployments[0].TaskDefinition != *dd.TaskDefinitionArn {
 		reason := "Deployment failed: Still running old task definition"
-		ecsLogger.Debugf(reason)
+		logger.Debugf(reason)
 		err := s.SetDeploymentStatusWithReason(dd, "failed", reason)
 		if err != nil {
 			return err
 		}
 		err = notification.LogFailure(dd.ServiceName + ": " + reason)
 		if err != nil {
-			ecsLogger.Errorf("Could not send notification: %s", err)
+			logger.Errorf("Could not send notification: %s", err)
 		}
-		err = e.Rollback(dd.DeployData.Cluster, dd.ServiceName)
+		err = e.rollback(logger, dd.DeployData.Cluster, dd.ServiceName)
 		if err != nil {
 			return err
 		}
@@ -1139,16 +1155,16 @@ func (e *ECS) LaunchWaitUntilServicesStable(dd, ddLast *service.DynamoDeployment
 	}
 	if len(runningService.Tasks) == 0 {
 		reason := "Deployment failed: no tasks running"
-		ecsLogger.Debugf(reason)
+		logger.Debugf(reason)
 		err := s.SetDeploymentStatusWithReason(dd, "failed", reason)
 		if err != nil {
 			return err
 		}
 		err = notification.LogFailure(dd.ServiceName + ": " + reason)
 		if err != nil {
-			ecsLogger.Errorf("Could not send notification: %s", err)
+			logger.Errorf("Could not send notification: %s", err)
 		}
-		err = e.Rollback(dd.DeployData.Cluster, dd.ServiceName)
+		err = e.rollback(logger, dd.DeployData.Cluster, dd.ServiceName)
 		if err != nil {
 			return err
 		}
@@ -1159,46 +1175,58 @@ func (e *ECS) LaunchWaitUntilServicesStable(dd, ddLast *service.DynamoDeployment
 		s.SetDeploymentStatusWithReason(dd, "failed", reason)
 		err = notification.LogFailure(dd.ServiceName + ": " + reason)
 		if err != nil {
-			ecsLogger.Errorf("Could not send notification: %s", err)
+			logger.Errorf("Could not send notification: %s", err)
 		}
 		return nil
 	}
+	// add the custom attributes to the instances registered by ecs
+	if dd.DeployData.ServiceRegistry != "" && len(dd.DeployData.ServiceDiscovery.Attributes) > 0 {
+		sd := ServiceDiscovery{}
+		err = sd.SetInstanceAttributes(dd.ServiceName, dd.DeployData.ServiceRegistry, dd.DeployData.ServiceDiscovery.Attributes)
+		if err != nil {
+			logger.Warningf("Could not set service discovery attributes: %v", err)
+		}
+	}
 	// set success
+	logger.Infof("Deployment of %v was successful", dd.ServiceName)
 	s.SetDeploymentStatus(dd, "success")
 	if ddLast != nil && ddLast.Status != "success" && ddLast.Status != "aborted" {
 		err = notification.LogRecovery(dd.ServiceName + ": Deployed successfully")
 		if err != nil {
-			ecsLogger.Errorf("Could not send notification: %s", err)
+			logger.Errorf("Could not send notification: %s", err)
 		}
 	}
 	return nil
 }
 func (e *ECS) Rollback(clusterName, serviceName string) error {
-	ecsLogger.Debugf("Starting rollback")
+	return e.rollback(util.WithFields(ecsLogger, util.Fields{"service": serviceName, "cluster": clusterName}), clusterName, serviceName)
+}
+func (e *ECS) rollback(logger util.FieldLogger, clusterName, serviceName string) error {
+	logger.Debugf("Starting rollback")
 	s := service.NewService()
 	s.ServiceName = serviceName
 	dd, err := s.GetDeploys("secondToLast", 1)
 	if err != nil {
-		ecsLogger.Errorf("Error: %v", err.Error())
+		logger.Errorf("Error: %v", err.Error())
 		return err
 	}
 	if len(dd) == 0 || dd[0].Status != "success" {
-		ecsLogger.Debugf("Rollback: Previous deploy was not successful")
+		logger.Debugf("Rollback: Previous deploy was not successful")
 		dd, err := s.GetDeploys("byMonth", 10)
 		if err != nil {
 			return err
 		}
-		ecsLogger.Debugf("Rollback: checking last %d deploys", len(dd))
+		logger.Debugf("Rollback: checking last %d deploys", len(dd))
 	}
 	for _, v := range dd {
-		ecsLogger.Debugf("Looping previous deployments: %v with status %v", *v.TaskDefinitionArn, v.Status)
+		logger.Debugf("Looping previous deployments: %v with status %v", *v.TaskDefinitionArn, v.Status)
 		if v.Status == "success" {
-			ecsLogger.Debugf("Rollback: rolling back to %v", *v.TaskDefinitionArn)
+			logger.Debugf("Rollback: rolling back to %v", *v.TaskDefinitionArn)
 			e.UpdateService(v.ServiceName, v.TaskDefinitionArn, *v.DeployData)
 			return nil
 		}
 	}
-	ecsLogger.Debugf("Could not rollback, no stable version found")
+	logger.Debugf("Could not rollback, no stable version found")
 	return errors.New("Could not rollback, no stable version found")
 }
 
@@ -1851,21 +1879,10 @@ func (e *ECS) GetContainerInstanceArnByInstanceId(clusterName, instanceId string
 	return "", errors.New("Couldn't find container instance Arn (instanceId=" + instanceId + ")")
 }
 func (e *ECS) LaunchWaitForDrainedNode(clusterName, containerInstanceArn, instanceId, autoScalingGroupName, lifecycleHookName, lifecycleHookToken string) error {
-	var tasksDrained bool
-	var err error
-	for i := 0; i < 80 && !tasksDrained; i++ {
-		cis, err := e.DescribeContainerInstances(clusterName, []string{containerInstanceArn})
-		if err != nil || len(cis) == 0 {
-			ecsLogger.Errorf("launchWaitForDrainedNode: %v", err.Error())
-			return err
-		}
-		ci := cis[0]
-		if ci.RunningTasksCount == 0 {
-			tasksDrained = true
-		} else {
-			ecsLogger.Infof("launchWaitForDrainedNode: still %d tasks running", ci.RunningTasksCount)
-		}
-		time.Sleep(15 * time.Second)
+	tasksDrained, err := e.WaitForDrainedNode(clusterName, containerInstanceArn)
+	if err != nil {
+		ecsLogger.Errorf("launchWaitForDrainedNode: %v", err.Error())
+		return err
 	}
 	if !tasksDrained {
 		ecsLogger.Errorf("launchWaitForDrainedNode: Not able to drain tasks: timeout of 20m reached")
@@ -1887,6 +1904,25 @@ func (e *ECS) LaunchWaitForDrainedNode(clusterName, containerInstanceArn, instan
 	return nil
 }
 
+// WaitForDrainedNode waits (max 20 minutes) until no tasks are running on the container instance
+func (e *ECS) WaitForDrainedNode(clusterName, containerInstanceArn string) (bool, error) {
+	for i := 0; i < 80; i++ {
+		cis, err := e.DescribeContainerInstances(clusterName, []string{containerInstanceArn})
+		if err != nil {
+			return false, err
+		}
+		if len(cis) == 0 {
+			return false, errors.New("Container instance " + containerInstanceArn + " not found")
+		}
+		if cis[0].RunningTasksCount == 0 {
+			return true, nil
+		}
+		ecsLogger.Infof("waitForDrainedNode: still %d tasks running", cis[0].RunningTasksCount)
+		time.Sleep(15 * time.Second)
+	}
+	return false, nil
+}
+
 // list services
 func (e *ECS) ListServices(clusterName string) ([]*string, error) {
 	svc := ecs.New(session.New())
@@ -1913,3 +1949,74 @@ func (e *ECS) ListServices(clusterName string) ([]*string, error) {
 	}
 	return services, err
 }
+
+// GetServiceDefinition returns the full service definition as returned by the ECS API
+func (e *ECS) GetServiceDefinition(clusterName, serviceName string) (*ecs.Service, error) {
+	svc := ecs.New(session.New())
+	input := &ecs.DescribeServicesInput{
+		Cluster:  aws.String(clusterName),
+		Services: aws.StringSlice([]string{serviceName}),
+	}
+	result, err := svc.DescribeServices(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf(aerr.Error())
+		} else {
+			ecsLogger.Errorf(err.Error())
+		}
+		return nil, err
+	}
+	if len(result.Services) == 0 {
+		return nil, errors.New("Service not found: " + serviceName)
+	}
+	return result.Services[0], nil
+}
+
+// GetTaskDefinitionDetails returns the full task definition as returned by the ECS API
+// GetTaskDefinitionHostPorts returns the static host ports of a task definition, tasks with dynamic host ports or awsvpc networking don't conflict on the instance
+func (e *ECS) GetTaskDefinitionHostPorts(taskDefinitionNameOrArn string) ([]int64, error) {
+	var ports []int64
+	taskDefinition, err := e.GetTaskDefinitionDetails(taskDefinitionNameOrArn)
+	if err != nil {
+		return ports, err
+	}
+	if aws.StringValue(taskDefinition.NetworkMode) == "awsvpc" {
+		return ports, nil
+	}
+	for _, containerDefinition := range taskDefinition.ContainerDefinitions {
+		for _, portMapping := range containerDefinition.PortMappings {
+			hostPort := aws.Int64Value(portMapping.HostPort)
+			// host network mode uses the container port
+			if aws.StringValue(taskDefinition.NetworkMode) == "host" && hostPort == 0 {
+				hostPort = aws.Int64Value(portMapping.ContainerPort)
+			}
+			if hostPort > 0 {
+				ports = append(ports, hostPort)
+			}
+		}
+	}
+	return ports, nil
+}
+
+func (e *ECS) GetTaskDefinitionDetails(taskDefinitionNameOrArn string) (*ecs.TaskDefinition, error) {
+	svc := ecs.New(session.New())
+	input := &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(taskDefinitionNameOrArn),
+	}
+	result, err := svc.DescribeTaskDefinition(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			ecsLogger.Errorf(aerr.Error())
+		} else {
+			ecsLogger.Errorf(err.Error())
+		}
+		return nil, err
+	}
+	return result.TaskDefinition, nil
+}
+
+// isAWSErrorCode returns whether the error is an aws error with the code
+func isAWSErrorCode(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
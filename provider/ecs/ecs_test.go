@@ -123,6 +123,28 @@ func TestCreateTaskDefinition(t *testing.T) {
 
 }
 
+func TestCreateTaskDefinitionWithDigest(t *testing.T) {
+	d, err := initDeployment()
+	if err != nil {
+		t.Errorf("initDeployment failed: %s", err)
+		return
+	}
+	d.Services[0].Containers[0].ContainerURI = ""
+	d.Services[0].Containers[0].ContainerTag = "latest"
+	d.Services[0].Containers[0].ContainerDigest = "sha256:0123456789abcdef"
+
+	ecs := ECS{}
+	err = ecs.CreateTaskDefinitionInput(d.Services[0], nil, "0123456789")
+	if err != nil {
+		t.Errorf("Error: %s", err)
+		return
+	}
+	expected := "0123456789.dkr.ecr." + providerConfig.Region + ".amazonaws.com/demo@sha256:0123456789abcdef"
+	if image := *ecs.TaskDefinition.ContainerDefinitions[0].Image; image != expected {
+		t.Errorf("Incorrect image: expected %s, got %s", expected, image)
+	}
+}
+
 func TestWaitUntilServicesStable(t *testing.T) {
 	if accountId == nil {
 		t.Skip(noAWSMsg)
@@ -231,7 +253,8 @@ func TestGetMaxWaitMinutes(t *testing.T) {
 	if num := ecs.getMaxWaitMinutes(100); num != 20 {
 		t.Errorf("Got wrong maxWaitMinutes: %d", num)
 	}
-	os.Setenv("DEPLOY_MAX_WAIT_SECONDS", "1800")
+	defer SetConfig(providerConfig)
+	providerConfig.DeployMaxWaitSeconds = 1800
 	if num := ecs.getMaxWaitMinutes(0); num != 30 {
 		t.Errorf("Got wrong maxWaitMinutes: %d", num)
 	}
@@ -302,3 +325,15 @@ xvY2FsAQIDBAUGBw==
 		t.Errorf("mismatch in public key output: %s vs %s", string(out), expected)
 	}
 }
+
+func TestGetCapacityProviderName(t *testing.T) {
+	name := GetCapacityProviderName("mycluster")
+	if name != "asg-mycluster" {
+		t.Errorf("Unexpected capacity provider name: %v", name)
+	}
+	for _, prefix := range []string{"aws", "ecs", "fargate"} {
+		if strings.HasPrefix(strings.ToLower(GetCapacityProviderName(prefix+"-cluster")), prefix) {
+			t.Errorf("Capacity provider name can't start with %v", prefix)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 )
 
 // logging
@@ -350,3 +351,75 @@ func (e *IAM) AssumeRole(roleArn, roleSessionName, prevCreds string) (*credentia
 	}
 	return creds, string(jsonCreds), nil
 }
+
+// GetRole returns the role, with the assume role policy document url decoded
+func (e *IAM) GetRole(roleName string) (*iam.Role, error) {
+	svc := iam.New(session.New())
+	result, err := svc.GetRole(&iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			iamLogger.Errorf(aerr.Error())
+		} else {
+			iamLogger.Errorf(err.Error())
+		}
+		return nil, fmt.Errorf("Could not retrieve role: %v", roleName)
+	}
+	assumeRolePolicyDocument, err := url.QueryUnescape(aws.StringValue(result.Role.AssumeRolePolicyDocument))
+	if err != nil {
+		return nil, err
+	}
+	result.Role.AssumeRolePolicyDocument = aws.String(assumeRolePolicyDocument)
+	return result.Role, nil
+}
+
+// GetRolePolicies returns the inline policies of a role (policy name => url decoded policy document)
+func (e *IAM) GetRolePolicies(roleName string) (map[string]string, error) {
+	policies := make(map[string]string)
+	svc := iam.New(session.New())
+	var policyNames []*string
+	err := svc.ListRolePoliciesPages(&iam.ListRolePoliciesInput{RoleName: aws.String(roleName)},
+		func(page *iam.ListRolePoliciesOutput, lastPage bool) bool {
+			policyNames = append(policyNames, page.PolicyNames...)
+			return true
+		})
+	if err != nil {
+		iamLogger.Errorf(err.Error())
+		return policies, fmt.Errorf("Could not list role policies for: %v", roleName)
+	}
+	for _, policyName := range policyNames {
+		result, err := svc.GetRolePolicy(&iam.GetRolePolicyInput{
+			RoleName:   aws.String(roleName),
+			PolicyName: policyName,
+		})
+		if err != nil {
+			iamLogger.Errorf(err.Error())
+			return policies, fmt.Errorf("Could not get role policy %v for: %v", aws.StringValue(policyName), roleName)
+		}
+		policyDocument, err := url.QueryUnescape(aws.StringValue(result.PolicyDocument))
+		if err != nil {
+			return policies, err
+		}
+		policies[aws.StringValue(policyName)] = policyDocument
+	}
+	return policies, nil
+}
+
+// GetAttachedRolePolicyArns returns the arns of the managed policies attached to a role
+func (e *IAM) GetAttachedRolePolicyArns(roleName string) ([]string, error) {
+	var policyArns []string
+	svc := iam.New(session.New())
+	err := svc.ListAttachedRolePoliciesPages(&iam.ListAttachedRolePoliciesInput{RoleName: aws.String(roleName)},
+		func(page *iam.ListAttachedRolePoliciesOutput, lastPage bool) bool {
+			for _, policy := range page.AttachedPolicies {
+				policyArns = append(policyArns, aws.StringValue(policy.PolicyArn))
+			}
+			return true
+		})
+	if err != nil {
+		iamLogger.Errorf(err.Error())
+		return policyArns, fmt.Errorf("Could not list attached role policies for: %v", roleName)
+	}
+	return policyArns, nil
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/in4it/ecs-deploy/service"
-	"github.com/in4it/ecs-deploy/util"
 	"github.com/juju/loggo"
 )
 
@@ -33,29 +32,25 @@ type Paramstore struct {
 }
 
 func (p *Paramstore) IsEnabled() bool {
-	if util.GetEnv("PARAMSTORE_ENABLED", "no") == "yes" {
-		return true
-	} else {
-		return false
-	}
+	return providerConfig.ParamstoreEnabled
 }
 
 func (p *Paramstore) GetPrefix() string {
-	if util.GetEnv("AWS_ENV_PATH", "") != "" {
-		return util.GetEnv("AWS_ENV_PATH", "")
+	if providerConfig.ParamstoreEnvPath != "" {
+		return providerConfig.ParamstoreEnvPath
 	} else {
-		if util.GetEnv("PARAMSTORE_PREFIX", "") == "" {
+		if providerConfig.ParamstorePrefix == "" {
 			return ""
 		} else {
-			return "/" + util.GetEnv("PARAMSTORE_PREFIX", "") + "-" + util.GetEnv("AWS_ACCOUNT_ENV", "") + "/ecs-deploy/"
+			return "/" + providerConfig.ParamstorePrefix + "-" + providerConfig.AccountEnv + "/ecs-deploy/"
 		}
 	}
 }
 func (p *Paramstore) GetPrefixForService(serviceName string) string {
-	if util.GetEnv("PARAMSTORE_PREFIX", "") == "" {
+	if providerConfig.ParamstorePrefix == "" {
 		return ""
 	} else {
-		return "/" + util.GetEnv("PARAMSTORE_PREFIX", "") + "-" + util.GetEnv("AWS_ACCOUNT_ENV", "") + "/" + serviceName + "/"
+		return "/" + providerConfig.ParamstorePrefix + "-" + providerConfig.AccountEnv + "/" + serviceName + "/"
 	}
 }
 func (p *Paramstore) AssumeRole(roleArn, roleSessionName, prevCreds string) (string, error) {
@@ -174,12 +169,12 @@ func (p *Paramstore) GetParamstoreIAMPolicy(path string) string {
           "ssm:GetParametersByPath"
         ],
         "Resource": [
-          "arn:aws:ssm:` + util.GetEnv("AWS_REGION", "") + `:` + accountId + `:parameter/` + util.GetEnv("PARAMSTORE_PREFIX", "") + `-` + util.GetEnv("AWS_ACCOUNT_ENV", "") + `/` + path + `/*"
+          "arn:aws:ssm:` + providerConfig.Region + `:` + accountId + `:parameter/` + providerConfig.ParamstorePrefix + `-` + providerConfig.AccountEnv + `/` + path + `/*"
         ],
         "Effect": "Allow"
       }`
 	kmsPolicy := ""
-	if kmsArn := util.GetEnv("PARAMSTORE_KMS_ARN", ""); kmsArn != "" {
+	if kmsArn := providerConfig.ParamstoreKmsArn; kmsArn != "" {
 		kmsPolicy = `,
 		  {
 			"Action": [
@@ -214,7 +209,7 @@ func (p *Paramstore) PutParameter(serviceName string, parameter service.DeploySe
 	}
 	if parameter.Encrypted {
 		input.SetType("SecureString")
-		input.SetKeyId(util.GetEnv("PARAMSTORE_KMS_ARN", ""))
+		input.SetKeyId(providerConfig.ParamstoreKmsArn)
 	} else {
 		input.SetType("String")
 	}
@@ -255,8 +250,9 @@ func (p *Paramstore) DeleteParameter(serviceName, parameter string) error {
 }
 
 func (p *Paramstore) Bootstrap(serviceName, prefix string, environment string, parameters []service.DeployServiceParameter) error {
-	os.Setenv("PARAMSTORE_PREFIX", prefix)
-	os.Setenv("AWS_ACCOUNT_ENV", environment)
+	// the parameters are stored with the prefix and environment of the new cluster
+	providerConfig.ParamstorePrefix = prefix
+	providerConfig.AccountEnv = environment
 	for _, v := range parameters {
 		p.PutParameter(serviceName, v)
 	}
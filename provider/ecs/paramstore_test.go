@@ -2,19 +2,19 @@ package ecs
 
 import (
 	"encoding/json"
-	"os"
 	"testing"
 )
 
 func TestGetPrefix(t *testing.T) {
+	defer SetConfig(providerConfig)
 	p := Paramstore{}
-	os.Setenv("AWS_ENV_PATH", "/mycompany-staging/ecs-deploy/")
-	os.Setenv("PARAMSTORE_PREFIX", "mycompany2")
-	os.Setenv("AWS_ACCOUNT_ENV", "prod")
+	providerConfig.ParamstoreEnvPath = "/mycompany-staging/ecs-deploy/"
+	providerConfig.ParamstorePrefix = "mycompany2"
+	providerConfig.AccountEnv = "prod"
 	if p.GetPrefix() != "/mycompany-staging/ecs-deploy/" {
 		t.Errorf("Wrong prefix returned: %v", p.GetPrefix())
 	}
-	os.Setenv("AWS_ENV_PATH", "")
+	providerConfig.ParamstoreEnvPath = ""
 	if p.GetPrefix() != "/mycompany2-prod/ecs-deploy/" {
 		t.Errorf("Wrong prefix returned: %v", p.GetPrefix())
 	}
@@ -29,11 +29,12 @@ func TestGetParamstoreIAMPolicy(t *testing.T) {
 			Effect   string   `json:"Effect"`
 		} `json:"Statement"`
 	}
+	defer SetConfig(providerConfig)
 	p := Paramstore{}
-	os.Setenv("AWS_ENV_PATH", "/mycluster-staging/ecs-deploy/")
-	os.Setenv("PARAMSTORE_PREFIX", "mycompany2")
-	os.Setenv("AWS_ACCOUNT_ENV", "staging")
-	os.Setenv("AWS_REGION", "us-east-1")
+	providerConfig.ParamstoreEnvPath = "/mycluster-staging/ecs-deploy/"
+	providerConfig.ParamstorePrefix = "mycompany2"
+	providerConfig.AccountEnv = "staging"
+	providerConfig.Region = "us-east-1"
 	out := p.GetParamstoreIAMPolicy("myservice")
 	var iamPolicy IAMPolicy
 	err := json.Unmarshal([]byte(out), &iamPolicy)
@@ -54,12 +55,13 @@ func TestGetParamstoreIAMPolicyWithKMS(t *testing.T) {
 			Effect   string   `json:"Effect"`
 		} `json:"Statement"`
 	}
+	defer SetConfig(providerConfig)
 	p := Paramstore{}
-	os.Setenv("AWS_ENV_PATH", "/mycluster-staging/ecs-deploy/")
-	os.Setenv("PARAMSTORE_PREFIX", "mycompany2")
-	os.Setenv("AWS_ACCOUNT_ENV", "staging")
-	os.Setenv("AWS_REGION", "us-east-1")
-	os.Setenv("PARAMSTORE_KMS_ARN", "arn:aws:kms:us-east-1:123456:testarn")
+	providerConfig.ParamstoreEnvPath = "/mycluster-staging/ecs-deploy/"
+	providerConfig.ParamstorePrefix = "mycompany2"
+	providerConfig.AccountEnv = "staging"
+	providerConfig.Region = "us-east-1"
+	providerConfig.ParamstoreKmsArn = "arn:aws:kms:us-east-1:123456:testarn"
 	out := p.GetParamstoreIAMPolicy("myservice")
 	var iamPolicy IAMPolicy
 	err := json.Unmarshal([]byte(out), &iamPolicy)
@@ -2,12 +2,15 @@ package ecs
 
 import (
 	"errors"
-	"strconv"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/in4it/ecs-deploy/service"
 	"github.com/in4it/ecs-deploy/util"
 	"github.com/juju/loggo"
 )
@@ -20,8 +23,14 @@ type ServiceDiscovery struct {
 }
 
 func (s *ServiceDiscovery) getNamespaceArnAndId(name string) (string, string, error) {
-	var result string
-	var id string
+	namespace, err := s.getNamespace(name)
+	if err != nil {
+		return "", "", err
+	}
+	return aws.StringValue(namespace.Arn), aws.StringValue(namespace.Id), nil
+}
+func (s *ServiceDiscovery) getNamespace(name string) (*servicediscovery.NamespaceSummary, error) {
+	var result *servicediscovery.NamespaceSummary
 	svc := servicediscovery.New(session.New())
 	input := &servicediscovery.ListNamespacesInput{}
 	pageNum := 0
@@ -30,8 +39,7 @@ func (s *ServiceDiscovery) getNamespaceArnAndId(name string) (string, string, er
 			pageNum++
 			for _, v := range page.Namespaces {
 				if aws.StringValue(v.Name) == name {
-					result = aws.StringValue(v.Arn)
-					id = aws.StringValue(v.Id)
+					result = v
 				}
 			}
 			return pageNum <= 100
@@ -44,13 +52,20 @@ func (s *ServiceDiscovery) getNamespaceArnAndId(name string) (string, string, er
 			ecsLogger.Errorf(err.Error())
 		}
 	}
-	if result == "" {
-		return result, id, errors.New("Namespace not found namespace=" + name)
+	if result == nil {
+		return result, errors.New("Namespace not found namespace=" + name)
 	}
-	return result, id, nil
+	return result, nil
 }
 func (s *ServiceDiscovery) getServiceArn(serviceName, namespaceID string) (string, error) {
-	var result string
+	summary, err := s.getService(serviceName, namespaceID)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(summary.Arn), nil
+}
+func (s *ServiceDiscovery) getService(serviceName, namespaceID string) (*servicediscovery.ServiceSummary, error) {
+	var result *servicediscovery.ServiceSummary
 	svc := servicediscovery.New(session.New())
 	input := &servicediscovery.ListServicesInput{
 		Filters: []*servicediscovery.ServiceFilter{
@@ -67,7 +82,7 @@ func (s *ServiceDiscovery) getServiceArn(serviceName, namespaceID string) (strin
 			pageNum++
 			for _, v := range page.Services {
 				if aws.StringValue(v.Name) == serviceName {
-					result = aws.StringValue(v.Arn)
+					result = v
 				}
 			}
 			return pageNum <= 100
@@ -80,48 +95,60 @@ func (s *ServiceDiscovery) getServiceArn(serviceName, namespaceID string) (strin
 			ecsLogger.Errorf(err.Error())
 		}
 	}
-	if result == "" {
+	if result == nil {
 		return result, errors.New("Service not found service=" + serviceName)
 	}
 	return result, nil
 }
-func (s *ServiceDiscovery) createService(serviceName, namespaceID string) (string, error) {
-	var (
-		ttl              int64
-		failureThreshold int64
-		err              error
-		output           string
-	)
-	ttl, err = strconv.ParseInt(util.GetEnv("SERVICE_DISCOVERY_TTL", "60"), 10, 64)
-	if err != nil {
-		ttl = 60
+
+// getDnsConfig returns the dns records and routing policy of a service, SRV and A records with SERVICE_DISCOVERY_TTL when not set
+func (s *ServiceDiscovery) getDnsConfig(config service.DeployServiceDiscovery) *servicediscovery.DnsConfig {
+	ttl := config.TTL
+	if ttl == 0 {
+		ttl = providerConfig.ServiceDiscoveryTTL
 	}
-	failureThreshold, err = strconv.ParseInt(util.GetEnv("SERVICE_DISCOVERY_FAILURETHRESHOLD", "1"), 10, 64)
-	if err != nil {
-		failureThreshold = 1
+	recordTypes := config.RecordTypes
+	if len(recordTypes) == 0 {
+		recordTypes = []string{"SRV", "A"}
 	}
+	dnsConfig := &servicediscovery.DnsConfig{}
+	for _, recordType := range recordTypes {
+		dnsConfig.DnsRecords = append(dnsConfig.DnsRecords, &servicediscovery.DnsRecord{
+			TTL:  aws.Int64(ttl),
+			Type: aws.String(recordType),
+		})
+	}
+	if config.RoutingPolicy == "" {
+		dnsConfig.SetRoutingPolicy("MULTIVALUE")
+	} else {
+		dnsConfig.SetRoutingPolicy(config.RoutingPolicy)
+	}
+	return dnsConfig
+}
+
+// getFailureThreshold returns the failure threshold of the custom health check, SERVICE_DISCOVERY_FAILURETHRESHOLD when not set
+func (s *ServiceDiscovery) getFailureThreshold(config service.DeployServiceDiscovery) int64 {
+	if config.FailureThreshold > 0 {
+		return config.FailureThreshold
+	}
+	return providerConfig.ServiceDiscoveryFailureThreshold
+}
+
+// createService creates the service in the namespace, services in http namespaces have no dns records
+func (s *ServiceDiscovery) createService(serviceName string, namespace *servicediscovery.NamespaceSummary, config service.DeployServiceDiscovery) (*servicediscovery.ServiceSummary, error) {
 	svc := servicediscovery.New(session.New())
 	input := &servicediscovery.CreateServiceInput{
 		CreatorRequestId: aws.String(serviceName + "-" + util.RandStringBytesMaskImprSrc(8)),
 		Description:      aws.String(serviceName),
 		Name:             aws.String(serviceName),
-		NamespaceId:      aws.String(namespaceID),
-		DnsConfig: &servicediscovery.DnsConfig{
-			DnsRecords: []*servicediscovery.DnsRecord{
-				{
-					TTL:  aws.Int64(ttl),
-					Type: aws.String("SRV"),
-				},
-				{
-					TTL:  aws.Int64(ttl),
-					Type: aws.String("A"),
-				},
-			},
-		},
+		NamespaceId:      namespace.Id,
 		HealthCheckCustomConfig: &servicediscovery.HealthCheckCustomConfig{
-			FailureThreshold: aws.Int64(failureThreshold),
+			FailureThreshold: aws.Int64(s.getFailureThreshold(config)),
 		},
 	}
+	if aws.StringValue(namespace.Type) != servicediscovery.NamespaceTypeHttp {
+		input.SetDnsConfig(s.getDnsConfig(config))
+	}
 	result, err := svc.CreateService(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -129,10 +156,185 @@ func (s *ServiceDiscovery) createService(serviceName, namespaceID string) (strin
 		} else {
 			ecsLogger.Errorf("%v", err.Error())
 		}
-		return output, err
+		return nil, err
 	}
 
-	output = aws.StringValue(result.Service.Arn)
+	return &servicediscovery.ServiceSummary{
+		Arn:                     result.Service.Arn,
+		Id:                      result.Service.Id,
+		Name:                    result.Service.Name,
+		DnsConfig:               result.Service.DnsConfig,
+		HealthCheckCustomConfig: result.Service.HealthCheckCustomConfig,
+	}, nil
+}
 
-	return output, nil
+// getServiceUpdate returns the dns records with the new ttl when the ttl changed, and a warning for every
+// setting that can't be changed once the service is created (record types, routing policy and failure threshold)
+func (s *ServiceDiscovery) getServiceUpdate(existing *servicediscovery.ServiceSummary, config service.DeployServiceDiscovery) ([]*servicediscovery.DnsRecord, []string) {
+	var warnings []string
+	serviceName := aws.StringValue(existing.Name)
+	if existing.HealthCheckCustomConfig != nil && aws.Int64Value(existing.HealthCheckCustomConfig.FailureThreshold) != s.getFailureThreshold(config) {
+		warnings = append(warnings, fmt.Sprintf("failureThreshold of service discovery service %v can't be changed from %d to %d, delete the service to apply it", serviceName, aws.Int64Value(existing.HealthCheckCustomConfig.FailureThreshold), s.getFailureThreshold(config)))
+	}
+	// http namespace
+	if existing.DnsConfig == nil {
+		return nil, warnings
+	}
+	dnsConfig := s.getDnsConfig(config)
+	if aws.StringValue(existing.DnsConfig.RoutingPolicy) != aws.StringValue(dnsConfig.RoutingPolicy) {
+		warnings = append(warnings, fmt.Sprintf("routingPolicy of service discovery service %v can't be changed from %v to %v, delete the service to apply it", serviceName, aws.StringValue(existing.DnsConfig.RoutingPolicy), aws.StringValue(dnsConfig.RoutingPolicy)))
+	}
+	var existingTypes, recordTypes []string
+	var records []*servicediscovery.DnsRecord
+	ttl := dnsConfig.DnsRecords[0].TTL
+	ttlChanged := false
+	for _, v := range existing.DnsConfig.DnsRecords {
+		existingTypes = append(existingTypes, aws.StringValue(v.Type))
+		if aws.Int64Value(v.TTL) != aws.Int64Value(ttl) {
+			ttlChanged = true
+		}
+		// the ttl is updated for the existing record types
+		records = append(records, &servicediscovery.DnsRecord{Type: v.Type, TTL: ttl})
+	}
+	for _, v := range dnsConfig.DnsRecords {
+		recordTypes = append(recordTypes, aws.StringValue(v.Type))
+	}
+	sort.Strings(existingTypes)
+	sort.Strings(recordTypes)
+	if strings.Join(existingTypes, ",") != strings.Join(recordTypes, ",") {
+		warnings = append(warnings, fmt.Sprintf("recordTypes of service discovery service %v can't be changed from %v to %v, delete the service to apply it", serviceName, strings.Join(existingTypes, ","), strings.Join(recordTypes, ",")))
+	}
+	if !ttlChanged {
+		return nil, warnings
+	}
+	return records, warnings
+}
+
+// updateService updates the ttl of the dns records when it changed, settings that can't be changed are logged
+func (s *ServiceDiscovery) updateService(existing *servicediscovery.ServiceSummary, config service.DeployServiceDiscovery) error {
+	records, warnings := s.getServiceUpdate(existing, config)
+	for _, warning := range warnings {
+		serviceDiscoveryLogger.Warningf("%v", warning)
+	}
+	if records == nil {
+		return nil
+	}
+	serviceName := aws.StringValue(existing.Name)
+	serviceDiscoveryLogger.Infof("Updating ttl of service discovery service %v to %d", serviceName, aws.Int64Value(records[0].TTL))
+	svc := servicediscovery.New(session.New())
+	_, err := svc.UpdateService(&servicediscovery.UpdateServiceInput{
+		Id: existing.Id,
+		Service: &servicediscovery.ServiceChange{
+			DnsConfig: &servicediscovery.DnsConfigChange{
+				DnsRecords: records,
+			},
+		},
+	})
+	if err != nil {
+		serviceDiscoveryLogger.Errorf("Could not update service discovery service %v: %v", serviceName, err)
+		return err
+	}
+	return nil
+}
+
+// GetOrCreateService returns the arn of the service in the namespace, the service is created when it doesn't exist and updated when the config changed
+func (s *ServiceDiscovery) GetOrCreateService(serviceName, namespaceName string, config service.DeployServiceDiscovery) (string, error) {
+	namespace, err := s.getNamespace(namespaceName)
+	if err != nil {
+		return "", err
+	}
+	existing, err := s.getService(serviceName, aws.StringValue(namespace.Id))
+	if err != nil {
+		if !strings.HasPrefix(err.Error(), "Service not found") {
+			return "", err
+		}
+		// Service not found, create service in service registry
+		created, err := s.createService(serviceName, namespace, config)
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(created.Arn), nil
+	}
+	// the deploy continues with the current settings of the service when the update fails
+	if err = s.updateService(existing, config); err != nil {
+		serviceDiscoveryLogger.Warningf("Service discovery service %v not updated: %v", serviceName, err)
+	}
+	return aws.StringValue(existing.Arn), nil
+}
+
+// DeleteService deletes the service from the namespace, a service that doesn't exist is ignored
+func (s *ServiceDiscovery) DeleteService(serviceName, namespaceName string) error {
+	namespace, err := s.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+	existing, err := s.getService(serviceName, aws.StringValue(namespace.Id))
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "Service not found") {
+			return nil
+		}
+		return err
+	}
+	svc := servicediscovery.New(session.New())
+	_, err = svc.DeleteService(&servicediscovery.DeleteServiceInput{Id: existing.Id})
+	if err != nil {
+		serviceDiscoveryLogger.Errorf("Could not delete service discovery service %v: %v", serviceName, err)
+		return err
+	}
+	serviceDiscoveryLogger.Infof("Deleted service discovery service %v from namespace %v", serviceName, namespaceName)
+	return nil
+}
+
+// SetInstanceAttributes adds the custom attributes to the registered instances of the service.
+// The instances are registered by ECS, so instances started after the deployment only get the attributes on the next deploy
+func (s *ServiceDiscovery) SetInstanceAttributes(serviceName, namespaceName string, attributes map[string]string) error {
+	namespace, err := s.getNamespace(namespaceName)
+	if err != nil {
+		return err
+	}
+	existing, err := s.getService(serviceName, aws.StringValue(namespace.Id))
+	if err != nil {
+		return err
+	}
+	svc := servicediscovery.New(session.New())
+	var instances []*servicediscovery.InstanceSummary
+	err = svc.ListInstancesPages(&servicediscovery.ListInstancesInput{ServiceId: existing.Id},
+		func(page *servicediscovery.ListInstancesOutput, lastPage bool) bool {
+			instances = append(instances, page.Instances...)
+			return true
+		})
+	if err != nil {
+		return err
+	}
+	for _, instance := range instances {
+		merged, changed := mergeInstanceAttributes(instance.Attributes, attributes)
+		if !changed {
+			continue
+		}
+		_, err = svc.RegisterInstance(&servicediscovery.RegisterInstanceInput{
+			ServiceId:  existing.Id,
+			InstanceId: instance.Id,
+			Attributes: merged,
+		})
+		if err != nil {
+			return fmt.Errorf("could not set attributes of instance %v: %v", aws.StringValue(instance.Id), err)
+		}
+	}
+	return nil
+}
+
+// mergeInstanceAttributes returns the attributes of the instance with the custom attributes and whether something changed
+func mergeInstanceAttributes(current map[string]*string, attributes map[string]string) (map[string]*string, bool) {
+	merged := make(map[string]*string)
+	for k, v := range current {
+		merged[k] = v
+	}
+	changed := false
+	for k, v := range attributes {
+		if aws.StringValue(current[k]) != v {
+			merged[k] = aws.String(v)
+			changed = true
+		}
+	}
+	return merged, changed
 }
@@ -0,0 +1,76 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/in4it/ecs-deploy/service"
+)
+
+func TestGetDnsConfig(t *testing.T) {
+	// the defaults are restored after the test
+	defer SetConfig(providerConfig)
+	providerConfig.ServiceDiscoveryTTL = 30
+	sd := ServiceDiscovery{}
+	dnsConfig := sd.getDnsConfig(service.DeployServiceDiscovery{})
+	if len(dnsConfig.DnsRecords) != 2 || aws.StringValue(dnsConfig.DnsRecords[0].Type) != "SRV" || aws.Int64Value(dnsConfig.DnsRecords[0].TTL) != 30 {
+		t.Errorf("unexpected default dns config: %v", dnsConfig)
+	}
+	if aws.StringValue(dnsConfig.RoutingPolicy) != "MULTIVALUE" {
+		t.Errorf("unexpected routing policy: %v", aws.StringValue(dnsConfig.RoutingPolicy))
+	}
+	dnsConfig = sd.getDnsConfig(service.DeployServiceDiscovery{RecordTypes: []string{"A"}, TTL: 10, RoutingPolicy: "WEIGHTED"})
+	if len(dnsConfig.DnsRecords) != 1 || aws.StringValue(dnsConfig.DnsRecords[0].Type) != "A" || aws.Int64Value(dnsConfig.DnsRecords[0].TTL) != 10 {
+		t.Errorf("unexpected dns config: %v", dnsConfig)
+	}
+	if aws.StringValue(dnsConfig.RoutingPolicy) != "WEIGHTED" {
+		t.Errorf("unexpected routing policy: %v", aws.StringValue(dnsConfig.RoutingPolicy))
+	}
+}
+
+func TestMergeInstanceAttributes(t *testing.T) {
+	current := map[string]*string{"AWS_INSTANCE_IPV4": aws.String("10.0.0.1"), "version": aws.String("1")}
+	_, changed := mergeInstanceAttributes(current, map[string]string{"version": "1"})
+	if changed {
+		t.Errorf("expected no change")
+	}
+	merged, changed := mergeInstanceAttributes(current, map[string]string{"version": "2", "team": "web"})
+	if !changed {
+		t.Errorf("expected change")
+	}
+	if aws.StringValue(merged["AWS_INSTANCE_IPV4"]) != "10.0.0.1" || aws.StringValue(merged["version"]) != "2" || aws.StringValue(merged["team"]) != "web" {
+		t.Errorf("unexpected attributes: %v", aws.StringValueMap(merged))
+	}
+}
+
+func TestGetServiceUpdate(t *testing.T) {
+	sd := ServiceDiscovery{}
+	existing := &servicediscovery.ServiceSummary{
+		Name: aws.String("myservice"),
+		DnsConfig: &servicediscovery.DnsConfig{
+			RoutingPolicy: aws.String("MULTIVALUE"),
+			DnsRecords: []*servicediscovery.DnsRecord{
+				{Type: aws.String("SRV"), TTL: aws.Int64(60)},
+				{Type: aws.String("A"), TTL: aws.Int64(60)},
+			},
+		},
+		HealthCheckCustomConfig: &servicediscovery.HealthCheckCustomConfig{FailureThreshold: aws.Int64(1)},
+	}
+	records, warnings := sd.getServiceUpdate(existing, service.DeployServiceDiscovery{TTL: 60})
+	if records != nil || len(warnings) != 0 {
+		t.Errorf("expected no update, got %v, %v", records, warnings)
+	}
+	records, warnings = sd.getServiceUpdate(existing, service.DeployServiceDiscovery{TTL: 30})
+	if len(records) != 2 || aws.Int64Value(records[1].TTL) != 30 || len(warnings) != 0 {
+		t.Errorf("expected ttl update, got %v, %v", records, warnings)
+	}
+	// settings that can't be changed result in warnings, the ttl of the existing records is still updated
+	records, warnings = sd.getServiceUpdate(existing, service.DeployServiceDiscovery{TTL: 30, RecordTypes: []string{"A"}, RoutingPolicy: "WEIGHTED", FailureThreshold: 3})
+	if len(warnings) != 3 {
+		t.Errorf("expected 3 warnings, got %v", warnings)
+	}
+	if len(records) != 2 || aws.StringValue(records[0].Type) != "SRV" || aws.Int64Value(records[0].TTL) != 30 {
+		t.Errorf("unexpected records: %v", records)
+	}
+}
@@ -72,3 +72,20 @@ type SNSPayloadLifecycleDetail struct {
 	EC2InstanceId        string `json:"EC2InstanceId"`
 	LifecycleTransition  string `json:"LifecycleTransition"`
 }
+
+// spot interruption warning and rebalance recommendation event
+type SNSPayloadSpotInterruption struct {
+	Version    string                           `json:"version"`
+	Id         string                           `json:"id"`
+	DetailType string                           `json:"detail-type" binding:"required"`
+	Source     string                           `json:"source"`
+	Account    string                           `json:"account"`
+	Time       string                           `json:"time"`
+	Region     string                           `json:"region"`
+	Resources  []string                         `json:"resources"`
+	Detail     SNSPayloadSpotInterruptionDetail `json:"detail"`
+}
+type SNSPayloadSpotInterruptionDetail struct {
+	InstanceId     string `json:"instance-id"`
+	InstanceAction string `json:"instance-action"`
+}
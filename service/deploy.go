@@ -29,8 +29,10 @@ type Deploy struct {
 	Volumes               []DeployVolume              `json:"volumes" yaml:"volumes"`
 	EnvNamespace          string                      `json:"envNamespace" yaml:"envNamespace"`
 	ServiceRegistry       string                      `json:"serviceRegistry" yaml:"serviceRegistry"`
+	ServiceDiscovery      DeployServiceDiscovery      `json:"serviceDiscovery" yaml:"serviceDiscovery"`
 	SchedulingStrategy    string                      `json:"schedulingStrategy" yaml:"schedulingStrategy"`
 	AppMesh               DeployAppMesh               `json:"appMesh" yaml:"appMesh"`
+	Autoscaling           *Autoscaling                `json:"autoscaling,omitempty" yaml:"autoscaling,omitempty"`
 }
 type DeployContainer struct {
 	ContainerName       string                        `json:"containerName" yaml:"containerName" binding:"required"`
@@ -39,6 +41,7 @@ type DeployContainer struct {
 	ContainerCommand    []*string                     `json:"containerCommand" yaml:"containerCommand"`
 	ContainerImage      string                        `json:"containerImage" yaml:"containerImage"`
 	ContainerURI        string                        `json:"containerURI" yaml:"containerURI"`
+	ContainerDigest     string                        `json:"containerDigest" yaml:"containerDigest"`
 	ContainerEntryPoint []*string                     `json:"containerEntryPoint" yaml:"containerEntryPoint"`
 	Essential           bool                          `json:"essential" yaml:"essential"`
 	Memory              int64                         `json:"memory" yaml:"memory"`
@@ -54,6 +57,13 @@ type DeployContainer struct {
 	LogConfiguration    DeployLogConfiguration        `json:"logConfiguration" yaml:"logConfiguration"`
 	PortMappings        []DeployContainerPortMapping  `json:"portMappings" yaml:"portMappings"`
 }
+type DeployServiceDiscovery struct {
+	RecordTypes      []string          `json:"recordTypes" yaml:"recordTypes"`
+	TTL              int64             `json:"ttl" yaml:"ttl"`
+	RoutingPolicy    string            `json:"routingPolicy" yaml:"routingPolicy"`
+	FailureThreshold int64             `json:"failureThreshold" yaml:"failureThreshold"`
+	Attributes       map[string]string `json:"attributes" yaml:"attributes"`
+}
 type DeployContainerPortMapping struct {
 	Protocol      string `json:"protocol" yaml:"protocol"`
 	HostPort      int64  `json:"hostPort" yaml:"hostPort"`
@@ -145,6 +155,211 @@ type DeployResult struct {
 	Status            string    `json:"status" yaml:"status"`
 	DeployError       string    `json:"deployError" yaml:"deployError"`
 	DeploymentTime    time.Time `json:"deploymentTime" yaml:"deploymentTime"`
+	RequestID         string    `json:"requestId" yaml:"requestId"`
+	// image digest per container name, for the images resolved at deploy time
+	ImageDigests map[string]string `json:"imageDigests,omitempty" yaml:"imageDigests,omitempty"`
+}
+
+// Import result (a service adopted by ecs-deploy)
+type ImportResult struct {
+	ServiceName       string    `json:"serviceName" yaml:"serviceName"`
+	ClusterName       string    `json:"clusterName" yaml:"clusterName"`
+	TaskDefinitionArn string    `json:"taskDefinitionArn" yaml:"taskDefinitionArn"`
+	Imported          bool      `json:"imported" yaml:"imported"`
+	DeploymentTime    time.Time `json:"deploymentTime" yaml:"deploymentTime"`
+	Warnings          []string  `json:"warnings" yaml:"warnings"`
+	DeployData        *Deploy   `json:"deployData" yaml:"deployData"`
+}
+
+// Drift between the last deployment and the live state of a service
+type ServiceDrift struct {
+	ServiceName string            `json:"serviceName" yaml:"serviceName"`
+	ClusterName string            `json:"clusterName" yaml:"clusterName"`
+	Drifted     bool              `json:"drifted" yaml:"drifted"`
+	Differences []DriftDifference `json:"differences" yaml:"differences"`
+	Error       string            `json:"error,omitempty" yaml:"error,omitempty"`
+	CheckedAt   time.Time         `json:"checkedAt" yaml:"checkedAt"`
+}
+type DriftDifference struct {
+	Field    string `json:"field" yaml:"field"`
+	Expected string `json:"expected" yaml:"expected"`
+	Actual   string `json:"actual" yaml:"actual"`
+}
+
+// Scaling decision of the cluster autoscaler, with the state of the cluster at the time of the decision
+type ScalingDecision struct {
+	ClusterName string    `json:"clusterName" yaml:"clusterName"`
+	Time        time.Time `json:"time" yaml:"time"`
+	// what triggered the decision, e.g. an ecs event or a pending scaling operation
+	Trigger   string `json:"trigger" yaml:"trigger"`
+	ScalingOp string `json:"scalingOp" yaml:"scalingOp"`
+	Strategy  string `json:"strategy" yaml:"strategy"`
+	// the strategy decided to scale
+	Scale   bool     `json:"scale" yaml:"scale"`
+	Reasons []string `json:"reasons" yaml:"reasons"`
+	// scaled, pending, cooldown, aborted, ...
+	Outcome           string                `json:"outcome" yaml:"outcome"`
+	CooldownMinutes   int64                 `json:"cooldownMinutes,omitempty" yaml:"cooldownMinutes,omitempty"`
+	CpuNeeded         int64                 `json:"cpuNeeded" yaml:"cpuNeeded"`
+	MemoryNeeded      int64                 `json:"memoryNeeded" yaml:"memoryNeeded"`
+	AvailabilityZones []ScalingDecisionZone `json:"availabilityZones" yaml:"availabilityZones"`
+	DesiredCapacity   int64                 `json:"desiredCapacity" yaml:"desiredCapacity"`
+	MinSize           int64                 `json:"minSize" yaml:"minSize"`
+	MaxSize           int64                 `json:"maxSize" yaml:"maxSize"`
+}
+type ScalingDecisionZone struct {
+	AvailabilityZone string `json:"availabilityZone" yaml:"availabilityZone"`
+	Instances        int64  `json:"instances" yaml:"instances"`
+	FreeCpu          int64  `json:"freeCpu" yaml:"freeCpu"`
+	FreeMemory       int64  `json:"freeMemory" yaml:"freeMemory"`
+}
+
+// Capacity and utilisation of the container instances of a cluster
+// utilisation and shares are percentages of the registered resources of the active instances
+type ClusterCapacityReport struct {
+	ClusterName       string                       `json:"clusterName" yaml:"clusterName"`
+	Instances         int64                        `json:"instances" yaml:"instances"`
+	DrainingInstances int64                        `json:"drainingInstances" yaml:"drainingInstances"`
+	RegisteredCpu     int64                        `json:"registeredCpu" yaml:"registeredCpu"`
+	RegisteredMemory  int64                        `json:"registeredMemory" yaml:"registeredMemory"`
+	FreeCpu           int64                        `json:"freeCpu" yaml:"freeCpu"`
+	FreeMemory        int64                        `json:"freeMemory" yaml:"freeMemory"`
+	CpuUtilisation    float64                      `json:"cpuUtilisation" yaml:"cpuUtilisation"`
+	MemoryUtilisation float64                      `json:"memoryUtilisation" yaml:"memoryUtilisation"`
+	AvailabilityZones []ClusterCapacityZone        `json:"availabilityZones" yaml:"availabilityZones"`
+	Services          []ClusterCapacityService     `json:"services" yaml:"services"`
+	Fragmentation     ClusterCapacityFragmentation `json:"fragmentation" yaml:"fragmentation"`
+	LargestTask       ClusterCapacityLargestTask   `json:"largestTask" yaml:"largestTask"`
+	GeneratedAt       time.Time                    `json:"generatedAt" yaml:"generatedAt"`
+}
+type ClusterCapacityZone struct {
+	AvailabilityZone string `json:"availabilityZone" yaml:"availabilityZone"`
+	Instances        int64  `json:"instances" yaml:"instances"`
+	RegisteredCpu    int64  `json:"registeredCpu" yaml:"registeredCpu"`
+	RegisteredMemory int64  `json:"registeredMemory" yaml:"registeredMemory"`
+	FreeCpu          int64  `json:"freeCpu" yaml:"freeCpu"`
+	FreeMemory       int64  `json:"freeMemory" yaml:"freeMemory"`
+}
+type ClusterCapacityService struct {
+	ServiceName       string  `json:"serviceName" yaml:"serviceName"`
+	RunningCount      int64   `json:"runningCount" yaml:"runningCount"`
+	CpuReservation    int64   `json:"cpuReservation" yaml:"cpuReservation"`
+	MemoryReservation int64   `json:"memoryReservation" yaml:"memoryReservation"`
+	ReservedCpu       int64   `json:"reservedCpu" yaml:"reservedCpu"`
+	ReservedMemory    int64   `json:"reservedMemory" yaml:"reservedMemory"`
+	CpuShare          float64 `json:"cpuShare" yaml:"cpuShare"`
+	MemoryShare       float64 `json:"memoryShare" yaml:"memoryShare"`
+}
+
+// percentage of the free resources that is not on the instance with the most free resources
+type ClusterCapacityFragmentation struct {
+	Cpu    float64 `json:"cpu" yaml:"cpu"`
+	Memory float64 `json:"memory" yaml:"memory"`
+}
+
+// the largest cpu and memory reservation of the services in the cluster, as used by the cluster autoscaler
+type ClusterCapacityLargestTask struct {
+	Cpu    int64 `json:"cpu" yaml:"cpu"`
+	Memory int64 `json:"memory" yaml:"memory"`
+	// copies that can be placed on the free resources of the active instances
+	AdditionalCopies int64 `json:"additionalCopies" yaml:"additionalCopies"`
+}
+
+// ECR repository settings, used when creating or updating a repository
+// on update, settings that are not set (null) are left unchanged
+type RepositorySettings struct {
+	// the default keeps the last 100 images
+	LifecycleRules  []RepositoryLifecycleRule `json:"lifecycleRules" yaml:"lifecycleRules"`
+	TagImmutability *bool                     `json:"tagImmutability" yaml:"tagImmutability"`
+	ScanOnPush      *bool                     `json:"scanOnPush" yaml:"scanOnPush"`
+	// KMS key (arn, id or alias) to encrypt the repository with, can only be set when the repository is created
+	KmsKey string `json:"kmsKey" yaml:"kmsKey"`
+	// AWS accounts that are allowed to pull images, an empty list removes the repository policy
+	PullAccounts []string `json:"pullAccounts" yaml:"pullAccounts"`
+	// regions and accounts to replicate the images to, an empty list removes the replication of the repository
+	Replication []RepositoryReplicationDestination `json:"replication" yaml:"replication"`
+}
+type RepositoryLifecycleRule struct {
+	Description string `json:"description" yaml:"description"`
+	// tagged, untagged or any
+	TagStatus   string   `json:"tagStatus" yaml:"tagStatus"`
+	TagPrefixes []string `json:"tagPrefixes" yaml:"tagPrefixes"`
+	// imageCountMoreThan or sinceImagePushed (countNumber in days)
+	CountType   string `json:"countType" yaml:"countType"`
+	CountNumber int64  `json:"countNumber" yaml:"countNumber"`
+}
+type RepositoryReplicationDestination struct {
+	Region string `json:"region" yaml:"region"`
+	// defaults to the current account
+	RegistryId string `json:"registryId" yaml:"registryId"`
+}
+
+// Image scan policy of a cluster, deploys with findings at or above the severity threshold are blocked
+type ImageScanPolicy struct {
+	ClusterName string `json:"clusterName" yaml:"clusterName"`
+	Enabled     bool   `json:"enabled" yaml:"enabled"`
+	// CRITICAL, HIGH, MEDIUM, LOW or INFORMATIONAL
+	SeverityThreshold string `json:"severityThreshold" yaml:"severityThreshold"`
+	// block deploys of images without a completed scan
+	RequireScan bool      `json:"requireScan" yaml:"requireScan"`
+	Allowlist   []string  `json:"allowlist" yaml:"allowlist"`
+	UpdatedBy   string    `json:"updatedBy" yaml:"updatedBy"`
+	UpdatedAt   time.Time `json:"updatedAt" yaml:"updatedAt"`
+}
+
+// Rolling AMI upgrade of the container instances of a cluster
+type ClusterUpgrade struct {
+	ClusterName string `json:"clusterName" yaml:"clusterName"`
+	AmiId       string `json:"amiId" yaml:"amiId"`
+	// running, completed, failed or cancelled
+	Status    string                   `json:"status" yaml:"status"`
+	Error     string                   `json:"error,omitempty" yaml:"error,omitempty"`
+	Instances []ClusterUpgradeInstance `json:"instances" yaml:"instances"`
+	// active container instances that were already running the AMI when the upgrade started
+	InitialUpgradedInstances int       `json:"initialUpgradedInstances" yaml:"initialUpgradedInstances"`
+	StartedBy                string    `json:"startedBy" yaml:"startedBy"`
+	StartedAt                time.Time `json:"startedAt" yaml:"startedAt"`
+	UpdatedAt                time.Time `json:"updatedAt" yaml:"updatedAt"`
+}
+type ClusterUpgradeInstance struct {
+	InstanceId string `json:"instanceId" yaml:"instanceId"`
+	AmiId      string `json:"amiId" yaml:"amiId"`
+	// pending, scaling, draining, terminating, replacing or terminated
+	Status string `json:"status" yaml:"status"`
+	// the autoscaling group is scaled up for the replacement instance
+	ScaledUp bool `json:"scaledUp" yaml:"scaledUp"`
+	// desired capacity of the autoscaling group before scaling up
+	DesiredCount int64 `json:"desiredCount,omitempty" yaml:"desiredCount,omitempty"`
+}
+type ImageScanFinding struct {
+	Name     string `json:"name" yaml:"name"`
+	Severity string `json:"severity" yaml:"severity"`
+	Uri      string `json:"uri" yaml:"uri"`
+}
+
+// Image scan override, recorded with the deployment
+type ImageScanOverride struct {
+	User     string             `json:"user" yaml:"user"`
+	Reason   string             `json:"reason" yaml:"reason"`
+	Findings []ImageScanFinding `json:"findings" yaml:"findings"`
+}
+
+// Deploy progress event (streamed while a deployment is running)
+type DeployProgressEvent struct {
+	Type        string        `json:"type" yaml:"type"`
+	ServiceName string        `json:"serviceName" yaml:"serviceName"`
+	Time        time.Time     `json:"time" yaml:"time"`
+	Phase       string        `json:"phase,omitempty" yaml:"phase,omitempty"`
+	Message     string        `json:"message" yaml:"message"`
+	TaskArn     string        `json:"taskArn,omitempty" yaml:"taskArn,omitempty"`
+	TaskStatus  string        `json:"taskStatus,omitempty" yaml:"taskStatus,omitempty"`
+	Result      *DeployResult `json:"result,omitempty" yaml:"result,omitempty"`
+}
+type ServiceLogEvent struct {
+	Timestamp     time.Time `json:"timestamp" yaml:"timestamp"`
+	TaskId        string    `json:"taskId" yaml:"taskId"`
+	ContainerName string    `json:"containerName" yaml:"containerName"`
+	Message       string    `json:"message" yaml:"message"`
 }
 type DeployServiceParameter struct {
 	Name      string `json:"name" yaml:"name" binding:"required"`
@@ -226,10 +441,12 @@ type RunTaskContainerOverride struct {
 
 // create Autoscaling Policy
 type Autoscaling struct {
-	MinimumCount int64               `json:"minimumCount" yaml:"minimumCount"`
-	DesiredCount int64               `json:"desiredCount" yaml:"desiredCount"`
-	MaximumCount int64               `json:"maximumCount" yaml:"maximumCount"`
-	Policies     []AutoscalingPolicy `json:"policies" yaml:"policies"`
+	MinimumCount           int64                             `json:"minimumCount" yaml:"minimumCount"`
+	DesiredCount           int64                             `json:"desiredCount" yaml:"desiredCount"`
+	MaximumCount           int64                             `json:"maximumCount" yaml:"maximumCount"`
+	Policies               []AutoscalingPolicy               `json:"policies" yaml:"policies"`
+	TargetTrackingPolicies []AutoscalingTargetTrackingPolicy `json:"targetTrackingPolicies" yaml:"targetTrackingPolicies"`
+	ScheduledActions       []AutoscalingScheduledAction      `json:"scheduledActions" yaml:"scheduledActions"`
 }
 type AutoscalingPolicy struct {
 	PolicyName           string  `json:"policyName" yaml:"policyName"`
@@ -243,6 +460,29 @@ type AutoscalingPolicy struct {
 	EvaluationPeriods    int64   `json:"evaluationPeriods" yaml:"evaluationPeriods"`
 	Period               int64   `json:"period" yaml:"period"`
 }
+type AutoscalingTargetTrackingPolicy struct {
+	PolicyName       string                   `json:"policyName" yaml:"policyName"`
+	Metric           string                   `json:"metric" yaml:"metric"`
+	TargetValue      float64                  `json:"targetValue" yaml:"targetValue"`
+	ScaleInCooldown  int64                    `json:"scaleInCooldown" yaml:"scaleInCooldown"`
+	ScaleOutCooldown int64                    `json:"scaleOutCooldown" yaml:"scaleOutCooldown"`
+	DisableScaleIn   bool                     `json:"disableScaleIn" yaml:"disableScaleIn"`
+	CustomMetric     *AutoscalingCustomMetric `json:"customMetric,omitempty" yaml:"customMetric,omitempty"`
+}
+type AutoscalingCustomMetric struct {
+	Namespace  string            `json:"namespace" yaml:"namespace"`
+	MetricName string            `json:"metricName" yaml:"metricName"`
+	Dimensions map[string]string `json:"dimensions" yaml:"dimensions"`
+	Statistic  string            `json:"statistic" yaml:"statistic"`
+	Unit       string            `json:"unit" yaml:"unit"`
+}
+type AutoscalingScheduledAction struct {
+	ActionName   string `json:"actionName" yaml:"actionName"`
+	Schedule     string `json:"schedule" yaml:"schedule"`
+	Timezone     string `json:"timezone" yaml:"timezone"`
+	MinimumCount *int64 `json:"minimumCount,omitempty" yaml:"minimumCount,omitempty"`
+	MaximumCount *int64 `json:"maximumCount,omitempty" yaml:"maximumCount,omitempty"`
+}
 
 type LoadBalancer struct {
 	Name          string
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSON Schema (draft-07) of the deploy files
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	MinLength            int                `json:"minLength,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// ValidationError is a validation error of a field in the deploy file
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []ValidationError
+
+func (v ValidationErrors) Error() string {
+	var errs []string
+	for _, e := range v {
+		if e.Field == "" {
+			errs = append(errs, e.Message)
+		} else {
+			errs = append(errs, e.Field+": "+e.Message)
+		}
+	}
+	return strings.Join(errs, "; ")
+}
+
+// allowed values for fields that are passed as-is to AWS (empty string is the default)
+var schemaEnums = map[string][]interface{}{
+	"AutoscalingPolicy.metric":                    {"cpu", "memory"},
+	"AutoscalingTargetTrackingPolicy.metric":      {"cpu", "memory", "albRequestCountPerTarget", "custom"},
+	"Deploy.launchType":                           {"", "EC2", "FARGATE"},
+	"Deploy.networkMode":                          {"", "bridge", "host", "awsvpc", "none"},
+	"Deploy.schedulingStrategy":                   {"", "REPLICA", "DAEMON"},
+	"DeployContainerPortMapping.protocol":         {"", "tcp", "udp"},
+	"DeployNetworkConfiguration.assignPublicIp":   {"", "ENABLED", "DISABLED"},
+	"DeployPlacementConstraint.type":              {"", "memberOf", "distinctInstance"},
+	"DeployServiceDiscovery.recordTypes":          {"A", "AAAA", "SRV"},
+	"DeployServiceDiscovery.routingPolicy":        {"", "MULTIVALUE", "WEIGHTED"},
+	"DeployVolumeDockerVolumeConfiguration.scope": {"", "task", "shared"},
+}
+
+// GetDeploySchema returns the JSON Schema of a deploy file, which is either a single service or a list of services
+func GetDeploySchema() *Schema {
+	definitions := make(map[string]*Schema)
+	return &Schema{
+		Schema: "http://json-schema.org/draft-07/schema#",
+		Title:  "ecs-deploy deploy file",
+		OneOf: []*Schema{
+			newSchema(reflect.TypeOf(Deploy{}), definitions),
+			newSchema(reflect.TypeOf(DeployServices{}), definitions),
+		},
+		Definitions: definitions,
+	}
+}
+
+// newSchema returns the schema of a type, structs are added to the definitions and referenced
+func newSchema(t reflect.Type, definitions map[string]*Schema) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		if t.Elem().Kind() == reflect.Struct {
+			return newSchema(t.Elem(), definitions)
+		}
+		s := newSchema(t.Elem(), definitions)
+		s.Type = []string{s.Type.(string), "null"}
+		return s
+	case reflect.Slice:
+		return &Schema{Type: []string{"array", "null"}, Items: newSchema(t.Elem(), definitions)}
+	case reflect.Map:
+		return &Schema{Type: []string{"object", "null"}, AdditionalProperties: newSchema(t.Elem(), definitions)}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		ref := &Schema{Ref: "#/definitions/" + t.Name()}
+		if _, ok := definitions[t.Name()]; ok {
+			return ref
+		}
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+		// add the definition before the fields to support recursive types
+		definitions[t.Name()] = s
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" || field.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := newSchema(field.Type, definitions)
+			if enum, ok := schemaEnums[t.Name()+"."+name]; ok {
+				// the enum of a list applies to its items
+				if property.Items != nil {
+					property.Items.Enum = enum
+				} else {
+					property.Enum = enum
+				}
+			}
+			if strings.Contains(","+field.Tag.Get("binding")+",", ",required,") {
+				s.Required = append(s.Required, name)
+				if field.Type.Kind() == reflect.String {
+					property.MinLength = 1
+				}
+			}
+			s.Properties[name] = property
+		}
+		return ref
+	}
+	return &Schema{}
+}
+
+// ValidateDeployFile validates the json content of a deploy file (a single service or a list of services) against the schema
+func ValidateDeployFile(content []byte) ValidationErrors {
+	var v interface{}
+	err := json.Unmarshal(content, &v)
+	if err != nil {
+		return ValidationErrors{{Message: "invalid json: " + err.Error()}}
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		if _, ok := m["services"]; ok {
+			return ValidateDeployJSON(v, "DeployServices")
+		}
+	}
+	return ValidateDeployJSON(v, "Deploy")
+}
+
+// ValidateDeployJSON validates a decoded json value against a definition of the schema (Deploy or DeployServices)
+func ValidateDeployJSON(v interface{}, definition string) ValidationErrors {
+	schema := GetDeploySchema()
+	var errs ValidationErrors
+	validateSchema(v, schema.Definitions[definition], schema.Definitions, "", &errs)
+	return errs
+}
+
+func validateSchema(v interface{}, s *Schema, definitions map[string]*Schema, path string, errs *ValidationErrors) {
+	if s.Ref != "" {
+		s = definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+	}
+	addError := func(format string, a ...interface{}) {
+		*errs = append(*errs, ValidationError{Field: path, Message: fmt.Sprintf(format, a...)})
+	}
+	var types []string
+	switch t := s.Type.(type) {
+	case string:
+		types = []string{t}
+	case []string:
+		types = t
+	}
+	actual := jsonType(v)
+	typeOk := false
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			typeOk = true
+		}
+	}
+	if !typeOk {
+		addError("expected %v, got %v", strings.Join(types, " or "), actual)
+		return
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if e == v {
+				found = true
+			}
+		}
+		if !found {
+			var values []string
+			for _, e := range s.Enum {
+				if e != "" {
+					values = append(values, fmt.Sprintf("%v", e))
+				}
+			}
+			addError("invalid value %v, allowed values: %v", v, strings.Join(values, ", "))
+		}
+	}
+	switch value := v.(type) {
+	case string:
+		if len(value) < s.MinLength {
+			addError("cannot be empty")
+		}
+	case []interface{}:
+		for i, item := range value {
+			validateSchema(item, s.Items, definitions, path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case map[string]interface{}:
+		for _, r := range s.Required {
+			if _, ok := value[r]; !ok {
+				*errs = append(*errs, ValidationError{Field: joinPath(path, r), Message: "is required"})
+			}
+		}
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if property, ok := s.Properties[k]; ok {
+				validateSchema(value[k], property, definitions, joinPath(path, k), errs)
+			} else if additional, ok := s.AdditionalProperties.(*Schema); ok {
+				validateSchema(value[k], additional, definitions, joinPath(path, k), errs)
+			} else {
+				*errs = append(*errs, ValidationError{Field: joinPath(path, k), Message: "unknown field"})
+			}
+		}
+	}
+}
+
+// jsonType returns the json schema type of a decoded json value
+func jsonType(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// ValidateDeployService validates the deploy data of a service
+func ValidateDeployService(serviceName string, d Deploy) ValidationErrors {
+	var errs ValidationErrors
+	if strings.ToLower(d.ServiceProtocol) != "none" && d.ServicePort == 0 {
+		errs = append(errs, ValidationError{Field: "servicePort", Message: "ServicePort needs to be set if ServiceProtocol is not set to none."})
+	}
+	found := false
+	for _, container := range d.Containers {
+		if container.ContainerName == serviceName {
+			found = true
+		}
+	}
+	if !found {
+		errs = append(errs, ValidationError{Field: "containers", Message: "At least one container needs to have the same name as the service (" + serviceName + ")"})
+	}
+	if d.Autoscaling != nil {
+		errs = append(errs, validateAutoscaling(*d.Autoscaling)...)
+	}
+	for i, container := range d.Containers {
+		for j, portMapping := range container.PortMappings {
+			if portMapping.ContainerPort == 0 {
+				errs = append(errs, ValidationError{Field: fmt.Sprintf("containers[%d].portMappings[%d].containerPort", i, j), Message: "containerPort needs to be set"})
+			}
+		}
+	}
+	return errs
+}
+
+// validateAutoscaling validates the autoscaling block of the deploy data, an autoscaling block without maximumCount removes the autoscaling
+func validateAutoscaling(a Autoscaling) ValidationErrors {
+	var errs ValidationErrors
+	if a.MaximumCount == 0 {
+		return errs
+	}
+	if a.MinimumCount > a.MaximumCount {
+		errs = append(errs, ValidationError{Field: "autoscaling.minimumCount", Message: "minimumCount can't be higher than maximumCount"})
+	}
+	for i, p := range a.TargetTrackingPolicies {
+		if p.TargetValue <= 0 {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("autoscaling.targetTrackingPolicies[%d].targetValue", i), Message: "targetValue needs to be set"})
+		}
+		if p.Metric == "custom" && (p.CustomMetric == nil || p.CustomMetric.Namespace == "" || p.CustomMetric.MetricName == "") {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("autoscaling.targetTrackingPolicies[%d].customMetric", i), Message: "customMetric with namespace and metricName needs to be set for metric custom"})
+		}
+	}
+	for i, action := range a.ScheduledActions {
+		if action.ActionName == "" || action.Schedule == "" {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("autoscaling.scheduledActions[%d]", i), Message: "actionName and schedule need to be set"})
+		}
+		if action.MinimumCount == nil && action.MaximumCount == nil {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("autoscaling.scheduledActions[%d]", i), Message: "minimumCount or maximumCount needs to be set"})
+		}
+	}
+	return errs
+}
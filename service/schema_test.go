@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGetDeploySchema(t *testing.T) {
+	schema := GetDeploySchema()
+	deploy, ok := schema.Definitions["Deploy"]
+	if !ok {
+		t.Fatalf("Deploy definition not found")
+	}
+	if deploy.AdditionalProperties != false {
+		t.Errorf("additional properties are allowed in Deploy")
+	}
+	if _, ok := deploy.Properties["containers"]; !ok {
+		t.Errorf("containers property not found")
+	}
+	if _, ok := schema.Definitions["DeployContainerPortMapping"]; !ok {
+		t.Errorf("DeployContainerPortMapping definition not found")
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		t.Errorf("error: %s", err)
+	}
+}
+
+func TestValidateDeployFile(t *testing.T) {
+	errs := ValidateDeployFile([]byte(`{
+		"cluster": "mycluster",
+		"serviceProtocol": "HTTP",
+		"desiredCount": "1",
+		"unknownKey": true,
+		"containers": [
+			{"containerName": "myservice", "containerTag": "latest"},
+			{"containerName": "sidecar", "containerTag": "", "portMappings": [{"protocol": "sctp", "containerPort": 80}]}
+		]
+	}`))
+	expected := map[string]bool{
+		"desiredCount":                           true,
+		"unknownKey":                             true,
+		"containers[1].containerTag":             true,
+		"containers[1].portMappings[0].protocol": true,
+	}
+	if len(errs) != len(expected) {
+		t.Errorf("expected %d errors, got: %v", len(expected), errs)
+	}
+	for _, e := range errs {
+		if !expected[e.Field] {
+			t.Errorf("unexpected error: %v: %v", e.Field, e.Message)
+		}
+	}
+	errs = ValidateDeployFile([]byte(`{"services": [{"cluster": "mycluster", "serviceProtocol": "none", "desiredCount": 1, "containers": [{"containerName": "myservice", "containerTag": "latest", "containerCommand": null}]}]}`))
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	errs = ValidateDeployFile([]byte(`{"services": [{"serviceProtocol": "none", "desiredCount": 1, "containers": []}]}`))
+	if len(errs) != 1 || errs[0].Field != "services[0].cluster" {
+		t.Errorf("expected services[0].cluster to be required, got: %v", errs)
+	}
+}
+
+func TestValidateDeployService(t *testing.T) {
+	d := Deploy{
+		ServiceProtocol: "HTTP",
+		Containers: []*DeployContainer{
+			{ContainerName: "sidecar", PortMappings: []DeployContainerPortMapping{{Protocol: "tcp"}}},
+		},
+	}
+	errs := ValidateDeployService("myservice", d)
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got: %v", errs)
+	}
+	if errs[2].Field != "containers[0].portMappings[0].containerPort" {
+		t.Errorf("unexpected field: %v", errs[2].Field)
+	}
+}
+
+func TestValidateDeployFileServiceDiscovery(t *testing.T) {
+	errs := ValidateDeployFile([]byte(`{
+		"cluster": "mycluster",
+		"serviceProtocol": "HTTP",
+		"desiredCount": 1,
+		"serviceRegistry": "local",
+		"serviceDiscovery": {"recordTypes": ["A", "CNAME"], "ttl": 10, "routingPolicy": "WEIGHTED", "attributes": {"version": "1"}},
+		"containers": [{"containerName": "myservice", "containerTag": "latest"}]
+	}`))
+	if len(errs) != 1 || errs[0].Field != "serviceDiscovery.recordTypes[1]" {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestValidateDeployServiceAutoscaling(t *testing.T) {
+	four := int64(4)
+	d := Deploy{
+		ServiceProtocol: "none",
+		Containers:      []*DeployContainer{{ContainerName: "myservice"}},
+		Autoscaling: &Autoscaling{
+			MinimumCount: 2,
+			MaximumCount: 10,
+			TargetTrackingPolicies: []AutoscalingTargetTrackingPolicy{
+				{Metric: "cpu", TargetValue: 60},
+				{Metric: "custom"},
+			},
+			ScheduledActions: []AutoscalingScheduledAction{
+				{ActionName: "office-hours", Schedule: "cron(0 8 ? * MON-FRI *)", MinimumCount: &four},
+				{ActionName: "evening", Schedule: "cron(0 19 ? * MON-FRI *)"},
+			},
+		},
+	}
+	errs := ValidateDeployService("myservice", d)
+	expected := []string{
+		"autoscaling.targetTrackingPolicies[1].targetValue",
+		"autoscaling.targetTrackingPolicies[1].customMetric",
+		"autoscaling.scheduledActions[1]",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got: %v", len(expected), errs)
+	}
+	for i, e := range errs {
+		if e.Field != expected[i] {
+			t.Errorf("unexpected field: %v (expected %v)", e.Field, expected[i])
+		}
+	}
+	// without maximumCount the autoscaling is removed, nothing to validate
+	d.Autoscaling = &Autoscaling{}
+	if errs = ValidateDeployService("myservice", d); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"errors"
 	"math"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,6 +25,11 @@ type Service struct {
 	ServiceName string
 	ClusterName string
 	Listeners   []string
+	RequestID   string
+	// recorded with the new deployment
+	ImageScanOverride *ImageScanOverride
+	// autoscaling reconciled from the deploy file, replaces the autoscaling of the last deployment
+	Autoscaling *DynamoDeploymentAutoscaling
 }
 
 // Service interface (for tests)
@@ -31,6 +37,9 @@ type ServiceIf interface {
 	GetClusterInfo() (*DynamoCluster, error)
 	IsDeployRunning() (bool, error)
 	PutClusterInfo(dc DynamoCluster, clusterName string, action string, pendingAction string) (*DynamoCluster, error)
+	PutScalingDecision(decision ScalingDecision) error
+	GetClusterUpgrade(clusterName string) (*ClusterUpgrade, error)
+	PutClusterUpgrade(upgrade ClusterUpgrade) error
 }
 
 type DynamoDeployment struct {
@@ -45,6 +54,11 @@ type DynamoDeployment struct {
 	ManualTasksArns   []string
 	TaskDefinitionArn *string
 	DeployData        *Deploy
+	// image digest per container name, the digests are also set in the deploy data
+	ImageDigests map[string]string
+	// set when the image scan policy was overridden
+	ImageScanOverride *ImageScanOverride
+	RequestID         string
 	Version           int64
 }
 
@@ -96,9 +110,33 @@ type DynamoClusterContainerInstance struct {
 	AvailabilityZone    string
 	FreeMemory          int64
 	FreeCpu             int64
+	RegisteredMemory    int64
+	RegisteredCpu       int64
 	Status              string
 }
 
+// dynamo image scan policy struct (one per cluster)
+type DynamoImageScanPolicy struct {
+	Identifier string `dynamo:"ServiceName,hash"`
+	Time       string `dynamo:"Time,range"`
+	Policy     ImageScanPolicy
+}
+
+// dynamo cluster upgrade struct (one per cluster)
+type DynamoClusterUpgrade struct {
+	Identifier string `dynamo:"ServiceName,hash"`
+	Time       string `dynamo:"Time,range"`
+	Upgrade    ClusterUpgrade
+}
+
+// dynamo scaling decision struct (one hash key per cluster)
+type DynamoScalingDecision struct {
+	Identifier        string    `dynamo:"ServiceName,hash"`
+	Time              time.Time `dynamo:"Time,range"`
+	Decision          ScalingDecision
+	ExpirationTimeTTL int64
+}
+
 // dynamo pull struct
 type DynamoAutoscalingPull struct {
 	Identifier    string    `dynamo:"ServiceName,hash"`
@@ -249,7 +287,15 @@ func (s *Service) ServiceExistsInDynamo() (bool, error) {
 func (s *Service) NewDeployment(taskDefinitionArn *string, d *Deploy) (*DynamoDeployment, error) {
 	day := time.Now().Format("2006-01-02")
 	month := time.Now().Format("2006-01")
-	w := DynamoDeployment{ServiceName: s.ServiceName, Time: time.Now(), Day: day, Month: month, TaskDefinitionArn: taskDefinitionArn, DeployData: d, Status: "running", Version: 1}
+	w := DynamoDeployment{ServiceName: s.ServiceName, Time: time.Now(), Day: day, Month: month, TaskDefinitionArn: taskDefinitionArn, DeployData: d, Status: "running", RequestID: s.RequestID, ImageScanOverride: s.ImageScanOverride, Version: 1}
+	for _, container := range d.Containers {
+		if container.ContainerDigest != "" {
+			if w.ImageDigests == nil {
+				w.ImageDigests = make(map[string]string)
+			}
+			w.ImageDigests[container.ContainerName] = container.ContainerDigest
+		}
+	}
 
 	lastDeploy, err := s.GetLastDeploy()
 	if err != nil {
@@ -258,6 +304,9 @@ func (s *Service) NewDeployment(taskDefinitionArn *string, d *Deploy) (*DynamoDe
 		w.Scaling = lastDeploy.Scaling
 		w.Scaling.DesiredCount = util.Max(d.DesiredCount, lastDeploy.Scaling.DesiredCount)
 	}
+	if s.Autoscaling != nil {
+		w.Scaling.Autoscaling = *s.Autoscaling
+	}
 
 	err = s.table.Put(w).Run()
 
@@ -685,6 +734,99 @@ func (s *Service) IsDeployRunning() (bool, error) {
 	return deployRunning, nil
 }
 
+func (s *Service) GetImageScanPolicy(clusterName string) (*ImageScanPolicy, error) {
+	var p DynamoImageScanPolicy
+	err := s.table.Get("ServiceName", "__IMAGESCANPOLICY").Range("Time", dynamo.Equal, clusterName).One(&p)
+	if err != nil {
+		if err.Error() == "dynamo: no item found" {
+			return nil, nil
+		}
+		serviceLogger.Errorf("Could not get image scan policy: %v", err.Error())
+		return nil, err
+	}
+	return &p.Policy, nil
+}
+func (s *Service) PutImageScanPolicy(policy ImageScanPolicy) error {
+	p := DynamoImageScanPolicy{Identifier: "__IMAGESCANPOLICY", Time: policy.ClusterName, Policy: policy}
+	err := s.table.Put(p).Run()
+	if err != nil {
+		serviceLogger.Errorf("Could not put image scan policy: %v", err.Error())
+		return err
+	}
+	return nil
+}
+func (s *Service) GetClusterUpgrade(clusterName string) (*ClusterUpgrade, error) {
+	var u DynamoClusterUpgrade
+	err := s.table.Get("ServiceName", "__CLUSTERUPGRADE").Range("Time", dynamo.Equal, clusterName).One(&u)
+	if err != nil {
+		if err.Error() == "dynamo: no item found" {
+			return nil, nil
+		}
+		serviceLogger.Errorf("Could not get cluster upgrade: %v", err.Error())
+		return nil, err
+	}
+	return &u.Upgrade, nil
+}
+func (s *Service) GetClusterUpgrades() ([]ClusterUpgrade, error) {
+	var us []DynamoClusterUpgrade
+	var upgrades []ClusterUpgrade
+	err := s.table.Get("ServiceName", "__CLUSTERUPGRADE").All(&us)
+	if err != nil {
+		if err.Error() == "dynamo: no item found" {
+			return upgrades, nil
+		}
+		serviceLogger.Errorf("Could not get cluster upgrades: %v", err.Error())
+		return upgrades, err
+	}
+	for _, u := range us {
+		upgrades = append(upgrades, u.Upgrade)
+	}
+	return upgrades, nil
+}
+func (s *Service) PutClusterUpgrade(upgrade ClusterUpgrade) error {
+	upgrade.UpdatedAt = time.Now()
+	u := DynamoClusterUpgrade{Identifier: "__CLUSTERUPGRADE", Time: upgrade.ClusterName, Upgrade: upgrade}
+	err := s.table.Put(u).Run()
+	if err != nil {
+		serviceLogger.Errorf("Could not put cluster upgrade: %v", err.Error())
+		return err
+	}
+	return nil
+}
+func (s *Service) PutScalingDecision(decision ScalingDecision) error {
+	retentionDays, err := strconv.Atoi(util.GetEnv("AUTOSCALING_DECISION_RETENTION_DAYS", "7"))
+	if err != nil {
+		retentionDays = 7
+	}
+	d := DynamoScalingDecision{
+		Identifier:        "__SCALINGDECISIONS:" + decision.ClusterName,
+		Time:              decision.Time,
+		Decision:          decision,
+		ExpirationTimeTTL: decision.Time.AddDate(0, 0, retentionDays).Unix(),
+	}
+	err = s.table.Put(d).Run()
+	if err != nil {
+		serviceLogger.Errorf("Could not put scaling decision: %v", err.Error())
+		return err
+	}
+	return nil
+}
+func (s *Service) GetScalingDecisions(clusterName string, since time.Time) ([]ScalingDecision, error) {
+	var ds []DynamoScalingDecision
+	decisions := []ScalingDecision{}
+	err := s.table.Get("ServiceName", "__SCALINGDECISIONS:"+clusterName).Range("Time", dynamo.GreaterOrEqual, since).Order(dynamo.Descending).All(&ds)
+	if err != nil {
+		if err.Error() == "dynamo: no item found" {
+			return decisions, nil
+		}
+		serviceLogger.Errorf("Could not get scaling decisions: %v", err.Error())
+		return decisions, err
+	}
+	for _, d := range ds {
+		decisions = append(decisions, d.Decision)
+	}
+	return decisions, nil
+}
 func (s *Service) AutoscalingPullInit() error {
 	p := &DynamoAutoscalingPull{Identifier: "__AUTOSCALINGPULL", Time: "0", Lock: "initial"}
 	err := s.table.Put(p).If("attribute_not_exists(L)").Run()
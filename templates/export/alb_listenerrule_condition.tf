@@ -1,4 +0,0 @@
-condition {
-    field = "${LISTENER_CONDITION_FIELD}"
-    values = ["${LISTENER_CONDITION_VALUE}"]
-  }
@@ -1,3 +0,0 @@
-resource "aws_ecr_repository" "${SERVICE}" {
-  name = "${SERVICE}"
-}
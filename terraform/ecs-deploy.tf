@@ -141,6 +141,7 @@ resource "aws_iam_role_policy" "ecs-deploy-policy" {
         "ecs:CreateService",
         "ecs:RegisterTaskDefinition",
         "ecs:UpdateContainerInstancesState",
+        "ecs:CreateCapacityProvider",
         "ecr:GetAuthorizationToken",
         "ecr:BatchCheckLayerAvailability",
         "ecr:GetDownloadUrlForLayer",
@@ -155,6 +156,10 @@ resource "aws_iam_role_policy" "ecs-deploy-policy" {
         "ecr:PutImage",
         "ecr:CreateRepository",
         "ecr:PutLifecyclePolicy",
+        "ecr:DescribeImageScanFindings",
+        "ecr:PutImageTagMutability",
+        "ecr:SetRepositoryPolicy",
+        "ecr:PutReplicationConfiguration",
         "elasticloadbalancing:Describe*",
         "elasticloadbalancing:CreateRule",
         "elasticloadbalancing:DeleteRule",
@@ -168,9 +173,12 @@ resource "aws_iam_role_policy" "ecs-deploy-policy" {
         "autoscaling:UpdateAutoScalingGroup",
         "autoscaling:CompleteLifecycleAction",
         "logs:GetLogEvents",
+        "logs:FilterLogEvents",
         "ec2:DescribeTags",
         "ec2:DescribeSecurityGroups",
         "ec2:DescribeSubnets",
+        "ec2:CreateLaunchTemplate",
+        "ec2:CreateLaunchTemplateVersion",
         "cloudwatch:PutMetricAlarm",
         "cloudwatch:DescribeAlarms",
         "cloudwatch:DeleteAlarms",
@@ -180,9 +188,15 @@ resource "aws_iam_role_policy" "ecs-deploy-policy" {
         "application-autoscaling:DescribeScalableTargets",
         "application-autoscaling:DescribeScalingPolicies",
         "application-autoscaling:DeleteScalingPolicy",
+        "application-autoscaling:PutScheduledAction",
+        "application-autoscaling:DescribeScheduledActions",
+        "application-autoscaling:DeleteScheduledAction",
         "servicediscovery:ListNamespaces",
         "servicediscovery:ListServices",
         "servicediscovery:CreateService",
+        "servicediscovery:UpdateService",
+        "servicediscovery:DeleteService",
+        "servicediscovery:RegisterInstance",
         "ssm:GetParametersByPath",
         "cognito-idp:DescribeUserPool",
         "cognito-idp:DescribeUserPoolClient",
@@ -0,0 +1,158 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/loggo"
+)
+
+// Fields are key/value pairs that are attached to log lines
+type Fields map[string]string
+
+// FieldLogger is a loggo logger with fields attached.
+// The fields are written out as separate keys when the JSON log format is enabled
+type FieldLogger struct {
+	loggo.Logger
+	fields Fields
+}
+
+var jsonLogging bool
+
+// EnableJSONLogging replaces the default loggo writer with a writer that outputs one JSON object per line
+func EnableJSONLogging(w io.Writer) error {
+	_, err := loggo.ReplaceDefaultWriter(NewJSONLogWriter(w))
+	if err != nil {
+		return err
+	}
+	jsonLogging = true
+	return nil
+}
+
+// WithFields returns a FieldLogger that adds fields to every line logged
+func WithFields(logger loggo.Logger, fields Fields) FieldLogger {
+	l := FieldLogger{Logger: logger, fields: Fields{}}
+	for k, v := range fields {
+		l.fields[k] = v
+	}
+	return l
+}
+
+// With returns a copy of the logger with an extra field
+func (l FieldLogger) With(key, value string) FieldLogger {
+	n := WithFields(l.Logger, l.fields)
+	n.fields[key] = value
+	return n
+}
+
+// Field returns the value of a field
+func (l FieldLogger) Field(key string) string {
+	return l.fields[key]
+}
+
+func (l FieldLogger) Criticalf(message string, args ...interface{}) {
+	l.logf(loggo.CRITICAL, message, args...)
+}
+func (l FieldLogger) Errorf(message string, args ...interface{}) {
+	l.logf(loggo.ERROR, message, args...)
+}
+func (l FieldLogger) Warningf(message string, args ...interface{}) {
+	l.logf(loggo.WARNING, message, args...)
+}
+func (l FieldLogger) Infof(message string, args ...interface{}) {
+	l.logf(loggo.INFO, message, args...)
+}
+func (l FieldLogger) Debugf(message string, args ...interface{}) {
+	l.logf(loggo.DEBUG, message, args...)
+}
+func (l FieldLogger) Tracef(message string, args ...interface{}) {
+	l.logf(loggo.TRACE, message, args...)
+}
+
+func (l FieldLogger) logf(level loggo.Level, message string, args ...interface{}) {
+	if !jsonLogging {
+		// text format: log as usual
+		l.LogCallf(2, level, message, args...)
+		return
+	}
+	if !l.IsLevelEnabled(level) {
+		return
+	}
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		file = "???"
+		line = 0
+	}
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	// loggo entries don't have fields, the labels are used to pass them on to the writer
+	labels := make([]string, 0, len(l.fields))
+	for k, v := range l.fields {
+		labels = append(labels, k+"="+v)
+	}
+	writer := loggo.DefaultContext().Writer(loggo.DefaultWriterName)
+	if writer == nil {
+		return
+	}
+	writer.Write(loggo.Entry{
+		Level:     level,
+		Module:    l.Name(),
+		Filename:  file,
+		Line:      line,
+		Timestamp: time.Now(),
+		Message:   strings.TrimSuffix(message, "\n"),
+		Labels:    labels,
+	})
+}
+
+// JSON log writer
+type jsonLogWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLogWriter returns a loggo writer that writes every entry as a JSON object.
+// Labels in key=value format are added as separate keys
+func NewJSONLogWriter(w io.Writer) loggo.Writer {
+	return &jsonLogWriter{w: w}
+}
+
+func (j *jsonLogWriter) Write(entry loggo.Entry) {
+	line := map[string]interface{}{
+		"time":    entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		"level":   entry.Level.String(),
+		"module":  entry.Module,
+		"caller":  fmt.Sprintf("%s:%d", filepath.Base(entry.Filename), entry.Line),
+		"message": entry.Message,
+	}
+	var labels []string
+	for _, label := range entry.Labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 {
+			labels = append(labels, label)
+			continue
+		}
+		if _, exists := line[kv[0]]; !exists {
+			line[kv[0]] = kv[1]
+		}
+	}
+	if len(labels) > 0 {
+		sort.Strings(labels)
+		line["labels"] = labels
+	}
+	out, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.w.Write(append(out, '\n'))
+}
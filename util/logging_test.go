@@ -0,0 +1,56 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/juju/loggo"
+)
+
+func TestJSONLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONLogWriter(&buf)
+	w.Write(loggo.Entry{
+		Level:     loggo.INFO,
+		Module:    "controller",
+		Filename:  "/src/api/controller.go",
+		Line:      10,
+		Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Message:   "Created task definition",
+		Labels:    []string{"service=myservice", "requestId=abc", "nokey"},
+	})
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Could not unmarshal log line: %s", err)
+	}
+	expected := map[string]string{
+		"level":     "INFO",
+		"module":    "controller",
+		"caller":    "controller.go:10",
+		"message":   "Created task definition",
+		"service":   "myservice",
+		"requestId": "abc",
+		"time":      "2020-01-02T03:04:05Z",
+	}
+	for k, v := range expected {
+		if line[k] != v {
+			t.Errorf("Unexpected value for %s: %v (wanted %s)", k, line[k], v)
+		}
+	}
+	if labels, ok := line["labels"].([]interface{}); !ok || len(labels) != 1 || labels[0] != "nokey" {
+		t.Errorf("Unexpected labels: %v", line["labels"])
+	}
+}
+
+func TestFieldLoggerWith(t *testing.T) {
+	l := WithFields(loggo.GetLogger("test"), Fields{"service": "myservice"})
+	l2 := l.With("requestId", "abc")
+	if l.Field("requestId") != "" {
+		t.Errorf("Field added to original logger")
+	}
+	if l2.Field("service") != "myservice" || l2.Field("requestId") != "abc" {
+		t.Errorf("Unexpected fields: %v", l2.fields)
+	}
+}
@@ -32,6 +32,9 @@ import { ServiceDetailService } from './service-detail/service-detail.service';
 import { InspectChildComponent } from './service-detail/inspect.component';
 import { DeployChildComponent } from './service-detail/deploy.component';
 import { ConfirmChildComponent } from './service-detail/confirm.component';
+import { ScalingDecisionsComponent } from './scaling-decisions/scaling-decisions.component';
+import { ScalingDecisionsResolver } from './scaling-decisions/scaling-decisions-resolver.service';
+import { ScalingDecisionsService } from './scaling-decisions/scaling-decisions.service';
 
 // routes
 const appRoutes: Routes = [
@@ -51,6 +54,13 @@ const appRoutes: Routes = [
     data: { title: 'ECS Deploy tool' },
     canActivate: [AuthGuard]
   },
+  {
+    path: 'autoscaling',
+    component: ScalingDecisionsComponent,
+    resolve: { sd: ScalingDecisionsResolver },
+    data: { title: 'ECS Deploy tool' },
+    canActivate: [AuthGuard]
+  },
   {
     path: 'service/:serviceName',
     component: ServiceDetailComponent,
@@ -80,6 +90,7 @@ const appRoutes: Routes = [
         InspectChildComponent,
         DeployChildComponent,
         ConfirmChildComponent,
+        ScalingDecisionsComponent,
     ],
     bootstrap: [AppComponent], imports: [BrowserModule,
         ReactiveFormsModule,
@@ -98,6 +109,8 @@ const appRoutes: Routes = [
         ServiceListService,
         ServiceDetailResolver,
         ServiceDetailService,
+        ScalingDecisionsResolver,
+        ScalingDecisionsService,
         { provide: HTTP_INTERCEPTORS, useClass: AppHttpInterceptor, multi: true },
         provideHttpClient(withXhr(), withInterceptorsFromDi()),
     ] })
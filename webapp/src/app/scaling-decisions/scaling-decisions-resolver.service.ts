@@ -0,0 +1,18 @@
+import { Injectable }             from '@angular/core';
+import { Observable }             from 'rxjs';
+import { Router, RouterStateSnapshot, ActivatedRouteSnapshot } from '@angular/router';
+
+
+import { ScalingDecisions, ScalingDecisionsService }  from './scaling-decisions.service';
+
+
+@Injectable()
+export class ScalingDecisionsResolver  {
+
+  constructor(private sd: ScalingDecisionsService, private router: Router) {}
+
+  resolve(route: ActivatedRouteSnapshot, state: RouterStateSnapshot): Observable<ScalingDecisions> {
+    return this.sd.getScalingDecisions("24h")
+  }
+
+}
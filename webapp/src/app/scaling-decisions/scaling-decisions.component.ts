@@ -0,0 +1,61 @@
+import { Component, OnInit, ChangeDetectionStrategy } from '@angular/core';
+import { ActivatedRoute, Router } from '@angular/router';
+
+import { ScalingDecisions, ScalingDecisionsService }  from './scaling-decisions.service';
+
+
+@Component({
+    selector: 'app-scaling-decisions',
+    templateUrl: './scaling-decisions.component.html',
+    styleUrls: ['./scaling-decisions.component.css'],
+    changeDetection: ChangeDetectionStrategy.Eager,
+    standalone: false
+})
+
+export class ScalingDecisionsComponent implements OnInit {
+  clusters: string[] = [];
+  decisions: any[] = [];
+  allDecisions: any[] = [];
+  filterList: string[] = [];
+  since: string = "24h";
+
+  constructor(
+    private route: ActivatedRoute,
+    private router: Router,
+    private sd: ScalingDecisionsService
+  ) {}
+
+  ngOnInit(): void {
+    this.route.data
+      .subscribe((data: { sd: ScalingDecisions }) => {
+        this.allDecisions = data.sd.decisions;
+        this.clusters = data.sd.clusters;
+        this.filterList = []
+        this.applyFilter()
+     });
+  }
+
+  filter(event: any, clusterName: string) {
+    if(event.target.checked) {
+      this.filterList.push(clusterName)
+    } else {
+      this.filterList = this.filterList.filter(a => a !== clusterName)
+    }
+    this.applyFilter()
+  }
+
+  changeSince(since: string) {
+    this.since = since
+    this.sd.getScalingDecisions(since).subscribe((data: ScalingDecisions) => {
+      this.allDecisions = data.decisions
+      this.clusters = data.clusters
+      this.applyFilter()
+    });
+  }
+
+  applyFilter() {
+    this.decisions = this.allDecisions.filter((decision) => {
+      return this.filterList.length === 0 || this.filterList.indexOf(decision["clusterName"]) != -1
+    })
+  }
+}
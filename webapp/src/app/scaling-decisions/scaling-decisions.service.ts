@@ -0,0 +1,41 @@
+import { AsyncSubject } from 'rxjs';
+import { HttpClient, HttpHeaders } from '@angular/common/http';
+import { AuthService } from '../services/auth.service';
+
+
+export class ScalingDecisions {
+  constructor(public decisions: any[], public clusters: string[]) { }
+}
+
+import { Injectable } from '@angular/core';
+
+@Injectable()
+export class ScalingDecisionsService {
+
+  private sd$: AsyncSubject<ScalingDecisions>
+  private sd: ScalingDecisions = new ScalingDecisions([], [])
+
+  constructor(private http: HttpClient, private auth: AuthService) { }
+
+  dateOptions = { year: "numeric", month: "numeric", day: "numeric", hour: "2-digit", minute: "2-digit", second: "2-digit", timeZoneName: "short"} as const;
+
+  getScalingDecisions(since: string) {
+    this.sd$ = new AsyncSubject<ScalingDecisions>()
+    this.http.get("/ecs-deploy/api/v1/autoscaling/decisions?since=" + since, {headers: new HttpHeaders().set('Authorization', "Bearer " + this.auth.getToken())})
+      .subscribe(data => {
+      // Read the result field from the JSON response.
+      this.sd.decisions = data["decisions"] || []
+      this.sd.clusters = []
+      for(let i=0; i<this.sd.decisions.length; i++){
+        this.sd.decisions[i]["date"] = new Date(this.sd.decisions[i]["time"]).toLocaleString("en-US", this.dateOptions)
+        if(this.sd.clusters.indexOf(this.sd.decisions[i]["clusterName"]) == -1) {
+          this.sd.clusters.push(this.sd.decisions[i]["clusterName"])
+        }
+      }
+      this.sd.clusters.sort()
+      this.sd$.next(this.sd)
+      this.sd$.complete()
+    })
+    return this.sd$
+  }
+}